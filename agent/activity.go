@@ -0,0 +1,368 @@
+package agent
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"agent/diff"
+	"agent/tools"
+)
+
+// maxSnapshotSize caps how much of a file is kept in memory for change tracking
+const maxSnapshotSize = 1 << 20
+
+// maxActivity and maxActivityBytes bound the file activity kept in memory.
+// Past either limit the oldest entries are dropped, so changes made long ago
+// in a session can no longer be shown or reverted.
+const (
+	maxActivity      = 5000
+	maxActivityBytes = 64 << 20
+)
+
+// FileActivity records a tool call that touched a file
+type FileActivity struct {
+	Path     string
+	AbsPath  string
+	Tool     string
+	Time     time.Time
+	ReadOnly bool
+
+	// Content snapshots around a modifying tool call. BeforeComplete is false
+	// when the file was too large to snapshot, so Before cannot be restored.
+	Before         string
+	After          string
+	ExistedBefore  bool
+	ExistsAfter    bool
+	BeforeComplete bool
+
+	// ChangedFrom and ChangedTo are the 1-based line range in After that differs
+	// from Before. Both are zero when nothing changed or the tool only read the file.
+	ChangedFrom int
+	ChangedTo   int
+}
+
+// toolPath extracts the "path" argument most file tools accept
+func toolPath(input json.RawMessage) string {
+	var target struct {
+		Path string `json:"path"`
+	}
+
+	if err := json.Unmarshal(input, &target); err != nil {
+		return ""
+	}
+
+	return target.Path
+}
+
+// toolTarget is a file a tool call operates on, with its state beforehand
+type toolTarget struct {
+	path    string
+	absPath string
+	before  fileSnapshot
+}
+
+// toolTargets resolves the files a tool call operates on: its "path"
+// argument, or the paths the tool's Paths function finds in the input.
+// Files are snapshotted before modifying tools run so their changes can be
+// shown afterwards.
+func (a *Agent) toolTargets(tool tools.ToolDefinition, input json.RawMessage) []toolTarget {
+	paths := []string{toolPath(input)}
+	if tool.Paths != nil {
+		paths = tool.Paths(input)
+	}
+
+	targets := []toolTarget{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		absPath, err := a.workspace.Resolve(path)
+		if err != nil {
+			continue
+		}
+
+		target := toolTarget{path: path, absPath: absPath}
+		if !tool.ReadOnly {
+			target.before = readSnapshot(absPath)
+		}
+		targets = append(targets, target)
+	}
+
+	return targets
+}
+
+// fileSnapshot is the state of a file at one point in time
+type fileSnapshot struct {
+	content  string
+	exists   bool
+	complete bool
+}
+
+// readSnapshot captures a file's content. Content is left empty and the snapshot
+// marked incomplete for directories and files too large to track.
+func readSnapshot(path string) fileSnapshot {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileSnapshot{complete: true}
+	}
+	if info.IsDir() || info.Size() > maxSnapshotSize {
+		return fileSnapshot{exists: true}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileSnapshot{exists: true}
+	}
+
+	return fileSnapshot{content: string(content), exists: true, complete: true}
+}
+
+// recordActivity remembers the file a successful tool call operated on
+func (a *Agent) recordActivity(tool tools.ToolDefinition, path, absPath string, before fileSnapshot) {
+	if path == "" || absPath == "" {
+		return
+	}
+
+	activity := FileActivity{
+		Path:     path,
+		AbsPath:  absPath,
+		Tool:     tool.Name,
+		Time:     time.Now(),
+		ReadOnly: tool.ReadOnly,
+	}
+
+	if !tool.ReadOnly {
+		after := readSnapshot(absPath)
+
+		activity.Before = before.content
+		activity.ExistedBefore = before.exists
+		activity.BeforeComplete = before.complete
+		activity.After = after.content
+		activity.ExistsAfter = after.exists
+		activity.ChangedFrom, activity.ChangedTo = changedLineRange(activity.Before, activity.After)
+	}
+
+	a.appendActivity(activity)
+}
+
+// appendActivity records an activity and keeps the history within its limits.
+// Only the latest After of each file is ever read back, since changes are
+// always aggregated up to now, so the previous one is released right away.
+func (a *Agent) appendActivity(activity FileActivity) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !activity.ReadOnly {
+		for i := len(a.activity) - 1; i >= 0; i-- {
+			previous := &a.activity[i]
+			if previous.ReadOnly || previous.AbsPath != activity.AbsPath {
+				continue
+			}
+			a.activityBytes -= len(previous.After)
+			previous.After = ""
+			break
+		}
+	}
+
+	a.activity = append(a.activity, activity)
+	a.activityBytes += len(activity.Before) + len(activity.After)
+	a.trimActivity()
+}
+
+// trimActivity drops the oldest activities once the history is over its limits,
+// remembering which files lost part of their history
+func (a *Agent) trimActivity() {
+	drop := 0
+	for drop < len(a.activity)-1 && (len(a.activity)-drop > maxActivity || a.activityBytes > maxActivityBytes) {
+		dropped := a.activity[drop]
+		a.activityBytes -= len(dropped.Before) + len(dropped.After)
+		if !dropped.ReadOnly {
+			if a.trimmedPaths == nil {
+				a.trimmedPaths = map[string]bool{}
+			}
+			a.trimmedPaths[dropped.AbsPath] = true
+		}
+		drop++
+	}
+	if drop == 0 {
+		return
+	}
+
+	// Copy so the dropped snapshots aren't kept alive by the backing array
+	a.activity = append([]FileActivity(nil), a.activity[drop:]...)
+	a.activityBase += drop
+}
+
+// changedLineRange finds the 1-based line range in after that differs from before
+// by trimming the common prefix and suffix lines
+func changedLineRange(before, after string) (int, int) {
+	if before == after {
+		return 0, 0
+	}
+
+	beforeLines := strings.Split(before, "\n")
+	afterLines := strings.Split(after, "\n")
+
+	prefix := 0
+	for prefix < len(beforeLines) && prefix < len(afterLines) && beforeLines[prefix] == afterLines[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(beforeLines)-prefix && suffix < len(afterLines)-prefix &&
+		beforeLines[len(beforeLines)-1-suffix] == afterLines[len(afterLines)-1-suffix] {
+		suffix++
+	}
+
+	from := prefix + 1
+	to := len(afterLines) - suffix
+
+	// Pure deletions leave no changed lines in after; point at the deletion site
+	if to < from {
+		to = from
+	}
+
+	return from, min(to, len(afterLines))
+}
+
+// RecentFiles returns the most recently touched file paths, newest first and without duplicates
+func (a *Agent) RecentFiles(limit int) []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seen := map[string]bool{}
+	files := []string{}
+
+	for i := len(a.activity) - 1; i >= 0; i-- {
+		path := a.activity[i].Path
+		if seen[path] {
+			continue
+		}
+
+		seen[path] = true
+		files = append(files, path)
+
+		if limit > 0 && len(files) >= limit {
+			break
+		}
+	}
+
+	return files
+}
+
+// LatestActivity returns the most recent file activity, if any
+func (a *Agent) LatestActivity() (FileActivity, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.activity) == 0 {
+		return FileActivity{}, false
+	}
+
+	return a.activity[len(a.activity)-1], true
+}
+
+// ChangeStatus describes the net effect of a turn on a file
+type ChangeStatus string
+
+const (
+	ChangeCreated  ChangeStatus = "created"
+	ChangeModified ChangeStatus = "modified"
+	ChangeDeleted  ChangeStatus = "deleted"
+)
+
+// FileChange is the net change to one file across a series of modifying tool calls
+type FileChange struct {
+	Path    string
+	AbsPath string
+	Status  ChangeStatus
+	Before  string
+	After   string
+	Added   int
+	Removed int
+
+	// Restorable is false when the original content was too large to snapshot,
+	// or when earlier changes to the file were dropped from the history
+	Restorable bool
+}
+
+// ActivityCount returns the number of recorded activities, usable as a marker for ChangesSince.
+// It keeps counting the activities dropped from the history.
+func (a *Agent) ActivityCount() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.activityBase + len(a.activity)
+}
+
+// ChangesSince aggregates the modifying activities recorded after the given marker
+// into one net change per file, in the order files were first touched. Files whose
+// earlier changes since the marker were dropped from the history start from the
+// oldest state still known and can't be restored.
+func (a *Agent) ChangesSince(marker int) []FileChange {
+	a.mu.Lock()
+	start := min(max(marker-a.activityBase, 0), len(a.activity))
+	activities := append([]FileActivity(nil), a.activity[start:]...)
+	trimmed := map[string]bool{}
+	if marker < a.activityBase {
+		trimmed = maps.Clone(a.trimmedPaths)
+	}
+	a.mu.Unlock()
+
+	type netChange struct {
+		first FileActivity
+		last  FileActivity
+	}
+
+	order := []string{}
+	byPath := map[string]*netChange{}
+
+	for _, activity := range activities {
+		if activity.ReadOnly {
+			continue
+		}
+
+		if change, ok := byPath[activity.AbsPath]; ok {
+			change.last = activity
+			continue
+		}
+
+		order = append(order, activity.AbsPath)
+		byPath[activity.AbsPath] = &netChange{first: activity, last: activity}
+	}
+
+	changes := []FileChange{}
+	for _, path := range order {
+		change := byPath[path]
+
+		fileChange := FileChange{
+			Path:       change.first.Path,
+			AbsPath:    change.first.AbsPath,
+			Before:     change.first.Before,
+			After:      change.last.After,
+			Restorable: change.first.BeforeComplete && !trimmed[path],
+		}
+
+		switch {
+		case !change.first.ExistedBefore && change.last.ExistsAfter:
+			fileChange.Status = ChangeCreated
+		case change.first.ExistedBefore && !change.last.ExistsAfter:
+			fileChange.Status = ChangeDeleted
+		case !change.first.ExistedBefore && !change.last.ExistsAfter:
+			continue
+		default:
+			if fileChange.Before == fileChange.After {
+				continue
+			}
+			fileChange.Status = ChangeModified
+		}
+
+		fileChange.Added, fileChange.Removed = diff.Stats(fileChange.Before, fileChange.After)
+		changes = append(changes, fileChange)
+	}
+
+	return changes
+}
@@ -3,7 +3,14 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 
+	"agent/config"
+	"agent/log"
+	"agent/provider"
 	"agent/tools"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -11,20 +18,74 @@ import (
 
 // Agent represents a conversational AI agent that can use tools
 type Agent struct {
-	client *anthropic.Client
-	tools  []tools.ToolDefinition
+	provider  provider.Provider
+	tools     []tools.ToolDefinition
+	workspace *tools.Workspace
+
+	mu               sync.Mutex
+	activity         []FileActivity
+	activityBase     int
+	activityBytes    int
+	trimmedPaths     map[string]bool
+	instructions     string
+	instructionsFile string
+	trusted          bool
+	projectConfig    config.ProjectConfig
+	projectErr       error
+	approver         Approver
+	approvalTools    []string
+	interceptor      ToolInterceptor
+	usage            Usage
+	spent            float64
+	unpriced         bool
+	budget           Budget
+	budgetWarned     bool
+	budgetApproved   bool
+	pinned           []string
+	autoPin          *bool
+	seen             map[string]seenVersion
+	notes            []string
+	switcher         ProfileSwitcher
+	profile          string
+	model            string
+	router           *config.ModelRouter
+	routed           anthropic.Model
+	thinking         int64
+	toolChoice       string
+	forceTools       bool
+	stopSequences    []string
+	toolRecords      map[string]*toolRecord
+	responseLanguage string
+	checkpoints      map[string]Checkpoint
+	rateLimits       config.RateLimits
+	requests         []sentRequest
+	spendAlerted     bool
 }
 
 // NewAgent creates a new agent instance
-func NewAgent(client *anthropic.Client, toolDefinitions []tools.ToolDefinition) *Agent {
-	return &Agent{
-		client: client,
-		tools:  toolDefinitions,
+func NewAgent(modelProvider provider.Provider, toolDefinitions []tools.ToolDefinition, workspace *tools.Workspace) *Agent {
+	a := &Agent{
+		provider:  modelProvider,
+		tools:     toolDefinitions,
+		workspace: workspace,
 	}
+	a.reloadProject()
+
+	return a
 }
 
-// executeTool executes a tool by name with the given input
+// ExecuteTool executes a tool by name with the given input and wraps the outcome in a tool result block
 func (a *Agent) ExecuteTool(id, name string, input json.RawMessage) anthropic.ContentBlockParamUnion {
+	response, err := a.runTool(name, input)
+	if err != nil {
+		return anthropic.NewToolResultBlock(id, err.Error()+a.hintSuffix(name, input, err), true)
+	}
+
+	return anthropic.NewToolResultBlock(id, response, false)
+}
+
+// runTool executes a tool by name with the given input
+func (a *Agent) runTool(name string, input json.RawMessage) (string, error) {
 	var toolDef tools.ToolDefinition
 	var found bool
 
@@ -37,17 +98,80 @@ func (a *Agent) ExecuteTool(id, name string, input json.RawMessage) anthropic.Co
 	}
 
 	if !found {
-		return anthropic.NewToolResultBlock(id, "tool not found", true)
+		return "", fmt.Errorf("tool not found")
 	}
 
-	// fmt.Printf("\u001b[92mtool\u001b[0m: %s(%s)\n", name, input)
+	log.Debug("running tool", "tool", name, "input", string(input))
+
+	if err := a.checkToolAllowed(toolDef); err != nil {
+		return "", err
+	}
+	if err := a.approveToolCall(name, input); err != nil {
+		return "", err
+	}
+
+	// Snapshot the files before modifying tools run so changes can be shown afterwards
+	targets := a.toolTargets(toolDef, input)
+	for _, target := range targets {
+		if err := a.checkExternalChange(toolDef, target.path, target.absPath, target.before); err != nil {
+			return "", err
+		}
+	}
+
+	response, err := toolDef.Function(a.workspace, input)
+
+	// Let the user override write quotas instead of failing outright
+	var quotaErr *tools.QuotaError
+	if errors.As(err, &quotaErr) && a.requestApproval(ApprovalRequest{
+		Title:  "Write limit exceeded",
+		Detail: fmt.Sprintf("%s wants to write to %s, but %s.\n\nAllow this write anyway?", name, quotaErr.Path, quotaErr.Reason),
+	}) {
+		a.workspace.AllowNextWrite()
+		response, err = toolDef.Function(a.workspace, input)
+	}
 
-	response, err := toolDef.Function(input)
 	if err != nil {
-		return anthropic.NewToolResultBlock(id, err.Error(), true)
+		return "", err
 	}
 
-	return anthropic.NewToolResultBlock(id, response, false)
+	// Staged writes haven't reached the disk; they are formatted, checked and
+	// recorded when the changeset is applied
+	staged := !toolDef.ReadOnly && a.workspace.Staging()
+
+	for _, target := range targets {
+		if staged {
+			continue
+		}
+
+		// Formatting comes before recording, so the change shown is the formatted one
+		if !toolDef.ReadOnly {
+			if note := a.formatWritten(target.absPath); note != "" {
+				response += "\n" + note
+			}
+		}
+
+		a.recordActivity(toolDef, target.path, target.absPath, target.before)
+
+		// Remember what the model now knows the file contains
+		if !toolDef.ReadOnly || toolDef.Name == tools.ReadFileDefinition.Name {
+			a.rememberVersion(target.absPath, readSnapshot(target.absPath))
+		}
+	}
+
+	// Report broken files as a failed call so the model fixes them straight away
+	if !toolDef.ReadOnly && !staged {
+		problems := []string{}
+		for _, target := range targets {
+			if err := a.checkEditSyntax(target.path, target.absPath, target.before); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+		if len(problems) > 0 {
+			return "", errors.New(strings.Join(problems, "\n\n"))
+		}
+	}
+
+	return response, nil
 }
 
 var MY_AGENT_SYSTEM_PROMPT = `Your Core Instructions:
@@ -65,38 +189,22 @@ var MY_AGENT_SYSTEM_PROMPT = `Your Core Instructions:
 - You are an experienced, multi-language developer skilled in architecture, design, UI/UX, and copywriting.
 - For UI/UX tasks, ensure designs are clear, attractive, user-friendly, and follow best practices, focusing on smooth and engaging interactions.
 - For large or vague tasks, break them into smaller subtasks. If unclear, ask the user to clarify or help decompose the problem.
+
+You are running on {{.OS}} ({{.Arch}}). Today is {{.Date}}.{{with .GitBranch}} The checked-out git branch is {{.}}.{{end}}{{with .Languages}} The project uses {{join . ", "}}{{with $.Toolchain}}, built with {{.}}{{end}}.{{end}}
 `
 
-type StreamingCallback func(text string)
+// StreamingCallback receives the text and thinking deltas of a streaming response
+type StreamingCallback func(event AgentEvent)
 
 // runInference sends a message to Claude and gets a response
 func (a *Agent) RunInferenceWithStreaming(
 	ctx context.Context,
 	conversation []anthropic.MessageParam,
-	onStreamingText StreamingCallback,
+	onStreamingEvent StreamingCallback,
 ) (*anthropic.Message, error) {
-	anthropicTools := []anthropic.ToolUnionParam{}
+	stream := a.modelProvider().NewStreaming(ctx, a.requestParams(conversation))
 
-	for _, tool := range a.tools {
-		anthropicTools = append(anthropicTools, anthropic.ToolUnionParam{
-			OfTool: &anthropic.ToolParam{
-				Name:        tool.Name,
-				Description: anthropic.String(tool.Description),
-				InputSchema: tool.InputSchema,
-			},
-		})
-	}
-
-	stream := a.client.Messages.NewStreaming(ctx, anthropic.MessageNewParams{
-		// Model: anthropic.ModelClaude3_7Sonnet20250219,
-		Model:     anthropic.ModelClaude_3_Haiku_20240307,
-		MaxTokens: int64(4096),
-		System: []anthropic.TextBlockParam{
-			{Text: MY_AGENT_SYSTEM_PROMPT},
-		},
-		Messages: conversation,
-		Tools:    anthropicTools,
-	})
+	defer stream.Close()
 
 	message := anthropic.Message{}
 
@@ -113,17 +221,79 @@ func (a *Agent) RunInferenceWithStreaming(
 			switch deltaVariant := eventVariant.Delta.AsAny().(type) {
 			case anthropic.TextDelta:
 				// send streaming text to callback
-				if onStreamingText != nil {
-					onStreamingText(deltaVariant.Text)
+				if onStreamingEvent != nil {
+					onStreamingEvent(TextDelta{Text: deltaVariant.Text})
+				}
+			case anthropic.ThinkingDelta:
+				if onStreamingEvent != nil {
+					onStreamingEvent(ThinkingDelta{Text: deltaVariant.Thinking})
 				}
 			}
 		}
 
 	}
 
-	if stream.Err() != nil {
-		panic(stream.Err())
+	if err := stream.Err(); err != nil {
+		return &message, fmt.Errorf("stream failed: %w", err)
 	}
 
 	return &message, nil
 }
+
+// requestParams builds the Messages API request for a conversation
+func (a *Agent) requestParams(conversation []anthropic.MessageParam) anthropic.MessageNewParams {
+	anthropicTools := []anthropic.ToolUnionParam{}
+
+	for _, tool := range a.tools {
+		// Tools disabled by the project config are not offered to the model at all
+		if !a.toolEnabled(tool.Name) {
+			continue
+		}
+
+		anthropicTools = append(anthropicTools, anthropic.ToolUnionParam{
+			OfTool: &anthropic.ToolParam{
+				Name:        tool.Name,
+				Description: anthropic.String(tool.Description),
+				InputSchema: tool.InputSchema,
+			},
+		})
+	}
+
+	a.mu.Lock()
+	model := a.requestModel()
+	thinking := a.thinking
+	choice := a.requestToolChoice()
+	stopSequences := a.requestStopSequences()
+	a.mu.Unlock()
+
+	params := anthropic.MessageNewParams{
+		// Model: anthropic.ModelClaude3_7Sonnet20250219,
+		Model:     model,
+		MaxTokens: int64(4096),
+		System: []anthropic.TextBlockParam{
+			{Text: a.systemPrompt()},
+		},
+		Messages: conversation,
+		Tools:    anthropicTools,
+	}
+
+	// The thinking budget comes out of max_tokens, so the answer gets its own room
+	if thinking > 0 {
+		params.Thinking = anthropic.ThinkingConfigParamOfEnabled(thinking)
+		params.MaxTokens = thinking + thinkingMaxTokens
+	}
+
+	switch choice {
+	case "", ToolChoiceAuto:
+	case ToolChoiceAny:
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfAny: &anthropic.ToolChoiceAnyParam{}}
+	case ToolChoiceNone:
+		none := anthropic.NewToolChoiceNoneParam()
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{OfNone: &none}
+	default:
+		params.ToolChoice = anthropic.ToolChoiceParamOfTool(choice)
+	}
+	params.StopSequences = stopSequences
+
+	return params
+}
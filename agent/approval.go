@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// ApprovalRequest asks the user to confirm an action the agent would otherwise refuse
+type ApprovalRequest struct {
+	Title  string
+	Detail string
+
+	// Tool and Input are the tool call awaiting approval, for tools that
+	// must be approved before every call
+	Tool  string
+	Input json.RawMessage
+}
+
+// Approver decides approval requests, typically by asking the user. It is called
+// from the goroutine running the turn and may block until a decision is made.
+type Approver func(req ApprovalRequest) bool
+
+// SetApprover installs the callback used to ask for approvals
+func (a *Agent) SetApprover(approver Approver) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.approver = approver
+}
+
+// requestApproval asks the approver, denying when none is installed
+func (a *Agent) requestApproval(req ApprovalRequest) bool {
+	a.mu.Lock()
+	approver := a.approver
+	a.mu.Unlock()
+
+	if approver == nil {
+		return false
+	}
+
+	return approver(req)
+}
+
+// SetApprovalTools makes the named tools ask for approval before every call
+func (a *Agent) SetApprovalTools(names []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.approvalTools = names
+}
+
+// approveToolCall asks for approval of a call to a tool that needs it,
+// returning an error when it is denied
+func (a *Agent) approveToolCall(name string, input json.RawMessage) error {
+	a.mu.Lock()
+	needed := slices.Contains(a.approvalTools, name)
+	a.mu.Unlock()
+
+	if !needed {
+		return nil
+	}
+
+	if !a.requestApproval(ApprovalRequest{
+		Title:  fmt.Sprintf("Run %s?", name),
+		Detail: fmt.Sprintf("The agent wants to call %s with:\n\n%s\n\nAllow it?", name, input),
+		Tool:   name,
+		Input:  input,
+	}) {
+		return fmt.Errorf("this %s call was not approved; ask the user before trying it again", name)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// budgetWarnFraction is the share of the budget at which a warning is emitted
+const budgetWarnFraction = 0.8
+
+// Budget caps what a session may spend. Either limit may be zero to disable it.
+// A soft budget asks for approval to continue once exhausted; a hard one stops.
+type Budget struct {
+	Tokens  int64
+	Dollars float64
+	Hard    bool
+}
+
+// IsZero reports whether the budget sets no limit
+func (b Budget) IsZero() bool {
+	return b.Tokens <= 0 && b.Dollars <= 0
+}
+
+// String renders the budget limits, e.g. "50000 tokens" or "$2.50 (hard)"
+func (b Budget) String() string {
+	if b.IsZero() {
+		return "none"
+	}
+
+	var parts []string
+	if b.Tokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d tokens", b.Tokens))
+	}
+	if b.Dollars > 0 {
+		parts = append(parts, fmt.Sprintf("$%.2f", b.Dollars))
+	}
+
+	text := strings.Join(parts, " or ")
+	if b.Hard {
+		text += " (hard)"
+	}
+	return text
+}
+
+// ParseBudget parses a budget such as "50000", "200k", "1.5m" (tokens) or "$2.50" (dollars)
+func ParseBudget(value string) (Budget, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if amount, ok := strings.CutPrefix(value, "$"); ok {
+		dollars, err := strconv.ParseFloat(amount, 64)
+		if err != nil || dollars <= 0 {
+			return Budget{}, fmt.Errorf("invalid dollar budget %q", value)
+		}
+		return Budget{Dollars: dollars}, nil
+	}
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(value, "k"):
+		multiplier, value = 1_000, strings.TrimSuffix(value, "k")
+	case strings.HasSuffix(value, "m"):
+		multiplier, value = 1_000_000, strings.TrimSuffix(value, "m")
+	}
+
+	tokens, err := strconv.ParseFloat(value, 64)
+	if err != nil || tokens <= 0 {
+		return Budget{}, fmt.Errorf("invalid token budget %q: use e.g. 50000, 200k or $2.50", value)
+	}
+
+	return Budget{Tokens: int64(tokens * multiplier)}, nil
+}
+
+// BudgetWarning reports that the session has used most of its budget
+type BudgetWarning struct {
+	Used   Usage
+	Budget Budget
+}
+
+func (BudgetWarning) isAgentEvent() {}
+
+// SetBudget replaces the session budget. An explicit budget takes precedence
+// over the one from the project config; a zero budget falls back to it.
+func (a *Agent) SetBudget(budget Budget) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.budget = budget
+	a.budgetWarned = false
+	a.budgetApproved = false
+}
+
+// Budget returns the budget in effect for the session
+func (a *Agent) Budget() Budget {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.effectiveBudget()
+}
+
+// effectiveBudget returns the explicit budget, or else the project one. Callers must hold the lock.
+func (a *Agent) effectiveBudget() Budget {
+	if !a.budget.IsZero() {
+		return a.budget
+	}
+
+	project := a.projectConfig.Budget
+	return Budget{Tokens: project.Tokens, Dollars: project.Dollars, Hard: project.Hard}
+}
+
+// SessionUsage returns the tokens used by all turns so far
+func (a *Agent) SessionUsage() Usage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.usage
+}
+
+// SessionCost returns the dollars spent by all turns so far, each response
+// priced for the model that gave it, and false when a model's price is unknown
+func (a *Agent) SessionCost() (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.spent, !a.unpriced
+}
+
+// addUsage adds a response's usage to the session total, priced for model
+func (a *Agent) addUsage(usage Usage, model anthropic.Model) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cost, ok := usage.Cost(model)
+	if !ok {
+		a.unpriced = true
+	}
+	a.usage = a.usage.Add(usage)
+	a.spent += cost
+	a.recordRequest(usage, cost)
+}
+
+// budgetFraction returns how much of the budget the session has consumed; the
+// larger of the token and dollar shares when both are set. Callers must hold the lock.
+func (a *Agent) budgetFraction(budget Budget) float64 {
+	fraction := 0.0
+
+	if budget.Tokens > 0 {
+		fraction = float64(a.usage.TotalTokens()) / float64(budget.Tokens)
+	}
+
+	if budget.Dollars > 0 && !a.unpriced {
+		fraction = max(fraction, a.spent/budget.Dollars)
+	}
+
+	return fraction
+}
+
+// BudgetUsed returns the share of the budget used so far, and false when there is no budget
+func (a *Agent) BudgetUsed() (float64, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	budget := a.effectiveBudget()
+	if budget.IsZero() {
+		return 0, false
+	}
+
+	return a.budgetFraction(budget), true
+}
+
+// checkBudget runs before each model request. It warns once the budget is
+// mostly used and, once exhausted, stops a hard budget or asks to continue past
+// a soft one.
+func (a *Agent) checkBudget(events chan<- AgentEvent) error {
+	a.mu.Lock()
+	budget := a.effectiveBudget()
+	usage := a.usage
+	fraction := a.budgetFraction(budget)
+
+	warn := !budget.IsZero() && fraction >= budgetWarnFraction && !a.budgetWarned
+	if warn {
+		a.budgetWarned = true
+	}
+	approved := a.budgetApproved
+	a.mu.Unlock()
+
+	if warn {
+		events <- BudgetWarning{Used: usage, Budget: budget}
+	}
+
+	if budget.IsZero() || fraction < 1 || approved {
+		return nil
+	}
+
+	if budget.Hard {
+		return fmt.Errorf("the session budget of %s is exhausted; raise it with /budget to continue", budget)
+	}
+
+	if !a.requestApproval(ApprovalRequest{
+		Title:  "Session budget exhausted",
+		Detail: fmt.Sprintf("This session has used %d tokens of its %s budget. Continue anyway?", usage.TotalTokens(), budget),
+	}) {
+		return fmt.Errorf("the session budget of %s is exhausted", budget)
+	}
+
+	a.mu.Lock()
+	a.budgetApproved = true
+	a.mu.Unlock()
+
+	return nil
+}
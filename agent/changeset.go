@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"agent/diff"
+	"agent/tools"
+)
+
+// SetDryRun turns dry-run mode on or off. In dry-run mode the writing tools
+// stage their changes instead of touching the disk, and the user applies or
+// discards the pending changeset as a whole. Turning it off keeps what is
+// already pending.
+func (a *Agent) SetDryRun(on bool) {
+	a.workspace.SetStaging(on)
+}
+
+// DryRun reports whether dry-run mode is on
+func (a *Agent) DryRun() bool {
+	return a.workspace.Staging()
+}
+
+// PendingChanges returns the staged changes, each against the file on disk
+func (a *Agent) PendingChanges() []FileChange {
+	changes := []FileChange{}
+
+	for _, staged := range a.workspace.Staged() {
+		before := readSnapshot(staged.AbsPath)
+		change := FileChange{
+			Path:       a.displayPath(staged.AbsPath),
+			AbsPath:    staged.AbsPath,
+			Status:     ChangeModified,
+			Before:     before.content,
+			After:      string(staged.Content),
+			Restorable: before.complete,
+		}
+		if !before.exists {
+			change.Status = ChangeCreated
+		} else if change.Before == change.After {
+			continue
+		}
+
+		change.Added, change.Removed = diff.Stats(change.Before, change.After)
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// displayPath shows an absolute path relative to the workspace root where it can
+func (a *Agent) displayPath(absPath string) string {
+	rel, err := filepath.Rel(a.workspace.Root(), absPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return absPath
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ApplyChanges writes the pending changeset to disk. It is all or nothing:
+// if any file can't be written, the ones already written are put back and
+// the changeset stays pending. Applied changes go through the same checks as
+// any other tool write, keep the files they replace in the trash, and are
+// recorded so they show in diffs and can be reverted.
+func (a *Agent) ApplyChanges() ([]FileChange, error) {
+	staged := a.workspace.Staged()
+	if len(staged) == 0 {
+		return nil, nil
+	}
+
+	// Staged is sorted by path, so locks are always taken in the same order
+	unlocks := []func(){}
+	unlockAll := func() {
+		for _, unlock := range unlocks {
+			unlock()
+		}
+	}
+	for _, file := range staged {
+		unlock, err := tools.LockFile(file.AbsPath)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+
+	marker := a.ActivityCount()
+	befores := make([]fileSnapshot, len(staged))
+	for i, file := range staged {
+		befores[i] = readSnapshot(file.AbsPath)
+		if befores[i].exists && !befores[i].complete {
+			unlockAll()
+			return nil, fmt.Errorf("%s is too large to apply safely; turn off dry-run mode to change it", a.displayPath(file.AbsPath))
+		}
+	}
+
+	for i, file := range staged {
+		if err := a.workspace.WriteStaged(file); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				restoreSnapshot(staged[j].AbsPath, befores[j])
+			}
+			unlockAll()
+			return nil, fmt.Errorf("failed to apply %s, nothing was changed: %w", a.displayPath(file.AbsPath), err)
+		}
+	}
+
+	a.workspace.ClearStaged()
+	unlockAll()
+
+	for i, file := range staged {
+		// Formatters take the file's lock themselves
+		a.formatWritten(file.AbsPath)
+
+		after := readSnapshot(file.AbsPath)
+		activity := FileActivity{
+			Path:           a.displayPath(file.AbsPath),
+			AbsPath:        file.AbsPath,
+			Tool:           "apply",
+			Time:           time.Now(),
+			Before:         befores[i].content,
+			After:          after.content,
+			ExistedBefore:  befores[i].exists,
+			ExistsAfter:    after.exists,
+			BeforeComplete: befores[i].complete,
+		}
+		activity.ChangedFrom, activity.ChangedTo = changedLineRange(activity.Before, activity.After)
+		a.appendActivity(activity)
+
+		// The model wrote this content, so it isn't an outside change
+		a.rememberVersion(file.AbsPath, after)
+	}
+
+	return a.ChangesSince(marker), nil
+}
+
+// restoreSnapshot puts a file back the way a snapshot found it
+func restoreSnapshot(absPath string, snapshot fileSnapshot) {
+	if !snapshot.exists {
+		os.Remove(absPath)
+		return
+	}
+	os.WriteFile(absPath, []byte(snapshot.content), 0644)
+}
+
+// DiscardChanges drops the pending changeset, returning how many files it held
+func (a *Agent) DiscardChanges() int {
+	count := a.workspace.StagedCount()
+	a.workspace.ClearStaged()
+	return count
+}
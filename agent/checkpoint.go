@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"agent/tools"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Checkpoint is a named restore point: the conversation, the task list, any
+// pending dry-run changes and a marker into the file activity, so they can
+// all be rolled back together
+type Checkpoint struct {
+	Name    string
+	Created time.Time
+
+	messages []anthropic.MessageParam
+	todos    []tools.TodoItem
+	staged   []tools.StagedFile
+	activity int
+}
+
+// CreateCheckpoint saves the session's current state under name, replacing
+// any earlier checkpoint of that name
+func (a *Agent) CreateCheckpoint(session *Session, name string) Checkpoint {
+	checkpoint := Checkpoint{
+		Name:     name,
+		Created:  time.Now(),
+		messages: session.Messages(),
+		todos:    a.workspace.Todos(),
+		staged:   a.workspace.Staged(),
+		activity: a.ActivityCount(),
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.checkpoints == nil {
+		a.checkpoints = map[string]Checkpoint{}
+	}
+	a.checkpoints[name] = checkpoint
+
+	return checkpoint
+}
+
+// Checkpoints returns the saved checkpoints, oldest first
+func (a *Agent) Checkpoints() []Checkpoint {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	checkpoints := make([]Checkpoint, 0, len(a.checkpoints))
+	for _, checkpoint := range a.checkpoints {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Created.Before(checkpoints[j].Created)
+	})
+
+	return checkpoints
+}
+
+// checkpoint looks up a checkpoint by name
+func (a *Agent) checkpoint(name string) (Checkpoint, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	checkpoint, ok := a.checkpoints[name]
+	if !ok {
+		return Checkpoint{}, fmt.Errorf("no checkpoint named %q", name)
+	}
+
+	return checkpoint, nil
+}
+
+// CheckpointChanges returns the net change to every file the agent modified
+// since the named checkpoint, which restoring it would undo
+func (a *Agent) CheckpointChanges(name string) ([]FileChange, error) {
+	checkpoint, err := a.checkpoint(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.ChangesSince(checkpoint.activity), nil
+}
+
+// RestoreCheckpoint rolls the files the agent changed since the named
+// checkpoint back, then puts the conversation, task list and pending
+// dry-run changes back as they were. Files too large to snapshot are left alone. It returns the changes
+// that were reverted; the conversation is only restored if every file was.
+func (a *Agent) RestoreCheckpoint(session *Session, name string) ([]FileChange, error) {
+	checkpoint, err := a.checkpoint(name)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted, err := a.revertChanges(a.ChangesSince(checkpoint.activity))
+	if err != nil {
+		return reverted, err
+	}
+
+	session.Replace(checkpoint.messages)
+	a.workspace.SetTodos(checkpoint.todos)
+	a.workspace.ReplaceStaged(checkpoint.staged)
+
+	return reverted, nil
+}
@@ -0,0 +1,135 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"agent/provider"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// contextWindow is the input limit, in tokens, of the models the agent uses
+const contextWindow = 200_000
+
+// exactCountFraction is the share of the context window below which the local
+// estimate is trusted and no token counting request is made
+const exactCountFraction = 0.5
+
+// keepRecentMessages are never compacted, so the model keeps the context of the current step
+const keepRecentMessages = 6
+
+// compactedToolResult replaces the content of tool results dropped by compaction
+const compactedToolResult = "[output removed to fit the context window; run the tool again if you need it]"
+
+// Notice reports something the user should know about that isn't an error
+type Notice struct {
+	Text string
+}
+
+func (Notice) isAgentEvent() {}
+
+// estimateTokens approximates a request's input tokens from its JSON size at
+// about four bytes per token, which overestimates for code and prose
+func estimateTokens(params anthropic.MessageNewParams) int64 {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return 0
+	}
+
+	return int64(len(data) / 4)
+}
+
+// countTokens returns the input tokens of a request. Small requests use the
+// local estimate; larger ones are counted exactly when the provider supports it.
+func (a *Agent) countTokens(ctx context.Context, params anthropic.MessageNewParams) int64 {
+	estimate := estimateTokens(params)
+	if float64(estimate) < exactCountFraction*contextWindow {
+		return estimate
+	}
+
+	if counter, ok := a.modelProvider().(provider.TokenCounter); ok {
+		if count, err := counter.CountTokens(ctx, params); err == nil {
+			return count
+		}
+	}
+
+	return estimate
+}
+
+// fitContext checks that the next request fits the context window before it is
+// sent. When it doesn't, old tool results are compacted in the session; if the
+// request is still too large a clear error is returned instead of an API failure.
+func (a *Agent) fitContext(ctx context.Context, session *Session, events chan<- AgentEvent) error {
+	params := a.requestParams(session.Messages())
+	limit := contextWindow - params.MaxTokens
+
+	tokens := a.countTokens(ctx, params)
+	if tokens <= limit {
+		return nil
+	}
+
+	compacted, removed := compactToolResults(params.Messages, keepRecentMessages)
+	if removed > 0 {
+		session.Replace(compacted)
+		params.Messages = compacted
+		tokens = a.countTokens(ctx, params)
+
+		events <- Notice{Text: fmt.Sprintf("The conversation was close to the context window, so %d old tool result(s) were removed.", removed)}
+	}
+
+	if tokens > limit {
+		return fmt.Errorf("the conversation is too long for the model's context window (about %d tokens, limit %d); use /clear to start a fresh conversation", tokens, limit)
+	}
+
+	return nil
+}
+
+// compactToolResults returns a copy of the conversation with the content of
+// tool results outside the last keep messages replaced by a short placeholder,
+// and how many results were replaced
+func compactToolResults(conversation []anthropic.MessageParam, keep int) ([]anthropic.MessageParam, int) {
+	compacted := append([]anthropic.MessageParam(nil), conversation...)
+	removed := 0
+
+	for i := 0; i < len(compacted)-keep; i++ {
+		message := compacted[i]
+		if message.Role != anthropic.MessageParamRoleUser {
+			continue
+		}
+
+		content := append([]anthropic.ContentBlockParamUnion(nil), message.Content...)
+		changed := false
+
+		for j, block := range content {
+			result := block.OfToolResult
+			if result == nil || isCompacted(result) {
+				continue
+			}
+
+			replaced := *result
+			replaced.Content = []anthropic.ToolResultBlockParamContentUnion{
+				{OfText: &anthropic.TextBlockParam{Text: compactedToolResult}},
+			}
+			content[j] = anthropic.ContentBlockParamUnion{OfToolResult: &replaced}
+
+			changed = true
+			removed++
+		}
+
+		if changed {
+			message.Content = content
+			compacted[i] = message
+		}
+	}
+
+	return compacted, removed
+}
+
+// isCompacted reports whether a tool result was already replaced by compaction
+func isCompacted(result *anthropic.ToolResultBlockParam) bool {
+	return len(result.Content) == 1 &&
+		result.Content[0].OfText != nil &&
+		result.Content[0].OfText.Text == compactedToolResult
+}
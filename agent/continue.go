@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// maxAutoContinuations caps how often a turn is continued after responses hit the output limit
+const maxAutoContinuations = 3
+
+// truncatedToolCallNote tells the model a tool call was lost to the output limit
+const truncatedToolCallNote = "Your previous response hit the output token limit while writing a tool call, so its tool calls were not run. " +
+	"Continue, breaking the work into smaller steps: for example, write a large file in several parts."
+
+// continueTruncated prepares the session to continue a response that stopped
+// at max_tokens. A response cut off in text is left as the final assistant
+// message, which the model then continues (prefill); it reports true in that
+// case, and the next response must be stitched onto it with extendLast. A
+// response cut off inside a tool call can't be run, so its tool calls are
+// dropped and the model is asked to retry in smaller steps.
+func (a *Agent) continueTruncated(session *Session, continuations *int, events chan<- AgentEvent) (prefill bool, retry bool) {
+	if *continuations >= maxAutoContinuations {
+		events <- Notice{Text: "The response was cut off at the output limit. Ask the agent to continue to get the rest."}
+		return false, false
+	}
+	*continuations++
+
+	last, ok := session.last()
+	if !ok || last.Role != anthropic.MessageParamRoleAssistant {
+		return false, false
+	}
+
+	content := []anthropic.ContentBlockParamUnion{}
+	droppedToolCall := false
+	for _, block := range last.Content {
+		if block.OfToolUse != nil {
+			droppedToolCall = true
+			continue
+		}
+		content = append(content, block)
+	}
+
+	if droppedToolCall {
+		if len(content) > 0 {
+			last.Content = content
+			session.setLast(last)
+		} else {
+			session.dropLast()
+		}
+		session.Append(anthropic.NewUserMessage(anthropic.NewTextBlock(truncatedToolCallNote)))
+
+		events <- Notice{Text: "The response was cut off while writing a tool call; asking the agent to retry in smaller steps…"}
+		return false, true
+	}
+
+	// The API rejects a final assistant message that ends in whitespace
+	if n := len(content); n > 0 && content[n-1].OfText != nil {
+		trimmed := *content[n-1].OfText
+		trimmed.Text = strings.TrimRight(trimmed.Text, " \t\r\n")
+		if trimmed.Text == "" {
+			content = content[:n-1]
+		} else {
+			content[n-1] = anthropic.ContentBlockParamUnion{OfText: &trimmed}
+		}
+	}
+	if len(content) == 0 {
+		return false, false
+	}
+
+	// The API doesn't continue a response while thinking is on
+	a.mu.Lock()
+	thinking := a.thinking
+	a.mu.Unlock()
+	if thinking > 0 {
+		events <- Notice{Text: "The response was cut off at the output limit. Ask the agent to continue to get the rest."}
+		return false, false
+	}
+
+	last.Content = content
+	session.setLast(last)
+
+	events <- Notice{Text: "The response hit the output limit; continuing it…"}
+	return true, true
+}
+
+// extendLast stitches a continuation onto the final message of the session,
+// joining the text where one left off and the other picks up
+func (s *Session) extendLast(continuation anthropic.MessageParam) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conversation) == 0 {
+		s.conversation = append(s.conversation, continuation)
+		return
+	}
+
+	last := &s.conversation[len(s.conversation)-1]
+	blocks := continuation.Content
+
+	if n := len(last.Content); n > 0 && len(blocks) > 0 && last.Content[n-1].OfText != nil && blocks[0].OfText != nil {
+		joined := *last.Content[n-1].OfText
+		joined.Text += blocks[0].OfText.Text
+		last.Content = append(last.Content[:n-1:n-1], anthropic.ContentBlockParamUnion{OfText: &joined})
+		blocks = blocks[1:]
+	}
+
+	last.Content = append(last.Content, blocks...)
+}
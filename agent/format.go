@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"agent/config"
+	"agent/tools"
+)
+
+// defaultFormatTimeout bounds a formatter that doesn't set timeout_seconds
+const defaultFormatTimeout = 30 * time.Second
+
+// formatWritten runs the project's formatters on a file a tool just wrote,
+// before the change is recorded, so the diff the user reviews is the
+// formatted one. It returns a note for the model, or "" when no formatter
+// applies.
+func (a *Agent) formatWritten(absPath string) string {
+	if info, err := os.Stat(absPath); absPath == "" || err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+
+	a.mu.Lock()
+	formatters := a.projectConfig.Format
+	a.mu.Unlock()
+
+	root := a.workspace.Root()
+	rel, err := filepath.Rel(root, absPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		rel = filepath.Base(absPath)
+	}
+	rel = filepath.ToSlash(rel)
+
+	notes := []string{}
+	for _, formatter := range formatters {
+		matches := slices.ContainsFunc(formatter.Files, func(pattern string) bool {
+			return tools.MatchGlob(pattern, rel)
+		})
+		if !matches || formatter.Command == "" {
+			continue
+		}
+
+		if err := runFormatter(root, absPath, formatter); err != nil {
+			notes = append(notes, fmt.Sprintf("Formatter %q failed, the file is unformatted: %s", formatter.Command, err))
+		} else {
+			notes = append(notes, fmt.Sprintf("The file was formatted with %q afterwards; re-read it before editing the changed lines again.", formatter.Command))
+		}
+	}
+
+	return strings.Join(notes, "\n")
+}
+
+// runFormatter runs one formatter command on a file, holding the file's lock
+func runFormatter(root, absPath string, formatter config.FormatterConfig) error {
+	timeout := defaultFormatTimeout
+	if formatter.Timeout > 0 {
+		timeout = time.Duration(formatter.Timeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	unlock, err := tools.LockFile(absPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	command := formatter.Command
+	if strings.Contains(command, "{file}") {
+		command = strings.ReplaceAll(command, "{file}", shellQuote(absPath))
+	} else {
+		command += " " + shellQuote(absPath)
+	}
+
+	cmd := config.ShellCommand(ctx, command)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// shellQuote quotes a path for the platform shell
+func shellQuote(path string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + path + `"`
+	}
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
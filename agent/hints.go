@@ -0,0 +1,320 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"agent/tools"
+)
+
+// minHintSimilarity is how alike text must be to be suggested as the text the model meant
+const minHintSimilarity = 0.5
+
+// maxHintLines caps the file lines quoted in a hint
+const maxHintLines = 12
+
+// maxHintFiles caps the files a search for a missing path looks at
+const maxHintFiles = 20_000
+
+// recoveryHint looks at a failed tool call and works out what the model
+// probably meant, e.g. the lines closest to an old_str that wasn't found, so it
+// can fix the call instead of retrying blindly. It returns "" when it has
+// nothing useful to add.
+func (a *Agent) recoveryHint(name string, input json.RawMessage, err error) string {
+	if err.Error() == "tool not found" {
+		return a.toolNameHint(name)
+	}
+
+	var args struct {
+		Path   string `json:"path"`
+		Source string `json:"source"`
+		OldStr string `json:"old_str"`
+		Mode   string `json:"mode"`
+	}
+	if json.Unmarshal(input, &args) != nil {
+		return a.inputHint(name)
+	}
+	if strings.HasPrefix(err.Error(), "failed to parse input") {
+		return a.inputHint(name)
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		path := args.Path
+		if path == "" {
+			path = args.Source
+		}
+		return a.missingPathHint(path)
+	}
+
+	if name == tools.EditFileDefinition.Name && args.OldStr != "" {
+		absPath, resolveErr := a.workspace.Resolve(args.Path)
+		if resolveErr != nil {
+			return ""
+		}
+		content, readErr := a.workspace.ReadFile(absPath)
+		if readErr != nil {
+			return ""
+		}
+
+		switch {
+		case strings.HasPrefix(err.Error(), "old_str not found"):
+			return closestMatchHint(string(content), args.OldStr)
+		case strings.HasPrefix(err.Error(), "old_str found"):
+			return occurrencesHint(string(content), args.OldStr, args.Mode)
+		}
+	}
+
+	return ""
+}
+
+// hintSuffix formats the recovery hint for a failed call to append to its error
+func (a *Agent) hintSuffix(name string, input json.RawMessage, err error) string {
+	if hint := a.recoveryHint(name, input, err); hint != "" {
+		return "\n\nHint: " + hint
+	}
+	return ""
+}
+
+// toolNameHint suggests the tools whose names are closest to an unknown one
+func (a *Agent) toolNameHint(name string) string {
+	names := []string{}
+	for _, tool := range a.tools {
+		names = append(names, tool.Name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return similarity(name, names[i]) > similarity(name, names[j])
+	})
+
+	return fmt.Sprintf("there is no tool named %q. The closest are: %s.", name, strings.Join(names[:min(3, len(names))], ", "))
+}
+
+// inputHint lists the input fields a tool accepts, for calls that didn't parse
+func (a *Agent) inputHint(name string) string {
+	for _, tool := range a.tools {
+		if tool.Name != name {
+			continue
+		}
+
+		var properties map[string]json.RawMessage
+		encoded, err := json.Marshal(tool.InputSchema.Properties)
+		if err != nil || json.Unmarshal(encoded, &properties) != nil {
+			return ""
+		}
+		fields := make([]string, 0, len(properties))
+		for field := range properties {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+
+		hint := fmt.Sprintf("%s takes a JSON object with the fields %s", name, strings.Join(fields, ", "))
+		return hint + ". Check the field names and value types."
+	}
+
+	return ""
+}
+
+// missingPathHint suggests existing files that look like the missing path:
+// files of the same name elsewhere, or near misses in the same directory
+func (a *Agent) missingPathHint(path string) string {
+	if path == "" {
+		return ""
+	}
+	absPath, err := a.workspace.Resolve(path)
+	if err != nil {
+		return ""
+	}
+
+	root := a.workspace.Root()
+	base := filepath.Base(absPath)
+	candidates := []string{}
+	seen := map[string]bool{}
+	add := func(candidate string) {
+		rel, err := filepath.Rel(root, candidate)
+		if err != nil || !filepath.IsLocal(rel) || seen[rel] {
+			return
+		}
+		seen[rel] = true
+		candidates = append(candidates, filepath.ToSlash(rel))
+	}
+
+	// Near misses in the directory the model was looking in, e.g. a typo or the wrong extension
+	if entries, err := os.ReadDir(filepath.Dir(absPath)); err == nil {
+		for _, entry := range entries {
+			if similarity(base, entry.Name()) >= minHintSimilarity {
+				add(filepath.Join(filepath.Dir(absPath), entry.Name()))
+			}
+		}
+	}
+
+	// The same name elsewhere, e.g. a guessed directory
+	visited := 0
+	filepath.WalkDir(root, func(walked string, entry fs.DirEntry, err error) error {
+		if len(candidates) >= 5 {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			return nil
+		}
+		visited++
+		if visited > maxHintFiles {
+			return filepath.SkipAll
+		}
+		rel, _ := filepath.Rel(root, walked)
+		if walked != root && a.workspace.Ignored(filepath.ToSlash(rel), entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() == base && walked != absPath {
+			add(walked)
+		}
+		return nil
+	})
+
+	if len(candidates) == 0 {
+		if _, err := os.Stat(filepath.Dir(absPath)); err != nil {
+			return fmt.Sprintf("the directory %s doesn't exist either. Use list_files to see what is there.", filepath.ToSlash(filepath.Dir(path)))
+		}
+		return "nothing with a similar name exists. Use list_files to see what is there."
+	}
+
+	sort.Strings(candidates)
+	return fmt.Sprintf("%s doesn't exist. Did you mean %s?", path, strings.Join(candidates[:min(5, len(candidates))], ", "))
+}
+
+// closestMatchHint finds the lines in content most like old_str and quotes
+// them with line numbers, so the model can copy the exact text
+func closestMatchHint(content, oldStr string) string {
+	lines := strings.Split(content, "\n")
+	wanted := strings.Split(strings.Trim(oldStr, "\n"), "\n")
+
+	// Whitespace is the most common difference; say so rather than just quoting
+	if start, end, ok := findIgnoringSpace(lines, wanted); ok {
+		return fmt.Sprintf("old_str matches %s if whitespace is ignored; the indentation or spacing differs. The exact text is:\n%s", lineRange(start+1, end), numberedLines(lines, start, end))
+	}
+
+	best, bestScore := -1, 0.0
+	for start := 0; start+len(wanted) <= len(lines); start++ {
+		score := 0.0
+		for i, line := range wanted {
+			score += similarity(strings.TrimSpace(line), strings.TrimSpace(lines[start+i]))
+		}
+		score /= float64(len(wanted))
+		if score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+
+	if best < 0 || bestScore < minHintSimilarity {
+		return "nothing in the file resembles old_str. Read the file again; it may not contain what you expect."
+	}
+
+	end := min(best+len(wanted), len(lines))
+	return fmt.Sprintf("the closest text is %s (%.0f%% similar):\n%s\nCopy old_str exactly from the file, including whitespace.", lineRange(best+1, end), bestScore*100, numberedLines(lines, best, end))
+}
+
+// findIgnoringSpace finds the run of lines containing wanted when whitespace is ignored
+func findIgnoringSpace(lines, wanted []string) (int, int, bool) {
+	target := collapseSpace(strings.Join(wanted, "\n"))
+	if target == "" {
+		return 0, 0, false
+	}
+
+	for start := range lines {
+		// old_str may begin partway into the first line
+		limit := len(target) + len(collapseSpace(lines[start]))
+		joined := ""
+		for end := start; end < len(lines) && len(joined) < limit; end++ {
+			joined += collapseSpace(lines[end])
+			if !strings.Contains(joined, target) {
+				continue
+			}
+
+			// Collapsed lines run together, so blank or unrelated leading lines may be included
+			for start < end && strings.Contains(collapseSpace(strings.Join(lines[start+1:end+1], "")), target) {
+				start++
+			}
+			return start, end + 1, true
+		}
+	}
+
+	return 0, 0, false
+}
+
+// occurrencesHint lists where an ambiguous old_str occurs
+func occurrencesHint(content, oldStr, mode string) string {
+	first := strings.Split(strings.TrimLeft(oldStr, "\n"), "\n")[0]
+	lineNumbers := []string{}
+	for i, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, first) {
+			lineNumbers = append(lineNumbers, fmt.Sprint(i+1))
+		}
+	}
+	if len(lineNumbers) > 10 {
+		lineNumbers = append(lineNumbers[:10], "…")
+	}
+
+	hint := fmt.Sprintf("it occurs on lines %s. Add surrounding lines to old_str so it matches only once", strings.Join(lineNumbers, ", "))
+	if mode == "replace" || mode == "" {
+		hint += ", or use replace_range with start_line and end_line"
+	}
+	return hint + "."
+}
+
+// numberedLines quotes lines[start:end] the way read_file numbers them
+func numberedLines(lines []string, start, end int) string {
+	end = min(end, start+maxHintLines, len(lines))
+	width := len(fmt.Sprint(end))
+
+	quoted := []string{}
+	for i := start; i < end; i++ {
+		quoted = append(quoted, fmt.Sprintf("%*d\t%s", width, i+1, strings.TrimRight(lines[i], "\r")))
+	}
+	return strings.Join(quoted, "\n")
+}
+
+// lineRange describes 1-based lines first to last
+func lineRange(first, last int) string {
+	if first == last {
+		return fmt.Sprintf("line %d", first)
+	}
+	return fmt.Sprintf("lines %d-%d", first, last)
+}
+
+// collapseSpace drops all whitespace, so text can be compared ignoring indentation and spacing
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), "")
+}
+
+// similarity is the Dice coefficient of the character bigrams of a and b,
+// from 0 for nothing in common to 1 for equal strings
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+
+	bigrams := map[string]int{}
+	for i := 0; i+1 < len(a); i++ {
+		bigrams[a[i:i+2]]++
+	}
+
+	shared := 0
+	for i := 0; i+1 < len(b); i++ {
+		if bigrams[b[i:i+2]] > 0 {
+			bigrams[b[i:i+2]]--
+			shared++
+		}
+	}
+
+	return 2 * float64(shared) / float64(len(a)+len(b)-2)
+}
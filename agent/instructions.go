@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/config"
+	"agent/tools"
+)
+
+// projectInstructionFiles are checked in order; the first one found in the workspace root is used
+var projectInstructionFiles = []string{
+	"AGENTS.md",
+	"CLAUDE.md",
+	filepath.Join(".cli-agent", "instructions.md"),
+}
+
+// loadProjectInstructions reads the project instruction file from root, if one exists
+func loadProjectInstructions(root string) (string, string) {
+	for _, name := range projectInstructionFiles {
+		content, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+
+		text := strings.TrimSpace(string(content))
+		if text == "" {
+			continue
+		}
+
+		return name, text
+	}
+
+	return "", ""
+}
+
+// systemPrompt builds the system prompt from the base prompt, the workspace,
+// project instructions and the pinned files. The base prompt and the
+// instructions are templates over PromptVars.
+func (a *Agent) systemPrompt() string {
+	pinned := a.pinnedContext()
+
+	a.mu.Lock()
+	instructions := a.instructions
+	a.mu.Unlock()
+
+	// Rendering may run git, so it happens outside the lock
+	vars := a.promptVars()
+	prompt := renderPrompt(MY_AGENT_SYSTEM_PROMPT, vars)
+	instructions = renderPrompt(instructions, vars)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	prompt += fmt.Sprintf("\nYour working directory is %s. Tool paths are relative to it.\n", a.workspace.Root())
+
+	if roots := a.workspace.Roots(); len(roots) > 0 {
+		prompt += "Additional workspace roots are available. Prefix a path with the root name to use one, e.g. \"name:src/main.go\":\n"
+		for _, root := range roots {
+			prompt += fmt.Sprintf("- %s: %s\n", root.Name, root.Path)
+		}
+	}
+
+	if instructions != "" {
+		prompt += fmt.Sprintf("\nProject instructions (from %s):\n%s\n", a.instructionsFile, instructions)
+	}
+
+	if a.responseLanguage != "" {
+		prompt += fmt.Sprintf("\nAlways write your replies to the user in %s, whatever language the code, files or tool output are in. Keep code, identifiers and file contents in their original language.\n", a.responseLanguage)
+	}
+
+	if a.workspace.Staging() {
+		prompt += "\nDry-run mode is on: your file changes are staged for the user to review and apply at the end of the turn instead of being written to disk. read_file and the editing tools see the staged content; other tools see the files as they are on disk. create_directory and copy_path are unavailable.\n"
+	}
+
+	// The task list survives compaction this way, so long jobs can pick up where they left off
+	if todos := a.workspace.Todos(); len(todos) > 0 {
+		prompt += "\nYour task list for the current job; keep it up to date with manage_todos:\n" + tools.FormatTodos(todos) + "\n"
+	}
+
+	prompt += pinned
+
+	return prompt
+}
+
+// SetResponseLanguage asks the model to reply in the given language, e.g.
+// "German"; an empty language leaves the choice to the model
+func (a *Agent) SetResponseLanguage(language string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.responseLanguage = language
+}
+
+// SetWorkingDirectory moves the agent to a new workspace root and reloads its project instructions
+func (a *Agent) SetWorkingDirectory(dir string) error {
+	if err := a.workspace.SetRoot(dir); err != nil {
+		return err
+	}
+
+	// Pins are relative to the old root
+	a.mu.Lock()
+	a.pinned = nil
+	a.mu.Unlock()
+
+	a.reloadProject()
+	return nil
+}
+
+// Workspace returns the workspace the agent's tools operate in
+func (a *Agent) Workspace() *tools.Workspace {
+	return a.workspace
+}
+
+// WorkingDirectory returns the absolute workspace root
+func (a *Agent) WorkingDirectory() string {
+	return a.workspace.Root()
+}
+
+// reloadProject re-reads the project instructions and config for the current workspace root
+func (a *Agent) reloadProject() {
+	root := a.workspace.Root()
+	file, instructions := loadProjectInstructions(root)
+	projectConfig, err := config.LoadProjectConfig(root)
+
+	limits := tools.DefaultWriteLimits
+	if projectConfig.Limits.MaxFileBytes != nil {
+		limits.MaxFileBytes = *projectConfig.Limits.MaxFileBytes
+	}
+	if projectConfig.Limits.MaxSessionBytes != nil {
+		limits.MaxSessionBytes = *projectConfig.Limits.MaxSessionBytes
+	}
+	a.workspace.SetLimits(limits)
+	a.workspace.SetIgnore(projectConfig.Ignore)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.instructionsFile = file
+	a.instructions = instructions
+	a.projectConfig = projectConfig
+	a.projectErr = err
+}
+
+// ProjectConfigError returns the error encountered loading the project config, if any
+func (a *Agent) ProjectConfigError() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.projectErr
+}
@@ -0,0 +1,38 @@
+package agent
+
+import "encoding/json"
+
+// ToolCall identifies a single tool invocation requested by the model
+type ToolCall struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+// ToolInterceptor can observe or replace tool execution. Calling next runs the tool normally.
+type ToolInterceptor func(call ToolCall, next func() (string, error)) (string, error)
+
+// SetToolInterceptor installs a hook around every tool execution in a turn
+func (a *Agent) SetToolInterceptor(interceptor ToolInterceptor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.interceptor = interceptor
+}
+
+// callTool runs a tool call for session through the interceptor, if one is installed
+func (a *Agent) callTool(session *Session, call ToolCall) (string, error) {
+	a.mu.Lock()
+	interceptor := a.interceptor
+	a.mu.Unlock()
+
+	next := func() (string, error) {
+		return a.runCachedTool(session, call)
+	}
+
+	if interceptor == nil {
+		return next()
+	}
+
+	return interceptor(call, next)
+}
@@ -0,0 +1,91 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// recentWriteWindow is how long after an agent write a change to the same file is attributed to the agent
+const recentWriteWindow = 5 * time.Second
+
+// AddNote queues a system note for the model. Notes are delivered with the
+// next message sent to the model: the user's next input or the next batch of
+// tool results.
+func (a *Agent) AddNote(text string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.notes = append(a.notes, text)
+}
+
+// takeNotes returns the queued notes as content blocks and clears the queue
+func (a *Agent) takeNotes() []anthropic.ContentBlockParamUnion {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(a.notes))
+	for _, note := range a.notes {
+		blocks = append(blocks, anthropic.NewTextBlock(fmt.Sprintf("<system-note>%s</system-note>", note)))
+	}
+	a.notes = nil
+
+	return blocks
+}
+
+// ExternalChanges filters workspace-relative paths down to those whose content
+// differs from what the agent last read or wrote, so the agent's own writes
+// aren't reported as outside changes
+func (a *Agent) ExternalChanges(paths []string) []string {
+	root := a.workspace.Root()
+	changed := []string{}
+
+	for _, path := range paths {
+		absPath := filepath.Join(root, filepath.FromSlash(path))
+
+		info, err := os.Stat(absPath)
+		if err == nil && info.IsDir() {
+			continue
+		}
+
+		a.mu.Lock()
+		seen, known := a.seen[absPath]
+		a.mu.Unlock()
+
+		if known {
+			snapshot := readSnapshot(absPath)
+			if snapshot.exists && snapshot.complete && seen.hash == sha256.Sum256([]byte(snapshot.content)) {
+				continue
+			}
+		} else if a.touchedRecently(absPath) {
+			// Files the agent wrote without a comparable snapshot, e.g. large ones
+			continue
+		}
+
+		changed = append(changed, path)
+	}
+
+	return changed
+}
+
+// touchedRecently reports whether a modifying tool wrote absPath in the last few seconds
+func (a *Agent) touchedRecently(absPath string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := len(a.activity) - 1; i >= 0; i-- {
+		activity := a.activity[i]
+		if time.Since(activity.Time) > recentWriteWindow {
+			break
+		}
+		if activity.AbsPath == absPath && !activity.ReadOnly {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// offlineRetryMin and offlineRetryMax bound the backoff between attempts to
+// reach the API while the connection is down
+const (
+	offlineRetryMin = 2 * time.Second
+	offlineRetryMax = 30 * time.Second
+)
+
+// Offline reports that the API can't be reached. The turn is paused, not
+// failed: the request is sent again at Retry, and until it goes through.
+type Offline struct {
+	Err   error
+	Retry time.Time
+}
+
+// Online reports that the API is reachable again after Offline, and the
+// paused turn is resuming
+type Online struct{}
+
+func (Offline) isAgentEvent() {}
+func (Online) isAgentEvent()  {}
+
+// infer sends the conversation to the model. When the connection is lost it
+// reports the agent offline and sends the request again with backoff until it
+// goes through, so a dropped network pauses the turn instead of ending it.
+// Anything streamed before the connection dropped is discarded.
+func (a *Agent) infer(ctx context.Context, session *Session, events chan<- AgentEvent) (*anthropic.Message, error) {
+	delay := offlineRetryMin
+	offline := false
+
+	for {
+		message, err := a.RunInferenceWithStreaming(ctx, session.Messages(), func(event AgentEvent) {
+			if offline {
+				offline = false
+				delay = offlineRetryMin
+				events <- Online{}
+			}
+			events <- event
+		})
+		if err == nil || !connectionLost(ctx, err) {
+			if offline {
+				events <- Online{}
+			}
+			return message, err
+		}
+
+		offline = true
+		events <- Offline{Err: err, Retry: time.Now().Add(delay)}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return message, ctx.Err()
+		case <-timer.C:
+		}
+		delay = min(delay*2, offlineRetryMax)
+	}
+}
+
+// connectionLost reports whether err means the API couldn't be reached, as
+// opposed to the API answering with an error or the turn being cancelled
+func connectionLost(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// The API answered, so the connection is fine
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ENETUNREACH) ||
+		errors.Is(err, syscall.EHOSTUNREACH)
+}
@@ -0,0 +1,159 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxPinnedBytes caps the pinned context added to each request
+const maxPinnedBytes = 100 * 1024
+
+// autoPinLimit is how many recently used files are pinned automatically
+const autoPinLimit = 5
+
+// Pin adds a workspace file to the pinned context sent with every request
+func (a *Agent) Pin(path string) error {
+	resolved, err := a.workspace.Resolve(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, pinned := range a.pinned {
+		if pinned == path {
+			return nil
+		}
+	}
+
+	a.pinned = append(a.pinned, path)
+	return nil
+}
+
+// Unpin removes a file from the pinned context, reporting whether it was pinned
+func (a *Agent) Unpin(path string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i, pinned := range a.pinned {
+		if pinned == path {
+			a.pinned = append(a.pinned[:i], a.pinned[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetAutoPin turns automatic pinning of recently used files on or off
+func (a *Agent) SetAutoPin(enabled bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.autoPin = &enabled
+}
+
+// AutoPin reports whether recently used files are pinned automatically. The
+// project config decides unless it was changed with SetAutoPin.
+func (a *Agent) AutoPin() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.autoPin != nil {
+		return *a.autoPin
+	}
+	return a.projectConfig.Context.AutoPin
+}
+
+// PinnedFiles returns the files included in the pinned context, in order:
+// explicit pins, pins from the project config, then recently used files
+func (a *Agent) PinnedFiles() []string {
+	autoPin := a.AutoPin()
+
+	var recent []string
+	if autoPin {
+		recent = a.RecentFiles(autoPinLimit * 2)
+	}
+
+	a.mu.Lock()
+	candidates := append(append([]string{}, a.pinned...), a.projectConfig.Context.Pin...)
+	a.mu.Unlock()
+
+	candidates = append(candidates, recent...)
+
+	seen := map[string]bool{}
+	files := []string{}
+	auto := 0
+
+	for i, path := range candidates {
+		if seen[path] {
+			continue
+		}
+
+		// Recent activity includes directories and deleted files; only pin regular files
+		resolved, err := a.workspace.Resolve(path)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(resolved); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		if i >= len(candidates)-len(recent) {
+			if auto >= autoPinLimit {
+				continue
+			}
+			auto++
+		}
+
+		seen[path] = true
+		files = append(files, path)
+	}
+
+	return files
+}
+
+// pinnedContext re-reads the pinned files and renders them for the system
+// prompt, so the model always sees their current contents
+func (a *Agent) pinnedContext() string {
+	files := a.PinnedFiles()
+	if len(files) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\nThe current contents of these files are pinned below and re-read before every request. They are always up to date, so prefer them over older tool results:\n")
+
+	remaining := maxPinnedBytes
+	for _, path := range files {
+		resolved, err := a.workspace.Resolve(path)
+		if err != nil {
+			continue
+		}
+
+		content, err := os.ReadFile(resolved)
+		if err != nil {
+			continue
+		}
+
+		if len(content) > remaining {
+			b.WriteString(fmt.Sprintf("\n<file path=%q omitted=\"pinned context is full\"/>\n", path))
+			continue
+		}
+		remaining -= len(content)
+
+		b.WriteString(fmt.Sprintf("\n<file path=%q>\n%s\n</file>\n", path, content))
+	}
+
+	return b.String()
+}
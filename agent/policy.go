@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"fmt"
+
+	"agent/tools"
+)
+
+// SetTrusted enables or disables modifying tools for the current workspace
+func (a *Agent) SetTrusted(trusted bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.trusted = trusted
+}
+
+// Trusted reports whether modifying tools are enabled
+func (a *Agent) Trusted() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.trusted
+}
+
+// ToolReadOnly reports whether the named tool never modifies the workspace
+func (a *Agent) ToolReadOnly(name string) bool {
+	for _, tool := range a.tools {
+		if tool.Name == name {
+			return tool.ReadOnly
+		}
+	}
+
+	return false
+}
+
+// toolEnabled reports whether the project config allows a tool
+func (a *Agent) toolEnabled(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.projectConfig.Tools.Allows(name)
+}
+
+// checkToolAllowed returns an error explaining why a tool may not run, or nil if it may
+func (a *Agent) checkToolAllowed(tool tools.ToolDefinition) error {
+	// A broken config may have meant to restrict tools, so modifying tools
+	// stay off until it loads; it is re-read in case it has been fixed
+	if (!tool.ReadOnly || tool.RunsPrograms) && a.ProjectConfigError() != nil {
+		a.reloadProject()
+		if err := a.ProjectConfigError(); err != nil {
+			return fmt.Errorf("%s is disabled until the project config is fixed: %w", tool.Name, err)
+		}
+	}
+
+	if !a.toolEnabled(tool.Name) {
+		return fmt.Errorf("the tool %s is disabled by the project config", tool.Name)
+	}
+
+	if (!tool.ReadOnly || tool.RunsPrograms) && !a.Trusted() {
+		return fmt.Errorf("the workspace %s is not trusted, so %s is disabled; ask the user to run /trust to enable tools that modify files or run programs", a.workspace.Root(), tool.Name)
+	}
+
+	return nil
+}
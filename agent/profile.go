@@ -0,0 +1,93 @@
+package agent
+
+import (
+	"fmt"
+
+	"agent/config"
+	"agent/provider"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// ActiveProfile is a resolved settings profile: the provider built from its
+// credentials and the model it selects. An empty Model means DefaultModel.
+type ActiveProfile struct {
+	Name       string
+	Provider   provider.Provider
+	Model      string
+	RateLimits config.RateLimits
+}
+
+// ProfileSwitcher resolves a profile by name, typically by loading the user
+// settings and building a provider from the profile's credentials
+type ProfileSwitcher func(name string) (ActiveProfile, error)
+
+// SetProfileSwitcher installs the callback used by SwitchProfile
+func (a *Agent) SetProfileSwitcher(switcher ProfileSwitcher) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.switcher = switcher
+}
+
+// SwitchProfile resolves the named profile and uses it for the following requests
+func (a *Agent) SwitchProfile(name string) error {
+	a.mu.Lock()
+	switcher := a.switcher
+	a.mu.Unlock()
+
+	if switcher == nil {
+		return fmt.Errorf("profiles are not available in this mode")
+	}
+
+	profile, err := switcher(name)
+	if err != nil {
+		return err
+	}
+
+	a.UseProfile(profile)
+	return nil
+}
+
+// UseProfile switches the provider and model to an already resolved profile
+func (a *Agent) UseProfile(profile ActiveProfile) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.profile = profile.Name
+	a.provider = profile.Provider
+	a.model = profile.Model
+	a.rateLimits = profile.RateLimits
+}
+
+// Profile returns the name of the active profile, or "" when none was selected
+func (a *Agent) Profile() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.profile
+}
+
+// Model returns the model used for inference
+func (a *Agent) Model() anthropic.Model {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.activeModel()
+}
+
+// activeModel returns the model used for inference. Callers must hold the lock.
+func (a *Agent) activeModel() anthropic.Model {
+	if a.model == "" {
+		return DefaultModel
+	}
+	return anthropic.Model(a.model)
+}
+
+// modelProvider returns the provider of the active profile
+func (a *Agent) modelProvider() provider.Provider {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.provider
+}
@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+
+	"agent/tools"
+)
+
+// gitBranchTimeout bounds the git call behind {{.GitBranch}}
+const gitBranchTimeout = 2 * time.Second
+
+// PromptVars are the variables of the system prompt and the project
+// instructions, which are Go templates, e.g. "Run the tests with
+// {{.Toolchain}}" or "{{if eq .OS "windows"}}Use PowerShell.{{end}}".
+// Each is looked up only when the prompt uses it.
+type PromptVars struct {
+	root string
+	now  time.Time
+}
+
+// Cwd is the workspace root
+func (v PromptVars) Cwd() string {
+	return v.root
+}
+
+// OS is the operating system, e.g. "linux", "darwin" or "windows"
+func (v PromptVars) OS() string {
+	return runtime.GOOS
+}
+
+// Arch is the processor architecture, e.g. "amd64" or "arm64"
+func (v PromptVars) Arch() string {
+	return runtime.GOARCH
+}
+
+// Date is today's date, e.g. "2025-06-30"
+func (v PromptVars) Date() string {
+	return v.now.Format(time.DateOnly)
+}
+
+// GitBranch is the checked-out branch, or empty outside a repository or on
+// a detached HEAD
+func (v PromptVars) GitBranch() string {
+	ctx, cancel := context.WithTimeout(context.Background(), gitBranchTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", "branch", "--show-current")
+	cmd.Dir = v.root
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// Language is the project's main language, e.g. "Go", or empty when no
+// manifest reveals it
+func (v PromptVars) Language() string {
+	if toolchains := tools.DetectToolchains(v.root); len(toolchains) > 0 {
+		return toolchains[0].Language
+	}
+	return ""
+}
+
+// Toolchain is the tool that builds the main language, e.g. "cargo" or "pnpm"
+func (v PromptVars) Toolchain() string {
+	if toolchains := tools.DetectToolchains(v.root); len(toolchains) > 0 {
+		return toolchains[0].Tool
+	}
+	return ""
+}
+
+// Languages are all the project's languages found from its manifests
+func (v PromptVars) Languages() []string {
+	languages := []string{}
+	for _, toolchain := range tools.DetectToolchains(v.root) {
+		languages = append(languages, toolchain.Language)
+	}
+	return languages
+}
+
+// promptFuncs are the functions prompt templates may call besides the builtins
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// promptVars returns the variables for the next request's system prompt
+func (a *Agent) promptVars() PromptVars {
+	return PromptVars{root: a.workspace.Root(), now: time.Now()}
+}
+
+// renderPrompt fills in a prompt's template variables. Text that isn't a
+// valid template, such as instructions quoting other template languages, is
+// used as it is.
+func renderPrompt(text string, vars PromptVars) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Parse(text)
+	if err != nil {
+		return text
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return text
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"agent/config"
+)
+
+// rateWindow is the window the API's per-minute rate limits are measured over
+const rateWindow = time.Minute
+
+// spendWindow is the window spend alerts are measured over
+const spendWindow = time.Hour
+
+// sentRequest is a model request made within the spend window, for the rate limits
+type sentRequest struct {
+	at     time.Time
+	input  int64
+	output int64
+	cost   float64
+}
+
+// RateLimitWait reports that the next request waits until Until to stay
+// within a rate limit. Limit is its name in the settings, e.g. "requests_per_minute".
+type RateLimitWait struct {
+	Until time.Time
+	Limit string
+}
+
+// SpendAlert reports that the dollars spent in the last hour passed the
+// alert threshold. It is sent once until spending drops below it again.
+type SpendAlert struct {
+	LastHour  float64
+	Threshold float64
+}
+
+func (RateLimitWait) isAgentEvent() {}
+func (SpendAlert) isAgentEvent()    {}
+
+// SetRateLimits replaces the rate limits requests are kept within
+func (a *Agent) SetRateLimits(limits config.RateLimits) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.rateLimits = limits
+}
+
+// RateLimits returns the rate limits in effect
+func (a *Agent) RateLimits() config.RateLimits {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.rateLimits
+}
+
+// recordRequest remembers a response's usage for the rate limits and spend
+// alerts, dropping requests that have left the spend window. Callers must hold the lock.
+func (a *Agent) recordRequest(usage Usage, cost float64) {
+	now := time.Now()
+
+	kept := a.requests[:0]
+	for _, request := range a.requests {
+		if now.Sub(request.at) < spendWindow {
+			kept = append(kept, request)
+		}
+	}
+
+	// Cache reads don't count towards the input token limit
+	a.requests = append(kept, sentRequest{
+		at:     now,
+		input:  usage.InputTokens + usage.CacheCreationInputTokens,
+		output: usage.OutputTokens,
+		cost:   cost,
+	})
+}
+
+// waitForRateLimit runs before each model request and waits, reporting a
+// countdown, for as long as the request would exceed a rate limit
+func (a *Agent) waitForRateLimit(ctx context.Context, events chan<- AgentEvent) error {
+	for {
+		a.mu.Lock()
+		wait, limit := rateLimitWait(a.requests, a.rateLimits, time.Now())
+		a.mu.Unlock()
+
+		if wait <= 0 {
+			return nil
+		}
+
+		events <- RateLimitWait{Until: time.Now().Add(wait), Limit: limit}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// rateLimitWait returns how long a request must wait at now to stay within
+// limits, and the limit it waits for. The next request is expected to send
+// as many input tokens as the last one; the conversation only grows.
+func rateLimitWait(requests []sentRequest, limits config.RateLimits, now time.Time) (time.Duration, string) {
+	recent := []sentRequest{}
+	for _, request := range requests {
+		if now.Sub(request.at) < rateWindow {
+			recent = append(recent, request)
+		}
+	}
+	if len(recent) == 0 {
+		return 0, ""
+	}
+
+	var wait time.Duration
+	limit := ""
+	consider := func(name string, max int, needed int64, amount func(sentRequest) int64) {
+		if max <= 0 {
+			return
+		}
+
+		// Let requests leave the window, oldest first, until the new one fits
+		used := int64(0)
+		for _, request := range recent {
+			used += amount(request)
+		}
+		for _, request := range recent {
+			if used+needed <= int64(max) {
+				break
+			}
+			used -= amount(request)
+			if until := request.at.Add(rateWindow).Sub(now); until > wait {
+				wait, limit = until, name
+			}
+		}
+	}
+
+	last := recent[len(recent)-1]
+	consider("requests_per_minute", limits.RequestsPerMinute, 1, func(sentRequest) int64 { return 1 })
+	consider("input_tokens_per_minute", limits.InputTokensPerMinute, last.input, func(r sentRequest) int64 { return r.input })
+	consider("output_tokens_per_minute", limits.OutputTokensPerMinute, 1, func(r sentRequest) int64 { return r.output })
+
+	return wait, limit
+}
+
+// checkSpendRate sends a SpendAlert when the last hour's spending passes the alert threshold
+func (a *Agent) checkSpendRate(events chan<- AgentEvent) {
+	a.mu.Lock()
+	threshold := a.rateLimits.SpendAlertPerHour
+	spent := 0.0
+	for _, request := range a.requests {
+		spent += request.cost
+	}
+
+	alert := threshold > 0 && spent > threshold && !a.spendAlerted
+	a.spendAlerted = threshold > 0 && spent > threshold
+	a.mu.Unlock()
+
+	if alert {
+		events <- SpendAlert{LastHour: spent, Threshold: threshold}
+	}
+}
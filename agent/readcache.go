@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"agent/tools"
+)
+
+// unchangedReadResult replaces a read_file result the model already has in the conversation
+const unchangedReadResult = "[unchanged since your last read_file call with these arguments; refer to that result]"
+
+// readStamp identifies a version of a file well enough to tell it hasn't changed
+type readStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// readCacheKey identifies a read_file call by the file it resolves to and the
+// rest of its arguments, so reads of different ranges are cached separately
+func (a *Agent) readCacheKey(input json.RawMessage) (string, string, bool) {
+	var readInput tools.ReadFileInput
+	if err := json.Unmarshal(input, &readInput); err != nil {
+		return "", "", false
+	}
+
+	absPath, err := a.workspace.Resolve(readInput.Path)
+	if err != nil {
+		return "", "", false
+	}
+
+	readInput.Path = ""
+	options, err := json.Marshal(readInput)
+	if err != nil {
+		return "", "", false
+	}
+
+	return absPath + "\x00" + string(options), absPath, true
+}
+
+// statRead returns the stamp of the file at absPath
+func statRead(absPath string) (readStamp, bool) {
+	info, err := os.Stat(absPath)
+	if err != nil || info.IsDir() {
+		return readStamp{}, false
+	}
+
+	return readStamp{modTime: info.ModTime(), size: info.Size()}, true
+}
+
+// runCachedTool runs a tool call, answering read_file calls for files the
+// session has already read, unchanged, with a short marker instead of the
+// full contents again
+func (a *Agent) runCachedTool(session *Session, call ToolCall) (string, error) {
+	if call.Name != tools.ReadFileDefinition.Name {
+		return a.runTool(call.Name, call.Input)
+	}
+
+	// Staged content isn't on disk, so the file's stamp says nothing about it
+	key, absPath, ok := a.readCacheKey(call.Input)
+	if !ok || a.workspace.IsStaged(absPath) {
+		return a.runTool(call.Name, call.Input)
+	}
+
+	before, statted := statRead(absPath)
+	if statted && session.unchangedRead(key, before) {
+		return unchangedReadResult, nil
+	}
+
+	response, err := a.runTool(call.Name, call.Input)
+	if err != nil {
+		return "", err
+	}
+
+	// Results that will be truncated or summarized aren't cached, since the
+	// model never saw them in full. Neither are files that changed during the read.
+	maxChars, _ := a.toolResultLimit()
+	after, ok := statRead(absPath)
+	if ok && statted && after == before && (maxChars <= 0 || len(response) <= maxChars) {
+		session.rememberRead(key, after)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,95 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// maxRepeatedResultChars is the longest earlier result quoted back for a
+// repeated call; longer ones are referred to instead of sent again
+const maxRepeatedResultChars = 2000
+
+// loopRepeats is how many repeats of one call make the user hear about it
+const loopRepeats = 2
+
+// callOutcome is the result of a tool call made earlier in the turn
+type callOutcome struct {
+	response string
+	err      error
+	repeats  int
+}
+
+// turnCalls remembers the outcomes of a turn's tool calls, so that a call
+// identical to an earlier one is answered without running it again while
+// nothing has changed in between. Weaker models sometimes get stuck making
+// the same call over and over; this keeps that from burning tokens.
+type turnCalls map[string]*callOutcome
+
+// callKey identifies a call by its tool and its input with the keys sorted,
+// so inputs differing only in formatting count as identical
+func callKey(name string, input json.RawMessage) string {
+	var decoded any
+	if err := json.Unmarshal(input, &decoded); err != nil {
+		return name + "\x00" + string(input)
+	}
+	canonical, err := json.Marshal(decoded)
+	if err != nil {
+		return name + "\x00" + string(input)
+	}
+
+	return name + "\x00" + string(canonical)
+}
+
+// repeated answers a call identical to an earlier one from the turn with that
+// call's outcome and a nudge to move on, and returns how often it has been
+// repeated. Calls that must run get 0 repeats.
+func (calls turnCalls) repeated(call ToolCall) (string, int, error) {
+	outcome, ok := calls[callKey(call.Name, call.Input)]
+	if !ok {
+		return "", 0, nil
+	}
+	outcome.repeats++
+
+	nudge := fmt.Sprintf("[You already made this exact %s call in this turn and nothing has changed since, so it wasn't run again.", call.Name)
+	if outcome.repeats >= loopRepeats {
+		nudge += fmt.Sprintf(" You have now repeated it %d times. Repeating it won't give a different result: try a different approach, or stop and tell the user what is blocking you.]", outcome.repeats)
+	} else if outcome.err != nil {
+		nudge += " It fails the same way; change the call instead of repeating it.]"
+	} else {
+		nudge += " Use the earlier result instead of repeating the call.]"
+	}
+
+	if outcome.err != nil {
+		return "", outcome.repeats, fmt.Errorf("%w\n\n%s", outcome.err, nudge)
+	}
+	if len(outcome.response) > maxRepeatedResultChars {
+		return nudge + "\nThe result is in your earlier tool result.", outcome.repeats, nil
+	}
+	return nudge + "\nThe result was:\n" + outcome.response, outcome.repeats, nil
+}
+
+// rememberCall records the outcome of a call that ran. Successful calls that may
+// have changed the workspace clear what was remembered, since earlier
+// results could be out of date now.
+func (a *Agent) rememberCall(calls turnCalls, call ToolCall, response string, err error) {
+	var readOnly, volatile bool
+	for _, tool := range a.tools {
+		if tool.Name == call.Name {
+			readOnly, volatile = tool.ReadOnly, tool.Volatile
+			break
+		}
+	}
+
+	if err == nil && !readOnly {
+		clear(calls)
+	}
+	if volatile {
+		return
+	}
+
+	// A failed call fails the same way while nothing changes; a successful
+	// write isn't repeated, in case the model means to write twice
+	if err != nil || readOnly {
+		calls[callKey(call.Name, call.Input)] = &callOutcome{response: response, err: err}
+	}
+}
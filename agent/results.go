@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"agent/provider"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// defaultMaxToolResultChars is the tool result budget when the project config doesn't set one
+const defaultMaxToolResultChars = 50_000
+
+// UtilityModel is the cheap model used for housekeeping such as summarizing tool output
+const UtilityModel = anthropic.ModelClaude_3_Haiku_20240307
+
+// summarizeSystemPrompt instructs the utility model when condensing tool output
+const summarizeSystemPrompt = `You condense the output of a developer tool for an AI coding agent that cannot see it in full.
+Keep everything relevant to the user's request verbatim where possible: error messages, file paths, line numbers, identifiers and numbers.
+Drop repetition and irrelevant parts. Reply with the condensed output only.`
+
+// IsUtilityRequest reports whether a request is a housekeeping call made within
+// a turn, such as a tool result summary, rather than a step of the conversation
+func IsUtilityRequest(params anthropic.MessageNewParams) bool {
+	return len(params.System) == 1 && params.System[0].Text == summarizeSystemPrompt
+}
+
+// toolResultLimit returns the tool result budget and whether to summarize oversized results
+func (a *Agent) toolResultLimit() (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	maxChars := defaultMaxToolResultChars
+	if configured := a.projectConfig.ToolResults.MaxChars; configured != nil {
+		maxChars = *configured
+	}
+
+	return maxChars, a.projectConfig.ToolResults.Summarize
+}
+
+// limitToolResult shortens tool output that exceeds the budget, either by
+// summarizing it with the utility model in light of the user's question or,
+// failing that, by truncating it
+func (a *Agent) limitToolResult(ctx context.Context, session *Session, name, content string, events chan<- AgentEvent) string {
+	maxChars, summarize := a.toolResultLimit()
+	if maxChars <= 0 || len(content) <= maxChars {
+		return content
+	}
+
+	if summarize {
+		summary, err := a.summarizeToolResult(ctx, lastUserText(session.Messages()), name, content, maxChars, events)
+		if err == nil {
+			return fmt.Sprintf("[%s output of %d characters, summarized]\n%s", name, len(content), summary)
+		}
+
+		events <- Notice{Text: fmt.Sprintf("Couldn't summarize the %s output, truncating it instead: %s", name, err)}
+	}
+
+	cut := maxChars
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+
+	return fmt.Sprintf("%s\n[output truncated: showing %d of %d characters]", content[:cut], cut, len(content))
+}
+
+// summarizeToolResult asks the utility model for a focused summary of oversized tool output
+func (a *Agent) summarizeToolResult(ctx context.Context, question, name, content string, maxChars int, events chan<- AgentEvent) (string, error) {
+	// Keep the request itself within reason; the tail of huge logs is usually the interesting part
+	const maxInput = 400_000
+	if len(content) > maxInput {
+		content = "[...]\n" + strings.ToValidUTF8(content[len(content)-maxInput:], "")
+	}
+
+	prompt := fmt.Sprintf("The user asked:\n%s\n\nThe agent ran the %s tool. Condense its output to at most %d characters:\n\n%s", question, name, maxChars, content)
+
+	message, err := provider.Complete(ctx, a.modelProvider(), anthropic.MessageNewParams{
+		Model:     UtilityModel,
+		MaxTokens: int64(min(maxChars/3, 4096)),
+		System:    []anthropic.TextBlockParam{{Text: summarizeSystemPrompt}},
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock(prompt))},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	usage := Usage{InputTokens: message.Usage.InputTokens, OutputTokens: message.Usage.OutputTokens}
+	a.addUsage(usage, UtilityModel)
+	events <- usage
+
+	summary := ""
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			summary += block.Text
+		}
+	}
+	if summary == "" {
+		return "", fmt.Errorf("the utility model returned no text")
+	}
+
+	return summary, nil
+}
+
+// lastUserText returns the text of the most recent message the user typed
+func lastUserText(conversation []anthropic.MessageParam) string {
+	for i := len(conversation) - 1; i >= 0; i-- {
+		message := conversation[i]
+		if message.Role != anthropic.MessageParamRoleUser {
+			continue
+		}
+
+		text := ""
+		for _, block := range message.Content {
+			if block.OfText != nil {
+				text += block.OfText.Text
+			}
+		}
+		if text != "" {
+			return text
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"agent/tools"
+)
+
+// SessionChanges returns the net change to every file modified since the session started
+func (a *Agent) SessionChanges() []FileChange {
+	return a.ChangesSince(0)
+}
+
+// RevertSession restores every file changed this session to its pre-session state.
+// Files created by the agent are removed. It returns the changes that were reverted;
+// files whose original content was too large to snapshot are skipped.
+func (a *Agent) RevertSession() ([]FileChange, error) {
+	return a.revertChanges(a.SessionChanges())
+}
+
+// revertChanges restores each changed file to its state before the change,
+// skipping files whose original content wasn't snapshotted
+func (a *Agent) revertChanges(changes []FileChange) ([]FileChange, error) {
+	reverted := []FileChange{}
+
+	for _, change := range changes {
+		if !change.Restorable {
+			continue
+		}
+
+		unlock, err := tools.LockFile(change.AbsPath)
+		if err != nil {
+			return reverted, err
+		}
+
+		before := readSnapshot(change.AbsPath)
+
+		switch change.Status {
+		case ChangeCreated:
+			err = os.Remove(change.AbsPath)
+		default:
+			if err = os.MkdirAll(filepath.Dir(change.AbsPath), 0755); err == nil {
+				err = os.WriteFile(change.AbsPath, []byte(change.Before), 0644)
+			}
+		}
+		unlock()
+		if err != nil {
+			return reverted, fmt.Errorf("failed to revert %s: %w", change.Path, err)
+		}
+
+		// Record the revert so the session's net changes reflect the restored state
+		after := readSnapshot(change.AbsPath)
+		a.appendActivity(FileActivity{
+			Path:           change.Path,
+			AbsPath:        change.AbsPath,
+			Tool:           "revert",
+			Time:           time.Now(),
+			Before:         before.content,
+			After:          after.content,
+			ExistedBefore:  before.exists,
+			ExistsAfter:    after.exists,
+			BeforeComplete: before.complete,
+		})
+
+		reverted = append(reverted, change)
+	}
+
+	return reverted, nil
+}
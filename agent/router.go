@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"strings"
+
+	"agent/config"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Models the router uses when the settings don't name its own
+const (
+	DefaultLightModel = anthropic.ModelClaude3_5HaikuLatest
+	DefaultHeavyModel = anthropic.ModelClaudeOpus4_0
+)
+
+// lightMaxChars is the longest message that counts as a short question
+const lightMaxChars = 200
+
+// heavyDiffLines is the number of changed lines from which a pasted diff
+// goes to the heavy model
+const heavyDiffLines = 150
+
+// escalateRounds is the number of tool rounds after which a turn on the
+// light model moves up to the profile's model
+const escalateRounds = 3
+
+// heavyHints ask for more effort than the profile's model gives
+var heavyHints = []string{"think harder", "think hard", "think deeply", "ultrathink"}
+
+// heavyWords mark work that spans a codebase, such as refactors
+var heavyWords = []string{"refactor", "rewrite", "redesign", "restructure", "migrate", "architecture"}
+
+// questionWords start a question rather than a request for changes
+var questionWords = []string{"what", "why", "how", "when", "where", "which", "who", "is", "are", "can", "could", "does", "do", "should", "explain"}
+
+// actionWords ask for changes, which the light model isn't trusted with
+var actionWords = []string{"fix", "add", "change", "implement", "write", "create", "edit", "update", "remove", "delete", "rename", "build", "run", "make", "move", "replace"}
+
+// route is the model the router picked for a turn and why. An empty model
+// means the profile's model.
+type route struct {
+	model  anthropic.Model
+	reason string
+	light  bool
+}
+
+// SetModelRouter turns the model router on with the given models, or off
+// when router is nil. While it is on, each turn goes to the light model, the
+// profile's model or the heavy one depending on what the message asks for.
+func (a *Agent) SetModelRouter(router *config.ModelRouter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.router = router
+}
+
+// ModelRouter returns the router's settings, or nil when it is off
+func (a *Agent) ModelRouter() *config.ModelRouter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.router
+}
+
+// routeTurn picks the model for a turn from the user's last message, unless
+// the message chose its own
+func (a *Agent) routeTurn(session *Session, options TurnOptions) route {
+	if options.Model != "" {
+		return route{model: options.Model, reason: "chosen for this message"}
+	}
+
+	a.mu.Lock()
+	router := a.router
+	a.mu.Unlock()
+
+	if router == nil {
+		return route{}
+	}
+
+	message, ok := session.lastUserText()
+	if !ok {
+		return route{reason: "continuing"}
+	}
+
+	light, heavy := DefaultLightModel, DefaultHeavyModel
+	if router.Light != "" {
+		light = anthropic.Model(router.Light)
+	}
+	if router.Heavy != "" {
+		heavy = anthropic.Model(router.Heavy)
+	}
+
+	lower := strings.ToLower(message)
+	switch {
+	case containsAny(lower, heavyHints):
+		return route{model: heavy, reason: "asked to think harder"}
+	case diffLines(message) >= heavyDiffLines:
+		return route{model: heavy, reason: "large diff"}
+	case containsWord(lower, heavyWords):
+		return route{model: heavy, reason: "refactor"}
+	case isShortQuestion(lower) && options.ThinkingBudget == 0:
+		return route{model: light, reason: "short question", light: true}
+	}
+
+	return route{reason: "default"}
+}
+
+// escalate moves a turn off the light model once it turns into tool-heavy
+// work: a change to the workspace, or many rounds of tool calls
+func (a *Agent) escalate(current route, rounds int, calls []string) route {
+	if !current.light {
+		return current
+	}
+
+	busy := rounds >= escalateRounds
+	for _, name := range calls {
+		if !a.ToolReadOnly(name) {
+			busy = true
+		}
+	}
+	if !busy {
+		return current
+	}
+	return route{reason: "tool-heavy work"}
+}
+
+// setRouted makes requests use the routed model until the turn ends
+func (a *Agent) setRouted(model anthropic.Model) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.routed = model
+}
+
+// turnModel returns the model for the next request of the running turn
+func (a *Agent) turnModel() anthropic.Model {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.requestModel()
+}
+
+// requestModel returns the model for the next request: the routed one during
+// a routed turn, or else the profile's. Callers must hold the lock.
+func (a *Agent) requestModel() anthropic.Model {
+	if a.routed != "" {
+		return a.routed
+	}
+	return a.activeModel()
+}
+
+// isShortQuestion reports whether a message is a short question that asks for
+// no changes, e.g. "what does parseArgs return?"
+func isShortQuestion(message string) bool {
+	message = strings.TrimSpace(message)
+	if len(message) > lightMaxChars || strings.Contains(message, "```") || strings.Contains(message, "\n") {
+		return false
+	}
+	if containsWord(message, actionWords) {
+		return false
+	}
+
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return false
+	}
+	return strings.HasSuffix(message, "?") || containsWord(fields[0], questionWords)
+}
+
+// diffLines counts the added and removed lines of a unified diff pasted into a message
+func diffLines(message string) int {
+	if !strings.Contains(message, "\n@@ ") && !strings.HasPrefix(message, "diff --git") && !strings.Contains(message, "\ndiff --git") {
+		return 0
+	}
+
+	count := 0
+	for _, line := range strings.Split(message, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			count++
+		}
+	}
+	return count
+}
+
+// containsAny reports whether text contains any of the phrases
+func containsAny(text string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(text, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWord reports whether text has any of the words, or a word starting
+// with one, e.g. "refactoring" for "refactor"
+func containsWord(text string, words []string) bool {
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool {
+		return !('a' <= r && r <= 'z')
+	}) {
+		for _, word := range words {
+			if field == word || (len(word) > 3 && strings.HasPrefix(field, word)) {
+				return true
+			}
+		}
+	}
+	return false
+}
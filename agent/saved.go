@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"agent/config"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// maxSavedSessions is how many saved conversations are kept; older ones are
+// deleted as new ones are saved
+const maxSavedSessions = 50
+
+// SavedSession is a conversation written to disk, e.g. when cli-agent
+// crashed or was stopped, so it can be resumed with --resume
+type SavedSession struct {
+	// Path is the file the conversation was read from, when listed by RecentSessions
+	Path string `json:"-"`
+
+	Dir      string                   `json:"dir"`
+	Saved    time.Time                `json:"saved"`
+	Messages []anthropic.MessageParam `json:"messages"`
+}
+
+// SessionsDir returns where conversations are saved, e.g.
+// ~/.config/cli-agent/sessions
+func SessionsDir() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions"), nil
+}
+
+// SaveSession writes the session's conversation, held in the workspace dir,
+// to a new file in SessionsDir and returns its path. An empty conversation
+// isn't saved, and its path is "".
+func SaveSession(session *Session, dir string) (string, error) {
+	messages := session.Messages()
+	if len(messages) == 0 {
+		return "", nil
+	}
+
+	sessionsDir, err := SessionsDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(sessionsDir, 0700); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	data, err := json.Marshal(SavedSession{Dir: dir, Saved: now, Messages: messages})
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.CreateTemp(sessionsDir, now.Format("20060102-150405")+"-*.json")
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	pruneSavedSessions(sessionsDir)
+	return file.Name(), nil
+}
+
+// RecentSessions returns up to limit saved conversations, newest first,
+// without their messages. Files that can't be read are skipped.
+func RecentSessions(limit int) []SavedSession {
+	dir, err := SessionsDir()
+	if err != nil {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil
+	}
+
+	// Names start with the time they were saved
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+
+	sessions := []SavedSession{}
+	for _, path := range matches {
+		if len(sessions) == limit {
+			break
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var header struct {
+			Dir   string    `json:"dir"`
+			Saved time.Time `json:"saved"`
+		}
+		if json.Unmarshal(data, &header) != nil {
+			continue
+		}
+		sessions = append(sessions, SavedSession{Path: path, Dir: header.Dir, Saved: header.Saved})
+	}
+	return sessions
+}
+
+// pruneSavedSessions deletes the oldest saved conversations beyond
+// maxSavedSessions. Their names start with the time they were saved.
+func pruneSavedSessions(dir string) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil || len(matches) <= maxSavedSessions {
+		return
+	}
+
+	sort.Strings(matches)
+	for _, path := range matches[:len(matches)-maxSavedSessions] {
+		os.Remove(path)
+	}
+}
+
+// LoadSession reads a saved conversation and the workspace it was held in.
+// A last response whose tool calls never got their results is dropped, as
+// the API wouldn't accept the conversation with it.
+func LoadSession(path string) (*Session, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	saved := SavedSession{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, "", fmt.Errorf("%s is not a saved session: %w", path, err)
+	}
+
+	messages := saved.Messages
+	for len(messages) > 0 && hasToolUse(messages[len(messages)-1]) {
+		messages = messages[:len(messages)-1]
+	}
+
+	session := NewSession()
+	session.Replace(messages)
+	return session, saved.Dir, nil
+}
+
+// hasToolUse reports whether a message calls tools
+func hasToolUse(message anthropic.MessageParam) bool {
+	if message.Role != anthropic.MessageParamRoleAssistant {
+		return false
+	}
+	for _, block := range message.Content {
+		if block.OfToolUse != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Session holds a conversation and serializes access to it, so the goroutine
+// running a turn and the UI can safely share it
+type Session struct {
+	mu           sync.Mutex
+	conversation []anthropic.MessageParam
+
+	// reads remembers the files whose contents are in the conversation, by read_file cache key
+	reads map[string]readStamp
+}
+
+// NewSession creates an empty session
+func NewSession() *Session {
+	return &Session{
+		conversation: []anthropic.MessageParam{},
+	}
+}
+
+// Append adds messages to the end of the conversation
+func (s *Session) Append(messages ...anthropic.MessageParam) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversation = append(s.conversation, messages...)
+}
+
+// Messages returns a snapshot of the conversation
+func (s *Session) Messages() []anthropic.MessageParam {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]anthropic.MessageParam(nil), s.conversation...)
+}
+
+// Replace swaps the conversation for a rewritten copy, e.g. after compaction
+func (s *Session) Replace(messages []anthropic.MessageParam) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversation = append([]anthropic.MessageParam(nil), messages...)
+
+	// Rewritten conversations may have dropped earlier read results
+	s.reads = nil
+}
+
+// Len returns the number of messages in the conversation
+func (s *Session) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.conversation)
+}
+
+// Reset clears the conversation
+func (s *Session) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.conversation = []anthropic.MessageParam{}
+	s.reads = nil
+}
+
+// unchangedRead reports whether the conversation already holds the result of
+// the read_file call identified by key for this version of the file
+func (s *Session) unchangedRead(key string, stamp readStamp) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached, ok := s.reads[key]
+	return ok && cached == stamp
+}
+
+// rememberRead records that the conversation holds a read_file result
+func (s *Session) rememberRead(key string, stamp readStamp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reads == nil {
+		s.reads = map[string]readStamp{}
+	}
+	s.reads[key] = stamp
+}
+
+// last returns the final message of the conversation
+func (s *Session) last() (anthropic.MessageParam, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conversation) == 0 {
+		return anthropic.MessageParam{}, false
+	}
+
+	message := s.conversation[len(s.conversation)-1]
+	message.Content = append([]anthropic.ContentBlockParamUnion(nil), message.Content...)
+	return message, true
+}
+
+// lastUserText returns what the user wrote in the final message, and false
+// when the final message isn't one the user wrote, e.g. tool results
+func (s *Session) lastUserText() (string, bool) {
+	message, ok := s.last()
+	if !ok || message.Role != anthropic.MessageParamRoleUser {
+		return "", false
+	}
+
+	for _, block := range message.Content {
+		if block.OfToolResult != nil {
+			return "", false
+		}
+	}
+
+	// Notes about outside changes come first; the user's text is last
+	if n := len(message.Content); n > 0 && message.Content[n-1].OfText != nil {
+		return message.Content[n-1].OfText.Text, true
+	}
+	return "", false
+}
+
+// setLast replaces the final message of the conversation
+func (s *Session) setLast(message anthropic.MessageParam) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conversation) > 0 {
+		s.conversation[len(s.conversation)-1] = message
+	}
+}
+
+// dropLast removes the final message of the conversation
+func (s *Session) dropLast() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conversation) > 0 {
+		s.conversation = s.conversation[:len(s.conversation)-1]
+	}
+}
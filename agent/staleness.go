@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"agent/diff"
+	"agent/tools"
+)
+
+// maxStaleDiffLines caps the diff included when an edit is refused
+const maxStaleDiffLines = 80
+
+// seenVersion is a file's content as the agent last saw it, either by reading
+// it or by writing it
+type seenVersion struct {
+	hash    [sha256.Size]byte
+	content string
+}
+
+// rememberVersion records the content the agent has seen for a file
+func (a *Agent) rememberVersion(absPath string, snapshot fileSnapshot) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.seen == nil {
+		a.seen = map[string]seenVersion{}
+	}
+
+	// Large files can't be compared, and deleted files have nothing to protect
+	if !snapshot.exists || !snapshot.complete {
+		delete(a.seen, absPath)
+		return
+	}
+
+	a.seen[absPath] = seenVersion{hash: sha256.Sum256([]byte(snapshot.content)), content: snapshot.content}
+}
+
+// checkExternalChange refuses an in-place edit when the file changed on disk
+// since the agent last read or wrote it, e.g. because the user edited it in
+// their editor. Files the agent hasn't seen yet, and pinned files, are not checked.
+func (a *Agent) checkExternalChange(tool tools.ToolDefinition, path, absPath string, current fileSnapshot) error {
+	if !tool.EditsInPlace || !current.exists || !current.complete {
+		return nil
+	}
+
+	a.mu.Lock()
+	seen, ok := a.seen[absPath]
+	a.mu.Unlock()
+
+	if !ok || seen.hash == sha256.Sum256([]byte(current.content)) {
+		return nil
+	}
+
+	// Pinned files are re-sent with every request, so the model already has the latest content
+	for _, pinned := range a.PinnedFiles() {
+		if pinned == path {
+			return nil
+		}
+	}
+
+	changes := diff.Unified(path+" (last read)", path+" (on disk)", seen.content, current.content, 2)
+	if lines := strings.Split(changes, "\n"); len(lines) > maxStaleDiffLines {
+		changes = strings.Join(lines[:maxStaleDiffLines], "\n") + fmt.Sprintf("\n... %d more lines", len(lines)-maxStaleDiffLines)
+	}
+
+	return fmt.Errorf("%s was changed outside this session since you last read it, so the edit was not applied. Read the file again with read_file before editing it. Changes:\n%s", path, changes)
+}
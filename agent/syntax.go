@@ -0,0 +1,173 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// syntaxCheckTimeout bounds an external syntax checker such as node
+const syntaxCheckTimeout = 10 * time.Second
+
+// syntaxChecker reports the syntax errors in a file's content, or "" when it
+// parses. name is the file's path, used in messages. ok is false when the
+// checker can't run, e.g. because the interpreter isn't installed.
+type syntaxChecker func(name string, content []byte) (problems string, ok bool)
+
+// syntaxCheckers maps file extensions to their checkers
+var syntaxCheckers = map[string]syntaxChecker{
+	".go":   checkGoSyntax,
+	".json": checkJSONSyntax,
+	".js":   externalChecker(".js", "node", "--check"),
+	".mjs":  externalChecker(".mjs", "node", "--check"),
+	".cjs":  externalChecker(".cjs", "node", "--check"),
+	".py": externalChecker(".py", "python3", "-c",
+		"import sys; compile(open(sys.argv[1], 'rb').read(), sys.argv[1], 'exec')"),
+}
+
+// syntaxCheckEnabled reports whether the project asks for syntax checks after edits
+func (a *Agent) syntaxCheckEnabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.projectConfig.SyntaxCheck
+}
+
+// checkEditSyntax checks a file a tool just wrote and returns an error
+// describing syntax errors the write introduced. Errors the file already had
+// before the write are not blamed on it.
+func (a *Agent) checkEditSyntax(path, absPath string, before fileSnapshot) error {
+	if path == "" || absPath == "" || !a.syntaxCheckEnabled() {
+		return nil
+	}
+
+	checker, ok := syntaxCheckers[strings.ToLower(filepath.Ext(absPath))]
+	if !ok {
+		return nil
+	}
+
+	after := readSnapshot(absPath)
+	if !after.exists || !after.complete {
+		return nil
+	}
+
+	problems, ok := checker(path, []byte(after.content))
+	if !ok || problems == "" {
+		return nil
+	}
+
+	if before.exists && before.complete {
+		if existing, ok := checker(path, []byte(before.content)); ok && existing != "" {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the change to %s was saved, but the file no longer parses:\n%s\nFix the syntax before continuing", path, strings.TrimSpace(problems))
+}
+
+// checkGoSyntax parses Go source in process
+func checkGoSyntax(name string, content []byte) (string, bool) {
+	_, err := parser.ParseFile(token.NewFileSet(), name, content, parser.AllErrors)
+	if err == nil {
+		return "", true
+	}
+
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return err.Error(), true
+	}
+
+	lines := []string{}
+	for i, e := range list {
+		if i == 10 {
+			lines = append(lines, fmt.Sprintf("(and %d more errors)", len(list)-i))
+			break
+		}
+		lines = append(lines, e.Error())
+	}
+	return strings.Join(lines, "\n"), true
+}
+
+// checkJSONSyntax validates a JSON document, reporting the line of the first error
+func checkJSONSyntax(name string, content []byte) (string, bool) {
+	var value any
+	err := json.Unmarshal(content, &value)
+	if err == nil {
+		return "", true
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		line := bytes.Count(content[:syntaxErr.Offset], []byte("\n")) + 1
+		return fmt.Sprintf("%s:%d: %s", name, line, syntaxErr), true
+	}
+	return fmt.Sprintf("%s: %s", name, err), true
+}
+
+// externalChecker runs a syntax-checking command on a copy of the content,
+// appending the copy's path to args. A missing command skips the check.
+func externalChecker(ext, command string, args ...string) syntaxChecker {
+	return func(name string, content []byte) (string, bool) {
+		binary, err := exec.LookPath(command)
+		if err != nil {
+			return "", false
+		}
+
+		scratch, err := os.CreateTemp("", "cli-agent-syntax-*"+ext)
+		if err != nil {
+			return "", false
+		}
+		defer os.Remove(scratch.Name())
+
+		_, err = scratch.Write(content)
+		scratch.Close()
+		if err != nil {
+			return "", false
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), syntaxCheckTimeout)
+		defer cancel()
+
+		output, err := exec.CommandContext(ctx, binary, append(args, scratch.Name())...).CombinedOutput()
+		if ctx.Err() != nil {
+			return "", false
+		}
+
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", err == nil
+		}
+
+		// Point the messages at the real file rather than the copy
+		return checkerMessages(strings.ReplaceAll(string(output), scratch.Name(), name)), true
+	}
+}
+
+// checkerMessages drops the interpreter's own stack frames from checker
+// output, which only point into the checker itself
+func checkerMessages(output string) string {
+	kept := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "Traceback (most recent call last):",
+			strings.HasPrefix(trimmed, `File "<string>"`),
+			strings.HasPrefix(trimmed, "at ") && strings.HasSuffix(trimmed, ")"),
+			strings.HasPrefix(trimmed, "Node.js v"):
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,171 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Extended thinking budgets, in tokens, of the !think and !deep modifiers.
+// The API takes no less than MinThinkingBudget.
+const (
+	MinThinkingBudget  = 1024
+	ThinkingBudget     = 4000
+	DeepThinkingBudget = 16000
+)
+
+// thinkingMaxTokens is the room left for the answer after the thinking budget
+const thinkingMaxTokens = 4096
+
+// outputLimits are the output token limits of models by name prefix, most
+// specific first. Models not listed are assumed to have defaultOutputLimit.
+var outputLimits = []struct {
+	prefix string
+	tokens int64
+}{
+	{"claude-opus-4-0", 32000},
+	{"claude-opus-4-1", 32000},
+	{"claude-opus-4-2", 32000},
+	{"claude-opus-4", 64000},
+	{"claude-sonnet-4", 64000},
+	{"claude-haiku-4", 64000},
+	{"claude-3-7-sonnet", 64000},
+}
+
+// defaultOutputLimit is assumed for models outputLimits doesn't know
+const defaultOutputLimit = 32000
+
+// maxOutputLimit is the largest output limit of any model, which bounds a
+// budget before the model answering the turn is known
+const maxOutputLimit = 64000
+
+// noThinkingModels are model prefixes without extended thinking
+var noThinkingModels = []string{"claude-3-haiku", "claude-3-opus", "claude-3-sonnet", "claude-3-5-"}
+
+// TurnOptions adjust a single turn without changing the session's defaults
+type TurnOptions struct {
+	// ThinkingBudget turns on extended thinking with this many tokens
+	ThinkingBudget int64
+	// Model answers the turn instead of the session's model or the router's pick
+	Model anthropic.Model
+}
+
+// ParseTurnModifiers takes the modifiers off the start of a message:
+// "!think" thinks before answering, "!think 10000" or "!think 10k" with that
+// budget, "!deep" with a large one, and "!model <name>" uses that model.
+// Anything else ends the modifiers, so the rest is returned as the message.
+func ParseTurnModifiers(input string) (TurnOptions, string, error) {
+	options := TurnOptions{}
+	rest := strings.TrimSpace(input)
+
+	for {
+		word, after := cutWord(rest)
+
+		switch word {
+		case "!deep":
+			options.ThinkingBudget = DeepThinkingBudget
+		case "!think":
+			options.ThinkingBudget = ThinkingBudget
+			budget, remaining := cutWord(after)
+			if tokens, ok := parseTokens(budget); ok {
+				if tokens < MinThinkingBudget {
+					return options, input, fmt.Errorf("the thinking budget must be at least %d tokens", MinThinkingBudget)
+				}
+				if tokens >= maxOutputLimit {
+					return options, input, fmt.Errorf("the thinking budget must be below %d tokens", maxOutputLimit)
+				}
+				options.ThinkingBudget = tokens
+				after = remaining
+			}
+		case "!model":
+			name, remaining := cutWord(after)
+			if name == "" {
+				return options, input, errors.New("!model needs a model name, e.g. !model claude-opus-4-0")
+			}
+			options.Model = anthropic.Model(name)
+			after = remaining
+		default:
+			if rest == "" && options != (TurnOptions{}) {
+				return options, input, errors.New("add a message after the modifiers")
+			}
+			return options, rest, nil
+		}
+
+		rest = after
+	}
+}
+
+// cutWord splits off the first word of text, which must not start with space
+func cutWord(text string) (string, string) {
+	end := strings.IndexFunc(text, unicode.IsSpace)
+	if end < 0 {
+		return text, ""
+	}
+	return text[:end], strings.TrimSpace(text[end:])
+}
+
+// parseTokens parses a token count such as "10000" or "10k". Counts too
+// large for an int64 are returned as math.MaxInt64, for callers to reject.
+func parseTokens(value string) (int64, bool) {
+	multiplier := int64(1)
+	if number, ok := strings.CutSuffix(strings.ToLower(value), "k"); ok {
+		value, multiplier = number, 1000
+	}
+
+	tokens, err := strconv.ParseInt(value, 10, 64)
+	if errors.Is(err, strconv.ErrRange) && !strings.HasPrefix(value, "-") {
+		return math.MaxInt64, true
+	}
+	if err != nil || tokens <= 0 {
+		return 0, false
+	}
+	if tokens > math.MaxInt64/multiplier {
+		return math.MaxInt64, true
+	}
+	return tokens * multiplier, true
+}
+
+// outputLimit returns the most tokens a model can output in one response
+func outputLimit(model anthropic.Model) int64 {
+	for _, limit := range outputLimits {
+		if strings.HasPrefix(string(model), limit.prefix) {
+			return limit.tokens
+		}
+	}
+	return defaultOutputLimit
+}
+
+// checkThinkingBudget refuses a budget that leaves the model no room to
+// answer within its output limit
+func checkThinkingBudget(model anthropic.Model, budget int64) error {
+	limit := outputLimit(model)
+	if budget > limit-thinkingMaxTokens {
+		return fmt.Errorf("a thinking budget of %d tokens leaves no room for the answer within the %d output tokens of %s; use at most %d", budget, limit, model, limit-thinkingMaxTokens)
+	}
+	return nil
+}
+
+// supportsThinking reports whether a model can think before answering.
+// Models the agent doesn't know are assumed to.
+func supportsThinking(model anthropic.Model) bool {
+	for _, prefix := range noThinkingModels {
+		if strings.HasPrefix(string(model), prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// setThinking turns extended thinking on for the running turn's requests, or
+// off with a zero budget
+func (a *Agent) setThinking(budget int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.thinking = budget
+}
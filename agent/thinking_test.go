@@ -0,0 +1,51 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTurnModifiersThinkingBudget(t *testing.T) {
+	tests := []struct {
+		input  string
+		budget int64
+		err    string
+	}{
+		{input: "!think why?", budget: ThinkingBudget},
+		{input: "!think 10k why?", budget: 10000},
+		{input: "!deep why?", budget: DeepThinkingBudget},
+		{input: "!think 100 why?", err: "at least"},
+		{input: "!think 64000 why?", err: "below"},
+		{input: "!think 9223372036854775807k why?", err: "below"},
+		{input: "!think 99999999999999999999 why?", err: "below"},
+	}
+
+	for _, test := range tests {
+		options, _, err := ParseTurnModifiers(test.input)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q: error = %v, want one mentioning %q", test.input, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.input, err)
+			continue
+		}
+		if options.ThinkingBudget != test.budget {
+			t.Errorf("%q: budget = %d, want %d", test.input, options.ThinkingBudget, test.budget)
+		}
+	}
+}
+
+func TestCheckThinkingBudget(t *testing.T) {
+	if err := checkThinkingBudget("claude-sonnet-4-20250514", 50000); err != nil {
+		t.Errorf("a budget within the output limit was refused: %v", err)
+	}
+	if err := checkThinkingBudget("claude-opus-4-1-20250805", 50000); err == nil {
+		t.Error("a budget above the output limit of Opus 4.1 was accepted")
+	}
+	if err := checkThinkingBudget("claude-sonnet-4-20250514", 64000-thinkingMaxTokens+1); err == nil {
+		t.Error("a budget leaving no room for the answer was accepted")
+	}
+}
@@ -0,0 +1,129 @@
+package agent
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Tool choices besides a tool's name
+const (
+	ToolChoiceAuto = "auto"
+	ToolChoiceAny  = "any"
+	ToolChoiceNone = "none"
+)
+
+// SetToolChoice overrides the project's tool_choice: "auto" lets the model
+// decide, "any" makes it start each turn with a tool call, "none" keeps it
+// from calling tools, and a tool's name makes it start with that tool. An
+// empty choice goes back to the project config. A forced choice only applies
+// to the first response of a turn, so the turn can end once the tools ran.
+func (a *Agent) SetToolChoice(choice string) error {
+	if err := a.validToolChoice(choice); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.toolChoice = choice
+	return nil
+}
+
+// ToolChoice returns the tool choice of the session, or else of the project
+// config; empty means auto
+func (a *Agent) ToolChoice() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.activeToolChoice()
+}
+
+// SetStopSequences overrides the project's stop sequences, or goes back to
+// them when sequences is nil. An empty, non-nil list turns them off.
+func (a *Agent) SetStopSequences(sequences []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopSequences = slices.Clone(sequences)
+}
+
+// StopSequences returns the stop sequences of the session, or else of the
+// project config
+func (a *Agent) StopSequences() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return slices.Clone(a.requestStopSequences())
+}
+
+// validToolChoice returns an error unless choice is a known choice or the
+// name of a tool the project config allows
+func (a *Agent) validToolChoice(choice string) error {
+	switch choice {
+	case "", ToolChoiceAuto, ToolChoiceAny, ToolChoiceNone:
+		return nil
+	}
+
+	for _, tool := range a.tools {
+		if tool.Name == choice {
+			if !a.toolEnabled(choice) {
+				return fmt.Errorf("tool_choice: the tool %s is disabled by the project config", choice)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("tool_choice: %q is not auto, any, none or the name of a tool", choice)
+}
+
+// startToolChoice checks the turn's tool choice and forces it on the turn's
+// first request. Extended thinking only works with auto and none.
+func (a *Agent) startToolChoice(thinking bool) error {
+	choice := a.ToolChoice()
+	if err := a.validToolChoice(choice); err != nil {
+		return err
+	}
+	if thinking && choice != "" && choice != ToolChoiceAuto && choice != ToolChoiceNone {
+		return fmt.Errorf("tool_choice %q can't be combined with extended thinking; use auto or none", choice)
+	}
+
+	a.setForceTools(true)
+	return nil
+}
+
+// setForceTools applies a forced tool choice to the next request, or stops
+// applying it once the turn's first response is in
+func (a *Agent) setForceTools(force bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.forceTools = force
+}
+
+// activeToolChoice returns the session's tool choice, or else the project's.
+// Callers must hold the lock.
+func (a *Agent) activeToolChoice() string {
+	if a.toolChoice != "" {
+		return a.toolChoice
+	}
+	return a.projectConfig.Request.ToolChoice
+}
+
+// requestToolChoice returns the tool choice of the next request: "none" for
+// every request, forced choices only for the first of a turn. Callers must
+// hold the lock.
+func (a *Agent) requestToolChoice() string {
+	choice := a.activeToolChoice()
+	if choice == ToolChoiceNone || a.forceTools {
+		return choice
+	}
+	return ""
+}
+
+// requestStopSequences returns the session's stop sequences, or else the
+// project's. Callers must hold the lock.
+func (a *Agent) requestStopSequences() []string {
+	if a.stopSequences != nil {
+		return a.stopSequences
+	}
+	return a.projectConfig.Request.StopSequences
+}
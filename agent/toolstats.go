@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// SlowToolCall is the duration from which a tool call counts as slow
+const SlowToolCall = 5 * time.Second
+
+// ToolStats summarizes the calls the model made to one tool in the session.
+// Repeats are calls identical to an earlier one in the same turn, which were
+// answered without running the tool again; they aren't timed.
+type ToolStats struct {
+	Name     string
+	Calls    int
+	Failures int
+	Repeats  int
+	Slow     int
+	Total    time.Duration
+	Median   time.Duration
+	P90      time.Duration
+	Max      time.Duration
+}
+
+// FailureRate returns the share of the calls that failed, from 0 to 1
+func (s ToolStats) FailureRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Calls)
+}
+
+// toolRecord collects the outcomes of one tool's calls
+type toolRecord struct {
+	durations []time.Duration
+	failures  int
+	repeats   int
+}
+
+// recordToolCall adds a call's outcome to the tool's statistics
+func (a *Agent) recordToolCall(name string, duration time.Duration, failed, repeated bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.toolRecords == nil {
+		a.toolRecords = map[string]*toolRecord{}
+	}
+	record, ok := a.toolRecords[name]
+	if !ok {
+		record = &toolRecord{}
+		a.toolRecords[name] = record
+	}
+
+	if failed {
+		record.failures++
+	}
+	if repeated {
+		record.repeats++
+		return
+	}
+	record.durations = append(record.durations, duration)
+}
+
+// ToolStats returns the statistics of every tool called in the session, the
+// most called first
+func (a *Agent) ToolStats() []ToolStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := make([]ToolStats, 0, len(a.toolRecords))
+	for name, record := range a.toolRecords {
+		durations := slices.Clone(record.durations)
+		slices.Sort(durations)
+
+		s := ToolStats{
+			Name:     name,
+			Calls:    len(durations) + record.repeats,
+			Failures: record.failures,
+			Repeats:  record.repeats,
+		}
+		for _, duration := range durations {
+			s.Total += duration
+			if duration >= SlowToolCall {
+				s.Slow++
+			}
+		}
+		if len(durations) > 0 {
+			s.Median = percentile(durations, 50)
+			s.P90 = percentile(durations, 90)
+			s.Max = durations[len(durations)-1]
+		}
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Calls != stats[j].Calls {
+			return stats[i].Calls > stats[j].Calls
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
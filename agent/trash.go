@@ -0,0 +1,42 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"agent/tools"
+)
+
+// RestoreTrash moves a file from the trash back to where it was. The restore
+// is recorded like a revert, and the model is told the file changed.
+func (a *Agent) RestoreTrash(item tools.TrashItem) error {
+	unlock, err := tools.LockFile(item.AbsPath)
+	if err != nil {
+		return err
+	}
+
+	before := readSnapshot(item.AbsPath)
+	err = a.workspace.RestoreTrash(item)
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	after := readSnapshot(item.AbsPath)
+	activity := FileActivity{
+		Path:           item.Path,
+		AbsPath:        item.AbsPath,
+		Tool:           "restore",
+		Time:           time.Now(),
+		Before:         before.content,
+		After:          after.content,
+		ExistedBefore:  before.exists,
+		ExistsAfter:    after.exists,
+		BeforeComplete: before.complete,
+	}
+	activity.ChangedFrom, activity.ChangedTo = changedLineRange(activity.Before, activity.After)
+	a.appendActivity(activity)
+
+	a.AddNote(fmt.Sprintf("The user restored %s from the trash, as it was at %s. Read it again before editing it.", item.Path, item.Time.Format("15:04:05")))
+	return nil
+}
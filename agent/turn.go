@@ -0,0 +1,282 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"agent/crash"
+	"agent/log"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// AgentEvent is emitted by RunTurn as a turn progresses. It is one of
+// TextDelta, ThinkingDelta, ToolCallStarted, ToolResult, Usage,
+// ResponseComplete, BudgetWarning, Notice, Offline, Online, Error or Done.
+type AgentEvent interface {
+	isAgentEvent()
+}
+
+// TextDelta carries a chunk of streamed assistant text
+type TextDelta struct {
+	Text string
+}
+
+// ThinkingDelta carries a chunk of streamed extended thinking
+type ThinkingDelta struct {
+	Text string
+}
+
+// ToolCallStarted is emitted before a tool is executed
+type ToolCallStarted struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+// ToolResult is emitted after a tool has executed
+type ToolResult struct {
+	ID       string
+	Name     string
+	Content  string
+	IsError  bool
+	Duration time.Duration
+}
+
+// Usage reports the token usage of one model response
+type Usage struct {
+	InputTokens              int64
+	OutputTokens             int64
+	CacheCreationInputTokens int64
+	CacheReadInputTokens     int64
+}
+
+// ResponseComplete follows the Usage of each model response, with how long it
+// took and why it stopped, e.g. "max_tokens" when the output was cut off.
+// Model is the model that answered; when the model router picked it,
+// RouteReason says why, e.g. "short question".
+type ResponseComplete struct {
+	Elapsed      time.Duration
+	OutputTokens int64
+	StopReason   string
+	Model        anthropic.Model
+	RouteReason  string
+}
+
+// Error reports a failure that ended the turn
+type Error struct {
+	Err error
+}
+
+// Done is the last event of a turn
+type Done struct {
+	StopReason string
+}
+
+func (TextDelta) isAgentEvent()        {}
+func (ThinkingDelta) isAgentEvent()    {}
+func (ToolCallStarted) isAgentEvent()  {}
+func (ToolResult) isAgentEvent()       {}
+func (Usage) isAgentEvent()            {}
+func (ResponseComplete) isAgentEvent() {}
+func (Error) isAgentEvent()            {}
+func (Done) isAgentEvent()             {}
+
+// RunTurn adds the user's input to the session and runs the model, executing
+// tool calls and feeding their results back until the model stops asking for
+// tools. Progress is reported on the returned channel, which is closed after
+// the final Done event.
+func (a *Agent) RunTurn(ctx context.Context, session *Session, userInput string) <-chan AgentEvent {
+	return a.RunTurnWith(ctx, session, userInput, TurnOptions{})
+}
+
+// RunTurnWith is RunTurn with options for this turn only, e.g. from the
+// modifiers of the user's message
+func (a *Agent) RunTurnWith(ctx context.Context, session *Session, userInput string, options TurnOptions) <-chan AgentEvent {
+	events := make(chan AgentEvent, 100)
+
+	if userInput != "" {
+		blocks := append(a.takeNotes(), anthropic.NewTextBlock(userInput))
+		session.Append(anthropic.NewUserMessage(blocks...))
+	}
+
+	go func() {
+		defer crash.Recover()
+		defer close(events)
+
+		stopReason, err := a.runToolLoop(ctx, session, options, events)
+		if err != nil {
+			log.Error("turn failed", "error", err)
+			events <- Error{Err: err}
+		}
+		events <- Done{StopReason: stopReason}
+	}()
+
+	return events
+}
+
+// runToolLoop runs inference rounds until a response contains no tool calls,
+// returning the stop reason of the final response
+func (a *Agent) runToolLoop(ctx context.Context, session *Session, options TurnOptions, events chan<- AgentEvent) (string, error) {
+	hasToolCalls := true
+	stopReason := ""
+
+	// A response cut off at max_tokens is continued: the partial response stays
+	// the final message and the next response is stitched onto it
+	continuing := false
+	continuations := 0
+	var requested time.Time
+	var outputTokens int64
+	calls := turnCalls{}
+
+	route := a.routeTurn(session, options)
+	a.setRouted(route.model)
+	defer a.setRouted("")
+	rounds := 0
+
+	if options.ThinkingBudget > 0 {
+		if model := a.turnModel(); !supportsThinking(model) {
+			return stopReason, fmt.Errorf("%s can't think before answering; pick another model for the message, e.g. !model %s", model, DefaultHeavyModel)
+		} else if err := checkThinkingBudget(model, options.ThinkingBudget); err != nil {
+			return stopReason, err
+		}
+		a.setThinking(options.ThinkingBudget)
+		defer a.setThinking(0)
+	}
+
+	if err := a.startToolChoice(options.ThinkingBudget > 0); err != nil {
+		return stopReason, err
+	}
+	defer a.setForceTools(false)
+
+	for hasToolCalls {
+		hasToolCalls = false // Reset flag
+
+		if err := a.checkBudget(events); err != nil {
+			return stopReason, err
+		}
+
+		if err := a.fitContext(ctx, session, events); err != nil {
+			return stopReason, err
+		}
+
+		if err := a.waitForRateLimit(ctx, events); err != nil {
+			return stopReason, err
+		}
+
+		if !continuing {
+			requested = time.Now()
+			outputTokens = 0
+		}
+		model := a.turnModel()
+		message, err := a.infer(ctx, session, events)
+		if err != nil {
+			return stopReason, err
+		}
+		a.setForceTools(false)
+
+		stopReason = string(message.StopReason)
+		usage := Usage{
+			InputTokens:              message.Usage.InputTokens,
+			OutputTokens:             message.Usage.OutputTokens,
+			CacheCreationInputTokens: message.Usage.CacheCreationInputTokens,
+			CacheReadInputTokens:     message.Usage.CacheReadInputTokens,
+		}
+		a.addUsage(usage, model)
+		events <- usage
+		a.checkSpendRate(events)
+		outputTokens += usage.OutputTokens
+
+		if continuing {
+			session.extendLast(message.ToParam())
+		} else {
+			session.Append(message.ToParam())
+		}
+		continuing = false
+
+		if message.StopReason == anthropic.StopReasonMaxTokens {
+			prefill, retry := a.continueTruncated(session, &continuations, events)
+			if prefill {
+				continuing = true
+				hasToolCalls = true
+				continue
+			}
+			if retry {
+				events <- ResponseComplete{Elapsed: time.Since(requested), OutputTokens: outputTokens, StopReason: stopReason, Model: model, RouteReason: route.reason}
+				hasToolCalls = true
+				continue
+			}
+		}
+
+		events <- ResponseComplete{Elapsed: time.Since(requested), OutputTokens: outputTokens, StopReason: stopReason, Model: model, RouteReason: route.reason}
+		log.Info("model response", "model", model, "route", route.reason, "stop_reason", stopReason, "input_tokens", usage.InputTokens, "output_tokens", outputTokens, "elapsed", time.Since(requested))
+
+		// handle tool call
+		toolResults := []anthropic.ContentBlockParamUnion{}
+		called := []string{}
+		for _, content := range message.Content {
+			switch content.Type {
+			case "tool_use":
+				// Continue the loop: we have tool calls
+				hasToolCalls = true
+
+				// Calls after a cancellation don't run, but still get a result
+				// so the conversation stays valid for the next turn
+				if ctx.Err() != nil {
+					toolResults = append(toolResults, anthropic.NewToolResultBlock(content.ID, "Not run: the turn was cancelled.", true))
+					continue
+				}
+
+				events <- ToolCallStarted{ID: content.ID, Name: content.Name, Input: content.Input}
+
+				started := time.Now()
+				call := ToolCall{ID: content.ID, Name: content.Name, Input: content.Input}
+				called = append(called, content.Name)
+				response, repeats, err := calls.repeated(call)
+				if repeats == 0 {
+					response, err = a.callTool(session, call)
+					a.rememberCall(calls, call, response, err)
+				} else if repeats == loopRepeats {
+					events <- Notice{Text: fmt.Sprintf("The agent keeps repeating the same %s call; it was told to try something else.", content.Name)}
+				}
+				result := ToolResult{ID: content.ID, Name: content.Name, Content: response, Duration: time.Since(started)}
+				if err != nil {
+					result.Content = err.Error()
+					result.IsError = true
+				} else {
+					result.Content = a.limitToolResult(ctx, session, content.Name, response, events)
+				}
+				a.recordToolCall(content.Name, result.Duration, result.IsError, repeats > 0)
+				if result.IsError {
+					log.Debug("tool call failed", "tool", content.Name, "duration", result.Duration, "repeated", repeats > 0, "error", result.Content)
+				} else {
+					log.Debug("tool call", "tool", content.Name, "duration", result.Duration, "repeated", repeats > 0)
+				}
+
+				// Only the model sees the hints; the user sees the plain error
+				modelContent := result.Content
+				if err != nil {
+					modelContent += a.hintSuffix(content.Name, content.Input, err)
+				}
+				toolResults = append(toolResults, anthropic.NewToolResultBlock(content.ID, modelContent, result.IsError))
+				events <- result
+			}
+		}
+
+		if hasToolCalls {
+			// Tool results must come first in the message; notes follow them
+			session.Append(anthropic.NewUserMessage(append(toolResults, a.takeNotes()...)...))
+			if err := ctx.Err(); err != nil {
+				return stopReason, err
+			}
+
+			rounds++
+			route = a.escalate(route, rounds, called)
+			a.setRouted(route.model)
+		}
+	}
+
+	return stopReason, nil
+}
@@ -0,0 +1,243 @@
+package agent_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent/agent"
+	"agent/provider/mock"
+	"agent/tools"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// newTestAgent creates an agent in a trusted scratch workspace, with the
+// user config pointed at an empty directory so local settings don't leak in
+func newTestAgent(t *testing.T, modelProvider *mock.Provider) (*agent.Agent, string) {
+	t.Helper()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	root := t.TempDir()
+	workspace, err := tools.NewWorkspace(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	agentApp := agent.NewAgent(modelProvider, tools.GetAllTools(), workspace)
+	agentApp.SetTrusted(true)
+	return agentApp, root
+}
+
+// turnResult collects the events of one turn
+type turnResult struct {
+	text    string
+	started []agent.ToolCallStarted
+	results []agent.ToolResult
+	err     error
+	done    *agent.Done
+}
+
+func runTurn(t *testing.T, agentApp *agent.Agent, session *agent.Session, prompt string) turnResult {
+	t.Helper()
+
+	result := turnResult{}
+	for event := range agentApp.RunTurn(context.Background(), session, prompt) {
+		switch event := event.(type) {
+		case agent.TextDelta:
+			result.text += event.Text
+		case agent.ToolCallStarted:
+			result.started = append(result.started, event)
+		case agent.ToolResult:
+			result.results = append(result.results, event)
+		case agent.Error:
+			result.err = event.Err
+		case agent.Done:
+			result.done = &event
+		}
+	}
+
+	if result.done == nil {
+		t.Fatal("turn ended without a Done event")
+	}
+	return result
+}
+
+// toolResultBlock returns the tool result the last request sent back for id
+func toolResultBlock(t *testing.T, modelProvider *mock.Provider, id string) anthropic.ToolResultBlockParam {
+	t.Helper()
+
+	request := modelProvider.Requests[len(modelProvider.Requests)-1]
+	last := request.Messages[len(request.Messages)-1]
+	for _, block := range last.Content {
+		if block.OfToolResult != nil && block.OfToolResult.ToolUseID == id {
+			return *block.OfToolResult
+		}
+	}
+
+	t.Fatalf("the last request has no tool result for %s", id)
+	return anthropic.ToolResultBlockParam{}
+}
+
+func toolResultText(block anthropic.ToolResultBlockParam) string {
+	var text strings.Builder
+	for _, content := range block.Content {
+		if content.OfText != nil {
+			text.WriteString(content.OfText.Text)
+		}
+	}
+	return text.String()
+}
+
+func TestRunTurnText(t *testing.T) {
+	modelProvider := mock.New(mock.Text("Hello!"))
+	agentApp, _ := newTestAgent(t, modelProvider)
+	session := agent.NewSession()
+
+	result := runTurn(t, agentApp, session, "Hi")
+
+	if result.err != nil {
+		t.Fatalf("unexpected error: %v", result.err)
+	}
+	if result.text != "Hello!" {
+		t.Errorf("text = %q, want %q", result.text, "Hello!")
+	}
+	if result.done.StopReason != "end_turn" {
+		t.Errorf("stop reason = %q, want end_turn", result.done.StopReason)
+	}
+	if len(result.started) != 0 {
+		t.Errorf("got %d tool calls, want none", len(result.started))
+	}
+	if got := len(session.Messages()); got != 2 {
+		t.Errorf("session has %d messages, want the prompt and the reply", got)
+	}
+	if modelProvider.Remaining() != 0 {
+		t.Errorf("%d responses weren't requested", modelProvider.Remaining())
+	}
+}
+
+func TestRunTurnToolRoundTrip(t *testing.T) {
+	modelProvider, err := mock.Load(filepath.Join("testdata", "read_file_round_trip.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	agentApp, root := newTestAgent(t, modelProvider)
+	if err := os.WriteFile(filepath.Join(root, "notes.txt"), []byte("water the plants\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := runTurn(t, agentApp, agent.NewSession(), "What do my notes say?")
+
+	if result.err != nil {
+		t.Fatalf("unexpected error: %v", result.err)
+	}
+	if len(result.started) != 1 || result.started[0].Name != "read_file" || result.started[0].ID != "toolu_01" {
+		t.Fatalf("tool calls = %+v, want one read_file call", result.started)
+	}
+	if len(result.results) != 1 || result.results[0].IsError {
+		t.Fatalf("tool results = %+v, want one successful result", result.results)
+	}
+	if !strings.Contains(result.results[0].Content, "water the plants") {
+		t.Errorf("tool result %q doesn't contain the file", result.results[0].Content)
+	}
+	if result.text != "The notes say to water the plants." {
+		t.Errorf("text = %q", result.text)
+	}
+
+	// The result goes back to the model in the second request
+	if got := len(modelProvider.Requests); got != 2 {
+		t.Fatalf("made %d requests, want 2", got)
+	}
+	block := toolResultBlock(t, modelProvider, "toolu_01")
+	if block.IsError.Value {
+		t.Error("the tool result was sent as an error")
+	}
+	if !strings.Contains(toolResultText(block), "water the plants") {
+		t.Errorf("the tool result sent back doesn't contain the file: %q", toolResultText(block))
+	}
+}
+
+func TestRunTurnToolError(t *testing.T) {
+	modelProvider := mock.New(
+		mock.ToolUse("toolu_missing", "read_file", map[string]any{"path": "missing.txt"}),
+		mock.Text("That file doesn't exist."),
+	)
+	agentApp, _ := newTestAgent(t, modelProvider)
+
+	result := runTurn(t, agentApp, agent.NewSession(), "Read missing.txt")
+
+	// A failing tool is reported to the model, not treated as a failed turn
+	if result.err != nil {
+		t.Fatalf("unexpected turn error: %v", result.err)
+	}
+	if len(result.results) != 1 || !result.results[0].IsError {
+		t.Fatalf("tool results = %+v, want one error", result.results)
+	}
+	block := toolResultBlock(t, modelProvider, "toolu_missing")
+	if !block.IsError.Value {
+		t.Error("the failed tool result wasn't marked as an error for the model")
+	}
+	if result.text != "That file doesn't exist." {
+		t.Errorf("text = %q", result.text)
+	}
+}
+
+func TestRunTurnUnknownTool(t *testing.T) {
+	modelProvider := mock.New(
+		mock.ToolUse("toolu_unknown", "launch_rockets", map[string]any{}),
+		mock.Text("Sorry."),
+	)
+	agentApp, _ := newTestAgent(t, modelProvider)
+
+	result := runTurn(t, agentApp, agent.NewSession(), "Launch")
+
+	if result.err != nil {
+		t.Fatalf("unexpected turn error: %v", result.err)
+	}
+	if len(result.results) != 1 || !result.results[0].IsError {
+		t.Fatalf("tool results = %+v, want one error", result.results)
+	}
+	if !strings.Contains(toolResultText(toolResultBlock(t, modelProvider, "toolu_unknown")), "tool not found") {
+		t.Error("the model wasn't told the tool doesn't exist")
+	}
+}
+
+func TestRunTurnProviderError(t *testing.T) {
+	modelProvider := mock.New()
+	agentApp, _ := newTestAgent(t, modelProvider)
+
+	result := runTurn(t, agentApp, agent.NewSession(), "Hi")
+
+	if result.err == nil {
+		t.Fatal("expected the provider's error to end the turn")
+	}
+	if !strings.Contains(result.err.Error(), "no response recorded") {
+		t.Errorf("error = %v, want the provider's error", result.err)
+	}
+	if result.text != "" || len(result.started) != 0 {
+		t.Errorf("got output from a failed request: text %q, %d tool calls", result.text, len(result.started))
+	}
+}
+
+func TestRunTurnErrorAfterToolCall(t *testing.T) {
+	// The provider runs out of responses after the tool call, so the request
+	// carrying the tool result fails
+	modelProvider := mock.New(mock.ToolUse("toolu_01", "list_files", map[string]any{"path": "."}))
+	agentApp, _ := newTestAgent(t, modelProvider)
+
+	result := runTurn(t, agentApp, agent.NewSession(), "List the files")
+
+	if len(result.results) != 1 || result.results[0].IsError {
+		t.Fatalf("tool results = %+v, want one successful result", result.results)
+	}
+	if result.err == nil {
+		t.Fatal("expected the failed follow-up request to end the turn")
+	}
+	if got := len(modelProvider.Requests); got != 2 {
+		t.Errorf("made %d requests, want 2", got)
+	}
+}
@@ -0,0 +1,54 @@
+package agent
+
+import "github.com/anthropics/anthropic-sdk-go"
+
+// DefaultModel is the model used for inference
+const DefaultModel = anthropic.ModelClaude_3_Haiku_20240307
+
+// modelPrice is the price in dollars per million tokens
+type modelPrice struct {
+	Input      float64
+	Output     float64
+	CacheWrite float64
+	CacheRead  float64
+}
+
+// modelPrices lists published prices for the models the agent can use
+var modelPrices = map[anthropic.Model]modelPrice{
+	anthropic.ModelClaude_3_Haiku_20240307: {Input: 0.25, Output: 1.25, CacheWrite: 0.30, CacheRead: 0.03},
+	anthropic.ModelClaude3_5HaikuLatest:    {Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+	anthropic.ModelClaude3_7SonnetLatest:   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	anthropic.ModelClaudeSonnet4_0:         {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+	anthropic.ModelClaudeOpus4_0:           {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+}
+
+// Add returns the sum of two usages
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:              u.InputTokens + other.InputTokens,
+		OutputTokens:             u.OutputTokens + other.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens + other.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens + other.CacheReadInputTokens,
+	}
+}
+
+// TotalTokens returns all input and output tokens, including cached ones
+func (u Usage) TotalTokens() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// Cost returns the dollar cost of the usage for model, and false when the
+// model's price is unknown
+func (u Usage) Cost(model anthropic.Model) (float64, bool) {
+	price, ok := modelPrices[model]
+	if !ok {
+		return 0, false
+	}
+
+	cost := float64(u.InputTokens)*price.Input +
+		float64(u.OutputTokens)*price.Output +
+		float64(u.CacheCreationInputTokens)*price.CacheWrite +
+		float64(u.CacheReadInputTokens)*price.CacheRead
+
+	return cost / 1_000_000, true
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"agent/agent"
+	"agent/config"
+	"agent/log"
+)
+
+// callbackTimeout bounds how long an approval callback may take to decide,
+// e.g. while it asks a person; a request it doesn't answer in time is denied
+const callbackTimeout = 5 * time.Minute
+
+// SignatureHeader carries the HMAC-SHA256 of an approval callback's body,
+// keyed with the approval secret, as "sha256=<hex>"
+const SignatureHeader = "X-Cli-Agent-Signature"
+
+// Decision is what an approval callback answers
+type Decision struct {
+	Approved bool `json:"approved"`
+}
+
+// PolicyApprover returns the approver for an approval policy: one that
+// allows or denies every request, one that asks the callback at a URL, or
+// ask for the "ask" policy. A nil ask denies every request.
+func PolicyApprover(policy string, secret string, ask agent.Approver) agent.Approver {
+	switch policy {
+	case config.ApprovalAllow:
+		return func(agent.ApprovalRequest) bool { return true }
+	case config.ApprovalDeny:
+		return func(agent.ApprovalRequest) bool { return false }
+	case "", config.ApprovalAsk:
+		if ask == nil {
+			return func(agent.ApprovalRequest) bool { return false }
+		}
+		return ask
+	default:
+		return callbackApprover(policy, secret)
+	}
+}
+
+// callbackApprover POSTs each request to url as an approval_request event and
+// approves it when the response is {"approved": true}. When there is a
+// secret, the body is signed with it, so the callback can tell the request
+// comes from this agent. The secret is never sent itself, and neither is the
+// API token. Failures deny the request.
+func callbackApprover(url, secret string) agent.Approver {
+	client := &http.Client{Timeout: callbackTimeout}
+	var sent atomic.Int64
+
+	return func(request agent.ApprovalRequest) bool {
+		event := Event{
+			Type:   "approval_request",
+			ID:     fmt.Sprintf("approval-%d", sent.Add(1)),
+			Title:  request.Title,
+			Detail: request.Detail,
+			Name:   request.Tool,
+			Input:  request.Input,
+		}
+
+		decision, err := postApproval(client, url, secret, event)
+		if err != nil {
+			log.Warn("approval callback failed; denying", "request", request.Title, "error", err)
+			return false
+		}
+		return decision.Approved
+	}
+}
+
+// postApproval asks the callback at url to decide event
+func postApproval(client *http.Client, url, secret string, event Event) (Decision, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Decision{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Decision{}, fmt.Errorf("the callback answered %s", resp.Status)
+	}
+
+	var decision Decision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return Decision{}, fmt.Errorf("failed to parse the callback's decision: %w", err)
+	}
+	return decision, nil
+}
+
+// Sign returns the signature header value of a callback body, for callbacks to verify
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
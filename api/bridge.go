@@ -0,0 +1,539 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+
+	"agent/agent"
+	"agent/diff"
+)
+
+// JSON-RPC error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// maxFrameSize bounds the body of one message, so a bad Content-Length can't
+// make the bridge allocate without limit
+const maxFrameSize = 8 << 20
+
+// errFrameTooLarge is returned for a message over maxFrameSize, whose body
+// has been skipped so the next message can still be read
+var errFrameTooLarge = fmt.Errorf("message is larger than %d MB", maxFrameSize>>20)
+
+// rpcMessage is a JSON-RPC 2.0 request, notification or response
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a failed JSON-RPC request
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// invalidParams is the error for a request whose params don't fit its method
+func invalidParams(format string, args ...any) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// SessionParams names the session a request is about
+type SessionParams struct {
+	Session string `json:"session"`
+}
+
+// OpenResult is the result of session/open
+type OpenResult struct {
+	Session  string `json:"session"`
+	Messages int    `json:"messages"`
+}
+
+// SendParams are the params of session/send
+type SendParams struct {
+	Session string `json:"session"`
+	Text    string `json:"text"`
+}
+
+// DiffParams are the params of session/diff. Scope is "turn" for the changes
+// of the last turn, the default, "session" for all of the session's changes,
+// or "pending" for the changes dry-run mode is holding back. Path limits the
+// diff to one file.
+type DiffParams struct {
+	Session string `json:"session"`
+	Scope   string `json:"scope,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// FileDiff is a changed file in the result of session/diff. Before and After
+// are the whole contents, for editors that show diffs their own way.
+type FileDiff struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	Diff    string `json:"diff"`
+	Before  string `json:"before"`
+	After   string `json:"after"`
+}
+
+// DiffResult is the result of session/diff
+type DiffResult struct {
+	Files []FileDiff `json:"files"`
+}
+
+// SessionEvent is the params of a session/event notification
+type SessionEvent struct {
+	Session string `json:"session"`
+	Event   Event  `json:"event"`
+}
+
+// Bridge serves chats to an editor over JSON-RPC 2.0 on stdin and stdout,
+// framed with Content-Length headers like the Language Server Protocol, so
+// editor plugins can use the JSON-RPC client they already have.
+//
+// Clients open or continue sessions with session/open, start turns with
+// session/send, and get the turns' events as session/event notifications.
+// session/cancel stops the running turn, session/diff returns the changes
+// the agent made, and session/close ends a session. When the agent needs
+// approval, the bridge asks the client with an approval/request request,
+// which the client answers with {"approved": true} or {"approved": false}.
+type Bridge struct {
+	newAgent func() (*agent.Agent, error)
+
+	// approvals is the approval policy and approvalSecret the key its
+	// callback requests are signed with; by default the client is asked
+	approvals      string
+	approvalSecret string
+
+	writeMu sync.Mutex
+	out     io.Writer
+
+	mu          sync.Mutex
+	sessions    map[string]*bridgeSession
+	nextSession int
+}
+
+// bridgeSession is a chat opened over the bridge
+type bridgeSession struct {
+	*chat
+	stop context.CancelFunc
+
+	// turnStart marks where the last turn's file activity began
+	turnStart int
+}
+
+// NewBridge creates a bridge that starts each session with an agent made by newAgent
+func NewBridge(newAgent func() (*agent.Agent, error)) *Bridge {
+	return &Bridge{newAgent: newAgent, sessions: map[string]*bridgeSession{}}
+}
+
+// SetApprovalPolicy decides approval requests by policy instead of asking
+// the client, e.g. "deny" or a callback URL, whose requests are signed with
+// secret. It must be called before serving.
+func (b *Bridge) SetApprovalPolicy(policy, secret string) {
+	b.approvals, b.approvalSecret = policy, secret
+}
+
+// Serve reads requests from in and writes responses and notifications to out
+// until in is closed. The sessions' turns are stopped when it returns.
+func (b *Bridge) Serve(in io.Reader, out io.Writer) error {
+	b.out = out
+	defer b.closeAll()
+
+	reader := bufio.NewReader(in)
+	for {
+		body, err := readFrame(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, errFrameTooLarge) {
+			b.respond(nil, nil, &rpcError{Code: rpcInvalidRequest, Message: err.Error()})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var message rpcMessage
+		if err := json.Unmarshal(body, &message); err != nil {
+			b.respond(nil, nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+		b.handle(message)
+	}
+}
+
+// readFrame reads one message body, after its headers
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	headers, err := textproto.NewReader(reader).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(headers) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read message headers: %w", err)
+	}
+
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", headers.Get("Content-Length"))
+	}
+	if length > maxFrameSize {
+		if _, err := io.CopyN(io.Discard, reader, int64(length)); err != nil {
+			return nil, fmt.Errorf("failed to read message body: %w", err)
+		}
+		return nil, errFrameTooLarge
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	return body, nil
+}
+
+// handle answers a request, or takes in the client's response to one of ours
+func (b *Bridge) handle(message rpcMessage) {
+	if message.JSONRPC != "2.0" {
+		b.respond(message.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: `only "jsonrpc": "2.0" is supported`})
+		return
+	}
+	if message.Method == "" {
+		if message.ID != nil {
+			b.handleResponse(message)
+		}
+		return
+	}
+
+	var result any
+	var err error
+	switch message.Method {
+	case "session/open":
+		result, err = b.open(message.Params)
+	case "session/send":
+		result, err = b.send(message.Params)
+	case "session/cancel":
+		result, err = b.cancel(message.Params)
+	case "session/diff":
+		result, err = b.diff(message.Params)
+	case "session/close":
+		result, err = b.close(message.Params)
+	default:
+		err = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", message.Method)}
+	}
+
+	// Notifications get no response, not even when they fail
+	if message.ID == nil {
+		return
+	}
+	b.respond(message.ID, result, err)
+}
+
+// open starts a session, or continues the one named in the params
+func (b *Bridge) open(params json.RawMessage) (any, error) {
+	var p SessionParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	if p.Session != "" {
+		s, err := b.session(p.Session)
+		if err != nil {
+			return nil, err
+		}
+		return OpenResult{Session: p.Session, Messages: s.session.Len()}, nil
+	}
+
+	agentApp, err := b.newAgent()
+	if err != nil {
+		return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextSession++
+	id := fmt.Sprintf("session-%d", b.nextSession)
+
+	ctx, stop := context.WithCancel(context.Background())
+	s := &bridgeSession{stop: stop}
+	s.chat = newChat(ctx, agentApp, func(event Event) error {
+		if event.Type == "approval_request" {
+			return b.requestApproval(id, event)
+		}
+		return b.notify("session/event", SessionEvent{Session: id, Event: event})
+	})
+	agentApp.SetApprover(PolicyApprover(b.approvals, b.approvalSecret, s.approve))
+	b.sessions[id] = s
+
+	return OpenResult{Session: id}, nil
+}
+
+// send starts a turn in a session; its events follow as notifications
+func (b *Bridge) send(params json.RawMessage) (any, error) {
+	var p SendParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	s, err := b.session(p.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	marker := s.agent.ActivityCount()
+	if err := s.startTurn(p.Text); err != nil {
+		return nil, invalidParams("%v", err)
+	}
+
+	b.mu.Lock()
+	s.turnStart = marker
+	b.mu.Unlock()
+	return nil, nil
+}
+
+// cancel stops a session's running turn
+func (b *Bridge) cancel(params json.RawMessage) (any, error) {
+	var p SessionParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	s, err := b.session(p.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	s.chat.cancel()
+	return nil, nil
+}
+
+// diff returns the changes a session's agent made, as unified diffs
+func (b *Bridge) diff(params json.RawMessage) (any, error) {
+	var p DiffParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	s, err := b.session(p.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []agent.FileChange
+	switch p.Scope {
+	case "", "turn":
+		b.mu.Lock()
+		marker := s.turnStart
+		b.mu.Unlock()
+		changes = s.agent.ChangesSince(marker)
+	case "session":
+		changes = s.agent.SessionChanges()
+	case "pending":
+		changes = s.agent.PendingChanges()
+	default:
+		return nil, invalidParams("unknown scope %q; use turn, session or pending", p.Scope)
+	}
+
+	result := DiffResult{Files: []FileDiff{}}
+	for _, change := range changes {
+		if p.Path != "" && change.Path != p.Path {
+			continue
+		}
+		result.Files = append(result.Files, newFileDiff(change))
+	}
+	return result, nil
+}
+
+// newFileDiff describes a change with its unified diff
+func newFileDiff(change agent.FileChange) FileDiff {
+	fromName, toName := "a/"+change.Path, "b/"+change.Path
+	switch change.Status {
+	case agent.ChangeCreated:
+		fromName = "/dev/null"
+	case agent.ChangeDeleted:
+		toName = "/dev/null"
+	}
+
+	return FileDiff{
+		Path:    change.Path,
+		Status:  string(change.Status),
+		Added:   change.Added,
+		Removed: change.Removed,
+		Diff:    diff.Unified(fromName, toName, change.Before, change.After, 3),
+		Before:  change.Before,
+		After:   change.After,
+	}
+}
+
+// close ends a session, stopping its running turn
+func (b *Bridge) close(params json.RawMessage) (any, error) {
+	var p SessionParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	s, ok := b.sessions[p.Session]
+	delete(b.sessions, p.Session)
+	b.mu.Unlock()
+
+	if !ok {
+		return nil, invalidParams("no session %q", p.Session)
+	}
+	s.stop()
+	s.wait()
+	return nil, nil
+}
+
+// closeAll ends every session, once the client has gone
+func (b *Bridge) closeAll() {
+	b.mu.Lock()
+	sessions := b.sessions
+	b.sessions = map[string]*bridgeSession{}
+	b.mu.Unlock()
+
+	for _, s := range sessions {
+		s.stop()
+		s.wait()
+	}
+}
+
+// session returns the open session with the given ID
+func (b *Bridge) session(id string) (*bridgeSession, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.sessions[id]
+	if !ok {
+		return nil, invalidParams("no session %q; open one with session/open", id)
+	}
+	return s, nil
+}
+
+// decodeParams parses a request's params into v
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams("invalid params: %v", err)
+	}
+	return nil
+}
+
+// ApprovalParams are the params of an approval/request request: the
+// session that needs approval and what for
+type ApprovalParams struct {
+	Session string          `json:"session"`
+	Title   string          `json:"title"`
+	Detail  string          `json:"detail,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Input   json.RawMessage `json:"input,omitempty"`
+}
+
+// requestApproval asks the client to decide a session's approval request.
+// The request's ID names the session and the approval, so the response can
+// be passed on to it.
+func (b *Bridge) requestApproval(session string, event Event) error {
+	id, err := json.Marshal(session + "/" + event.ID)
+	if err != nil {
+		return err
+	}
+	params, err := json.Marshal(ApprovalParams{Session: session, Title: event.Title, Detail: event.Detail, Name: event.Name, Input: event.Input})
+	if err != nil {
+		return err
+	}
+	return b.write(rpcMessage{JSONRPC: "2.0", ID: id, Method: "approval/request", Params: params})
+}
+
+// handleResponse passes the client's answer to an approval request on to
+// the session waiting for it. An error response denies the request.
+func (b *Bridge) handleResponse(message rpcMessage) {
+	var id string
+	if err := json.Unmarshal(message.ID, &id); err != nil {
+		return
+	}
+	session, approval, ok := strings.Cut(id, "/")
+	if !ok {
+		return
+	}
+	s, err := b.session(session)
+	if err != nil {
+		return
+	}
+
+	var decision Decision
+	if message.Error == nil {
+		json.Unmarshal(message.Result, &decision)
+	}
+	s.answer(approval, decision.Approved)
+}
+
+// notify sends the client a notification
+func (b *Bridge) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return b.write(rpcMessage{JSONRPC: "2.0", Method: method, Params: data})
+}
+
+// respond answers a request with its result or error
+func (b *Bridge) respond(id json.RawMessage, result any, err error) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	response := rpcMessage{JSONRPC: "2.0", ID: id}
+
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		response.Error = rpcErr
+	} else {
+		data, marshalErr := json.Marshal(result)
+		if marshalErr != nil {
+			response.Error = &rpcError{Code: rpcInternalError, Message: marshalErr.Error()}
+		} else {
+			response.Result = data
+		}
+	}
+
+	b.write(response)
+}
+
+// write sends a message to the client with its Content-Length header
+func (b *Bridge) write(message rpcMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+
+	if _, err := fmt.Fprintf(b.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = b.out.Write(body)
+	return err
+}
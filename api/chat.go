@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"agent/agent"
+)
+
+// chat is a conversation with one client. Its turns' events and approval
+// requests go out through send; the transport feeds it the client's
+// messages, cancellations and approvals.
+type chat struct {
+	ctx     context.Context
+	agent   *agent.Agent
+	session *agent.Session
+	send    func(Event) error
+
+	turns sync.WaitGroup
+
+	// approvalRequests carries the agent's approval requests to the running
+	// turn's relay, so they reach the client in order with its events
+	approvalRequests chan Event
+
+	mu           sync.Mutex
+	cancelTurn   context.CancelFunc
+	approvals    map[string]chan bool
+	nextApproval int
+}
+
+// newChat starts a conversation that lasts until ctx is done
+func newChat(ctx context.Context, agentApp *agent.Agent, send func(Event) error) *chat {
+	return &chat{
+		ctx:              ctx,
+		agent:            agentApp,
+		session:          agent.NewSession(),
+		send:             send,
+		approvalRequests: make(chan Event),
+		approvals:        map[string]chan bool{},
+	}
+}
+
+// startTurn runs a turn with the client's message, with the options of its
+// modifiers, and streams its events, unless a turn is already running
+func (c *chat) startTurn(text string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancelTurn != nil {
+		return errors.New("a turn is already running; wait for done or cancel it")
+	}
+	if strings.TrimSpace(text) == "" {
+		return errors.New("the message is empty")
+	}
+	options, text, err := agent.ParseTurnModifiers(text)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.cancelTurn = cancel
+	events := c.agent.RunTurnWith(ctx, c.session, text, options)
+
+	c.turns.Add(1)
+	go func() {
+		defer c.turns.Done()
+		defer cancel()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				c.relay(event)
+			case request := <-c.approvalRequests:
+				// The turn waits for the answer, so every event that came
+				// before the request is buffered by now and goes first
+				for len(events) > 0 {
+					c.relay(<-events)
+				}
+				c.send(request)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// cancel stops the running turn, if there is one
+func (c *chat) cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancelTurn != nil {
+		c.cancelTurn()
+	}
+}
+
+// wait blocks until the chat's turns have finished
+func (c *chat) wait() {
+	c.turns.Wait()
+}
+
+// relay sends a turn's event to the client
+func (c *chat) relay(event agent.AgentEvent) {
+	// The client may send its next message as soon as it sees done
+	if _, ok := event.(agent.Done); ok {
+		c.mu.Lock()
+		c.cancelTurn = nil
+		c.mu.Unlock()
+	}
+	if apiEvent, ok := NewEvent(event); ok {
+		c.send(apiEvent)
+	}
+}
+
+// approve is the agent's approver: it asks the client and waits for the
+// answer. Requests are denied once the chat is over.
+func (c *chat) approve(request agent.ApprovalRequest) bool {
+	c.mu.Lock()
+	c.nextApproval++
+	id := fmt.Sprintf("approval-%d", c.nextApproval)
+	reply := make(chan bool, 1)
+	c.approvals[id] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.approvals, id)
+		c.mu.Unlock()
+	}()
+
+	select {
+	case c.approvalRequests <- Event{Type: "approval_request", ID: id, Title: request.Title, Detail: request.Detail, Name: request.Tool, Input: request.Input}:
+	case <-c.ctx.Done():
+		return false
+	}
+
+	select {
+	case approved := <-reply:
+		return approved
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+// answer passes the client's decision to the approval request waiting for it
+func (c *chat) answer(id string, approved bool) error {
+	c.mu.Lock()
+	reply, ok := c.approvals[id]
+	c.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("no approval request %q is waiting", id)
+	}
+	select {
+	case reply <- approved:
+	default:
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Package api lets other programs, such as editor extensions and web UIs,
+// chat with the agent: their messages start turns, and the turns' events are
+// streamed back to them as JSON
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"agent/agent"
+)
+
+// Event is an agent event as sent to API clients. Type names the event; only
+// the fields that event carries are set.
+type Event struct {
+	// Type is one of text, thinking, tool_call, tool_result, usage,
+	// response_complete, budget_warning, notice, offline, online,
+	// rate_limit_wait, spend_alert, approval_request, error or done
+	Type string `json:"type"`
+
+	// Text is the chunk of a text or thinking event, or a notice
+	Text string `json:"text,omitempty"`
+
+	// A tool call or its result, matched by ID. Approval requests have an
+	// ID too, to answer them by.
+	ID         string          `json:"id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Input      json.RawMessage `json:"input,omitempty"`
+	Content    string          `json:"content,omitempty"`
+	IsError    bool            `json:"is_error,omitempty"`
+	DurationMS int64           `json:"duration_ms,omitempty"`
+
+	// Tokens of a usage event, the tokens used so far for a budget warning,
+	// or the output tokens of a complete response
+	InputTokens              int64 `json:"input_tokens,omitempty"`
+	OutputTokens             int64 `json:"output_tokens,omitempty"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens,omitempty"`
+
+	// StopReason is why a response or the turn stopped, e.g. "end_turn"
+	StopReason string `json:"stop_reason,omitempty"`
+
+	// Model answered a response; RouteReason says why the model router
+	// picked it, when it did
+	Model       string `json:"model,omitempty"`
+	RouteReason string `json:"route_reason,omitempty"`
+
+	// Budget is the session budget a warning is about, e.g. "200000 tokens"
+	Budget string `json:"budget,omitempty"`
+
+	// Error is what failed, for error and offline events
+	Error string `json:"error,omitempty"`
+
+	// Until is when a paused turn tries again: the next attempt to reach the
+	// API while offline, or the end of a rate limit wait. Limit names the
+	// rate limit, e.g. "requests_per_minute".
+	Until *time.Time `json:"until,omitempty"`
+	Limit string     `json:"limit,omitempty"`
+
+	// Dollars spent in the last hour and the alert threshold they passed
+	LastHourDollars  float64 `json:"last_hour_dollars,omitempty"`
+	ThresholdDollars float64 `json:"threshold_dollars,omitempty"`
+
+	// Title and Detail describe what an approval request asks for
+	Title  string `json:"title,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// NewEvent converts an agent event for API clients, reporting false for
+// events that aren't sent to them
+func NewEvent(event agent.AgentEvent) (Event, bool) {
+	switch event := event.(type) {
+	case agent.TextDelta:
+		return Event{Type: "text", Text: event.Text}, true
+	case agent.ThinkingDelta:
+		return Event{Type: "thinking", Text: event.Text}, true
+	case agent.ToolCallStarted:
+		return Event{Type: "tool_call", ID: event.ID, Name: event.Name, Input: event.Input}, true
+	case agent.ToolResult:
+		return Event{
+			Type:       "tool_result",
+			ID:         event.ID,
+			Name:       event.Name,
+			Content:    event.Content,
+			IsError:    event.IsError,
+			DurationMS: event.Duration.Milliseconds(),
+		}, true
+	case agent.Usage:
+		return Event{
+			Type:                     "usage",
+			InputTokens:              event.InputTokens,
+			OutputTokens:             event.OutputTokens,
+			CacheCreationInputTokens: event.CacheCreationInputTokens,
+			CacheReadInputTokens:     event.CacheReadInputTokens,
+		}, true
+	case agent.ResponseComplete:
+		return Event{
+			Type:         "response_complete",
+			DurationMS:   event.Elapsed.Milliseconds(),
+			OutputTokens: event.OutputTokens,
+			StopReason:   event.StopReason,
+			Model:        string(event.Model),
+			RouteReason:  event.RouteReason,
+		}, true
+	case agent.BudgetWarning:
+		return Event{
+			Type:                     "budget_warning",
+			InputTokens:              event.Used.InputTokens,
+			OutputTokens:             event.Used.OutputTokens,
+			CacheCreationInputTokens: event.Used.CacheCreationInputTokens,
+			CacheReadInputTokens:     event.Used.CacheReadInputTokens,
+			Budget:                   event.Budget.String(),
+		}, true
+	case agent.Notice:
+		return Event{Type: "notice", Text: event.Text}, true
+	case agent.Offline:
+		return Event{Type: "offline", Error: event.Err.Error(), Until: &event.Retry}, true
+	case agent.Online:
+		return Event{Type: "online"}, true
+	case agent.RateLimitWait:
+		return Event{Type: "rate_limit_wait", Until: &event.Until, Limit: event.Limit}, true
+	case agent.SpendAlert:
+		return Event{Type: "spend_alert", LastHourDollars: event.LastHour, ThresholdDollars: event.Threshold}, true
+	case agent.Error:
+		return Event{Type: "error", Error: event.Err.Error()}, true
+	case agent.Done:
+		return Event{Type: "done", StopReason: event.StopReason}, true
+	}
+
+	return Event{}, false
+}
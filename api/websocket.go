@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"agent/agent"
+	"agent/log"
+
+	"github.com/gorilla/websocket"
+)
+
+// ChatPath is where clients open a chat over WebSocket
+const ChatPath = "/v1/chat"
+
+// Request is a message from an API client
+type Request struct {
+	// Type is "message" to start a turn with Text, "cancel" to stop the
+	// running turn, or "approval" to answer the approval request with ID
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Approved bool   `json:"approved,omitempty"`
+}
+
+// Server serves chats with the agent over WebSocket. Every connection is a
+// chat of its own, with a new agent and conversation, which ends when the
+// connection closes.
+type Server struct {
+	token    string
+	newAgent func() (*agent.Agent, error)
+	mux      *http.ServeMux
+
+	// approvals is the approval policy and approvalSecret the key its
+	// callback requests are signed with; by default the client is asked
+	approvals      string
+	approvalSecret string
+}
+
+// NewServer creates a server that lets in clients presenting token, and
+// starts each chat with an agent made by newAgent
+func NewServer(token string, newAgent func() (*agent.Agent, error)) *Server {
+	s := &Server{token: token, newAgent: newAgent, mux: http.NewServeMux()}
+	s.mux.HandleFunc(ChatPath, s.authorized(s.serveChat))
+	return s
+}
+
+// SetApprovalPolicy decides approval requests by policy instead of asking
+// the client, e.g. "deny" or a callback URL, whose requests are signed with
+// secret. It must be called before serving.
+func (s *Server) SetApprovalPolicy(policy, secret string) {
+	s.approvals, s.approvalSecret = policy, secret
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// authorized rejects requests without the bearer token. Browsers can't set
+// headers on WebSocket requests, so the token may also be passed as the
+// access_token query parameter.
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			token = r.URL.Query().Get("access_token")
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing or wrong bearer token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// upgrader accepts connections from any origin: a page can only connect
+// with the token, which it has no way to learn from another site
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// serveChat runs a chat for one connection
+func (s *Server) serveChat(w http.ResponseWriter, r *http.Request) {
+	agentApp, err := s.newAgent()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	log.Info("chat opened", "remote", r.RemoteAddr)
+
+	var writeMu sync.Mutex
+	ctx, cancel := context.WithCancel(r.Context())
+	c := newChat(ctx, agentApp, func(event Event) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+
+		return conn.WriteJSON(event)
+	})
+	agentApp.SetApprover(PolicyApprover(s.approvals, s.approvalSecret, c.approve))
+
+	readRequests(conn, c)
+
+	// The turn running when the client left is stopped, not left to finish unseen
+	cancel()
+	c.wait()
+	log.Info("chat closed", "remote", r.RemoteAddr)
+}
+
+// readRequests passes the client's requests to the chat until it disconnects
+func readRequests(conn *websocket.Conn, c *chat) {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var request Request
+		if err := json.Unmarshal(message, &request); err != nil {
+			c.send(Event{Type: "error", Error: fmt.Sprintf("invalid request: %v", err)})
+			continue
+		}
+
+		switch request.Type {
+		case "message":
+			err = c.startTurn(request.Text)
+		case "cancel":
+			c.cancel()
+		case "approval":
+			err = c.answer(request.ID, request.Approved)
+		default:
+			err = fmt.Errorf("unknown request type %q", request.Type)
+		}
+		if err != nil {
+			c.send(Event{Type: "error", Error: err.Error()})
+		}
+	}
+}
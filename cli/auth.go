@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"agent/config"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// Auth manages API keys and OAuth sign-ins stored in the OS keychain
+func Auth(args []string) error {
+	usage := "Usage: cli-agent auth <login|logout|status> [--profile name]"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	flags := flag.NewFlagSet("auth "+args[0], flag.ContinueOnError)
+	profile := flags.String("profile", "", "Profile the key belongs to (defaults to default_profile in settings.json)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// Resolve the name so a key saved for the default profile is found under its own name
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+	name, selected, err := settings.Profile(*profile)
+	if err != nil {
+		return err
+	}
+	account := config.KeychainAccount(name)
+
+	switch args[0] {
+	case "login":
+		if selected.OAuth != nil {
+			return oauthLogin(*selected.OAuth, name)
+		}
+		return authLogin(account)
+	case "logout":
+		if selected.OAuth != nil {
+			if err := config.DeleteOAuthToken(name); err != nil {
+				return err
+			}
+			fmt.Printf("Signed %s out.\n", account)
+			return nil
+		}
+		if err := config.DeleteAPIKey(account); err != nil {
+			return err
+		}
+		fmt.Printf("Removed the API key for %s from the keychain.\n", account)
+		return nil
+	case "status":
+		return authStatus(settings, name)
+	default:
+		return fmt.Errorf("unknown auth command %q\n%s", args[0], usage)
+	}
+}
+
+// authLogin reads an API key, without echoing it when stdin is a terminal, and saves it
+func authLogin(account string) error {
+	var key string
+	if term.IsTerminal(os.Stdin.Fd()) {
+		fmt.Fprintf(os.Stderr, "API key for %s: ", account)
+		data, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+		key = string(data)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+		key = line
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("no key given")
+	}
+
+	if err := config.StoreAPIKey(account, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved the API key for %s in the keychain.\n", account)
+	return nil
+}
+
+// authStatus reports where the profile's API key will be read from
+func authStatus(settings config.Settings, name string) error {
+	_, profile, err := settings.Profile(name)
+	if err != nil {
+		return err
+	}
+
+	account := config.KeychainAccount(name)
+	fmt.Printf("Profile: %s\n", account)
+
+	switch {
+	case profile.OAuth != nil:
+		token, err := config.LoadOAuthToken(name)
+		switch {
+		case err == nil && token.ExpiresAt.IsZero():
+			fmt.Println("Key source: OAuth sign-in")
+		case err == nil:
+			fmt.Printf("Key source: OAuth sign-in (access token expires %s)\n", token.ExpiresAt.Local().Format(time.DateTime))
+		case errors.Is(err, config.ErrKeyNotFound):
+			fmt.Println("Key source: OAuth, not signed in (run `cli-agent auth login`)")
+		default:
+			return err
+		}
+	case profile.APIKeyCommand != "":
+		fmt.Printf("Key source: command %q\n", profile.APIKeyCommand)
+	case profile.APIKeyEnv != "":
+		fmt.Printf("Key source: environment variable %s\n", profile.APIKeyEnv)
+	case os.Getenv("ANTHROPIC_API_KEY") != "":
+		fmt.Println("Key source: environment variable ANTHROPIC_API_KEY")
+	default:
+		_, err := config.LoadAPIKey(account)
+		switch {
+		case err == nil:
+			fmt.Println("Key source: keychain")
+		case errors.Is(err, config.ErrKeyNotFound):
+			fmt.Println("Key source: none (run `cli-agent auth login`)")
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
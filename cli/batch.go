@@ -0,0 +1,453 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"agent/agent"
+	"agent/config"
+	"agent/tools"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// batchSystemPrompt is used for batch requests, which are answered in one shot without tools
+const batchSystemPrompt = `You are an experienced developer processing one item of a bulk job.
+Answer with the requested result only; there is no follow-up conversation and no tools are available.
+When asked to change a file, reply with the complete new file content and nothing else.`
+
+// maxBatchFileSize skips files too large to embed in a single request
+const maxBatchFileSize = 200 * 1024
+
+// batchManifest is stored locally for each submitted batch so results can be
+// matched back to the files or prompt IDs they came from
+type batchManifest struct {
+	ID        string            `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Dir       string            `json:"dir"`
+	Labels    map[string]string `json:"labels"`
+}
+
+// batchPrompt is one line of a --input JSON Lines file
+type batchPrompt struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+}
+
+// Batch submits one-shot prompts through the Message Batches API and collects the results later
+func Batch(args []string) error {
+	usage := "Usage: cli-agent batch <submit|status|results> [flags]"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	switch args[0] {
+	case "submit":
+		return batchSubmit(args[1:])
+	case "status":
+		return batchStatus(args[1:])
+	case "results":
+		return batchResults(args[1:])
+	default:
+		return fmt.Errorf("unknown batch command %q\n%s", args[0], usage)
+	}
+}
+
+func batchSubmit(args []string) error {
+	flags := flag.NewFlagSet("batch submit", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Directory the file arguments are relative to (defaults to the current directory)")
+	prompt := flags.String("prompt", "", "Instruction applied to every file argument")
+	input := flags.String("input", "", "JSON Lines file of {\"id\", \"prompt\"} requests, instead of files")
+	maxTokens := flags.Int64("max-tokens", 4096, "Maximum tokens per response")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent batch submit [--profile name] --prompt <instruction> [--dir path] <files...>")
+		fmt.Fprintln(flags.Output(), "       cli-agent batch submit [--profile name] --input prompts.jsonl")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	model := agent.DefaultModel
+	if cfg.Model != "" {
+		model = anthropic.Model(cfg.Model)
+	}
+
+	workspace, err := tools.NewWorkspace(*dir)
+	if err != nil {
+		return err
+	}
+
+	manifest := batchManifest{Dir: workspace.Root(), Labels: map[string]string{}}
+	var prompts []batchPrompt
+
+	switch {
+	case *input != "":
+		if prompts, err = loadBatchPrompts(*input); err != nil {
+			return err
+		}
+	case *prompt != "" && flags.NArg() > 0:
+		for _, name := range flags.Args() {
+			path, err := workspace.Resolve(name)
+			if err != nil {
+				return err
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || info.Size() > maxBatchFileSize {
+				fmt.Fprintf(os.Stderr, "skipping %s: directory or larger than %d bytes\n", name, maxBatchFileSize)
+				continue
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(workspace.Root(), path)
+			if err != nil {
+				rel = name
+			}
+
+			prompts = append(prompts, batchPrompt{
+				ID:     rel,
+				Prompt: fmt.Sprintf("%s\n\nFile: %s\n```\n%s\n```", *prompt, rel, content),
+			})
+		}
+	default:
+		flags.Usage()
+		return fmt.Errorf("pass --prompt with one or more files, or --input")
+	}
+
+	if len(prompts) == 0 {
+		return fmt.Errorf("nothing to submit")
+	}
+
+	requests := make([]anthropic.MessageBatchNewParamsRequest, 0, len(prompts))
+	for i, p := range prompts {
+		// Custom IDs are restricted to [a-zA-Z0-9_-]{1,64}, so map them back through the manifest
+		customID := fmt.Sprintf("req-%d", i+1)
+		manifest.Labels[customID] = p.ID
+
+		requests = append(requests, anthropic.MessageBatchNewParamsRequest{
+			CustomID: customID,
+			Params: anthropic.MessageBatchNewParamsRequestParams{
+				Model:     model,
+				MaxTokens: *maxTokens,
+				System:    []anthropic.TextBlockParam{{Text: batchSystemPrompt}},
+				Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock(p.Prompt))},
+			},
+		})
+	}
+
+	batch, err := cfg.Client.Messages.Batches.New(context.Background(), anthropic.MessageBatchNewParams{Requests: requests})
+	if err != nil {
+		return fmt.Errorf("failed to submit batch: %w", err)
+	}
+
+	manifest.ID = batch.ID
+	manifest.CreatedAt = batch.CreatedAt
+	if err := saveBatchManifest(manifest); err != nil {
+		return err
+	}
+
+	fmt.Printf("Submitted batch %s with %d request(s).\n", batch.ID, len(requests))
+	fmt.Printf("Check progress with `cli-agent batch status %s` and collect results with `cli-agent batch results %s`.\n", batch.ID, batch.ID)
+	return nil
+}
+
+func batchStatus(args []string) error {
+	flags := flag.NewFlagSet("batch status", flag.ContinueOnError)
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent batch status [--profile name] [batch-id...]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+
+	ids := flags.Args()
+	if len(ids) == 0 {
+		manifests, err := listBatchManifests()
+		if err != nil {
+			return err
+		}
+		if len(manifests) == 0 {
+			fmt.Println("No batches submitted yet.")
+			return nil
+		}
+		for _, manifest := range manifests {
+			ids = append(ids, manifest.ID)
+		}
+	}
+
+	for _, id := range ids {
+		batch, err := cfg.Client.Messages.Batches.Get(context.Background(), id)
+		if err != nil {
+			return fmt.Errorf("failed to get batch %s: %w", id, err)
+		}
+
+		counts := batch.RequestCounts
+		fmt.Printf("%s  %-11s  processing %d • succeeded %d • errored %d • canceled %d • expired %d\n",
+			batch.ID, batch.ProcessingStatus, counts.Processing, counts.Succeeded, counts.Errored, counts.Canceled, counts.Expired)
+	}
+
+	return nil
+}
+
+func batchResults(args []string) error {
+	flags := flag.NewFlagSet("batch results", flag.ContinueOnError)
+	out := flags.String("out", "", "Directory to write results to (defaults to batch-<id>)")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent batch results [--profile name] [--out dir] <batch-id>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one batch ID")
+	}
+
+	id := flags.Arg(0)
+	manifest, err := loadBatchManifest(id)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	client := cfg.Client
+	ctx := context.Background()
+
+	batch, err := client.Messages.Batches.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get batch %s: %w", id, err)
+	}
+	if batch.ProcessingStatus != anthropic.MessageBatchProcessingStatusEnded {
+		return fmt.Errorf("batch %s is still %s; try again later", id, batch.ProcessingStatus)
+	}
+
+	outDir := *out
+	if outDir == "" {
+		outDir = "batch-" + id
+	}
+
+	succeeded, failed := 0, 0
+	usage := agent.Usage{}
+
+	stream := client.Messages.Batches.ResultsStreaming(ctx, id)
+	defer stream.Close()
+
+	for stream.Next() {
+		result := stream.Current()
+
+		label := manifest.Labels[result.CustomID]
+		if label == "" || !filepath.IsLocal(filepath.FromSlash(label)) {
+			label = result.CustomID
+		}
+
+		if result.Result.Type != "succeeded" {
+			failed++
+			reason := result.Result.Type
+			if message := result.Result.Error.Error.Message; message != "" {
+				reason += ": " + message
+			}
+			fmt.Printf("✗ %s (%s)\n", label, reason)
+			continue
+		}
+
+		message := result.Result.Message
+		usage = usage.Add(agent.Usage{InputTokens: message.Usage.InputTokens, OutputTokens: message.Usage.OutputTokens})
+
+		var text strings.Builder
+		for _, block := range message.Content {
+			if block.Type == "text" {
+				text.WriteString(block.Text)
+			}
+		}
+
+		path := filepath.Join(outDir, filepath.FromSlash(label)+".md")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(text.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write result: %w", err)
+		}
+
+		succeeded++
+		fmt.Printf("✓ %s → %s\n", label, path)
+	}
+
+	if err := stream.Err(); err != nil {
+		return fmt.Errorf("failed to read batch results: %w", err)
+	}
+
+	summary := fmt.Sprintf("\n%d succeeded, %d failed", succeeded, failed)
+	if cost, ok := usage.Cost(agent.DefaultModel); ok {
+		// Batch requests are billed at half the standard price
+		summary += fmt.Sprintf(" • $%.4f", cost/2)
+	}
+	fmt.Println(summary)
+
+	return nil
+}
+
+// loadBatchPrompts reads a JSON Lines file of batch prompts
+func loadBatchPrompts(path string) ([]batchPrompt, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prompts: %w", err)
+	}
+	defer file.Close()
+
+	var prompts []batchPrompt
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var p batchPrompt
+		if err := json.Unmarshal([]byte(text), &p); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if p.Prompt == "" {
+			return nil, fmt.Errorf("%s:%d: missing prompt", path, line)
+		}
+		if p.ID == "" {
+			p.ID = fmt.Sprintf("prompt-%d", line)
+		}
+
+		prompts = append(prompts, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompts: %w", err)
+	}
+
+	return prompts, nil
+}
+
+func batchManifestDir() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "batches"), nil
+}
+
+func saveBatchManifest(manifest batchManifest) error {
+	dir, err := batchManifestDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create batch directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch manifest: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, manifest.ID+".json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write batch manifest: %w", err)
+	}
+
+	return nil
+}
+
+func loadBatchManifest(id string) (batchManifest, error) {
+	manifest := batchManifest{ID: id, Labels: map[string]string{}}
+
+	dir, err := batchManifestDir()
+	if err != nil {
+		return manifest, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, filepath.Base(id)+".json"))
+	if os.IsNotExist(err) {
+		// Batches submitted elsewhere still work; results are named by custom ID
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, fmt.Errorf("failed to read batch manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to parse batch manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// listBatchManifests returns the locally known batches, newest first
+func listBatchManifests() ([]batchManifest, error) {
+	dir, err := batchManifestDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch directory: %w", err)
+	}
+
+	var manifests []batchManifest
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+
+		manifest, err := loadBatchManifest(id)
+		if err != nil {
+			continue
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		return manifests[i].CreatedAt.After(manifests[j].CreatedAt)
+	})
+
+	return manifests, nil
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"agent/api"
+	"agent/config"
+	"agent/tools"
+)
+
+// Bridge serves chats to an editor plugin over JSON-RPC on stdin and stdout,
+// for editors that would rather run the agent as a child process than talk
+// to the WebSocket API
+func Bridge(args []string) error {
+	flags := flag.NewFlagSet("bridge", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Working directory for the agent (defaults to the current directory)")
+	trust := flags.Bool("trust", false, "Allow write tools even if the workspace hasn't been trusted interactively")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	approvals := flags.String("approvals", "", "Approval policy: ask the editor, allow, deny, or an http(s) URL to ask (defaults to approvals.policy in settings.json, or ask)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent bridge [--dir path] [--profile name] [--trust] [--approvals policy]")
+		fmt.Fprintln(flags.Output(), "Serves chats to an editor over JSON-RPC 2.0 on stdin and stdout, with Content-Length framing like the Language Server Protocol.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+
+	policy, approvalTools, err := approvalSettings(settings, *approvals)
+	if err != nil {
+		return err
+	}
+
+	root, err := tools.NewWorkspace(*dir)
+	if err != nil {
+		return err
+	}
+	trusted := *trust || config.LoadTrust(root.Root()) == config.TrustGranted
+
+	// stdout carries the protocol, so anything for people goes to stderr
+	if !trusted {
+		fmt.Fprintln(os.Stderr, "The workspace isn't trusted, so the agent can only read; pass --trust to let it make changes.")
+	}
+	bridge := api.NewBridge(chatAgents(root.Root(), cfg, settings, trusted, approvalTools))
+	bridge.SetApprovalPolicy(policy, os.Getenv(approvalSecretEnv))
+	return bridge.Serve(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"agent/config"
+)
+
+// defaultCheckTimeout bounds a check that doesn't set timeout_seconds
+const defaultCheckTimeout = 5 * time.Minute
+
+// maxCheckOutputLines is how much of a failing check's output is shown and sent to the model
+const maxCheckOutputLines = 60
+
+// checkResult is the outcome of one check run
+type checkResult struct {
+	Check  config.CheckConfig
+	Passed bool
+	Output string
+}
+
+// runChecks runs each configured check in root, in order
+func runChecks(ctx context.Context, root string, checks []config.CheckConfig) []checkResult {
+	results := make([]checkResult, 0, len(checks))
+	for _, check := range checks {
+		results = append(results, runCheck(ctx, root, check))
+	}
+
+	return results
+}
+
+// runCheck runs one check command through the shell and captures its output
+func runCheck(ctx context.Context, root string, check config.CheckConfig) checkResult {
+	timeout := defaultCheckTimeout
+	if check.Timeout > 0 {
+		timeout = time.Duration(check.Timeout) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := config.ShellCommand(ctx, check.Command)
+	cmd.Dir = root
+	output, err := cmd.CombinedOutput()
+
+	result := checkResult{Check: check, Passed: err == nil, Output: tailLines(string(output), maxCheckOutputLines)}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.Output += fmt.Sprintf("\n[timed out after %s]", timeout)
+	}
+
+	return result
+}
+
+// checkName returns the check's name, falling back to its command
+func checkName(check config.CheckConfig) string {
+	if check.Name != "" {
+		return check.Name
+	}
+	return check.Command
+}
+
+// tailLines keeps the last n lines of output, where failures are usually reported
+func tailLines(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+
+	return fmt.Sprintf("[... %d lines omitted]\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no
+func confirm(in *bufio.Reader, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := in.ReadString('\n')
+	if err != nil {
+		fmt.Println()
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,73 @@
+// Package cli implements the non-interactive subcommands of cli-agent
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"agent/agent"
+)
+
+// Command runs a subcommand with its arguments (excluding the subcommand name)
+type Command func(args []string) error
+
+// Commands maps subcommand names to their implementations
+var Commands = map[string]Command{
+	"attach": Attach,
+	"auth":   Auth,
+	"batch":  Batch,
+	"bridge": Bridge,
+	"config": Config,
+	"doctor": Doctor,
+	"eval":   Eval,
+	"hook":   Hook,
+	"replay": Replay,
+	"review": Review,
+	"run":    Run,
+	"serve":  Serve,
+	"update": Update,
+	"watch":  Watch,
+}
+
+// printTurn prints a turn's text and tool calls to stdout as they arrive and
+// returns the error that ended the turn, if any
+func printTurn(events <-chan agent.AgentEvent) error {
+	return printTurnTo(os.Stdout, events)
+}
+
+// printTurnTo is printTurn writing to w
+func printTurnTo(w io.Writer, events <-chan agent.AgentEvent) error {
+	var turnErr error
+
+	for event := range events {
+		switch event := event.(type) {
+		case agent.TextDelta:
+			fmt.Fprint(w, event.Text)
+		case agent.ToolCallStarted:
+			fmt.Fprintf(w, "\n  → %s %s\n", event.Name, event.Input)
+		case agent.ToolResult:
+			status := "✓"
+			if event.IsError {
+				status = "✗"
+			}
+			fmt.Fprintf(w, "  %s %s\n", status, event.Name)
+		case agent.Notice:
+			fmt.Fprintf(w, "\n  ℹ %s\n", event.Text)
+		case agent.RateLimitWait:
+			fmt.Fprintf(w, "\n  ⏳ waiting %s to stay within the %s limit\n", time.Until(event.Until).Round(time.Second), event.Limit)
+		case agent.Offline:
+			fmt.Fprintf(w, "\n  ⚠ offline (%v); retrying in %s\n", event.Err, time.Until(event.Retry).Round(time.Second))
+		case agent.Online:
+			fmt.Fprintf(w, "  ✓ back online, resuming\n")
+		case agent.SpendAlert:
+			fmt.Fprintf(w, "\n  ⚠ $%.2f spent in the last hour, above the $%.2f alert\n", event.LastHour, event.Threshold)
+		case agent.Error:
+			turnErr = event.Err
+		}
+	}
+	fmt.Fprintln(w)
+
+	return turnErr
+}
@@ -0,0 +1,372 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"agent/config"
+)
+
+// secretKeyPattern matches settings whose values are masked by `config show`
+var secretKeyPattern = regexp.MustCompile(`(?i)^(api_key|x-api-key|authorization|client_secret|secret|password|access_token|refresh_token)$`)
+
+// configFile is a config file `cli-agent config` operates on
+type configFile struct {
+	path    string
+	project bool
+}
+
+// parse strictly validates the file contents and returns the decoded config
+func (f configFile) parse(data []byte) (any, error) {
+	if f.project {
+		return config.ParseProjectConfig(data)
+	}
+	return config.ParseSettings(data)
+}
+
+// read returns the file contents, or an empty object when it doesn't exist yet
+func (f configFile) read() ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return []byte("{}\n"), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+
+	return data, nil
+}
+
+func (f configFile) write(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := os.WriteFile(f.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+
+	return nil
+}
+
+// Config shows and changes the user settings, or the project config with --project
+func Config(args []string) error {
+	usage := "Usage: cli-agent config [--project] [--dir path] <show | set <key> <value> | edit>"
+
+	flags := flag.NewFlagSet("config", flag.ContinueOnError)
+	project := flags.Bool("project", false, "Use the project config (.cli-agent/config.json) instead of the user settings")
+	dir := flags.String("dir", "", "Project root for --project (defaults to the current directory)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	// Flags may come before or after the subcommand
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("no config command given")
+	}
+	command := flags.Arg(0)
+	if err := flags.Parse(flags.Args()[1:]); err != nil {
+		return err
+	}
+
+	file := configFile{project: *project}
+	if *project {
+		root, err := filepath.Abs(*dir)
+		if err != nil {
+			return err
+		}
+		file.path = config.ProjectConfigPath(root)
+	} else {
+		path, err := config.SettingsPath()
+		if err != nil {
+			return err
+		}
+		file.path = path
+	}
+
+	switch command {
+	case "show":
+		return configShow(file)
+	case "set":
+		if flags.NArg() != 2 {
+			return fmt.Errorf("%s\nValues are JSON (e.g. true, 30 or '[\"ctrl+s\"]') or plain strings; null removes a setting", usage)
+		}
+		return configSet(file, flags.Arg(0), flags.Arg(1))
+	case "edit":
+		return configEdit(file)
+	default:
+		return fmt.Errorf("unknown config command %q\n%s", command, usage)
+	}
+}
+
+// configShow prints the effective config with secrets masked
+func configShow(file configFile) error {
+	data, err := file.read()
+	if err != nil {
+		return err
+	}
+
+	parsed, err := file.parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file.path, err)
+	}
+
+	if settings, ok := parsed.(config.Settings); ok {
+		// Show the defaults that apply, not just what was written
+		parsed, err = config.LoadSettings()
+		if err != nil {
+			return err
+		}
+		defer printCredentials(settings)
+	}
+
+	var document any
+	encoded, err := json.Marshal(parsed)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		return err
+	}
+
+	output, err := json.MarshalIndent(maskSecrets(document, ""), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("# %s\n%s\n", file.path, output)
+	return nil
+}
+
+// maskSecrets replaces the values of secret-looking keys
+func maskSecrets(value any, key string) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for k, v := range value {
+			value[k] = maskSecrets(v, k)
+		}
+		return value
+	case []any:
+		for i, v := range value {
+			value[i] = maskSecrets(v, key)
+		}
+		return value
+	case string:
+		if secretKeyPattern.MatchString(key) {
+			return maskSecret(value)
+		}
+	}
+
+	return value
+}
+
+// maskSecret keeps just enough of a key to tell keys apart
+func maskSecret(secret string) string {
+	if len(secret) < 16 {
+		return "****"
+	}
+	return secret[:7] + "…" + secret[len(secret)-4:]
+}
+
+// printCredentials lists where each profile's API key comes from, masked
+func printCredentials(settings config.Settings) {
+	names := settings.ProfileNames()
+	if len(names) == 0 {
+		names = []string{""}
+	}
+
+	fmt.Println("\n# API keys")
+	for _, name := range names {
+		profile := settings.Profiles[name]
+		account := config.KeychainAccount(name)
+
+		switch {
+		case profile.OAuth != nil:
+			if _, err := config.LoadOAuthToken(name); err == nil {
+				fmt.Printf("%s: OAuth sign-in\n", account)
+			} else {
+				fmt.Printf("%s: OAuth, not signed in\n", account)
+			}
+		case profile.APIKeyCommand != "":
+			fmt.Printf("%s: from command %q\n", account, profile.APIKeyCommand)
+		case profile.APIKeyEnv != "":
+			fmt.Printf("%s: %s from %s\n", account, maskedEnv(profile.APIKeyEnv), profile.APIKeyEnv)
+		case os.Getenv("ANTHROPIC_API_KEY") != "":
+			fmt.Printf("%s: %s from ANTHROPIC_API_KEY\n", account, maskedEnv("ANTHROPIC_API_KEY"))
+		default:
+			key, err := config.LoadAPIKey(account)
+			switch {
+			case err == nil:
+				fmt.Printf("%s: %s from the keychain\n", account, maskSecret(key))
+			case errors.Is(err, config.ErrKeyNotFound):
+				fmt.Printf("%s: none\n", account)
+			default:
+				fmt.Printf("%s: %s\n", account, err)
+			}
+		}
+	}
+}
+
+func maskedEnv(name string) string {
+	if value := os.Getenv(name); value != "" {
+		return maskSecret(value)
+	}
+	return "(not set)"
+}
+
+// configSet changes one setting, addressed by a dotted path such as
+// "profiles.work.model", and saves the file only if the result is valid
+func configSet(file configFile, key, raw string) error {
+	data, err := file.read()
+	if err != nil {
+		return err
+	}
+
+	document := map[string]any{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("%s is not valid JSON, fix it with `cli-agent config edit`: %w", file.path, err)
+	}
+
+	// Values are JSON when they parse as JSON, otherwise plain strings
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	if err := setPath(document, strings.Split(key, "."), value); err != nil {
+		return err
+	}
+
+	updated, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	updated = append(updated, '\n')
+
+	if _, err := file.parse(updated); err != nil {
+		return fmt.Errorf("not saved: %w", err)
+	}
+
+	if err := file.write(updated); err != nil {
+		return err
+	}
+
+	fmt.Printf("Set %s in %s\n", key, file.path)
+	return nil
+}
+
+// setPath sets or, for a nil value, removes a nested key, creating objects along the way
+func setPath(document map[string]any, path []string, value any) error {
+	for i, segment := range path[:len(path)-1] {
+		next, ok := document[segment]
+		if !ok {
+			if value == nil {
+				return nil
+			}
+			next = map[string]any{}
+			document[segment] = next
+		}
+
+		object, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s is not an object", strings.Join(path[:i+1], "."))
+		}
+		document = object
+	}
+
+	last := path[len(path)-1]
+	if value == nil {
+		delete(document, last)
+	} else {
+		document[last] = value
+	}
+
+	return nil
+}
+
+// configEdit opens a copy of the file in the user's editor and saves it back
+// once it is valid, offering to fix mistakes instead of saving a broken file
+func configEdit(file configFile) error {
+	data, err := file.read()
+	if err != nil {
+		return err
+	}
+
+	scratch, err := os.CreateTemp("", "cli-agent-config-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scratch.Name())
+
+	_, err = scratch.Write(data)
+	scratch.Close()
+	if err != nil {
+		return err
+	}
+
+	input := bufio.NewReader(os.Stdin)
+	for {
+		if err := runEditor(scratch.Name()); err != nil {
+			return err
+		}
+
+		edited, err := os.ReadFile(scratch.Name())
+		if err != nil {
+			return err
+		}
+
+		if _, err := file.parse(edited); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\nEdit again? [Y/n] ", err)
+			answer, _ := input.ReadString('\n')
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(answer)), "n") {
+				return fmt.Errorf("changes discarded")
+			}
+			continue
+		}
+
+		if err := file.write(edited); err != nil {
+			return err
+		}
+		fmt.Printf("Saved %s\n", file.path)
+		return nil
+	}
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, which may include arguments such as "code --wait"
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", fields[0], err)
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/daemon"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+// Daemon runs the daemon that keeps sessions alive between attaches, or stops it
+func Daemon(args []string, newChat daemon.ChatFactory) error {
+	flags := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent daemon [stop]")
+		fmt.Fprintln(flags.Output(), "Runs the daemon that cli-agent attach connects to, in the foreground. `cli-agent attach` starts it in the background when it isn't running; `stop` quits every session and stops it.")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case flags.NArg() == 0:
+		return daemon.NewServer(newChat).ListenAndServe()
+
+	case flags.NArg() == 1 && flags.Arg(0) == "stop":
+		conn, err := daemon.Dial()
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if _, err := conn.Request(daemon.Request{Action: "stop"}); err != nil {
+			return err
+		}
+		fmt.Println("Daemon stopped.")
+		return nil
+
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown daemon command %q", strings.Join(flags.Args(), " "))
+	}
+}
+
+// Attach connects the terminal to a chat running in the daemon, starting the
+// daemon if needed. Ctrl+] detaches and leaves the chat running.
+func Attach(args []string) error {
+	flags := flag.NewFlagSet("attach", flag.ContinueOnError)
+	startNew := flags.Bool("new", false, "Start a new session, passing the arguments after -- to it as chat flags")
+	list := flags.Bool("list", false, "List the running sessions")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent attach [-new] [name] [-- chat flags]")
+		fmt.Fprintln(flags.Output(), "       cli-agent attach -list")
+		fmt.Fprintln(flags.Output(), "Attaches to the named session, the only one running, or a new one. Ctrl+] detaches.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// The session name comes first, the chat's own flags after "--"
+	rest := flags.Args()
+	name := ""
+	if len(rest) > 0 && rest[0] != "--" && !strings.HasPrefix(rest[0], "-") {
+		name, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && rest[0] == "--" {
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && !*startNew {
+		return fmt.Errorf("chat flags are only used for a new session; add -new")
+	}
+
+	if *list {
+		return listSessions()
+	}
+
+	// A new session starts where attach was run, unless its flags say otherwise
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	rest = append([]string{"--dir", cwd}, rest...)
+
+	if !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stdout.Fd()) {
+		return errors.New("attach needs a terminal")
+	}
+	if !daemon.Running() {
+		if err := startDaemon(); err != nil {
+			return err
+		}
+	}
+
+	conn, err := daemon.Dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	width, height, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return err
+	}
+
+	response, err := conn.Request(daemon.Request{
+		Action:       "attach",
+		Name:         name,
+		New:          *startNew,
+		Args:         rest,
+		Size:         daemon.Size{Width: width, Height: height},
+		ColorProfile: int(lipgloss.ColorProfile()),
+	})
+	if err != nil {
+		return err
+	}
+
+	reason, err := relaySession(conn, response.AltScreen)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		fmt.Printf("Detached from session %s; it keeps running. Reattach with: cli-agent attach %s\n", response.Session, response.Session)
+	} else {
+		fmt.Printf("Left session %s: %s.\n", response.Session, reason)
+	}
+	return nil
+}
+
+// relaySession puts the terminal in raw mode and relays it to the attached
+// session until the user detaches, returning "", or the daemon lets the
+// client go, returning why
+func relaySession(conn *daemon.Conn, altScreen bool) (string, error) {
+	state, err := term.MakeRaw(os.Stdin.Fd())
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(os.Stdin.Fd(), state)
+
+	enter, leave := daemon.ScreenModes(altScreen)
+	fmt.Print(enter)
+	defer fmt.Print(leave)
+
+	stopResize := watchResize(func() {
+		if width, height, err := term.GetSize(os.Stdout.Fd()); err == nil {
+			conn.SendResize(daemon.Size{Width: width, Height: height})
+		}
+	})
+	defer stopResize()
+
+	detached := make(chan struct{})
+	go func() {
+		buffer := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buffer)
+			if err != nil {
+				return
+			}
+			input := buffer[:n]
+
+			// Input up to the detach key still goes to the chat
+			if i := strings.IndexByte(string(input), daemon.DetachKey); i >= 0 {
+				if i > 0 {
+					conn.SendInput(input[:i])
+				}
+				close(detached)
+				conn.Detach()
+				conn.Close()
+				return
+			}
+			if err := conn.SendInput(input); err != nil {
+				return
+			}
+		}
+	}()
+
+	reason, err := conn.Relay(os.Stdout)
+	select {
+	case <-detached:
+		return "", nil
+	default:
+	}
+	if errors.Is(err, io.EOF) {
+		return "the daemon stopped", nil
+	}
+	return reason, err
+}
+
+// listSessions prints the sessions running in the daemon
+func listSessions() error {
+	conn, err := daemon.Dial()
+	if err != nil {
+		fmt.Println("No sessions; the daemon isn't running.")
+		return nil
+	}
+	defer conn.Close()
+
+	response, err := conn.Request(daemon.Request{Action: "list"})
+	if err != nil {
+		return err
+	}
+	if len(response.Sessions) == 0 {
+		fmt.Println("No sessions.")
+		return nil
+	}
+
+	for _, session := range response.Sessions {
+		state := "detached"
+		if session.Attached {
+			state = "attached"
+		}
+		line := fmt.Sprintf("%-12s started %s, %s", session.Name, session.Started.Format("2006-01-02 15:04"), state)
+		if len(session.Args) > 0 {
+			line += "  " + strings.Join(session.Args, " ")
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// startDaemon starts the daemon in the background, detached from this
+// terminal and logging to the daemon log, and waits for it to listen
+func startDaemon() error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logPath, err := daemon.LogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0700); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, "daemon")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start the daemon: %w", err)
+	}
+	cmd.Process.Release()
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if daemon.Running() {
+			return nil
+		}
+	}
+	return fmt.Errorf("the daemon didn't start; see %s", logPath)
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// detachProcess makes cmd run without a console, so it survives the terminal closing
+func detachProcess(cmd *exec.Cmd) {
+	const detachedProcess = 0x00000008
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// watchResize calls onResize whenever the console is resized, until stopped.
+// Windows doesn't signal resizes, so the size is polled.
+func watchResize(onResize func()) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		width, height, _ := term.GetSize(os.Stdout.Fd())
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w, h, err := term.GetSize(os.Stdout.Fd())
+				if err == nil && (w != width || h != height) {
+					width, height = w, h
+					onResize()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"agent/config"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+// doctorTimeout bounds the API connectivity check
+const doctorTimeout = 15 * time.Second
+
+// doctorStatus is the outcome of one doctor check
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+// doctorCheck is one line of the doctor report. Fix says how to resolve a
+// warning or failure.
+type doctorCheck struct {
+	Name   string
+	Status doctorStatus
+	Detail string
+	Fix    string
+}
+
+// Doctor checks the environment cli-agent runs in and prints how to fix
+// what's wrong
+func Doctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Workspace to check the project config of (defaults to the current directory)")
+	profile := flags.String("profile", "", "Profile to check the credentials of (defaults to default_profile in settings.json)")
+	offline := flags.Bool("offline", false, "Skip the API connectivity check")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent doctor [--dir path] [--profile name] [--offline]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	root := *dir
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	settingsCheck, settings, settingsOK := checkSettings()
+	checks := []doctorCheck{
+		checkGo(root),
+		checkTerminal(settings),
+		checkGit(root),
+		settingsCheck,
+		checkProjectConfig(root),
+	}
+	if settingsOK {
+		checks = append(checks, checkAPI(settings, *profile, *offline))
+	}
+
+	failed := 0
+	for _, check := range checks {
+		symbol := "✓"
+		switch check.Status {
+		case doctorWarn:
+			symbol = "!"
+		case doctorFail:
+			symbol = "✗"
+			failed++
+		}
+
+		fmt.Printf("%s %s: %s\n", symbol, check.Name, check.Detail)
+		if check.Fix != "" && check.Status != doctorOK {
+			fmt.Printf("    → %s\n", check.Fix)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// checkGo reports the Go cli-agent was built with and the toolchain on PATH,
+// which the agent needs to build and test Go projects
+func checkGo(root string) doctorCheck {
+	check := doctorCheck{Name: "Go", Detail: fmt.Sprintf("built with %s for %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)}
+
+	output, err := exec.Command("go", "version").Output()
+	if err == nil {
+		check.Detail += "; " + strings.TrimSpace(string(output))
+		return check
+	}
+
+	check.Detail += "; no go toolchain on PATH"
+	if _, err := os.Stat(filepath.Join(root, "go.mod")); err == nil {
+		check.Status = doctorWarn
+		check.Fix = "Install Go from https://go.dev/dl so the agent can build and test this project"
+	}
+	return check
+}
+
+// checkTerminal reports whether the chat can run here and which colors it gets
+func checkTerminal(settings config.Settings) doctorCheck {
+	check := doctorCheck{Name: "Terminal"}
+
+	if !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stdout.Fd()) {
+		check.Status = doctorWarn
+		check.Detail = "not an interactive terminal"
+		check.Fix = "Run the chat in a terminal; use `cli-agent run` in scripts and CI"
+		return check
+	}
+
+	profile := termenv.NewOutput(os.Stdout).EnvColorProfile()
+	colors := map[termenv.Profile]string{
+		termenv.TrueColor: "true color",
+		termenv.ANSI256:   "256 colors",
+		termenv.ANSI:      "16 colors",
+		termenv.Ascii:     "no colors",
+	}[profile]
+
+	check.Detail = fmt.Sprintf("%s, TERM=%s", colors, os.Getenv("TERM"))
+	if width, height, err := term.GetSize(os.Stdout.Fd()); err == nil {
+		check.Detail = fmt.Sprintf("%dx%d, %s", width, height, check.Detail)
+	}
+
+	switch {
+	case settings.PlainOutput():
+		check.Detail += ", plain output"
+	case profile == termenv.Ascii:
+		check.Status = doctorWarn
+		check.Fix = "Set TERM to a color terminal such as xterm-256color, or \"accessible\": true in the settings for plain output"
+	}
+	return check
+}
+
+// checkGit reports the git version, which review, hook and the git tools need
+func checkGit(root string) doctorCheck {
+	check := doctorCheck{Name: "Git"}
+
+	output, err := exec.Command("git", "--version").Output()
+	if err != nil {
+		check.Status = doctorWarn
+		check.Detail = "git not found on PATH"
+		check.Fix = "Install git to use `cli-agent review`, `cli-agent hook` and the agent's git tools"
+		return check
+	}
+
+	check.Detail = strings.TrimSpace(string(output))
+
+	inside := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	inside.Dir = root
+	if err := inside.Run(); err == nil {
+		check.Detail += "; the workspace is a repository"
+	} else {
+		check.Detail += "; the workspace isn't a repository"
+	}
+	return check
+}
+
+// checkSettings strictly validates the user settings. It returns the
+// settings with defaults, and whether they could be used.
+func checkSettings() (doctorCheck, config.Settings, bool) {
+	check := doctorCheck{Name: "Settings"}
+
+	path, err := config.SettingsPath()
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Fix = "Set HOME (or XDG_CONFIG_HOME) so cli-agent can find its config directory"
+		return check, config.Settings{}, false
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		check.Detail = path + " doesn't exist; using the defaults"
+		settings, _ := config.LoadSettings()
+		return check, settings, true
+	}
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Fix = "Make " + path + " readable"
+		return check, config.Settings{}, false
+	}
+
+	if _, err := config.ParseSettings(data); err != nil {
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("%s: %v", path, err)
+		check.Fix = "Correct it with `cli-agent config edit`"
+		return check, config.Settings{}, false
+	}
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Fix = "Correct it with `cli-agent config edit`"
+		return check, config.Settings{}, false
+	}
+
+	check.Detail = path + " is valid"
+	return check, settings, true
+}
+
+// checkProjectConfig strictly validates the workspace's project config, if it has one
+func checkProjectConfig(root string) doctorCheck {
+	check := doctorCheck{Name: "Project config"}
+	path := config.ProjectConfigPath(root)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		check.Detail = "none in " + root
+		return check
+	}
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Fix = "Make " + path + " readable"
+		return check
+	}
+
+	if _, err := config.ParseProjectConfig(data); err != nil {
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("%s: %v", path, err)
+		check.Fix = "Correct it with `cli-agent config edit --project`"
+		return check
+	}
+
+	check.Detail = path + " is valid"
+	return check
+}
+
+// checkAPI resolves the profile's credentials and lists the models they can
+// use, which proves the key works and the endpoint is reachable
+func checkAPI(settings config.Settings, name string, offline bool) doctorCheck {
+	check := doctorCheck{Name: "API"}
+
+	name, profile, err := settings.Profile(name)
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Fix = "Pass an existing --profile, or add the profile with `cli-agent config edit`"
+		return check
+	}
+
+	login := "cli-agent auth login"
+	if name != "" {
+		login += " --profile " + name
+	}
+
+	client, err := profile.NewClient(name)
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = err.Error()
+		check.Fix = fmt.Sprintf("Fix the profile's key source, or run `%s`", login)
+		return check
+	}
+
+	if profile.OAuth == nil && profile.APIKeyCommand == "" && profile.APIKeyEnv == "" &&
+		os.Getenv("ANTHROPIC_API_KEY") == "" && os.Getenv("ANTHROPIC_AUTH_TOKEN") == "" {
+		if _, err := config.LoadAPIKey(config.KeychainAccount(name)); errors.Is(err, config.ErrKeyNotFound) {
+			check.Status = doctorFail
+			check.Detail = "no API key"
+			check.Fix = fmt.Sprintf("Run `%s` or set ANTHROPIC_API_KEY", login)
+			return check
+		}
+	}
+
+	endpoint := "api.anthropic.com"
+	if base := os.Getenv("ANTHROPIC_BASE_URL"); base != "" {
+		endpoint = base
+	}
+	if profile.BaseURL != "" {
+		endpoint = profile.BaseURL
+	}
+	if offline {
+		check.Detail = "not checked (--offline); requests go to " + endpoint
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	page, err := client.Models.List(ctx, anthropic.ModelListParams{}, option.WithMaxRetries(0))
+	if err != nil {
+		check.Status = doctorFail
+		check.Detail = fmt.Sprintf("%s: %v", endpoint, err)
+
+		var apiErr *anthropic.Error
+		switch {
+		case errors.As(err, &apiErr) && (apiErr.StatusCode == 401 || apiErr.StatusCode == 403):
+			check.Detail = fmt.Sprintf("%s rejected the key (%d)", endpoint, apiErr.StatusCode)
+			check.Fix = fmt.Sprintf("Save a valid key with `%s`", login)
+		case errors.As(err, &apiErr):
+			check.Fix = "Check the profile's base_url, or try again later"
+		default:
+			check.Fix = "Check your network connection, proxy settings (HTTPS_PROXY) and the profile's base_url"
+		}
+		return check
+	}
+
+	check.Detail = fmt.Sprintf("%s is reachable; the key can use %d models", endpoint, len(page.Data))
+	if name != "" {
+		check.Detail = "profile " + name + ": " + check.Detail
+	}
+	return check
+}
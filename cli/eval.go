@@ -0,0 +1,286 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"agent/agent"
+	"agent/config"
+	"agent/provider"
+	"agent/tools"
+)
+
+// evalTask is the task.json of one evaluation task directory. Next to it, a
+// task has an optional workspace/ fixture that is copied into a scratch
+// directory for the run, and a check.sh assertion script that runs in that
+// directory afterwards; the task passes when the script exits zero.
+type evalTask struct {
+	Prompt  string `json:"prompt"`
+	Timeout int    `json:"timeout_seconds,omitempty"`
+}
+
+// defaultEvalTimeout bounds a task that doesn't set timeout_seconds
+const defaultEvalTimeout = 5 * time.Minute
+
+// evalResult is the outcome of running one task
+type evalResult struct {
+	Name     string
+	Passed   bool
+	Reason   string
+	Usage    agent.Usage
+	Duration time.Duration
+}
+
+// Eval runs a directory of tasks through the agent and reports pass/fail and cost
+func Eval(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	run := flags.String("run", "", "Only run tasks whose name matches this regular expression")
+	keep := flags.Bool("keep", false, "Keep the scratch workspaces instead of deleting them")
+	verbose := flags.Bool("v", false, "Print the agent transcript of every task")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent eval [--profile name] [--run regexp] [--keep] [-v] <tasks-dir>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one tasks directory")
+	}
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			return fmt.Errorf("invalid --run pattern: %w", err)
+		}
+	}
+
+	tasksDir := flags.Arg(0)
+	entries, err := os.ReadDir(tasksDir)
+	if err != nil {
+		return fmt.Errorf("failed to read tasks directory: %w", err)
+	}
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	modelProvider := provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+	activeProfile := agent.ActiveProfile{Name: cfg.Profile, Provider: modelProvider, Model: cfg.Model, RateLimits: cfg.RateLimits}
+
+	var results []evalResult
+	for _, entry := range entries {
+		if !entry.IsDir() || (filter != nil && !filter.MatchString(entry.Name())) {
+			continue
+		}
+
+		taskDir := filepath.Join(tasksDir, entry.Name())
+		if _, err := os.Stat(filepath.Join(taskDir, "task.json")); err != nil {
+			continue
+		}
+
+		fmt.Printf("=== RUN   %s\n", entry.Name())
+		result := runEvalTask(activeProfile, entry.Name(), taskDir, *keep, *verbose)
+		results = append(results, result)
+
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("--- %s: %s (%s, %s)\n", status, result.Name, result.Duration.Round(time.Millisecond), formatUsage(result.Usage))
+		if result.Reason != "" {
+			fmt.Printf("    %s\n", strings.ReplaceAll(strings.TrimSpace(result.Reason), "\n", "\n    "))
+		}
+	}
+
+	if len(results) == 0 {
+		return fmt.Errorf("no tasks found in %s", tasksDir)
+	}
+
+	passed := 0
+	total := agent.Usage{}
+	for _, result := range results {
+		if result.Passed {
+			passed++
+		}
+		total = total.Add(result.Usage)
+	}
+
+	fmt.Printf("\n%d/%d tasks passed • %s\n", passed, len(results), formatUsage(total))
+
+	if passed < len(results) {
+		return fmt.Errorf("%d task(s) failed", len(results)-passed)
+	}
+
+	return nil
+}
+
+// runEvalTask runs one task in a scratch copy of its fixture and checks the result
+func runEvalTask(profile agent.ActiveProfile, name, taskDir string, keep, verbose bool) evalResult {
+	result := evalResult{Name: name}
+	started := time.Now()
+	defer func() { result.Duration = time.Since(started) }()
+
+	data, err := os.ReadFile(filepath.Join(taskDir, "task.json"))
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	var task evalTask
+	if err := json.Unmarshal(data, &task); err != nil {
+		result.Reason = fmt.Sprintf("failed to parse task.json: %s", err)
+		return result
+	}
+	if strings.TrimSpace(task.Prompt) == "" {
+		result.Reason = "task.json has no prompt"
+		return result
+	}
+
+	scratch, err := os.MkdirTemp("", "cli-agent-eval-"+name+"-")
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+	if keep {
+		fmt.Printf("    workspace: %s\n", scratch)
+	} else {
+		defer os.RemoveAll(scratch)
+	}
+
+	if err := copyDir(filepath.Join(taskDir, "workspace"), scratch, nil); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		result.Reason = fmt.Sprintf("failed to copy fixture: %s", err)
+		return result
+	}
+
+	workspace, err := tools.NewWorkspace(scratch)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	// The scratch copy is disposable, so write tools are always allowed
+	agentApp := agent.NewAgent(profile.Provider, tools.GetAllTools(), workspace)
+	agentApp.UseProfile(profile)
+	agentApp.SetTrusted(true)
+
+	timeout := defaultEvalTimeout
+	if task.Timeout > 0 {
+		timeout = time.Duration(task.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for event := range agentApp.RunTurn(ctx, agent.NewSession(), task.Prompt) {
+		switch event := event.(type) {
+		case agent.Usage:
+			result.Usage = result.Usage.Add(event)
+		case agent.TextDelta:
+			if verbose {
+				fmt.Print(event.Text)
+			}
+		case agent.ToolCallStarted:
+			if verbose {
+				fmt.Printf("\n  → %s %s\n", event.Name, event.Input)
+			}
+		case agent.Error:
+			result.Reason = fmt.Sprintf("agent error: %s", event.Err)
+		}
+	}
+	if verbose {
+		fmt.Println()
+	}
+	if result.Reason != "" {
+		return result
+	}
+
+	check := filepath.Join(taskDir, "check.sh")
+	if _, err := os.Stat(check); err != nil {
+		result.Reason = "task has no check.sh"
+		return result
+	}
+
+	absCheck, err := filepath.Abs(check)
+	if err != nil {
+		result.Reason = err.Error()
+		return result
+	}
+
+	cmd := exec.Command("sh", absCheck)
+	cmd.Dir = scratch
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		result.Reason = fmt.Sprintf("check.sh failed: %s\n%s", err, output)
+		return result
+	}
+
+	result.Passed = true
+	return result
+}
+
+// formatUsage renders token counts and, when the price is known, the cost
+func formatUsage(usage agent.Usage) string {
+	text := fmt.Sprintf("%d tokens", usage.TotalTokens())
+	if cost, ok := usage.Cost(agent.DefaultModel); ok {
+		text += fmt.Sprintf(", $%.4f", cost)
+	}
+	return text
+}
+
+// copyDir recursively copies the files under src into dst, leaving out
+// directories whose name is in skipDirs
+func copyDir(src, dst string, skipDirs map[string]bool) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if entry.IsDir() {
+			if path != src && skipDirs[entry.Name()] {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
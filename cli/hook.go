@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"agent/agent"
+	"agent/config"
+	"agent/provider"
+	"agent/review"
+	"agent/tools"
+)
+
+// hookMarker identifies hook scripts written by `cli-agent hook install`
+const hookMarker = "# Installed by cli-agent"
+
+// Hook implements git hook integrations
+func Hook(args []string) error {
+	usage := "Usage: cli-agent hook <pre-commit|install> [flags]"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	switch args[0] {
+	case "pre-commit":
+		return hookPreCommit(args[1:])
+	case "install":
+		return hookInstall(args[1:])
+	default:
+		return fmt.Errorf("unknown hook %q\n%s", args[0], usage)
+	}
+}
+
+// hookPreCommit reviews the staged changes and fails when rules are violated
+func hookPreCommit(args []string) error {
+	flags := flag.NewFlagSet("hook pre-commit", flag.ContinueOnError)
+	fix := flags.Bool("fix", false, "Let the agent fix the problems and re-stage the fixed files")
+	noModel := flags.Bool("no-model", false, "Skip the model review of natural-language rules")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	root, err := gitOutput("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	projectConfig, err := config.LoadProjectConfig(root)
+	if err != nil {
+		return err
+	}
+	rules := projectConfig.PreCommit
+
+	ctx := context.Background()
+	var modelProvider provider.Provider
+	if len(rules.Rules) > 0 && !*noModel {
+		cfg, err := config.LoadConfig(*profile)
+		if err != nil {
+			return err
+		}
+		modelProvider = provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+	}
+
+	findings, err := reviewStaged(ctx, root, rules, modelProvider)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		fmt.Println("pre-commit: no problems found")
+		return nil
+	}
+
+	printFindings(findings)
+
+	if *fix {
+		if err := fixStaged(ctx, root, *profile, findings); err != nil {
+			return err
+		}
+
+		if findings, err = reviewStaged(ctx, root, rules, modelProvider); err != nil {
+			return err
+		}
+		if len(findings) == 0 {
+			fmt.Println("pre-commit: all problems fixed")
+			return nil
+		}
+
+		fmt.Println("\nRemaining problems:")
+		printFindings(findings)
+	}
+
+	return fmt.Errorf("pre-commit: %d problem(s) found; fix them or commit with --no-verify", len(findings))
+}
+
+// reviewStaged applies the configured rules to the staged diff
+func reviewStaged(ctx context.Context, root string, rules config.ReviewConfig, modelProvider provider.Provider) ([]review.Finding, error) {
+	staged, err := gitOutput(root, "diff", "--cached", "--no-color", "--no-ext-diff", "-U3")
+	if err != nil {
+		return nil, err
+	}
+
+	return reviewChanges(ctx, root, staged, rules, modelProvider)
+}
+
+// fixStaged runs the agent on the findings and re-stages the files it fixed.
+// Only files that were already staged are re-staged, and not when they also
+// have unstaged changes, so unrelated work doesn't sneak into the commit.
+func fixStaged(ctx context.Context, root, profile string, findings []review.Finding) error {
+	if config.LoadTrust(root) != config.TrustGranted {
+		return fmt.Errorf("workspace %s is not trusted; open it interactively and trust it before using --fix", root)
+	}
+
+	unstaged, err := gitOutput(root, "diff", "--name-only")
+	if err != nil {
+		return err
+	}
+	dirty := map[string]bool{}
+	for _, file := range strings.Split(unstaged, "\n") {
+		dirty[file] = true
+	}
+
+	stagedFiles, err := gitOutput(root, "diff", "--cached", "--name-only")
+	if err != nil {
+		return err
+	}
+	staged := map[string]bool{}
+	for _, file := range strings.Split(stagedFiles, "\n") {
+		staged[file] = true
+	}
+
+	workspace, err := tools.NewWorkspace(root)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(profile)
+	if err != nil {
+		return err
+	}
+	modelProvider := provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+	agentApp := agent.NewAgent(modelProvider, tools.GetAllTools(), workspace)
+	agentApp.UseProfile(agent.ActiveProfile{Name: cfg.Profile, Provider: modelProvider, Model: cfg.Model, RateLimits: cfg.RateLimits})
+	agentApp.SetTrusted(true)
+
+	var prompt strings.Builder
+	prompt.WriteString("A pre-commit review of the staged changes found these problems:\n\n")
+	for _, finding := range findings {
+		prompt.WriteString("- " + finding.String() + "\n")
+	}
+	prompt.WriteString("\nFix them with minimal changes. Don't modify files that aren't involved.")
+
+	fmt.Println("\nAsking the agent to fix the problems…")
+	if err := printTurn(agentApp.RunTurn(ctx, agent.NewSession(), prompt.String())); err != nil {
+		return fmt.Errorf("agent error: %w", err)
+	}
+
+	for _, change := range agentApp.SessionChanges() {
+		rel, err := filepath.Rel(root, change.AbsPath)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !staged[rel] {
+			fmt.Printf("  %s wasn't staged; review the agent's change and stage it yourself if it belongs in the commit\n", rel)
+			continue
+		}
+		if dirty[rel] {
+			fmt.Printf("  %s has unstaged changes of its own; review and stage the fix yourself\n", rel)
+			continue
+		}
+		if _, err := gitOutput(root, "add", "--", rel); err != nil {
+			return err
+		}
+		fmt.Printf("  re-staged %s\n", rel)
+	}
+
+	return nil
+}
+
+// hookInstall writes a git pre-commit hook that runs `cli-agent hook pre-commit`
+func hookInstall(args []string) error {
+	flags := flag.NewFlagSet("hook install", flag.ContinueOnError)
+	force := flags.Bool("force", false, "Replace an existing pre-commit hook")
+	fix := flags.Bool("fix", false, "Install the hook with --fix")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	hooksDir, err := gitOutput("", "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(hooksDir, "pre-commit")
+
+	if existing, err := os.ReadFile(path); err == nil && !bytes.Contains(existing, []byte(hookMarker)) && !*force {
+		return fmt.Errorf("%s already exists; pass --force to replace it", path)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate cli-agent: %w", err)
+	}
+
+	command := fmt.Sprintf("exec %s hook pre-commit", shellQuote(executable))
+	if *fix {
+		command += " --fix"
+	}
+
+	script := fmt.Sprintf("#!/bin/sh\n%s\n%s\n", hookMarker, command)
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return fmt.Errorf("failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return fmt.Errorf("failed to write hook: %w", err)
+	}
+
+	fmt.Printf("Installed %s\n", path)
+	return nil
+}
+
+// shellQuote quotes a word for the POSIX shell git runs hooks with, on every platform
+func shellQuote(word string) string {
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// printFindings lists findings on stdout
+func printFindings(findings []review.Finding) {
+	for _, finding := range findings {
+		fmt.Println(finding.String())
+	}
+}
+
+// gitOutput runs git in dir and returns its trimmed output
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
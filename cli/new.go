@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/config"
+	"agent/templates"
+)
+
+// NewProject is a project created by `cli-agent new`, to open the chat in
+type NewProject struct {
+	Dir    string
+	Notice string
+}
+
+// New creates a project from a template. It returns the project to open the
+// chat in, or nil when there is nothing to open, e.g. after --list.
+func New(args []string) (*NewProject, error) {
+	usage := "Usage: cli-agent new [--var key=value]... [--no-chat] <template> [dir]\n       cli-agent new --list"
+
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	list := flags.Bool("list", false, "List the available templates")
+	noChat := flags.Bool("no-chat", false, "Create the project without opening a chat in it")
+	given := map[string]string{}
+	flags.Func("var", "Template variable as key=value (repeatable)", func(value string) error {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", value)
+		}
+		given[key] = val
+		return nil
+	})
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *list {
+		return nil, listTemplates()
+	}
+
+	if flags.NArg() == 0 || flags.NArg() > 2 {
+		flags.Usage()
+		return nil, fmt.Errorf("no template given")
+	}
+
+	tmpl, err := templates.Find(flags.Arg(0))
+	if err != nil {
+		return nil, err
+	}
+
+	// The directory defaults to the project name, and the name to the directory
+	dir := flags.Arg(1)
+	if dir == "" {
+		dir = given["name"]
+	}
+	if dir == "" {
+		dir = tmpl.Name
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := given["name"]; !ok {
+		given["name"] = filepath.Base(dir)
+	}
+
+	values, missing := tmpl.Values(given)
+	if len(missing) > 0 {
+		input := bufio.NewReader(os.Stdin)
+		for _, variable := range missing {
+			prompt := variable.Name
+			if variable.Description != "" {
+				prompt = variable.Description
+			}
+			fmt.Printf("%s: ", prompt)
+			answer, _ := input.ReadString('\n')
+			given[variable.Name] = strings.TrimSpace(answer)
+		}
+		values, _ = tmpl.Values(given)
+	}
+
+	written, err := tmpl.Create(dir, values)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("Created %s from template %s:\n", dir, tmpl.Name)
+	for _, path := range written {
+		fmt.Printf("  %s\n", path)
+	}
+
+	if *noChat {
+		return nil, nil
+	}
+
+	// The user just created these files, so the agent may work on them
+	if err := config.SaveTrust(dir, config.TrustGranted); err != nil {
+		return nil, err
+	}
+
+	notice := fmt.Sprintf("Created %s from template %s.", filepath.Base(dir), tmpl.Name)
+	if tmpl.Prompt != "" {
+		notice += " " + templates.Substitute(tmpl.Prompt, values)
+	}
+
+	return &NewProject{Dir: dir, Notice: notice}, nil
+}
+
+// listTemplates prints the available templates and their variables
+func listTemplates() error {
+	list, err := templates.List()
+	if err != nil {
+		return err
+	}
+
+	for _, tmpl := range list {
+		source := ""
+		if tmpl.User {
+			source = " (user)"
+		}
+		fmt.Printf("%s%s\n  %s\n", tmpl.Name, source, tmpl.Description)
+		for _, variable := range tmpl.Variables {
+			fmt.Printf("  --var %s=…  %s", variable.Name, variable.Description)
+			if variable.Default != "" {
+				fmt.Printf(" (default %q)", variable.Default)
+			}
+			fmt.Println()
+		}
+	}
+
+	if dir, err := templates.UserDir(); err == nil {
+		fmt.Printf("\nAdd your own templates as directories in %s\n", dir)
+	}
+
+	return nil
+}
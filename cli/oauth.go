@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"agent/config"
+)
+
+// oauthLoginTimeout bounds how long the login waits for the browser to come back
+const oauthLoginTimeout = 5 * time.Minute
+
+// oauthLogin signs a profile in with the authorization code flow and PKCE.
+// The browser is redirected back to a one-off server on the loopback interface.
+func oauthLogin(settings config.OAuthConfig, profile string) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to start the login callback server: %w", err)
+	}
+	defer listener.Close()
+
+	redirectURL := fmt.Sprintf("http://%s/callback", listener.Addr())
+	verifier := randomToken()
+	state := randomToken()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authorizeURL, err := url.Parse(settings.AuthorizeURL)
+	if err != nil {
+		return fmt.Errorf("invalid authorize_url: %w", err)
+	}
+	query := authorizeURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", settings.ClientID)
+	query.Set("redirect_uri", redirectURL)
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	if len(settings.Scopes) > 0 {
+		query.Set("scope", strings.Join(settings.Scopes, " "))
+	}
+	authorizeURL.RawQuery = query.Encode()
+
+	type callback struct {
+		code string
+		err  error
+	}
+	results := make(chan callback, 1)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+
+		params := r.URL.Query()
+		result := callback{code: params.Get("code")}
+		switch {
+		case params.Get("state") != state:
+			result.err = fmt.Errorf("login callback had an unexpected state")
+		case params.Get("error") != "":
+			result.err = fmt.Errorf("login failed: %s %s", params.Get("error"), params.Get("error_description"))
+		case result.code == "":
+			result.err = fmt.Errorf("login callback had no authorization code")
+		}
+
+		if result.err != nil {
+			http.Error(w, result.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Signed in. You can close this window and return to the terminal.")
+		}
+
+		select {
+		case results <- result:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	fmt.Fprintf(os.Stderr, "Opening the browser to sign in. If it doesn't open, visit:\n%s\n", authorizeURL)
+	openBrowser(authorizeURL.String())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, oauthLoginTimeout)
+	defer cancel()
+
+	var result callback
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("timed out waiting for the browser login")
+		}
+		return fmt.Errorf("login cancelled")
+	}
+	if result.err != nil {
+		return result.err
+	}
+
+	token, err := settings.ExchangeOAuthCode(result.code, verifier, redirectURL)
+	if err != nil {
+		return err
+	}
+	if err := config.StoreOAuthToken(profile, token); err != nil {
+		return err
+	}
+
+	fmt.Printf("Signed in as %s.\n", config.KeychainAccount(profile))
+	return nil
+}
+
+// randomToken returns 32 random bytes, base64url encoded, for PKCE verifiers and state
+func randomToken() string {
+	data := make([]byte, 32)
+	rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// openBrowser opens url in the default browser, ignoring failures since the URL is also printed
+func openBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err == nil {
+		go cmd.Wait()
+	}
+}
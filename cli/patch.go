@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/agent"
+	"agent/diff"
+)
+
+// patchSkipDirs are left out of the scratch copy used in patch mode
+var patchSkipDirs = map[string]bool{
+	".git": true,
+	".hg":  true,
+	".svn": true,
+}
+
+// newPatchWorkspace copies root to a scratch directory for the agent to edit
+// in patch mode, so the original tree is never written to
+func newPatchWorkspace(root string) (string, error) {
+	scratch, err := os.MkdirTemp("", "cli-agent-patch-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := copyDir(root, scratch, patchSkipDirs); err != nil {
+		os.RemoveAll(scratch)
+		return "", fmt.Errorf("failed to copy workspace: %w", err)
+	}
+
+	return scratch, nil
+}
+
+// writePatch writes the agent's changes in scratch as a unified diff against
+// the original tree at root, with a/ and b/ prefixes for `git apply` or `patch -p1`
+func writePatch(w io.Writer, agentApp *agent.Agent, root, scratch string) error {
+	for _, change := range agentApp.SessionChanges() {
+		rel, err := filepath.Rel(scratch, change.AbsPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		before, existedBefore, err := readPatchFile(filepath.Join(root, rel))
+		if err != nil {
+			return err
+		}
+		after, existsAfter, err := readPatchFile(change.AbsPath)
+		if err != nil {
+			return err
+		}
+
+		fromName, toName := "a/"+rel, "b/"+rel
+		if !existedBefore {
+			fromName = "/dev/null"
+		}
+		if !existsAfter {
+			toName = "/dev/null"
+		}
+
+		if _, err := io.WriteString(w, diff.Unified(fromName, toName, before, after, 3)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readPatchFile reads a file for diffing, reporting whether it exists
+func readPatchFile(path string) (string, bool, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return string(content), true, nil
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"agent/agent"
+	"agent/config"
+	"agent/provider/mock"
+	"agent/recording"
+	"agent/tools"
+)
+
+// Replay re-drives a recorded session without the API and reports where it diverges
+func Replay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Working directory for tools (defaults to the current directory)")
+	liveTools := flags.Bool("live-tools", false, "Execute tools in the workspace and compare their output with the recorded results")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent replay [--dir path] [--live-tools] <session.json>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected exactly one recording file")
+	}
+
+	file, err := recording.Load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	workspace, err := tools.NewWorkspace(*dir)
+	if err != nil {
+		return err
+	}
+
+	mockProvider := mock.New(file.Responses()...)
+	agentApp := agent.NewAgent(mockProvider, tools.GetAllTools(), workspace)
+
+	if *liveTools {
+		if config.LoadTrust(workspace.Root()) != config.TrustGranted {
+			return fmt.Errorf("workspace %s is not trusted; open it interactively and trust it before replaying with --live-tools", workspace.Root())
+		}
+		agentApp.SetTrusted(true)
+	}
+
+	recorded := map[string]recording.ToolResult{}
+	for _, result := range file.ToolResults {
+		recorded[result.ID] = result
+	}
+
+	divergences := 0
+	agentApp.SetToolInterceptor(func(call agent.ToolCall, next func() (string, error)) (string, error) {
+		expected, ok := recorded[call.ID]
+		if !ok {
+			divergences++
+			fmt.Printf("  ! no recorded result for %s (%s)\n", call.Name, call.ID)
+			return "", fmt.Errorf("no recorded result for tool call %s", call.ID)
+		}
+
+		if !*liveTools {
+			if expected.IsError {
+				return "", errors.New(expected.Content)
+			}
+			return expected.Content, nil
+		}
+
+		content, err := next()
+		isError := err != nil
+		if isError {
+			content = err.Error()
+		}
+
+		if content != expected.Content || isError != expected.IsError {
+			divergences++
+			fmt.Printf("  ! %s (%s) output differs from the recording\n    recorded: %q\n    actual:   %q\n", call.Name, call.ID, expected.Content, content)
+		}
+
+		return content, err
+	})
+
+	session := agent.NewSession()
+	for _, input := range file.Inputs() {
+		fmt.Printf("\n> %s\n", input)
+
+		if err := printTurn(agentApp.RunTurn(context.Background(), session, input)); err != nil {
+			divergences++
+			fmt.Fprintf(os.Stderr, "\n  ! %s\n", err)
+		}
+		fmt.Println()
+	}
+
+	if remaining := mockProvider.Remaining(); remaining > 0 {
+		divergences++
+		fmt.Printf("\n! %d recorded response(s) were never requested\n", remaining)
+	}
+
+	if divergences > 0 {
+		return fmt.Errorf("replay diverged from the recording in %d place(s)", divergences)
+	}
+
+	fmt.Println("\nReplay matched the recording.")
+	return nil
+}
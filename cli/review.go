@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"agent/config"
+	"agent/diff"
+	"agent/provider"
+	"agent/review"
+)
+
+// Review checks a diff against the project's review rules and reports the
+// findings, in a format CI systems can turn into inline annotations
+func Review(args []string) error {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	base := flags.String("base", "", "Review the changes since this git ref, e.g. origin/main (defaults to uncommitted changes)")
+	staged := flags.Bool("staged", false, "Review only the staged changes")
+	diffFile := flags.String("diff", "", "Review a unified diff from this file instead of git (- for stdin)")
+	format := flags.String("format", "text", "Output format: text, sarif, rdjson (reviewdog) or github (Actions annotations)")
+	output := flags.String("output", "", "Write the findings to this file instead of stdout")
+	noModel := flags.Bool("no-model", false, "Skip the model review of natural-language rules")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent review [--base ref | --staged | --diff file] [--format text|sarif|rdjson|github] [--output file] [--profile name]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	writeFindings, err := findingsWriter(*format)
+	if err != nil {
+		return err
+	}
+
+	root, err := gitOutput("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		if *diffFile == "" {
+			return err
+		}
+		if root, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+
+	changes, err := readChanges(root, *base, *staged, *diffFile)
+	if err != nil {
+		return err
+	}
+
+	projectConfig, err := config.LoadProjectConfig(root)
+	if err != nil {
+		return err
+	}
+
+	var modelProvider provider.Provider
+	if len(projectConfig.Review.Rules) > 0 && !*noModel {
+		cfg, err := config.LoadConfig(*profile)
+		if err != nil {
+			return err
+		}
+		modelProvider = provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+	}
+
+	findings, err := reviewChanges(context.Background(), root, changes, projectConfig.Review, modelProvider)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := writeFindings(out, findings); err != nil {
+		return err
+	}
+
+	failures := 0
+	for _, finding := range findings {
+		if finding.Severity == review.SeverityError {
+			failures++
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("review: %d error(s) found", failures)
+	}
+
+	return nil
+}
+
+// findingsWriter returns the writer for an output format
+func findingsWriter(format string) (func(io.Writer, []review.Finding) error, error) {
+	switch format {
+	case "text":
+		return writeFindingsText, nil
+	case "sarif":
+		return review.WriteSARIF, nil
+	case "rdjson":
+		return review.WriteRDJSON, nil
+	case "github":
+		return review.WriteGitHub, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q: use text, sarif, rdjson or github", format)
+	}
+}
+
+func writeFindingsText(w io.Writer, findings []review.Finding) error {
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "review: no problems found")
+		return err
+	}
+
+	for _, finding := range findings {
+		if _, err := fmt.Fprintln(w, finding.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readChanges returns the unified diff to review
+func readChanges(root, base string, staged bool, diffFile string) (string, error) {
+	switch {
+	case diffFile == "-":
+		data, err := io.ReadAll(os.Stdin)
+		return string(data), err
+	case diffFile != "":
+		data, err := os.ReadFile(diffFile)
+		return string(data), err
+	case staged:
+		return gitOutput(root, "diff", "--cached", "--no-color", "--no-ext-diff", "-U3")
+	case base != "":
+		return gitOutput(root, "diff", "--no-color", "--no-ext-diff", "-U3", base+"...HEAD")
+	default:
+		return gitOutput(root, "diff", "--no-color", "--no-ext-diff", "-U3", "HEAD")
+	}
+}
+
+// reviewChanges applies review rules to a unified diff
+func reviewChanges(ctx context.Context, root, changes string, rules config.ReviewConfig, modelProvider provider.Provider) ([]review.Finding, error) {
+	files := diff.Parse(changes)
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	var findings []review.Finding
+	if rules.NoTodos {
+		findings = append(findings, review.NoTodos(files)...)
+	}
+	if rules.RequireTests {
+		findings = append(findings, review.TestsUpdated(files)...)
+	}
+
+	// Checks see the working tree, which may differ from the diff under review
+	for _, result := range runChecks(ctx, root, rules.Checks) {
+		if !result.Passed {
+			message := fmt.Sprintf("`%s` failed", result.Check.Command)
+			if output := strings.TrimSpace(result.Output); output != "" {
+				message += ":\n" + output
+			}
+
+			findings = append(findings, review.Finding{
+				Rule:     checkName(result.Check),
+				Message:  message,
+				Severity: review.SeverityError,
+			})
+		}
+	}
+
+	if modelProvider != nil {
+		modelFindings, _, err := review.Review(ctx, modelProvider, files, rules.Rules)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, modelFindings...)
+	}
+
+	review.Sort(findings)
+	return findings, nil
+}
@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"agent/agent"
+	"agent/api"
+	"agent/config"
+	"agent/provider"
+	"agent/review"
+	"agent/tools"
+)
+
+// annotationInstructions are appended to the prompt when --github-annotations is set
+const annotationInstructions = "\n\nReport every problem you find with the report_problem tool, one call per problem, " +
+	"giving the file and line where it occurs."
+
+// Run executes a single prompt without the TUI and prints the agent's output
+func Run(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Working directory for the agent (defaults to the current directory)")
+	trust := flags.Bool("trust", false, "Allow write tools even if the workspace hasn't been trusted interactively")
+	annotations := flags.Bool("github-annotations", false, "Print problems the agent reports as GitHub Actions workflow commands")
+	patch := flags.Bool("patch", false, "Don't write files; print the agent's changes as a unified diff on stdout")
+	patchFile := flags.String("patch-file", "", "Like --patch, but write the diff to this file")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	approvals := flags.String("approvals", "", "Approval policy: allow, deny, or an http(s) URL to ask (defaults to approvals.policy in settings.json; with nobody to ask, requests are denied)")
+	toolChoice := flags.String("tool-choice", "", "auto, any, none or a tool's name (defaults to request.tool_choice in the project config)")
+	responseCache := flags.String("response-cache", "", "Answer repeated requests from responses cached in this directory instead of the API (for development)")
+	var stopSequences stringsFlag
+	flags.Var(&stopSequences, "stop", "Stop sequence ending the model's responses; may be repeated (replaces request.stop_sequences in the project config)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent run [--dir path] [--profile name] [--trust] [--approvals policy] [--tool-choice choice] [--stop sequence]... [--response-cache dir] [--github-annotations] [--patch | --patch-file file] <prompt...|->")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	prompt, err := readPrompt(flags.Args())
+	if err != nil {
+		flags.Usage()
+		return err
+	}
+	turnOptions, prompt, err := agent.ParseTurnModifiers(prompt)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := tools.NewWorkspace(*dir)
+	if err != nil {
+		return err
+	}
+	root := workspace.Root()
+	trusted := *trust || config.LoadTrust(root) == config.TrustGranted
+
+	// In patch mode the agent edits a scratch copy and its changes are
+	// reported as a diff, so the workspace doesn't need to be trusted
+	patchMode := *patch || *patchFile != ""
+	transcript := io.Writer(os.Stdout)
+	var scratch string
+	if patchMode {
+		scratch, err = newPatchWorkspace(root)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(scratch)
+
+		if err := workspace.SetRoot(scratch); err != nil {
+			return err
+		}
+		trusted = true
+
+		// Keep stdout for the diff
+		if *patchFile == "" {
+			transcript = os.Stderr
+		}
+	}
+
+	availableTools := tools.GetAllTools()
+	if *annotations {
+		availableTools = append(availableTools, tools.ReportProblemDefinition)
+		prompt += annotationInstructions
+	}
+
+	var modelProvider provider.Provider = provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+	if *responseCache != "" {
+		modelProvider = provider.NewCache(modelProvider, *responseCache)
+	}
+	agentApp := agent.NewAgent(modelProvider, availableTools, workspace)
+	agentApp.UseProfile(agent.ActiveProfile{Name: cfg.Profile, Provider: modelProvider, Model: cfg.Model, RateLimits: cfg.RateLimits})
+	settings, _ := config.LoadSettings()
+	agentApp.SetResponseLanguage(settings.ResponseLanguage)
+	agentApp.SetTrusted(trusted)
+
+	policy, approvalTools, err := approvalSettings(settings, *approvals)
+	if err != nil {
+		return err
+	}
+	agentApp.SetApprover(api.PolicyApprover(policy, os.Getenv(approvalSecretEnv), nil))
+	agentApp.SetApprovalTools(approvalTools)
+	agentApp.SetModelRouter(settings.ModelRouter)
+	if err := agentApp.SetToolChoice(*toolChoice); err != nil {
+		return err
+	}
+	if stopSequences != nil {
+		agentApp.SetStopSequences(stopSequences)
+	}
+
+	var reporter problemReporter
+	if *annotations {
+		agentApp.SetToolInterceptor(reporter.intercept)
+	}
+
+	// SIGINT and SIGTERM cancel the model stream and skip the tool calls not
+	// yet run; the conversation is saved so it can be continued in the chat
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	session := agent.NewSession()
+	turnErr := printTurnTo(transcript, agentApp.RunTurnWith(ctx, session, prompt, turnOptions))
+	if ctx.Err() != nil {
+		stop()
+		if path, err := agent.SaveSession(session, root); err == nil && path != "" {
+			fmt.Fprintf(os.Stderr, "\nInterrupted. Continue the conversation in the chat with: cli-agent --resume %s\n", path)
+		}
+	}
+
+	if *annotations {
+		findings := reporter.findings()
+		review.Sort(findings)
+		if err := review.WriteGitHub(transcript, findings); err != nil {
+			return err
+		}
+	}
+
+	if patchMode {
+		if err := savePatch(*patchFile, agentApp, root, scratch); err != nil {
+			return err
+		}
+	}
+
+	return turnErr
+}
+
+// savePatch writes the patch to path, or to stdout when path is empty
+func savePatch(path string, agentApp *agent.Agent, root, scratch string) error {
+	if path == "" {
+		return writePatch(os.Stdout, agentApp, root, scratch)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create patch file: %w", err)
+	}
+	defer file.Close()
+
+	if err := writePatch(file, agentApp, root, scratch); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return file.Close()
+}
+
+// readPrompt joins the prompt arguments, reading stdin when the only argument is "-"
+func readPrompt(args []string) (string, error) {
+	if len(args) == 1 && args[0] == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read prompt: %w", err)
+		}
+		args = []string{string(data)}
+	}
+
+	prompt := strings.TrimSpace(strings.Join(args, " "))
+	if prompt == "" {
+		return "", fmt.Errorf("no prompt given")
+	}
+
+	return prompt, nil
+}
+
+// problemReporter collects report_problem calls as findings
+type problemReporter struct {
+	mu       sync.Mutex
+	reported []review.Finding
+}
+
+func (r *problemReporter) intercept(call agent.ToolCall, next func() (string, error)) (string, error) {
+	result, err := next()
+	if err != nil || call.Name != tools.ReportProblemDefinition.Name {
+		return result, err
+	}
+
+	var input tools.ReportProblemInput
+	if err := json.Unmarshal(call.Input, &input); err != nil {
+		return result, nil
+	}
+
+	severity := review.Severity(input.Severity)
+	if severity == "" {
+		severity = review.SeverityError
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reported = append(r.reported, review.Finding{
+		Path:     input.Path,
+		Line:     input.Line,
+		Rule:     input.Title,
+		Message:  input.Message,
+		Severity: severity,
+	})
+
+	return result, nil
+}
+
+func (r *problemReporter) findings() []review.Finding {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]review.Finding(nil), r.reported...)
+}
+
+// stringsFlag collects the values of a flag that may be given more than once
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ", ")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
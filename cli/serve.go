@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"agent/agent"
+	"agent/api"
+	"agent/config"
+	"agent/provider"
+	"agent/tools"
+)
+
+// tokenEnv holds the bearer token API clients must present
+const tokenEnv = "CLI_AGENT_TOKEN"
+
+// approvalSecretEnv holds the key approval callback requests are signed with.
+// It is separate from the token, which would give a callback full API access.
+const approvalSecretEnv = "CLI_AGENT_APPROVAL_SECRET"
+
+// Serve runs the WebSocket API, which editor extensions and web UIs use to
+// chat with the agent without running the TUI
+func Serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	address := flags.String("addr", "127.0.0.1:8420", "Address to listen on")
+	dir := flags.String("dir", "", "Working directory for the agent (defaults to the current directory)")
+	trust := flags.Bool("trust", false, "Allow write tools even if the workspace hasn't been trusted interactively")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	approvals := flags.String("approvals", "", "Approval policy: ask the client, allow, deny, or an http(s) URL to ask (defaults to approvals.policy in settings.json, or ask)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent serve [--addr host:port] [--dir path] [--profile name] [--trust] [--approvals policy]")
+		fmt.Fprintf(flags.Output(), "Serves chats over WebSocket at %s. Clients authenticate with the bearer token in %s, or a generated one that is printed at startup.\n", api.ChatPath, tokenEnv)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return err
+	}
+
+	policy, approvalTools, err := approvalSettings(settings, *approvals)
+	if err != nil {
+		return err
+	}
+
+	root, err := tools.NewWorkspace(*dir)
+	if err != nil {
+		return err
+	}
+	trusted := *trust || config.LoadTrust(root.Root()) == config.TrustGranted
+	newAgent := chatAgents(root.Root(), cfg, settings, trusted, approvalTools)
+
+	token := os.Getenv(tokenEnv)
+	if token == "" {
+		token, err = newToken()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Token: %s (set %s to choose your own)\n", token, tokenEnv)
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving chats in %s at ws://%s%s\n", root.Root(), *address, api.ChatPath)
+	if !trusted {
+		fmt.Fprintln(os.Stderr, "The workspace isn't trusted, so the agent can only read; pass --trust to let it make changes.")
+	}
+	server := api.NewServer(token, newAgent)
+	server.SetApprovalPolicy(policy, os.Getenv(approvalSecretEnv))
+	return http.ListenAndServe(*address, server)
+}
+
+// chatAgents returns a factory for the agents of API chats in root. Each
+// chat gets its own workspace, so one chat's staged writes and quotas don't
+// leak into another's.
+func chatAgents(root string, cfg *config.Config, settings config.Settings, trusted bool, approvalTools []string) func() (*agent.Agent, error) {
+	return func() (*agent.Agent, error) {
+		workspace, err := tools.NewWorkspace(root)
+		if err != nil {
+			return nil, err
+		}
+
+		modelProvider := provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+		agentApp := agent.NewAgent(modelProvider, tools.GetAllTools(), workspace)
+		agentApp.UseProfile(agent.ActiveProfile{Name: cfg.Profile, Provider: modelProvider, Model: cfg.Model, RateLimits: cfg.RateLimits})
+		agentApp.SetResponseLanguage(settings.ResponseLanguage)
+		agentApp.SetDryRun(settings.DryRun)
+		agentApp.SetTrusted(trusted)
+		agentApp.SetApprovalTools(approvalTools)
+		agentApp.SetModelRouter(settings.ModelRouter)
+		return agentApp, nil
+	}
+}
+
+// approvalSettings returns the approval policy, from the flag when it is set
+// or else the settings, and the tools that need approval before every call
+func approvalSettings(settings config.Settings, flagPolicy string) (string, []string, error) {
+	var policy string
+	var names []string
+	if settings.Approvals != nil {
+		policy, names = settings.Approvals.Policy, settings.Approvals.Tools
+	}
+	if flagPolicy != "" {
+		policy = flagPolicy
+	}
+
+	if err := config.ValidateApprovalPolicy(policy); err != nil {
+		return "", nil, fmt.Errorf("approvals: %w", err)
+	}
+	return policy, names, nil
+}
+
+// newToken generates a random bearer token
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate a token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
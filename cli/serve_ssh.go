@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"agent/config"
+	"agent/daemon"
+)
+
+// ServeSSH serves chats over SSH, so a team can run the agent on a shared
+// machine. Each SSH user gets their own sessions, which keep running between
+// connections.
+func ServeSSH(args []string, newChat daemon.ChatFactory) error {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("serve-ssh", flag.ContinueOnError)
+	address := flags.String("addr", ":2222", "Address to listen on")
+	hostKey := flags.String("host-key", filepath.Join(dir, "ssh_host_ed25519"), "The server's private key, generated when missing")
+	authorizedKeys := flags.String("authorized-keys", filepath.Join(dir, "ssh_authorized_keys"), "Public keys that may connect, in the format of ~/.ssh/authorized_keys")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent serve-ssh [flags] [-- chat flags]")
+		fmt.Fprintln(flags.Output(), "Serves the chat over SSH. `ssh -p 2222 -t <user>@<host> [name]` attaches to the user's session, or to their session with that name, starting it if needed. Ctrl+] detaches.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return daemon.NewServer(newChat).ServeSSH(daemon.SSHConfig{
+		Address:            *address,
+		HostKeyPath:        *hostKey,
+		AuthorizedKeysPath: *authorizedKeys,
+		Args:               flags.Args(),
+	})
+}
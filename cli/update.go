@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"agent/update"
+)
+
+// Update replaces cli-agent with the latest release from GitHub
+func Update(args []string) error {
+	flags := flag.NewFlagSet("update", flag.ContinueOnError)
+	check := flags.Bool("check", false, "Only report whether a newer release is available")
+	force := flags.Bool("force", false, "Install the latest release even if this build isn't older, e.g. a development build")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent update [--check] [--force]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	release, err := update.Latest(ctx)
+	if err != nil {
+		return err
+	}
+
+	newer := update.Newer(release.Version, update.Version)
+	switch {
+	case newer:
+		fmt.Printf("cli-agent %s is available (this is %s): %s\n", release.Version, update.Version, release.URL)
+	case !update.Released():
+		fmt.Printf("This is a development build; the latest release is %s.\n", release.Version)
+	default:
+		fmt.Printf("cli-agent %s is up to date.\n", update.Version)
+	}
+
+	if *check || (!newer && !*force) {
+		if !newer && !update.Released() && !*check {
+			fmt.Println("Pass --force to replace it with the release.")
+		}
+		return nil
+	}
+
+	if !update.CanInstall() {
+		return fmt.Errorf("this build has no release key to verify updates with; download %s from %s instead", release.Version, release.URL)
+	}
+
+	fmt.Printf("Downloading %s…\n", update.AssetName())
+	path, err := update.Install(ctx, release)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s to %s.\n", path, release.Version)
+	return nil
+}
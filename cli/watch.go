@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"agent/agent"
+	"agent/config"
+	"agent/provider"
+	"agent/tools"
+	"agent/watcher"
+)
+
+// settleTime is how long the workspace must be quiet before watching resumes
+// after checks or a fix ran, so their own output doesn't trigger another run
+const settleTime = 500 * time.Millisecond
+
+// Watch runs the project's checks whenever files change and starts an agent
+// turn to fix checks that newly fail
+func Watch(args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	dir := flags.String("dir", "", "Directory to watch (defaults to the current directory)")
+	autoApprove := flags.Bool("auto-approve", false, "Apply the agent's fixes without asking")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: cli-agent watch [--dir path] [--profile name] [--auto-approve]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	workspace, err := tools.NewWorkspace(*dir)
+	if err != nil {
+		return err
+	}
+	root := workspace.Root()
+
+	projectConfig, err := config.LoadProjectConfig(root)
+	if err != nil {
+		return err
+	}
+	if len(projectConfig.Checks) == 0 {
+		return fmt.Errorf("no checks configured; add a \"checks\" list to %s", config.ProjectConfigPath(root))
+	}
+
+	if config.LoadTrust(root) != config.TrustGranted {
+		return fmt.Errorf("workspace %s is not trusted; open it interactively and trust it before using watch mode", root)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg, err := config.LoadConfig(*profile)
+	if err != nil {
+		return err
+	}
+	modelProvider := provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+	agentApp := agent.NewAgent(modelProvider, tools.GetAllTools(), workspace)
+	agentApp.UseProfile(agent.ActiveProfile{Name: cfg.Profile, Provider: modelProvider, Model: cfg.Model, RateLimits: cfg.RateLimits})
+	agentApp.SetTrusted(true)
+
+	stdin := bufio.NewReader(os.Stdin)
+	agentApp.SetApprover(func(req agent.ApprovalRequest) bool {
+		return confirm(stdin, fmt.Sprintf("\n%s\n%s\n", req.Title, req.Detail))
+	})
+	if !*autoApprove {
+		agentApp.SetToolInterceptor(approveChanges(agentApp, stdin))
+	}
+
+	w, err := watcher.New(root)
+	if err != nil {
+		return fmt.Errorf("failed to watch %s: %w", root, err)
+	}
+	defer w.Close()
+
+	fmt.Printf("Watching %s. Checks run when files change; press Ctrl+C to stop.\n\n", root)
+
+	passing := map[string]bool{}
+	report := func(results []checkResult) []checkResult {
+		var newFailures []checkResult
+		for _, result := range results {
+			name := checkName(result.Check)
+			if result.Passed {
+				fmt.Printf("✓ %s\n", name)
+			} else {
+				fmt.Printf("✗ %s\n", name)
+				// Only checks that were passing count as new failures; pre-existing ones are left alone
+				if passing[name] {
+					newFailures = append(newFailures, result)
+				}
+			}
+			passing[name] = result.Passed
+		}
+		return newFailures
+	}
+
+	report(runChecks(ctx, root, projectConfig.Checks))
+	settle(w)
+
+	for {
+		var paths []string
+		select {
+		case <-ctx.Done():
+			fmt.Println("\nStopped watching.")
+			return nil
+		case batch, ok := <-w.Changes():
+			if !ok {
+				return nil
+			}
+			paths = batch
+		}
+
+		changed := agentApp.ExternalChanges(paths)
+		if len(changed) == 0 {
+			continue
+		}
+
+		fmt.Printf("\nChanged: %s\n", strings.Join(changed, ", "))
+		newFailures := report(runChecks(ctx, root, projectConfig.Checks))
+
+		if len(newFailures) > 0 {
+			fmt.Println("\nAsking the agent to fix the new failures…")
+			if err := printTurn(agentApp.RunTurn(ctx, agent.NewSession(), fixPrompt(changed, newFailures))); err != nil {
+				fmt.Fprintf(os.Stderr, "agent error: %s\n", err)
+			}
+
+			fmt.Println("\nRe-running checks…")
+			report(runChecks(ctx, root, projectConfig.Checks))
+		}
+
+		settle(w)
+	}
+}
+
+// fixPrompt asks the agent to fix checks that started failing after a change
+func fixPrompt(changed []string, failures []checkResult) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("These files were just changed: %s.\n", strings.Join(changed, ", ")))
+	b.WriteString("Since then the following checks fail that passed before:\n")
+	for _, failure := range failures {
+		b.WriteString(fmt.Sprintf("\n%s (`%s`):\n```\n%s\n```\n", checkName(failure.Check), failure.Check.Command, failure.Output))
+	}
+	b.WriteString("\nFind the cause and make the smallest change that fixes it. Don't rewrite the user's recent changes beyond what the fix needs.")
+
+	return b.String()
+}
+
+// approveChanges asks on the terminal before each tool call that modifies files
+func approveChanges(agentApp *agent.Agent, stdin *bufio.Reader) agent.ToolInterceptor {
+	return func(call agent.ToolCall, next func() (string, error)) (string, error) {
+		if agentApp.ToolReadOnly(call.Name) {
+			return next()
+		}
+
+		input, err := json.MarshalIndent(call.Input, "  ", "  ")
+		if err != nil {
+			input = call.Input
+		}
+
+		if !confirm(stdin, fmt.Sprintf("\nApply %s?\n  %s\n", call.Name, input)) {
+			return "", fmt.Errorf("the user rejected this change")
+		}
+
+		return next()
+	}
+}
+
+// settle discards change batches until the workspace has been quiet for a moment
+func settle(w *watcher.Watcher) {
+	for {
+		select {
+		case _, ok := <-w.Changes():
+			if !ok {
+				return
+			}
+		case <-time.After(settleTime):
+			return
+		}
+	}
+}
@@ -1,25 +1,58 @@
 package config
 
 import (
+	"fmt"
+
 	"github.com/anthropics/anthropic-sdk-go"
 )
 
 // Config holds the application configuration
 type Config struct {
 	Client *anthropic.Client
+
+	// Profile is the name of the profile the client was built from, if any,
+	// and Model the profile's model override
+	Profile string
+	Model   string
+
+	// Endpoint names the provider and base URL the client calls
+	Endpoint string
+
+	// RateLimits are the profile's rate limits, or else those in the settings
+	RateLimits RateLimits
 }
 
-// NewConfig creates a new configuration instance
-func NewConfig() *Config {
-	return &Config{
-		Client: setupAnthropicClient(),
+// LoadConfig creates a configuration from the named profile in the user
+// settings, or from the default profile when name is empty
+func LoadConfig(name string) (*Config, error) {
+	settings, err := LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	name, profile, err := settings.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := profile.NewClient(name)
+	if err != nil {
+		if name != "" {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		return nil, err
 	}
-}
 
-// setupAnthropicClient creates and configures the Anthropic client
-func setupAnthropicClient() *anthropic.Client {
-	client := anthropic.NewClient()
-	return &client
+	limits := settings.RateLimits
+	if profile.RateLimits != nil {
+		limits = profile.RateLimits
+	}
+
+	cfg := &Config{Client: client, Profile: name, Model: profile.Model, Endpoint: profile.Endpoint()}
+	if limits != nil {
+		cfg.RateLimits = *limits
+	}
+	return cfg, nil
 }
 
 // setupUserInput creates a function for reading user input from stdin
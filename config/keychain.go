@@ -0,0 +1,34 @@
+package config
+
+import "errors"
+
+// keychainService is the service name API keys are stored under in the OS keychain
+const keychainService = "cli-agent"
+
+// ErrKeyNotFound is returned when the keychain has no API key for an account
+var ErrKeyNotFound = errors.New("no API key stored in the keychain")
+
+// KeychainAccount returns the keychain account holding a profile's API key.
+// The key used without a profile is stored as "default".
+func KeychainAccount(profile string) string {
+	if profile == "" {
+		return "default"
+	}
+	return profile
+}
+
+// StoreAPIKey saves an API key in the OS keychain, replacing any existing one
+func StoreAPIKey(account, key string) error {
+	return keychainStore(account, key)
+}
+
+// LoadAPIKey reads an API key from the OS keychain, returning ErrKeyNotFound
+// when none is stored for the account
+func LoadAPIKey(account string) (string, error) {
+	return keychainLoad(account)
+}
+
+// DeleteAPIKey removes an API key from the OS keychain
+func DeleteAPIKey(account string) error {
+	return keychainDelete(account)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The macOS Keychain is driven through the security tool. Commands are passed
+// on stdin in interactive mode so the key never shows up in the process list.
+
+func keychainStore(account, key string) error {
+	command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		securityQuote(keychainService), securityQuote(account), securityQuote(key))
+
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(command)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store key in the keychain: %s", strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+func keychainLoad(account string) (string, error) {
+	output, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return "", ErrKeyNotFound
+		}
+		return "", fmt.Errorf("failed to read key from the keychain: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+func keychainDelete(account string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", account).Run()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return ErrKeyNotFound
+		}
+		return fmt.Errorf("failed to delete key from the keychain: %w", err)
+	}
+
+	return nil
+}
+
+// securityQuote quotes an argument for the security tool's interactive mode
+func securityQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,65 @@
+//go:build !darwin && !windows
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// On Linux and the BSDs keys are kept in the Secret Service (GNOME Keyring,
+// KWallet) through libsecret's secret-tool, which reads the secret from stdin.
+
+func keychainStore(account, key string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", "cli-agent API key ("+account+")",
+		"service", keychainService, "account", account)
+	cmd.Stdin = strings.NewReader(key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return secretToolError("store key in", err, output)
+	}
+
+	return nil
+}
+
+func keychainLoad(account string) (string, error) {
+	output, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account).Output()
+	if err != nil {
+		// secret-tool exits with status 1 and no output when nothing matches,
+		// and without it installed nothing can have been stored
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) == 0 || errors.Is(err, exec.ErrNotFound) {
+			return "", ErrKeyNotFound
+		}
+		return "", secretToolError("read key from", err, nil)
+	}
+
+	key := strings.TrimSpace(string(output))
+	if key == "" {
+		return "", ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func keychainDelete(account string) error {
+	if _, err := keychainLoad(account); err != nil {
+		return err
+	}
+
+	if output, err := exec.Command("secret-tool", "clear", "service", keychainService, "account", account).CombinedOutput(); err != nil {
+		return secretToolError("delete key from", err, output)
+	}
+
+	return nil
+}
+
+func secretToolError(action string, err error, output []byte) error {
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("failed to %s the keychain: secret-tool not found (install libsecret-tools)", action)
+	}
+	if message := strings.TrimSpace(string(output)); message != "" {
+		return fmt.Errorf("failed to %s the keychain: %s", action, message)
+	}
+	return fmt.Errorf("failed to %s the keychain: %w", action, err)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// On Windows keys are encrypted with DPAPI, which ties them to the current
+// user's login, and the encrypted blobs are kept in the user config directory.
+
+func keychainPath(account string) (string, error) {
+	dir, err := UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "credentials", account+".dpapi"), nil
+}
+
+func keychainStore(account, key string) error {
+	path, err := keychainPath(account)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := dpapi(windows.CryptProtectData, []byte(key))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+	if err := os.WriteFile(path, encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write key: %w", err)
+	}
+
+	return nil
+}
+
+func keychainLoad(account string) (string, error) {
+	path, err := keychainPath(account)
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", ErrKeyNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read key: %w", err)
+	}
+
+	key, err := dpapi(windows.CryptUnprotectData, encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key: %w", err)
+	}
+
+	return string(key), nil
+}
+
+func keychainDelete(account string) error {
+	path, err := keychainPath(account)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrKeyNotFound
+	}
+	return err
+}
+
+// dpapiFunc matches the signatures of CryptProtectData and CryptUnprotectData
+type dpapiFunc[N any] func(in *windows.DataBlob, name N, entropy *windows.DataBlob, reserved uintptr, prompt *windows.CryptProtectPromptStruct, flags uint32, out *windows.DataBlob) error
+
+// dpapi runs data through CryptProtectData or CryptUnprotectData
+func dpapi[N any](transform dpapiFunc[N], data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data")
+	}
+
+	var name N
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	if err := transform(&in, name, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(out.Data)))
+
+	return append([]byte(nil), unsafe.Slice(out.Data, out.Size)...), nil
+}
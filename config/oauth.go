@@ -0,0 +1,229 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuthConfig lets a profile sign in through a browser instead of using an
+// API key. cli-agent ships no client registration of its own, so the client
+// ID and endpoints come from whoever issued the client.
+type OAuthConfig struct {
+	ClientID     string   `json:"client_id"`
+	AuthorizeURL string   `json:"authorize_url"`
+	TokenURL     string   `json:"token_url"`
+	Scopes       []string `json:"scopes,omitempty"`
+	// Headers are added to every API request made with the token, e.g. a beta flag
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Validate reports missing fields, and where to get them: there is no
+// built-in client to fall back on
+func (c OAuthConfig) Validate() error {
+	missing := []string{}
+	if c.ClientID == "" {
+		missing = append(missing, "client_id")
+	}
+	if c.AuthorizeURL == "" {
+		missing = append(missing, "authorize_url")
+	}
+	if c.TokenURL == "" {
+		missing = append(missing, "token_url")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("oauth settings are missing %s; cli-agent has no OAuth client of its own, so register one with the provider and add its client_id, authorize_url and token_url, or remove the oauth section and sign in with an API key", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// OAuthToken is a stored OAuth access token and the refresh token used to renew it
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// tokenRefreshMargin renews tokens this long before they expire
+const tokenRefreshMargin = time.Minute
+
+// expired reports whether the token needs refreshing before use
+func (t OAuthToken) expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().Add(tokenRefreshMargin).After(t.ExpiresAt)
+}
+
+// oauthAccount returns the keychain account holding a profile's OAuth token
+func oauthAccount(profile string) string {
+	return KeychainAccount(profile) + ":oauth"
+}
+
+// StoreOAuthToken saves a profile's OAuth token in the OS keychain
+func StoreOAuthToken(profile string, token OAuthToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("failed to marshal token: %w", err)
+	}
+
+	return StoreAPIKey(oauthAccount(profile), string(data))
+}
+
+// LoadOAuthToken reads a profile's OAuth token from the OS keychain
+func LoadOAuthToken(profile string) (OAuthToken, error) {
+	data, err := LoadAPIKey(oauthAccount(profile))
+	if err != nil {
+		return OAuthToken{}, err
+	}
+
+	var token OAuthToken
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return OAuthToken{}, fmt.Errorf("failed to parse stored token: %w", err)
+	}
+
+	return token, nil
+}
+
+// DeleteOAuthToken removes a profile's OAuth token from the OS keychain
+func DeleteOAuthToken(profile string) error {
+	return DeleteAPIKey(oauthAccount(profile))
+}
+
+// tokenResponse is the token endpoint's reply (RFC 6749 section 5.1)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// ExchangeOAuthCode trades an authorization code for a token, proving
+// possession of the PKCE verifier
+func (c OAuthConfig) ExchangeOAuthCode(code, verifier, redirectURL string) (OAuthToken, error) {
+	return c.requestToken(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {redirectURL},
+		"client_id":     {c.ClientID},
+	})
+}
+
+// refresh renews an expired token. Servers may omit the refresh token when it is unchanged.
+func (c OAuthConfig) refresh(token OAuthToken) (OAuthToken, error) {
+	if token.RefreshToken == "" {
+		return OAuthToken{}, fmt.Errorf("the sign-in has expired; run `cli-agent auth login` again")
+	}
+
+	refreshed, err := c.requestToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+		"client_id":     {c.ClientID},
+	})
+	if err != nil {
+		return OAuthToken{}, err
+	}
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+
+	return refreshed, nil
+}
+
+func (c OAuthConfig) requestToken(form url.Values) (OAuthToken, error) {
+	client := http.Client{Timeout: 30 * time.Second}
+	response, err := client.PostForm(c.TokenURL, form)
+	if err != nil {
+		return OAuthToken{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer response.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return OAuthToken{}, fmt.Errorf("token request failed: %s", response.Status)
+	}
+	if body.Error != "" {
+		return OAuthToken{}, fmt.Errorf("token request failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if response.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return OAuthToken{}, fmt.Errorf("token request failed: %s", response.Status)
+	}
+
+	token := OAuthToken{AccessToken: body.AccessToken, RefreshToken: body.RefreshToken}
+	if body.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
+// oauthSession hands out a valid access token for a profile, refreshing and
+// re-storing it when it expires during a long session
+type oauthSession struct {
+	config  OAuthConfig
+	profile string
+
+	mu    sync.Mutex
+	token OAuthToken
+}
+
+// newOAuthSession loads the profile's stored token
+func newOAuthSession(config OAuthConfig, profile string) (*oauthSession, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	token, err := LoadOAuthToken(profile)
+	if errors.Is(err, ErrKeyNotFound) {
+		if profile != "" {
+			return nil, fmt.Errorf("not signed in; run `cli-agent auth login --profile %s`", profile)
+		}
+		return nil, fmt.Errorf("not signed in; run `cli-agent auth login`")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauthSession{config: config, profile: profile, token: token}, nil
+}
+
+// accessToken returns a current access token
+func (s *oauthSession) accessToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.expired() {
+		token, err := s.config.refresh(s.token)
+		if err != nil {
+			return "", err
+		}
+		s.token = token
+
+		if err := StoreOAuthToken(s.profile, token); err != nil {
+			return "", err
+		}
+	}
+
+	return s.token.AccessToken, nil
+}
+
+// middleware authenticates each API request with the access token in place of an API key
+func (s *oauthSession) middleware(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	token, err := s.accessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Del("X-Api-Key")
+	req.Header.Set("Authorization", "Bearer "+token)
+	for name, value := range s.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	return next(req)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// ProviderAnthropic is the only supported profile provider; other endpoints
+// speaking the Anthropic API can be reached through a profile's base URL
+const ProviderAnthropic = "anthropic"
+
+// Profile bundles the credentials and defaults for one account or endpoint,
+// e.g. "work", "personal" or a "local" proxy
+type Profile struct {
+	Provider string `json:"provider,omitempty"`
+	// APIKeyEnv names the environment variable holding the API key
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+	// APIKeyCommand is a shell command printing the API key, e.g. "pass show anthropic/work"
+	APIKeyCommand string `json:"api_key_command,omitempty"`
+	Model         string `json:"model,omitempty"`
+	BaseURL       string `json:"base_url,omitempty"`
+	// OAuth signs in through the browser with `cli-agent auth login` instead of using an API key
+	OAuth *OAuthConfig `json:"oauth,omitempty"`
+	// RateLimits overrides the rate_limits in the settings for this profile's account
+	RateLimits *RateLimits `json:"rate_limits,omitempty"`
+}
+
+// ProfileNames returns the configured profile names sorted alphabetically
+func (s Settings) ProfileNames() []string {
+	names := make([]string, 0, len(s.Profiles))
+	for name := range s.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Profile looks up a profile by name. An empty name selects the default
+// profile; with no default configured the zero profile is returned, which
+// uses the ANTHROPIC_API_KEY environment variable and the built-in model.
+func (s Settings) Profile(name string) (string, Profile, error) {
+	if name == "" {
+		name = s.DefaultProfile
+	}
+	if name == "" {
+		return "", Profile{}, nil
+	}
+
+	profile, ok := s.Profiles[name]
+	if !ok {
+		if len(s.Profiles) == 0 {
+			return "", Profile{}, fmt.Errorf("unknown profile %q: no profiles are configured", name)
+		}
+		return "", Profile{}, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(s.ProfileNames(), ", "))
+	}
+
+	return name, profile, nil
+}
+
+// APIKey resolves the key for the named profile. Without a configured source
+// ANTHROPIC_API_KEY wins, then a key saved with `cli-agent auth login`; when
+// neither is set the key is empty and the client falls back to its defaults.
+func (p Profile) APIKey(name string) (string, error) {
+	switch {
+	case p.APIKeyCommand != "":
+		cmd := ShellCommand(context.Background(), p.APIKeyCommand)
+		cmd.Stderr = os.Stderr
+
+		output, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("api_key_command failed: %w", err)
+		}
+
+		key := strings.TrimSpace(string(output))
+		if key == "" {
+			return "", fmt.Errorf("api_key_command printed no key")
+		}
+		return key, nil
+
+	case p.APIKeyEnv != "":
+		key := os.Getenv(p.APIKeyEnv)
+		if key == "" {
+			return "", fmt.Errorf("environment variable %s is not set", p.APIKeyEnv)
+		}
+		return key, nil
+
+	case os.Getenv("ANTHROPIC_API_KEY") != "":
+		return "", nil
+	}
+
+	key, err := LoadAPIKey(KeychainAccount(name))
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return "", err
+	}
+	return key, nil
+}
+
+// Endpoint names the provider and base URL the profile's requests go to
+func (p Profile) Endpoint() string {
+	provider := p.Provider
+	if provider == "" {
+		provider = ProviderAnthropic
+	}
+
+	// The SDK takes the base URL from the environment when none is given
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = os.Getenv("ANTHROPIC_BASE_URL")
+	}
+	return strings.TrimSpace(provider + " " + baseURL)
+}
+
+// NewClient creates an Anthropic client for the named profile using its credentials and base URL
+func (p Profile) NewClient(name string) (*anthropic.Client, error) {
+	if p.Provider != "" && p.Provider != ProviderAnthropic {
+		return nil, fmt.Errorf("unsupported provider %q", p.Provider)
+	}
+
+	options := []option.RequestOption{}
+	if p.OAuth != nil {
+		session, err := newOAuthSession(*p.OAuth, name)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, option.WithMiddleware(session.middleware))
+	} else {
+		key, err := p.APIKey(name)
+		if err != nil {
+			return nil, err
+		}
+		if key != "" {
+			options = append(options, option.WithAPIKey(key))
+		}
+	}
+	if p.BaseURL != "" {
+		options = append(options, option.WithBaseURL(p.BaseURL))
+	}
+
+	client := anthropic.NewClient(options...)
+	return &client, nil
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ProjectConfigDir is the per-project directory holding cli-agent files
+const ProjectConfigDir = ".cli-agent"
+
+// ProjectConfig is the per-project configuration stored in .cli-agent/config.json
+type ProjectConfig struct {
+	Tools       ToolsConfig       `json:"tools"`
+	Limits      LimitsConfig      `json:"limits"`
+	Budget      BudgetConfig      `json:"budget"`
+	ToolResults ToolResultsConfig `json:"tool_results"`
+	Context     ContextConfig     `json:"context"`
+	Checks      []CheckConfig     `json:"checks,omitempty"`
+	PreCommit   ReviewConfig      `json:"pre_commit"`
+	Review      ReviewConfig      `json:"review"`
+	// SyntaxCheck parses files after the agent edits them, with go/parser,
+	// node --check or Python's compiler, and reports new syntax errors to
+	// the model as a failed tool call
+	SyntaxCheck bool `json:"syntax_check,omitempty"`
+	// Format runs formatters on the files the agent writes
+	Format []FormatterConfig `json:"format,omitempty"`
+	// Ignore lists globs that recursive listings skip, on top of the default
+	// dependency and build directories
+	Ignore []string `json:"ignore,omitempty"`
+	// Request shapes the model requests of every turn
+	Request RequestConfig `json:"request"`
+}
+
+// RequestConfig controls tool use and stop sequences of the model requests.
+// ToolChoice is "auto", the default, "any" to make the model start each turn
+// with a tool call, "none" to keep it from calling tools, or a tool's name to
+// make it start with that tool, e.g. for structured extraction.
+// StopSequences end a response where the model writes one of them.
+type RequestConfig struct {
+	ToolChoice    string   `json:"tool_choice,omitempty"`
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+// ReviewConfig sets the rules changes are checked against, by `cli-agent
+// review` and by `cli-agent hook pre-commit`
+type ReviewConfig struct {
+	// NoTodos rejects added TODO, FIXME and XXX markers
+	NoTodos bool `json:"no_todos,omitempty"`
+	// RequireTests rejects source changes that don't touch any tests
+	RequireTests bool `json:"require_tests,omitempty"`
+	// Checks must pass, e.g. a formatting check such as test -z "$(gofmt -l .)"
+	Checks []CheckConfig `json:"checks,omitempty"`
+	// Rules are natural-language rules the model reviews the diff against
+	Rules []string `json:"rules,omitempty"`
+}
+
+// CheckConfig is a shell command that verifies the project, such as a build,
+// test or lint run. It passes when the command exits zero.
+type CheckConfig struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	Timeout int    `json:"timeout_seconds,omitempty"`
+}
+
+// FormatterConfig is a formatter run on the files the agent writes whose
+// paths match one of Files, e.g. {"files": ["*.go"], "command": "gofmt -w"}.
+// The file's path replaces {file} in the command, or is appended to it.
+type FormatterConfig struct {
+	Files   []string `json:"files"`
+	Command string   `json:"command"`
+	Timeout int      `json:"timeout_seconds,omitempty"`
+}
+
+// ContextConfig lists files whose latest contents are included with every
+// request. With AutoPin the files the agent recently worked on are included too.
+type ContextConfig struct {
+	Pin     []string `json:"pin,omitempty"`
+	AutoPin bool     `json:"auto_pin,omitempty"`
+}
+
+// ToolResultsConfig controls how tool output larger than MaxChars is shortened
+// before it is sent to the model: truncated, or summarized by the utility model
+// when Summarize is set. A MaxChars of 0 disables the limit.
+type ToolResultsConfig struct {
+	MaxChars  *int `json:"max_chars,omitempty"`
+	Summarize bool `json:"summarize,omitempty"`
+}
+
+// BudgetConfig caps the tokens or dollars a session may spend. When Hard is
+// set the agent stops at the budget instead of asking to continue.
+type BudgetConfig struct {
+	Tokens  int64   `json:"tokens,omitempty"`
+	Dollars float64 `json:"dollars,omitempty"`
+	Hard    bool    `json:"hard,omitempty"`
+}
+
+// LimitsConfig overrides the default write limits. A value of 0 disables a limit.
+type LimitsConfig struct {
+	MaxFileBytes    *int64 `json:"max_file_bytes,omitempty"`
+	MaxSessionBytes *int64 `json:"max_session_bytes,omitempty"`
+}
+
+// ToolsConfig controls which tools are available in a project. Entries are
+// tool names and may use glob patterns such as "read_*" or "*".
+type ToolsConfig struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// ProjectConfigPath returns the location of the project config file under root
+func ProjectConfigPath(root string) string {
+	return filepath.Join(root, ProjectConfigDir, "config.json")
+}
+
+// LoadProjectConfig strictly reads the project config under root. A missing
+// file yields an empty config. On an error the config is empty too, and
+// callers must not mistake it for one without restrictions.
+func LoadProjectConfig(root string) (ProjectConfig, error) {
+	cfg := ProjectConfig{}
+
+	data, err := os.ReadFile(ProjectConfigPath(root))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read project config: %w", err)
+	}
+
+	parsed, err := ParseProjectConfig(data)
+	if err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %w", ProjectConfigPath(root), err)
+	}
+
+	return parsed, nil
+}
+
+// Allows reports whether a tool is enabled: it must not match any deny
+// pattern and, when an allow list is present, must match one of its patterns
+func (t ToolsConfig) Allows(name string) bool {
+	if matchesAny(t.Deny, name) {
+		return false
+	}
+
+	if len(t.Allow) == 0 {
+		return true
+	}
+
+	return matchesAny(t.Allow, name)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
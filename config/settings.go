@@ -0,0 +1,229 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Settings are user-level preferences, stored in settings.json in the user config directory
+type Settings struct {
+	Keys KeysConfig `json:"keys"`
+
+	// Profiles are named sets of credentials and defaults, selected with
+	// --profile or /profile. DefaultProfile is used when none is given.
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
+	DefaultProfile string             `json:"default_profile,omitempty"`
+
+	// Language is the interface language, e.g. "de"; by default it follows
+	// LANG. ResponseLanguage asks the agent to reply in a language, e.g. "German".
+	Language         string `json:"language,omitempty"`
+	ResponseLanguage string `json:"response_language,omitempty"`
+
+	// Accessible renders the chat as plain, linear text without colors,
+	// borders or emoji, for screen readers. NO_COLOR and TERM=dumb imply it.
+	Accessible bool `json:"accessible,omitempty"`
+
+	// DryRun stages the agent's file changes for review at the end of each
+	// turn instead of writing them as it goes
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// RateLimits applies to profiles that don't set their own
+	RateLimits *RateLimits `json:"rate_limits,omitempty"`
+
+	// Approvals decides the agent's approval requests where there is no
+	// chat to ask in, and which tools need approval before every call
+	Approvals *Approvals `json:"approvals,omitempty"`
+
+	// ModelRouter picks a cheaper or stronger model than the profile's for
+	// each turn; it is off unless set
+	ModelRouter *ModelRouter `json:"model_router,omitempty"`
+
+	// UpdateCheck set to false stops the chat from looking for new releases
+	UpdateCheck *bool `json:"update_check,omitempty"`
+}
+
+// ModelRouter names the models the router picks from. Turns that need
+// neither use the profile's model.
+type ModelRouter struct {
+	// Light answers short questions, by default claude-3-5-haiku-latest
+	Light string `json:"light,omitempty"`
+	// Heavy takes refactors, large diffs and requests to think harder, by
+	// default claude-opus-4-0
+	Heavy string `json:"heavy,omitempty"`
+}
+
+// Approval policies for requests made outside the chat
+const (
+	ApprovalAsk   = "ask"
+	ApprovalAllow = "allow"
+	ApprovalDeny  = "deny"
+)
+
+// Approvals configures how approval requests, such as a write over the write
+// limits, are decided by cli-agent run and serve
+type Approvals struct {
+	// Policy is "ask", "allow", "deny", or an http(s) URL each request is
+	// POSTed to for a decision. "ask" asks the client of cli-agent serve; with
+	// nobody to ask, as in cli-agent run, requests are denied.
+	Policy string `json:"policy,omitempty"`
+
+	// Tools must be approved before every call, e.g. ["run_command"]. The
+	// chat asks for these approvals too.
+	Tools []string `json:"tools,omitempty"`
+}
+
+// RateLimits keeps requests within the account's API rate limits, e.g. those
+// of its Anthropic usage tier, by waiting before a request would exceed them
+// instead of running into 429 errors. Zero disables a limit.
+type RateLimits struct {
+	RequestsPerMinute     int `json:"requests_per_minute,omitempty"`
+	InputTokensPerMinute  int `json:"input_tokens_per_minute,omitempty"`
+	OutputTokensPerMinute int `json:"output_tokens_per_minute,omitempty"`
+	// SpendAlertPerHour warns when more than this many dollars were spent in the last hour
+	SpendAlertPerHour float64 `json:"spend_alert_per_hour,omitempty"`
+}
+
+// PlainOutput reports whether the accessible plain-output mode applies,
+// either from the settings or because the environment asks for no color
+func (s Settings) PlainOutput() bool {
+	return s.Accessible || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb"
+}
+
+// CheckForUpdates reports whether the chat may look for new releases
+func (s Settings) CheckForUpdates() bool {
+	return s.UpdateCheck == nil || *s.UpdateCheck
+}
+
+// KeysConfig rebinds the chat input. Keys are named as Bubble Tea reports
+// them, e.g. "enter", "alt+enter" or "ctrl+j". Most terminals can't tell
+// Shift+Enter from Enter; those that can usually send it as "alt+enter".
+type KeysConfig struct {
+	Send    []string `json:"send,omitempty"`
+	Newline []string `json:"newline,omitempty"`
+}
+
+// DefaultKeys sends on Enter and inserts a newline on Alt+Enter or Ctrl+J
+var DefaultKeys = KeysConfig{
+	Send:    []string{"enter"},
+	Newline: defaultNewlineKeys(),
+}
+
+// defaultNewlineKeys leads with Ctrl+J on Windows, where Windows Terminal
+// takes Alt+Enter for full screen unless that binding is removed
+func defaultNewlineKeys() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"ctrl+j", "alt+enter"}
+	}
+	return []string{"alt+enter", "ctrl+j"}
+}
+
+// SettingsPath returns the location of the user settings file
+func SettingsPath() (string, error) {
+	dir, err := UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "settings.json"), nil
+}
+
+// LoadSettings reads the user settings, filling in defaults for anything
+// not set. A missing file is not an error.
+func LoadSettings() (Settings, error) {
+	settings, err := readSettings()
+	if err != nil {
+		return withDefaults(Settings{}), err
+	}
+
+	return withDefaults(settings), nil
+}
+
+// readSettings reads the settings file as written, without defaults
+func readSettings() (Settings, error) {
+	settings := Settings{}
+
+	path, err := SettingsPath()
+	if err != nil {
+		return settings, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, fmt.Errorf("failed to read settings: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return Settings{}, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return settings, nil
+}
+
+// UpdateSettings applies change to the settings file as written, so defaults
+// aren't copied into it, and saves the result
+func UpdateSettings(change func(settings *Settings)) error {
+	settings, err := readSettings()
+	if err != nil {
+		return err
+	}
+
+	change(&settings)
+
+	path, err := SettingsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write settings: %w", err)
+	}
+
+	return nil
+}
+
+// NeedsSetup reports whether this looks like a first run: there is no
+// settings file and no API key in the environment or the keychain
+func NeedsSetup() bool {
+	path, err := SettingsPath()
+	if err != nil {
+		return false
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return false
+	}
+
+	if os.Getenv("ANTHROPIC_API_KEY") != "" || os.Getenv("ANTHROPIC_AUTH_TOKEN") != "" {
+		return false
+	}
+
+	_, err = LoadAPIKey(KeychainAccount(""))
+	return errors.Is(err, ErrKeyNotFound)
+}
+
+// withDefaults fills unset settings with their defaults
+func withDefaults(settings Settings) Settings {
+	if len(settings.Keys.Send) == 0 {
+		settings.Keys.Send = DefaultKeys.Send
+	}
+	if len(settings.Keys.Newline) == 0 {
+		settings.Keys.Newline = DefaultKeys.Newline
+	}
+
+	return settings
+}
@@ -0,0 +1,16 @@
+package config
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+)
+
+// ShellCommand runs a command line from the settings through the platform's
+// shell: sh -c, or cmd /C on Windows
+func ShellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
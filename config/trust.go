@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// TrustDecision records whether the user allows write tools in a directory
+type TrustDecision string
+
+const (
+	TrustUnknown    TrustDecision = ""
+	TrustGranted    TrustDecision = "trusted"
+	TrustRestricted TrustDecision = "restricted"
+)
+
+// trustFile is the on-disk format of the trusted folders list
+type trustFile struct {
+	Folders map[string]TrustDecision `json:"folders"`
+}
+
+// UserConfigDir returns the directory holding user-level cli-agent settings
+func UserConfigDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+
+	return filepath.Join(dir, "cli-agent"), nil
+}
+
+func trustFilePath() (string, error) {
+	dir, err := UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "trusted_folders.json"), nil
+}
+
+func loadTrustFile() (trustFile, error) {
+	file := trustFile{Folders: map[string]TrustDecision{}}
+
+	path, err := trustFilePath()
+	if err != nil {
+		return file, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return file, fmt.Errorf("failed to read trust settings: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("failed to parse trust settings: %w", err)
+	}
+	if file.Folders == nil {
+		file.Folders = map[string]TrustDecision{}
+	}
+
+	return file, nil
+}
+
+// LoadTrust returns the trust decision for dir. A decision for dir itself wins;
+// otherwise dir inherits trust granted to any of its parent directories.
+func LoadTrust(dir string) TrustDecision {
+	file, err := loadTrustFile()
+	if err != nil {
+		return TrustUnknown
+	}
+
+	if decision, ok := file.Folders[dir]; ok {
+		return decision
+	}
+
+	for parent := filepath.Dir(dir); parent != dir; dir, parent = parent, filepath.Dir(parent) {
+		if file.Folders[parent] == TrustGranted {
+			return TrustGranted
+		}
+	}
+
+	return TrustUnknown
+}
+
+// SaveTrust persists the trust decision for dir
+func SaveTrust(dir string, decision TrustDecision) error {
+	file, err := loadTrustFile()
+	if err != nil {
+		return err
+	}
+
+	file.Folders[dir] = decision
+
+	path, err := trustFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal trust settings: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write trust settings: %w", err)
+	}
+
+	return nil
+}
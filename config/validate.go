@@ -0,0 +1,280 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ParseSettings strictly parses a settings file: unknown keys are reported
+// with a suggestion for the setting that was probably meant
+func ParseSettings(data []byte) (Settings, error) {
+	settings := Settings{}
+	if err := parseStrict(data, &settings); err != nil {
+		return settings, err
+	}
+
+	return settings, settings.Validate()
+}
+
+// ParseProjectConfig strictly parses a project config file like ParseSettings
+func ParseProjectConfig(data []byte) (ProjectConfig, error) {
+	cfg := ProjectConfig{}
+	err := parseStrict(data, &cfg)
+	return cfg, err
+}
+
+// Validate checks settings for values that parse but can't work
+func (s Settings) Validate() error {
+	if s.DefaultProfile != "" {
+		if _, ok := s.Profiles[s.DefaultProfile]; !ok {
+			return fmt.Errorf("default_profile: no profile named %q%s", s.DefaultProfile, didYouMean(s.DefaultProfile, s.ProfileNames()))
+		}
+	}
+
+	if err := s.RateLimits.Validate(); err != nil {
+		return fmt.Errorf("rate_limits: %w", err)
+	}
+
+	if s.Approvals != nil {
+		if err := ValidateApprovalPolicy(s.Approvals.Policy); err != nil {
+			return fmt.Errorf("approvals.policy: %w", err)
+		}
+	}
+
+	for _, name := range s.ProfileNames() {
+		profile := s.Profiles[name]
+		if profile.Provider != "" && profile.Provider != ProviderAnthropic {
+			return fmt.Errorf("profiles.%s.provider: unsupported provider %q (only %q is supported)", name, profile.Provider, ProviderAnthropic)
+		}
+		if profile.APIKeyEnv != "" && profile.APIKeyCommand != "" {
+			return fmt.Errorf("profiles.%s: set api_key_env or api_key_command, not both", name)
+		}
+		if err := profile.RateLimits.Validate(); err != nil {
+			return fmt.Errorf("profiles.%s.rate_limits: %w", name, err)
+		}
+		if profile.OAuth != nil {
+			if err := profile.OAuth.Validate(); err != nil {
+				return fmt.Errorf("profiles.%s.oauth: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate rejects negative limits
+func (r *RateLimits) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.RequestsPerMinute < 0 || r.InputTokensPerMinute < 0 || r.OutputTokensPerMinute < 0 || r.SpendAlertPerHour < 0 {
+		return errors.New("limits can't be negative; use 0 or leave a limit out to disable it")
+	}
+	return nil
+}
+
+// ValidateApprovalPolicy checks an approval policy is one of the known ones
+// or a URL to ask
+func ValidateApprovalPolicy(policy string) error {
+	switch policy {
+	case "", ApprovalAsk, ApprovalAllow, ApprovalDeny:
+		return nil
+	}
+
+	parsed, err := url.Parse(policy)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("unknown policy %q; use %q, %q, %q or an http(s) URL", policy, ApprovalAsk, ApprovalAllow, ApprovalDeny)
+	}
+	// Requests and their signatures would cross the network in the clear
+	if parsed.Scheme == "http" && !isLoopback(parsed.Hostname()) {
+		return fmt.Errorf("approval callback %q must use https unless it is on this machine", policy)
+	}
+	return nil
+}
+
+// isLoopback reports whether host is this machine: localhost or a loopback address
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseStrict checks the keys of a JSON document against target's json tags
+// before decoding it, so typos are caught instead of silently ignored
+func parseStrict(data []byte, target any) error {
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if err := checkKeys(document, reflect.TypeOf(target).Elem(), ""); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%s: expected %s, got %s", typeErr.Field, describeType(typeErr.Type), typeErr.Value)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// describeType names a Go type the way a settings file author thinks of it
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Pointer:
+		return describeType(t.Elem())
+	default:
+		return "a number"
+	}
+}
+
+// checkKeys walks a decoded JSON value alongside the Go type it decodes into
+// and reports the first object key that doesn't match a field
+func checkKeys(value any, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(object) {
+			field, ok := fields[key]
+			if !ok {
+				names := make([]string, 0, len(fields))
+				for name := range fields {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				return fmt.Errorf("%s: unknown setting%s", joinPath(path, key), didYouMean(key, names))
+			}
+			if err := checkKeys(object[key], field, joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		for _, key := range sortedKeys(object) {
+			if err := checkKeys(object[key], t.Elem(), joinPath(path, key)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			return nil
+		}
+		for i, item := range items {
+			if err := checkKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonFields maps the JSON names of a struct's fields to their types
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// didYouMean suggests the candidate closest to a misspelled name, if any is close enough
+func didYouMean(name string, candidates []string) string {
+	best := ""
+	bestDistance := 0
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if best == "" || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	if best == "" || bestDistance > max(2, len(name)/3) {
+		return ""
+	}
+	return fmt.Sprintf("; did you mean %q?", best)
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
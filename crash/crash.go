@@ -0,0 +1,173 @@
+// Package crash turns a panic into a crash report: the terminal is given back,
+// open conversations are saved to be resumed, and the user is told where to
+// find both, instead of being left in a raw alt-screen terminal with the
+// conversation gone.
+package crash
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/config"
+	"agent/log"
+)
+
+// Hook runs when cli-agent crashes, e.g. restoring the terminal or saving a
+// conversation. It returns a line for the user, or "".
+type Hook func() string
+
+var (
+	mu     sync.Mutex
+	hooks  = map[int]Hook{}
+	nextID int
+
+	// remembered is the panic a caller recovered from and passed on
+	remembered *panicInfo
+	crashing   sync.Once
+)
+
+// panicInfo is a panic's value and the stack it was raised on
+type panicInfo struct {
+	value any
+	stack []byte
+}
+
+// OnCrash runs hook if cli-agent crashes. The returned function unregisters
+// it, e.g. when the conversation it saves is closed.
+func OnCrash(hook Hook) func() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	id := nextID
+	nextID++
+	hooks[id] = hook
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		delete(hooks, id)
+	}
+}
+
+// Recover is deferred at the top of a goroutine to turn its panics into a
+// crash report. It exits the process.
+func Recover() {
+	if r := recover(); r != nil {
+		Crash(r, debug.Stack())
+	}
+}
+
+// Remember is deferred where someone else recovers from panics, e.g. in a
+// bubbletea model, so Recovered can still report the stack. It passes the
+// panic on.
+func Remember() {
+	if r := recover(); r != nil {
+		mu.Lock()
+		if remembered == nil {
+			remembered = &panicInfo{value: r, stack: debug.Stack()}
+		}
+		mu.Unlock()
+		panic(r)
+	}
+}
+
+// Recovered reports a panic that was already recovered from, with the stack
+// Remember kept, if any. It exits the process.
+func Recovered(err error) {
+	mu.Lock()
+	info := remembered
+	mu.Unlock()
+
+	if info == nil {
+		info = &panicInfo{value: err}
+	}
+	Crash(info.value, info.stack)
+}
+
+// Crash writes a crash report for a panic, runs the hooks, tells the user
+// where the report and their conversations are and exits
+func Crash(value any, stack []byte) {
+	crashing.Do(func() {
+		log.Error("cli-agent crashed", "panic", fmt.Sprint(value))
+
+		mu.Lock()
+		running := make([]Hook, 0, len(hooks))
+		for _, hook := range hooks {
+			running = append(running, hook)
+		}
+		mu.Unlock()
+
+		lines := []string{}
+		for _, hook := range running {
+			if line := runHook(hook); line != "" {
+				lines = append(lines, line)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "\ncli-agent crashed: %v\n", value)
+		if path, err := writeReport(value, stack); err == nil {
+			fmt.Fprintf(os.Stderr, "A crash report was written to %s\n", path)
+		} else {
+			fmt.Fprintf(os.Stderr, "The crash report could not be written (%v):\n\n%s\n", err, stack)
+		}
+		for _, line := range lines {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	})
+	os.Exit(2)
+}
+
+// runHook runs a hook, so that one failing doesn't keep the others from running
+func runHook(hook Hook) (line string) {
+	defer func() {
+		if r := recover(); r != nil {
+			line = ""
+		}
+	}()
+	return hook()
+}
+
+// ReportDir returns where crash reports are written, e.g.
+// ~/.config/cli-agent/crashes
+func ReportDir() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "crashes"), nil
+}
+
+// writeReport writes the details of a crash to a new file in ReportDir
+func writeReport(value any, stack []byte) (string, error) {
+	dir, err := ReportDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	var b strings.Builder
+	fmt.Fprintf(&b, "cli-agent crashed at %s\n\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Panic: %v\n", value)
+	fmt.Fprintf(&b, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "Arguments: %d\n", len(os.Args)-1)
+	if logPath, err := log.Path(); err == nil {
+		fmt.Fprintf(&b, "Log: %s\n", logPath)
+	}
+	if len(stack) > 0 {
+		fmt.Fprintf(&b, "\n%s", stack)
+	} else {
+		b.WriteString("\nThe stack trace was printed to the terminal when the panic was caught.\n")
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s-%d.txt", now.Format("20060102-150405"), os.Getpid()))
+	return path, os.WriteFile(path, []byte(b.String()), 0600)
+}
@@ -0,0 +1,273 @@
+// Package daemon keeps chats running in a background process that terminals
+// attach to and detach from, like tmux, so closing a terminal doesn't end a
+// long-running task
+package daemon
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent/config"
+)
+
+// Frames carry the protocol between a client and the daemon over a Unix
+// socket: a type byte, the payload length as a big-endian uint32, then the payload.
+const (
+	// Client to daemon
+	frameRequest = 'q' // a Request, always the first frame
+	frameInput   = 'i' // raw terminal input
+	frameResize  = 'r' // a Size
+	frameDetach  = 'd' // the client is leaving; the session keeps running
+
+	// Daemon to client
+	frameResponse = 'a' // a Response to the request
+	frameOutput   = 'o' // raw terminal output
+	frameEnded    = 'e' // why the client was let go, e.g. the chat was quit
+)
+
+// maxFrameSize caps a frame's payload, so a bad peer can't make us allocate without bound
+const maxFrameSize = 16 << 20
+
+// Request is what a client asks of the daemon
+type Request struct {
+	// Action is "attach", "list" or "stop"
+	Action string `json:"action"`
+
+	// Name picks the session to attach to. New starts a session, using Args
+	// as its command-line flags, e.g. ["--dir", "~/api"].
+	Name string   `json:"name,omitempty"`
+	New  bool     `json:"new,omitempty"`
+	Args []string `json:"args,omitempty"`
+
+	// The attaching terminal, so the chat is drawn to fit and in its colors
+	Size         Size `json:"size"`
+	ColorProfile int  `json:"color_profile"`
+}
+
+// Size is a terminal size in cells
+type Size struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Response answers a Request
+type Response struct {
+	Error string `json:"error,omitempty"`
+
+	// Session is the session attached to. AltScreen tells the client to
+	// switch its terminal to the alternate screen while attached.
+	Session   string `json:"session,omitempty"`
+	AltScreen bool   `json:"alt_screen,omitempty"`
+
+	Sessions []SessionInfo `json:"sessions,omitempty"`
+}
+
+// SessionInfo describes a running session for listings
+type SessionInfo struct {
+	Name     string    `json:"name"`
+	Args     []string  `json:"args,omitempty"`
+	Started  time.Time `json:"started"`
+	Attached bool      `json:"attached"`
+}
+
+// DetachKey detaches a terminal from its session and leaves the session
+// running: Ctrl+], as in telnet
+const DetachKey = 0x1d
+
+// Terminal modes an attached terminal switches on while the chat is shown:
+// the alternate screen, mouse reporting and bracketed paste
+const (
+	enterChatScreen = "\x1b[?1049h\x1b[?1002h\x1b[?1006h\x1b[?2004h\x1b[?25l"
+	leaveChatScreen = "\x1b[?2004l\x1b[?1006l\x1b[?1002l\x1b[?25h\x1b[?1049l"
+	enterPlainChat  = "\x1b[?2004h"
+	leavePlainChat  = "\x1b[?2004l\x1b[?25h"
+)
+
+// ScreenModes returns what an attached terminal writes to switch to the modes
+// the chat draws in, and back. The program itself switched them on only in
+// the terminal that was attached when it started.
+func ScreenModes(altScreen bool) (enter, leave string) {
+	if altScreen {
+		return enterChatScreen, leaveChatScreen
+	}
+	return enterPlainChat, leavePlainChat
+}
+
+// SocketPath returns where the daemon listens, in a directory of its own in
+// the user config directory, which only the user may open
+func SocketPath() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon", "daemon.sock"), nil
+}
+
+// LogPath returns where a daemon started in the background writes its log
+func LogPath() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "daemon.log"), nil
+}
+
+// Dial connects to the running daemon
+func Dial() (*Conn, error) {
+	path, err := SocketPath()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("the daemon isn't running: %w", err)
+	}
+	return newConn(conn), nil
+}
+
+// Running reports whether a daemon is listening
+func Running() bool {
+	conn, err := Dial()
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Conn is a connection between a client and the daemon. Frames may be
+// written from several goroutines.
+type Conn struct {
+	conn    net.Conn
+	writeMu sync.Mutex
+}
+
+func newConn(conn net.Conn) *Conn {
+	return &Conn{conn: conn}
+}
+
+// Close closes the connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// writeFrame sends one frame
+func (c *Conn) writeFrame(kind byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := make([]byte, 5)
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// writeJSON sends a frame holding v as JSON
+func (c *Conn) writeJSON(kind byte, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(kind, payload)
+}
+
+// readFrame receives one frame
+func (c *Conn) readFrame() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// Request sends a request and waits for the daemon's response. A failed
+// request is returned as an error.
+func (c *Conn) Request(request Request) (Response, error) {
+	if err := c.writeJSON(frameRequest, request); err != nil {
+		return Response{}, err
+	}
+
+	kind, payload, err := c.readFrame()
+	if err != nil {
+		return Response{}, fmt.Errorf("the daemon closed the connection: %w", err)
+	}
+	if kind != frameResponse {
+		return Response{}, fmt.Errorf("unexpected frame %q from the daemon", kind)
+	}
+
+	var response Response
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return Response{}, fmt.Errorf("failed to parse the daemon's response: %w", err)
+	}
+	if response.Error != "" {
+		return response, fmt.Errorf("%s", response.Error)
+	}
+	return response, nil
+}
+
+// SendInput forwards terminal input to the attached session
+func (c *Conn) SendInput(input []byte) error {
+	return c.writeFrame(frameInput, input)
+}
+
+// SendResize tells the attached session the terminal was resized
+func (c *Conn) SendResize(size Size) error {
+	return c.writeJSON(frameResize, size)
+}
+
+// Detach leaves the attached session running in the daemon
+func (c *Conn) Detach() error {
+	return c.writeFrame(frameDetach, nil)
+}
+
+// Relay copies the attached session's output to w until the session lets the
+// client go, returning the reason, or the connection fails
+func (c *Conn) Relay(w io.Writer) (string, error) {
+	for {
+		kind, payload, err := c.readFrame()
+		if err != nil {
+			return "", err
+		}
+
+		switch kind {
+		case frameOutput:
+			if _, err := w.Write(payload); err != nil {
+				return "", err
+			}
+		case frameEnded:
+			return string(payload), nil
+		}
+	}
+}
+
+// removeStaleSocket deletes a socket file left behind by a daemon that is
+// no longer running, so a new one can listen there
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	if Running() {
+		return fmt.Errorf("a daemon is already running")
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,448 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"agent/log"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Chat is a chat ready to run as a Bubble Tea program
+type Chat struct {
+	Model   tea.Model
+	Options []tea.ProgramOption
+
+	// AltScreen is set when the program draws on the alternate screen, so
+	// attached terminals switch to it too
+	AltScreen bool
+
+	// Close releases what the chat holds open once its program has exited
+	Close func()
+}
+
+// ChatFactory sets up a chat from command-line flags, as given to cli-agent.
+// The chat draws with renderer, which is the session's own, so sessions
+// started from different terminals each keep their colors.
+type ChatFactory func(args []string, renderer *lipgloss.Renderer) (Chat, error)
+
+// Server runs chats as sessions that outlive the terminals attached to them
+type Server struct {
+	newChat  ChatFactory
+	listener net.Listener
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	stopping bool
+}
+
+// NewServer creates a daemon that starts sessions with newChat
+func NewServer(newChat ChatFactory) *Server {
+	return &Server{
+		newChat:  newChat,
+		sessions: map[string]*session{},
+	}
+}
+
+// ListenAndServe listens on the daemon socket and serves clients until a
+// client stops the daemon
+func (s *Server) ListenAndServe() error {
+	path, err := SocketPath()
+	if err != nil {
+		return err
+	}
+	// Only the user may attach; a session runs tools with their rights. The
+	// directory is private before the socket exists, so there is no moment
+	// in which someone else could connect.
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("%s must be a directory, not a link", dir)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	return s.Serve(listener)
+}
+
+// Serve accepts clients on listener until a client stops the daemon
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	log.Info("daemon listening", "address", listener.Addr().String())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			stopping := s.stopping
+			s.mu.Unlock()
+			if stopping {
+				return nil
+			}
+			return err
+		}
+		go s.handle(newConn(conn))
+	}
+}
+
+// handle answers a client's request. Attached clients stay connected until
+// they detach or their session ends.
+func (s *Server) handle(conn *Conn) {
+	kind, payload, err := conn.readFrame()
+	if err != nil || kind != frameRequest {
+		conn.Close()
+		return
+	}
+
+	var request Request
+	if err := json.Unmarshal(payload, &request); err != nil {
+		conn.writeJSON(frameResponse, Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		conn.Close()
+		return
+	}
+
+	switch request.Action {
+	case "list":
+		conn.writeJSON(frameResponse, Response{Sessions: s.list()})
+		conn.Close()
+
+	case "stop":
+		conn.writeJSON(frameResponse, Response{})
+		conn.Close()
+		s.stop()
+
+	case "attach":
+		session, err := s.session(request)
+		if err != nil {
+			conn.writeJSON(frameResponse, Response{Error: err.Error()})
+			conn.Close()
+			return
+		}
+		session.serve(conn, request.Size)
+
+	default:
+		conn.writeJSON(frameResponse, Response{Error: fmt.Sprintf("unknown action %q", request.Action)})
+		conn.Close()
+	}
+}
+
+// session finds the session a request attaches to, or starts it. Without a
+// name a client attaches to the only session, or starts one when there is none.
+func (s *Server) session(request Request) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := request.Name
+	if !request.New {
+		if name == "" && len(s.sessions) > 1 {
+			return nil, errors.New("several sessions are running; name the one to attach to (see cli-agent attach -list)")
+		}
+		if name == "" && len(s.sessions) == 1 {
+			for _, existing := range s.sessions {
+				return existing, nil
+			}
+		}
+		if existing, ok := s.sessions[name]; ok {
+			return existing, nil
+		}
+		if name != "" {
+			return nil, fmt.Errorf("no session named %q; start it with cli-agent attach -new %s", name, name)
+		}
+	}
+
+	if name == "" {
+		name = s.freeName()
+	}
+	if _, ok := s.sessions[name]; ok {
+		return nil, fmt.Errorf("a session named %q is already running", name)
+	}
+
+	return s.start(name, request.Args, termenv.Profile(request.ColorProfile), "")
+}
+
+// start starts a session running the chat set up from args. Callers must hold the lock.
+func (s *Server) start(name string, args []string, colorProfile termenv.Profile, owner string) (*session, error) {
+	if s.stopping {
+		return nil, errors.New("the daemon is stopping")
+	}
+
+	// The chat is drawn in the colors of the terminal that started it. Its
+	// output goes to the attached clients, not through the renderer.
+	renderer := lipgloss.NewRenderer(io.Discard)
+	renderer.SetColorProfile(colorProfile)
+
+	chat, err := s.newChat(args, renderer)
+	if err != nil {
+		return nil, err
+	}
+
+	started := newSession(name, args, owner, chat)
+	s.sessions[name] = started
+	log.Info("session started", "session", name)
+
+	go func() {
+		started.run()
+		s.mu.Lock()
+		delete(s.sessions, name)
+		s.mu.Unlock()
+		log.Info("session ended", "session", name)
+	}()
+
+	return started, nil
+}
+
+// freeName picks the lowest-numbered unused default session name. Callers must hold the lock.
+func (s *Server) freeName() string {
+	for n := 1; ; n++ {
+		name := fmt.Sprint(n)
+		if _, ok := s.sessions[name]; !ok {
+			return name
+		}
+	}
+}
+
+// list describes the running sessions, oldest first
+func (s *Server) list() []SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := []SessionInfo{}
+	for _, session := range s.sessions {
+		sessions = append(sessions, session.info())
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Started.Before(sessions[j].Started)
+	})
+	return sessions
+}
+
+// stop quits every session and stops accepting clients
+func (s *Server) stop() {
+	s.mu.Lock()
+	s.stopping = true
+	sessions := make([]*session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	listener := s.listener
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.program.Quit()
+		<-session.done
+	}
+	if listener != nil {
+		listener.Close()
+	}
+	log.Info("daemon stopped")
+}
+
+// client is a terminal attached to a session
+type client interface {
+	// draw shows program output
+	draw(output []byte)
+
+	// end lets the client go, telling it why
+	end(reason string)
+}
+
+// session is a chat running in the daemon. Its program reads input from and
+// draws to whichever client is attached, or to nothing while none is.
+type session struct {
+	name    string
+	args    []string
+	started time.Time
+	chat    Chat
+	program *tea.Program
+	input   *io.PipeWriter
+	done    chan struct{}
+
+	// owner is the fingerprint of the SSH key that started the session, the
+	// only key it may be attached with. Sessions started over the daemon
+	// socket have none.
+	owner string
+
+	mu       sync.Mutex
+	attached client
+}
+
+func newSession(name string, args []string, owner string, chat Chat) *session {
+	session := &session{
+		name:    name,
+		args:    args,
+		started: time.Now(),
+		chat:    chat,
+		done:    make(chan struct{}),
+		owner:   owner,
+	}
+
+	input, inputWriter := io.Pipe()
+	session.input = inputWriter
+
+	options := append([]tea.ProgramOption{
+		tea.WithInput(input),
+		tea.WithOutput(outputFunc(session.draw)),
+		tea.WithoutSignalHandler(),
+	}, chat.Options...)
+	session.program = tea.NewProgram(chat.Model, options...)
+
+	return session
+}
+
+// run runs the chat until it is quit, then lets the attached client go
+func (s *session) run() {
+	_, err := s.program.Run()
+	// Input that arrives after the program stopped reading fails instead of blocking
+	s.input.Close()
+	if s.chat.Close != nil {
+		s.chat.Close()
+	}
+
+	reason := "the session ended"
+	if err != nil {
+		reason = fmt.Sprintf("the session failed: %v", err)
+	}
+
+	s.mu.Lock()
+	attached := s.attached
+	s.attached = nil
+	s.mu.Unlock()
+	if attached != nil {
+		attached.end(reason)
+	}
+
+	close(s.done)
+}
+
+// draw sends program output to the attached client, if any
+func (s *session) draw(output []byte) {
+	s.mu.Lock()
+	attached := s.attached
+	s.mu.Unlock()
+
+	if attached != nil {
+		attached.draw(output)
+	}
+}
+
+// attach makes c the session's terminal. A client attaching elsewhere takes
+// the session over.
+func (s *session) attach(c client, size Size) {
+	s.mu.Lock()
+	previous := s.attached
+	s.attached = c
+	s.mu.Unlock()
+	if previous != nil {
+		previous.end("the session was attached from another terminal")
+	}
+
+	// A resize redraws the whole chat, which brings the new terminal up to date
+	s.resize(size)
+}
+
+// detach lets a client go, leaving the session running
+func (s *session) detach(c client) {
+	s.mu.Lock()
+	if s.attached == c {
+		s.attached = nil
+	}
+	s.mu.Unlock()
+}
+
+// serve attaches a client connected over the daemon socket and relays its
+// input until it detaches or disconnects
+func (s *session) serve(conn *Conn, size Size) {
+	conn.writeJSON(frameResponse, Response{Session: s.name, AltScreen: s.chat.AltScreen})
+
+	c := &socketClient{conn: conn}
+	s.attach(c, size)
+	defer func() {
+		s.detach(c)
+		conn.Close()
+	}()
+
+	for {
+		kind, payload, err := conn.readFrame()
+		if err != nil {
+			return
+		}
+
+		switch kind {
+		case frameInput:
+			s.input.Write(payload)
+		case frameResize:
+			var size Size
+			if json.Unmarshal(payload, &size) == nil {
+				s.resize(size)
+			}
+		case frameDetach:
+			return
+		}
+	}
+}
+
+// resize tells the chat its terminal's size
+func (s *session) resize(size Size) {
+	if size.Width <= 0 || size.Height <= 0 {
+		return
+	}
+	s.program.Send(tea.WindowSizeMsg{Width: size.Width, Height: size.Height})
+}
+
+// info describes the session for listings
+func (s *session) info() SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SessionInfo{Name: s.name, Args: s.args, Started: s.started, Attached: s.attached != nil}
+}
+
+// socketClient is a client attached over the daemon socket
+type socketClient struct {
+	conn *Conn
+}
+
+func (c *socketClient) draw(output []byte) {
+	c.conn.writeFrame(frameOutput, output)
+}
+
+func (c *socketClient) end(reason string) {
+	c.conn.writeFrame(frameEnded, []byte(reason))
+	c.conn.Close()
+}
+
+// outputFunc adapts a function to an io.Writer for the program's output
+type outputFunc func([]byte)
+
+func (f outputFunc) Write(p []byte) (int, error) {
+	f(append([]byte(nil), p...))
+	return len(p), nil
+}
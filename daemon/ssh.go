@@ -0,0 +1,204 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"agent/log"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/charmbracelet/wish/activeterm"
+	"github.com/muesli/termenv"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// SSHConfig configures serving sessions over SSH
+type SSHConfig struct {
+	// Address is the host and port to listen on, e.g. ":2222"
+	Address string
+
+	// HostKeyPath is the server's private key, generated when missing.
+	// AuthorizedKeysPath lists the public keys that may connect, in the
+	// format of ~/.ssh/authorized_keys.
+	HostKeyPath        string
+	AuthorizedKeysPath string
+
+	// Args are the command-line flags every session starts with
+	Args []string
+}
+
+// ServeSSH serves sessions to terminals connecting over SSH. Every SSH user
+// has their own sessions, which keep running between connections; only the
+// key that started a session may attach to it again.
+func (s *Server) ServeSSH(config SSHConfig) error {
+	if _, err := os.Stat(config.AuthorizedKeysPath); err != nil {
+		return fmt.Errorf("no authorized keys at %s; add the public keys that may connect there, one per line as in ~/.ssh/authorized_keys", config.AuthorizedKeysPath)
+	}
+
+	server, err := wish.NewServer(
+		wish.WithAddress(config.Address),
+		wish.WithHostKeyPath(config.HostKeyPath),
+		wish.WithAuthorizedKeys(config.AuthorizedKeysPath),
+		wish.WithMiddleware(
+			func(next ssh.Handler) ssh.Handler {
+				return func(sess ssh.Session) {
+					s.serveSSH(sess, config.Args)
+					next(sess)
+				}
+			},
+			activeterm.Middleware(),
+		),
+	)
+	if err != nil {
+		return err
+	}
+
+	log.Info("serving SSH", "address", config.Address)
+	if err := server.ListenAndServe(); err != nil && err != ssh.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// serveSSH attaches an SSH connection to its user's session, starting it if
+// needed, until the user detaches or disconnects. The session is named after
+// the SSH user, or user/name when a name is given as the command, as in
+// `ssh -t host api`.
+func (s *Server) serveSSH(sess ssh.Session, args []string) {
+	name := sess.User()
+	switch command := sess.Command(); len(command) {
+	case 0:
+	case 1:
+		name += "/" + command[0]
+	default:
+		wish.Fatalln(sess, "the command is a session name, e.g. ssh -t host api")
+		return
+	}
+
+	pty, windowChanges, _ := sess.Pty()
+	owner := gossh.FingerprintSHA256(sess.PublicKey())
+	session, err := s.sshSession(name, owner, sshColorProfile(sess, pty.Term), args)
+	if err != nil {
+		wish.Fatalln(sess, err)
+		return
+	}
+	log.Info("session attached over SSH", "session", name, "remote", sess.RemoteAddr().String())
+
+	enter, leave := ScreenModes(session.chat.AltScreen)
+	io.WriteString(sess, enter)
+
+	c := &sshClient{sess: sess, ended: make(chan string, 1)}
+	session.attach(c, Size{Width: pty.Window.Width, Height: pty.Window.Height})
+
+	// Window changes resize the session until this connection detaches
+	attached := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case window, ok := <-windowChanges:
+				if !ok {
+					return
+				}
+				session.resize(Size{Width: window.Width, Height: window.Height})
+			case <-attached:
+				return
+			case <-sess.Context().Done():
+				return
+			}
+		}
+	}()
+
+	// Input up to the detach key still goes to the chat
+	detached := make(chan struct{})
+	go func() {
+		defer close(detached)
+		buffer := make([]byte, 4096)
+		for {
+			n, err := sess.Read(buffer)
+			if err != nil {
+				return
+			}
+			input := buffer[:n]
+			if i := strings.IndexByte(string(input), DetachKey); i >= 0 {
+				session.input.Write(input[:i])
+				return
+			}
+			session.input.Write(input)
+		}
+	}()
+
+	var reason string
+	select {
+	case <-detached:
+	case reason = <-c.ended:
+	case <-sess.Context().Done():
+	}
+	close(attached)
+	session.detach(c)
+
+	io.WriteString(sess, leave)
+	if reason == "" {
+		wish.Printf(sess, "Detached from session %s; it keeps running. Reconnect to reattach.\n", name)
+	} else {
+		wish.Printf(sess, "Left session %s: %s.\n", name, reason)
+	}
+	sess.Exit(0)
+}
+
+// sshSession finds the session an SSH user attaches to, or starts it. A
+// session may only be attached with the key that started it.
+func (s *Server) sshSession(name, owner string, colorProfile termenv.Profile, args []string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.sessions[name]; ok {
+		if existing.owner != owner {
+			return nil, fmt.Errorf("session %s was started with another key", name)
+		}
+		return existing, nil
+	}
+	return s.start(name, args, colorProfile, owner)
+}
+
+// sshClient is a client attached over SSH
+type sshClient struct {
+	sess  ssh.Session
+	ended chan string
+}
+
+func (c *sshClient) draw(output []byte) {
+	c.sess.Write(output)
+}
+
+func (c *sshClient) end(reason string) {
+	select {
+	case c.ended <- reason:
+	default:
+	}
+}
+
+// sshColorProfile works out the colors an SSH client's terminal supports
+// from the terminal type and environment it sent
+func sshColorProfile(sess ssh.Session, term string) termenv.Profile {
+	environ := sshEnviron(append(sess.Environ(), "TERM="+term))
+	return termenv.NewOutput(sess, termenv.WithEnvironment(environ), termenv.WithUnsafe()).EnvColorProfile()
+}
+
+// sshEnviron is the environment an SSH client sent, for termenv
+type sshEnviron []string
+
+func (e sshEnviron) Environ() []string {
+	return e
+}
+
+func (e sshEnviron) Getenv(key string) string {
+	for _, entry := range e {
+		if value, ok := strings.CutPrefix(entry, key+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,230 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxMatrixCells bounds the memory used by the LCS table; larger inputs fall
+// back to a coarse diff that replaces the whole differing middle section
+const maxMatrixCells = 4_000_000
+
+// Op is the kind of a single line edit
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is one line of a line-based diff
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// Lines computes a line-based diff turning before into after
+func Lines(before, after string) []Edit {
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// Trim the common prefix and suffix so the expensive part only sees the changed middle
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, line := range a[:prefix] {
+		edits = append(edits, Edit{Op: Equal, Line: line})
+	}
+
+	edits = append(edits, lcsDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Op: Equal, Line: line})
+	}
+
+	return edits
+}
+
+// lcsDiff diffs two line slices using a longest-common-subsequence table
+func lcsDiff(a, b []string) []Edit {
+	edits := []Edit{}
+
+	if len(a)*len(b) > maxMatrixCells {
+		for _, line := range a {
+			edits = append(edits, Edit{Op: Delete, Line: line})
+		}
+		for _, line := range b {
+			edits = append(edits, Edit{Op: Insert, Line: line})
+		}
+		return edits
+	}
+
+	// table[i][j] holds the LCS length of a[i:] and b[j:]
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, Edit{Op: Equal, Line: a[i]})
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			edits = append(edits, Edit{Op: Delete, Line: a[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Op: Insert, Line: b[j]})
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		edits = append(edits, Edit{Op: Delete, Line: a[i]})
+	}
+	for ; j < len(b); j++ {
+		edits = append(edits, Edit{Op: Insert, Line: b[j]})
+	}
+
+	return edits
+}
+
+// Stats returns the number of added and removed lines between before and after
+func Stats(before, after string) (added, removed int) {
+	for _, edit := range Lines(before, after) {
+		switch edit.Op {
+		case Insert:
+			added++
+		case Delete:
+			removed++
+		}
+	}
+
+	return added, removed
+}
+
+// Unified renders a unified diff with the given number of context lines.
+// It returns an empty string when the contents are identical.
+func Unified(fromName, toName, before, after string, context int) string {
+	edits := Lines(before, after)
+
+	changed := false
+	for _, edit := range edits {
+		if edit.Op != Equal {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+
+	// Line numbers in the old and new file for each edit index
+	oldLine, newLine := 1, 1
+	oldNumbers := make([]int, len(edits))
+	newNumbers := make([]int, len(edits))
+	for i, edit := range edits {
+		oldNumbers[i], newNumbers[i] = oldLine, newLine
+		if edit.Op != Insert {
+			oldLine++
+		}
+		if edit.Op != Delete {
+			newLine++
+		}
+	}
+
+	for start := 0; start < len(edits); {
+		// Find the next change
+		first := start
+		for first < len(edits) && edits[first].Op == Equal {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+
+		// Extend the hunk while changes are within 2*context lines of each other
+		last := first
+		for i := first; i < len(edits); i++ {
+			if edits[i].Op != Equal {
+				last = i
+				continue
+			}
+			if i-last > 2*context {
+				break
+			}
+		}
+
+		hunkStart := max(first-context, start)
+		hunkEnd := min(last+context+1, len(edits))
+
+		oldCount, newCount := 0, 0
+		for _, edit := range edits[hunkStart:hunkEnd] {
+			if edit.Op != Insert {
+				oldCount++
+			}
+			if edit.Op != Delete {
+				newCount++
+			}
+		}
+
+		oldStart, newStart := oldNumbers[hunkStart], newNumbers[hunkStart]
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, edit := range edits[hunkStart:hunkEnd] {
+			switch edit.Op {
+			case Equal:
+				b.WriteString(" ")
+			case Insert:
+				b.WriteString("+")
+			case Delete:
+				b.WriteString("-")
+			}
+			b.WriteString(edit.Line)
+			b.WriteString("\n")
+		}
+
+		start = hunkEnd
+	}
+
+	return b.String()
+}
+
+// splitLines splits text into lines, treating a trailing newline as a terminator rather than an empty line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,131 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FileDiff is the part of a unified diff that concerns one file
+type FileDiff struct {
+	// OldPath and NewPath have git's a/ and b/ prefixes removed. OldPath is
+	// empty for created files and NewPath is empty for deleted ones.
+	OldPath string
+	NewPath string
+	Added   []Line
+	Removed int
+	Text    string
+}
+
+// Line is a line added by a diff, with its 1-based number in the new file
+type Line struct {
+	Number int
+	Text   string
+}
+
+// Path returns the file's path after the change, or before it for deletions
+func (f FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Parse splits a unified diff, such as the output of git diff, into files
+// and collects the lines each one adds
+func Parse(text string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var body strings.Builder
+
+	// Lines left in the current hunk; headers are only recognized outside hunks,
+	// since a removed line can itself start with "--"
+	oldLeft, newLeft, newLine := 0, 0, 0
+
+	flush := func() {
+		if current != nil {
+			current.Text = body.String()
+			files = append(files, *current)
+		}
+		current = nil
+		body.Reset()
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		inHunk := oldLeft > 0 || newLeft > 0
+
+		switch {
+		case inHunk && strings.HasPrefix(line, "+"):
+			current.Added = append(current.Added, Line{Number: newLine, Text: line[1:]})
+			newLine++
+			newLeft--
+		case inHunk && strings.HasPrefix(line, "-"):
+			current.Removed++
+			oldLeft--
+		case inHunk && (strings.HasPrefix(line, " ") || line == ""):
+			newLine++
+			oldLeft--
+			newLeft--
+		case inHunk:
+			// "\ No newline at end of file" and similar markers
+
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			current = &FileDiff{}
+		case strings.HasPrefix(line, "--- "):
+			if current == nil || current.OldPath != "" || current.NewPath != "" || len(current.Added) > 0 {
+				flush()
+				current = &FileDiff{}
+			}
+			current.OldPath = diffPath(strings.TrimPrefix(line, "--- "), "a/")
+		case strings.HasPrefix(line, "+++ ") && current != nil:
+			current.NewPath = diffPath(strings.TrimPrefix(line, "+++ "), "b/")
+		case strings.HasPrefix(line, "@@ ") && current != nil:
+			newLine, oldLeft, newLeft = parseHunkHeader(line)
+		}
+
+		if current != nil {
+			body.WriteString(line)
+			body.WriteString("\n")
+		}
+	}
+	flush()
+
+	return files
+}
+
+// diffPath strips a diff header's prefix, timestamp and /dev/null marker
+func diffPath(header, prefix string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	if path == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(path, prefix)
+}
+
+// parseHunkHeader reads a "@@ -a,b +c,d @@" header, returning the first new
+// line number and the old and new line counts
+func parseHunkHeader(header string) (int, int, int) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 {
+		return 0, 0, 0
+	}
+
+	_, oldCount := parseRange(strings.TrimPrefix(fields[1], "-"))
+	newStart, newCount := parseRange(strings.TrimPrefix(fields[2], "+"))
+
+	// A hunk adding to an empty file starts at 0; its first line is 1
+	return max(newStart, 1), oldCount, newCount
+}
+
+// parseRange parses "start,count", where a missing count means 1
+func parseRange(text string) (int, int) {
+	startText, countText, hasCount := strings.Cut(text, ",")
+
+	start, _ := strconv.Atoi(startText)
+	count := 1
+	if hasCount {
+		count, _ = strconv.Atoi(countText)
+	}
+
+	return start, count
+}
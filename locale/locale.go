@@ -0,0 +1,168 @@
+// Package locale holds the translatable strings of the user interface
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"agent/config"
+)
+
+// DefaultLanguage is the language of the built-in strings, used for any missing translation
+const DefaultLanguage = "en"
+
+//go:embed catalogs/*.json
+var catalogs embed.FS
+
+var (
+	mu       sync.RWMutex
+	language = DefaultLanguage
+	messages = mustLoadEmbedded(DefaultLanguage)
+	fallback = messages
+)
+
+// Catalog maps message keys to fmt format strings
+type Catalog map[string]string
+
+func mustLoadEmbedded(lang string) Catalog {
+	catalog, err := loadEmbedded(lang)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+}
+
+func loadEmbedded(lang string) (Catalog, error) {
+	data, err := catalogs.ReadFile("catalogs/" + lang + ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := Catalog{}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("invalid %s catalog: %w", lang, err)
+	}
+	return catalog, nil
+}
+
+// userCatalogPath is where a user's own translation or overrides for lang live
+func userCatalogPath(lang string) (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "locales", lang+".json"), nil
+}
+
+// Detect returns the language from the environment (LC_ALL, LC_MESSAGES or
+// LANG, e.g. "de_DE.UTF-8" is "de"), or DefaultLanguage
+func Detect() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		lang, _, _ := strings.Cut(value, "_")
+		lang, _, _ = strings.Cut(lang, ".")
+		lang = strings.ToLower(lang)
+		if lang == "c" || lang == "posix" {
+			return DefaultLanguage
+		}
+		return lang
+	}
+
+	return DefaultLanguage
+}
+
+// Set switches the interface language. Strings come from the built-in
+// catalog for lang, then from locales/<lang>.json in the user config
+// directory, which may add a language or override single strings. Missing
+// strings fall back to English. An unknown language is an error and leaves
+// English in place.
+func Set(lang string) error {
+	lang = strings.ToLower(lang)
+
+	catalog := Catalog{}
+	for key, value := range fallback {
+		catalog[key] = value
+	}
+
+	found := lang == DefaultLanguage
+	if builtin, err := loadEmbedded(lang); err == nil {
+		found = true
+		for key, value := range builtin {
+			catalog[key] = value
+		}
+	}
+
+	if path, err := userCatalogPath(lang); err == nil {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			user := Catalog{}
+			if err := json.Unmarshal(data, &user); err != nil {
+				return fmt.Errorf("failed to parse %s: %w", path, err)
+			}
+			found = true
+			for key, value := range user {
+				catalog[key] = value
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("no translation for language %q (available: %s)", lang, strings.Join(Available(), ", "))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	language = lang
+	messages = catalog
+	return nil
+}
+
+// Language returns the active interface language
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return language
+}
+
+// Available lists the built-in languages
+func Available() []string {
+	entries, _ := catalogs.ReadDir("catalogs")
+
+	languages := []string{}
+	for _, entry := range entries {
+		languages = append(languages, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(languages)
+
+	return languages
+}
+
+// T returns the message for key in the active language, formatted with args.
+// Unknown keys are returned as is, so a missing string is visible but harmless.
+func T(key string, args ...any) string {
+	mu.RLock()
+	format, ok := messages[key]
+	mu.RUnlock()
+
+	if !ok {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+
+	return fmt.Sprintf(format, args...)
+}
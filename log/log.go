@@ -0,0 +1,173 @@
+// Package log writes the leveled, structured logs shared by the agent, its
+// tools, the chat and the servers: JSON lines in a rotating file in the user
+// config directory, optionally echoed to the terminal.
+package log
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"agent/config"
+)
+
+// Levels accepted by --log-level
+var levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+var (
+	// level is the level of the log file, and of an echo that follows it
+	level = new(slog.LevelVar)
+
+	mu      sync.Mutex
+	file    slog.Handler
+	echo    slog.Handler
+	current atomic.Pointer[slog.Logger]
+)
+
+func init() {
+	current.Store(slog.New(teeHandler{}))
+}
+
+// Path returns the log file, e.g. ~/.config/cli-agent/logs/cli-agent.log
+func Path() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs", "cli-agent.log"), nil
+}
+
+// Setup starts writing the log file. Until it is called, nothing is logged.
+// It returns a function closing the file.
+func Setup() (func() error, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	writer, err := openRotating(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the log file: %w", err)
+	}
+
+	mu.Lock()
+	file = slog.NewJSONHandler(writer, &slog.HandlerOptions{Level: level})
+	update()
+	mu.Unlock()
+
+	return writer.Close, nil
+}
+
+// EchoTo also writes the log to w as text lines, from minimum up, e.g. to
+// stderr for servers. Pass Level() to follow --log-level.
+func EchoTo(w io.Writer, minimum slog.Leveler) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	echo = slog.NewTextHandler(w, &slog.HandlerOptions{Level: minimum})
+	update()
+}
+
+// update replaces the logger with one writing to the current handlers.
+// Callers must hold mu.
+func update() {
+	handlers := []slog.Handler{}
+	for _, handler := range []slog.Handler{file, echo} {
+		if handler != nil {
+			handlers = append(handlers, handler)
+		}
+	}
+	current.Store(slog.New(teeHandler(handlers)))
+}
+
+// ParseLevel parses a --log-level value: debug, info, warn or error
+func ParseLevel(name string) (slog.Level, error) {
+	parsed, ok := levels[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown log level %q; use debug, info, warn or error", name)
+	}
+	return parsed, nil
+}
+
+// SetLevel sets the level of the log file from a --log-level value
+func SetLevel(name string) error {
+	parsed, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(parsed)
+	return nil
+}
+
+// Level returns the level set by --log-level, info unless changed
+func Level() slog.Leveler {
+	return level
+}
+
+// Debug logs details only wanted when tracking a problem down
+func Debug(msg string, args ...any) {
+	current.Load().Debug(msg, args...)
+}
+
+// Info logs what happened in normal operation
+func Info(msg string, args ...any) {
+	current.Load().Info(msg, args...)
+}
+
+// Warn logs a problem that was worked around
+func Warn(msg string, args ...any) {
+	current.Load().Warn(msg, args...)
+}
+
+// Error logs a failure
+func Error(msg string, args ...any) {
+	current.Load().Error(msg, args...)
+}
+
+// teeHandler passes records on to every handler enabled for their level
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range t {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	for _, handler := range t {
+		if handler.Enabled(ctx, record.Level) {
+			if err := handler.Handle(ctx, record.Clone()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, handler := range t {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(t))
+	for i, handler := range t {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,100 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// maxFileBytes is the size from which the log file is rotated
+const maxFileBytes = 10 << 20
+
+// maxBackups is how many rotated log files are kept, as cli-agent.log.1 and so on
+const maxBackups = 3
+
+// rotatingFile appends to a log file, moving it aside once it grows too large
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+// openRotating opens the log file at path for appending, creating it if needed
+func openRotating(path string) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current log file and notes its size
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > maxFileBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves the log file to cli-agent.log.1, shifting the older backups
+// along and dropping the oldest, and starts a new file
+func (r *rotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	os.Remove(backupPath(r.path, maxBackups))
+	for n := maxBackups - 1; n >= 1; n-- {
+		os.Rename(backupPath(r.path, n), backupPath(r.path, n+1))
+	}
+	if err := os.Rename(r.path, backupPath(r.path, 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// backupPath is the name of the nth rotated log file
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
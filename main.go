@@ -2,31 +2,362 @@ package main
 
 import (
 	"agent/agent"
+	"agent/cli"
 	"agent/config"
+	"agent/crash"
+	"agent/daemon"
+	"agent/locale"
+	"agent/log"
+	"agent/provider"
+	"agent/recording"
 	"agent/tools"
 	"agent/tui"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
 func main() {
-	// Initialize configuration
-	cfg := config.NewConfig()
+	defer crash.Recover()
+
+	args, err := leadingLogLevel(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], args...)
+
+	// The agent works without its log, so a log that can't be opened is only reported
+	if closeLog, err := log.Setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		defer closeLog()
+	}
+	log.Info("cli-agent started", "pid", os.Getpid())
+
+	// Subcommands such as "replay" run headless and exit
+	if len(os.Args) > 1 {
+		if command, ok := cli.Commands[os.Args[1]]; ok {
+			// Servers report what they do on stderr; other commands only problems
+			if os.Args[1] == "serve" || os.Args[1] == "bridge" {
+				log.EchoTo(os.Stderr, log.Level())
+			} else {
+				log.EchoTo(os.Stderr, slog.LevelWarn)
+			}
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// "new" creates a project from a template and then opens the chat in it
+	var created *cli.NewProject
+	if len(os.Args) > 1 && os.Args[1] == "new" {
+		project, err := cli.New(os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if project == nil {
+			return
+		}
+		created = project
+		os.Args = os.Args[:1]
+	}
+
+	// "daemon" keeps chats running in the background for `cli-agent attach`,
+	// and "serve-ssh" serves them to SSH clients
+	servers := map[string]func([]string, daemon.ChatFactory) error{
+		"daemon":    cli.Daemon,
+		"serve-ssh": cli.ServeSSH,
+	}
+	if len(os.Args) > 1 && servers[os.Args[1]] != nil {
+		log.EchoTo(os.Stderr, log.Level())
+		newSession := func(args []string, renderer *lipgloss.Renderer) (daemon.Chat, error) {
+			return newChat(args, renderer, flag.ContinueOnError, nil, false)
+		}
+		if err := servers[os.Args[1]](os.Args[2:], newSession); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	chat, err := newChat(os.Args[1:], lipgloss.NewRenderer(os.Stdout), flag.ExitOnError, created, true)
+	if err != nil {
+		log.Error("chat failed to start", "error", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer chat.Close()
+
+	program := tea.NewProgram(chat.Model, chat.Options...)
+
+	// Panics in the program's own goroutines are caught by bubbletea, which
+	// gives the terminal back; the others leave it to the crash handler
+	forgetProgram := crash.OnCrash(func() string {
+		program.ReleaseTerminal()
+		return ""
+	})
+	final, err := program.Run()
+	forgetProgram()
+
+	if errors.Is(err, tea.ErrProgramPanic) {
+		crash.Recovered(err)
+	}
+
+	// Turns still running when the chat quit, e.g. on Ctrl+C or SIGTERM, are
+	// stopped cleanly and the conversations saved to be resumed
+	for _, line := range tui.Shutdown(final) {
+		fmt.Println(line)
+	}
+	if err != nil {
+		log.Error("chat failed", "error", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// leadingLogLevel applies a --log-level flag given before the subcommand,
+// which works for every command, e.g. cli-agent --log-level debug run ...,
+// and returns the arguments after it
+func leadingLogLevel(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return args, nil
+	}
+
+	name, value, hasValue := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+	if !strings.HasPrefix(args[0], "-") || name != "log-level" {
+		return args, nil
+	}
+	rest := args[1:]
+	if !hasValue {
+		if len(rest) == 0 {
+			return nil, fmt.Errorf("--log-level needs a level: debug, info, warn or error")
+		}
+		value, rest = rest[0], rest[1:]
+	}
+	return rest, log.SetLevel(value)
+}
+
+// newChat sets up a chat from the command-line flags in args, drawn with
+// renderer. Interactive chats walk new users through setup; the daemon's
+// can't, so they fail instead.
+func newChat(args []string, renderer *lipgloss.Renderer, errorHandling flag.ErrorHandling, created *cli.NewProject, interactive bool) (daemon.Chat, error) {
+	flags := flag.NewFlagSet("cli-agent", errorHandling)
+	dir := flags.String("dir", "", "Working directory for the agent (defaults to the current directory)")
+	record := flags.String("record", "", "Record the session (inputs, responses and tool results) to this file for `cli-agent replay`")
+	budget := flags.String("budget", "", "Session budget in tokens (e.g. 200k) or dollars (e.g. $2.50)")
+	hardBudget := flags.Bool("hard-budget", false, "Stop at the budget instead of asking to continue")
+	profile := flags.String("profile", "", "Settings profile to use (defaults to default_profile in settings.json)")
+	plain := flags.Bool("plain", false, "Accessible plain output: no colors, borders or emoji (also set by accessible in settings.json, NO_COLOR or TERM=dumb)")
+	dryRun := flags.Bool("dry-run", false, "Stage file changes for review at the end of each turn instead of writing them (also set by dry_run in settings.json)")
+	debugLog := flags.String("debug-log", "", "Record raw model response streams to this file (replayable with the mock provider)")
+	responseCache := flags.String("response-cache", "", "Answer repeated requests from responses cached in this directory instead of the API (for development)")
+	resume := flags.String("resume", "", "Continue a conversation saved when cli-agent crashed or was stopped")
+	flags.Func("log-level", "Level of the log in the user config directory's logs folder: debug, info, warn or error (default info)", log.SetLevel)
+	extraRoots := map[string]string{}
+	flags.Func("root", "Additional workspace root as name=path (repeatable)", func(value string) error {
+		name, path, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("expected name=path, got %q", value)
+		}
+		extraRoots[name] = path
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return daemon.Chat{}, err
+	}
+	if created != nil {
+		*dir = created.Dir
+	}
+
+	// A resumed conversation continues in its own workspace unless --dir says otherwise
+	var resumed *agent.Session
+	if *resume != "" {
+		session, savedDir, err := agent.LoadSession(*resume)
+		if err != nil {
+			return daemon.Chat{}, err
+		}
+		resumed = session
+		if *dir == "" {
+			*dir = savedDir
+		}
+	}
+
+	// Resolve the workspace the tools operate in
+	newWorkspace := func(dir string) (*tools.Workspace, error) {
+		workspace, err := tools.NewWorkspace(dir)
+		if err != nil {
+			return nil, err
+		}
+		for name, path := range extraRoots {
+			if err := workspace.AddRoot(name, path); err != nil {
+				return nil, err
+			}
+		}
+		return workspace, nil
+	}
+	workspace, err := newWorkspace(*dir)
+	if err != nil {
+		return daemon.Chat{}, err
+	}
 
 	// Get all available tools
 	availableTools := tools.GetAllTools()
 
-	// Create the agent
-	agentInstance := agent.NewAgent(cfg.Client, availableTools)
+	// Providers built for each profile get the same cache, debug log and
+	// recorder wrapping. The cache is innermost, so cached responses are
+	// still logged and recorded.
+	var closers []func() error
+	var wrappers []func(provider.Provider) provider.Provider
+	if *responseCache != "" {
+		wrappers = append(wrappers, func(inner provider.Provider) provider.Provider {
+			return provider.NewCache(inner, *responseCache)
+		})
+	}
+	if *debugLog != "" {
+		logFile, err := os.OpenFile(*debugLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return daemon.Chat{}, err
+		}
+		closers = append(closers, logFile.Close)
 
-	_, err := tea.NewProgram(
-		tui.InitialChatModel(agentInstance),
-		tea.WithAltScreen(),
-		tea.WithMouseCellMotion(),
-	).Run()
+		writeLog := provider.JSONLinesWriter(logFile)
+		wrappers = append(wrappers, func(inner provider.Provider) provider.Provider {
+			return provider.NewRecorder(inner, writeLog)
+		})
+	}
+
+	var recorder *recording.Recorder
+	if *record != "" {
+		recorder = recording.NewRecorder(*record)
+		wrappers = append(wrappers, recorder.Wrap)
+	}
+
+	// Settings problems are reported in the chat once it opens
+	settings, _ := config.LoadSettings()
+	language := settings.Language
+	if language == "" {
+		language = locale.Detect()
+	}
+	var notices []string
+	if err := locale.Set(language); err != nil && settings.Language != "" {
+		notices = append(notices, err.Error())
+	}
+	if created != nil {
+		notices = append(notices, created.Notice)
+	}
+	tui.SetPlainMode(*plain || settings.PlainOutput())
+
+	// Walk new users through setup instead of failing on the first request
+	if *profile == "" && config.NeedsSetup() {
+		if !interactive {
+			return daemon.Chat{}, fmt.Errorf("cli-agent isn't set up yet; run cli-agent once in a terminal first")
+		}
+		setupNotice, err := tui.RunSetup()
+		if err != nil {
+			return daemon.Chat{}, err
+		}
+		notices = append(notices, setupNotice)
+	}
 
+	loadProfile := func(name string) (agent.ActiveProfile, error) {
+		cfg, err := config.LoadConfig(name)
+		if err != nil {
+			return agent.ActiveProfile{}, err
+		}
+
+		var modelProvider provider.Provider = provider.NewAnthropic(cfg.Client, cfg.Endpoint)
+		for _, wrap := range wrappers {
+			modelProvider = wrap(modelProvider)
+		}
+
+		return agent.ActiveProfile{Name: cfg.Profile, Provider: modelProvider, Model: cfg.Model, RateLimits: cfg.RateLimits}, nil
+	}
+
+	// Initialize configuration
+	activeProfile, err := loadProfile(*profile)
 	if err != nil {
-		log.Fatal(err)
+		return daemon.Chat{}, err
+	}
+
+	var sessionBudget *agent.Budget
+	if *budget != "" {
+		parsed, err := agent.ParseBudget(*budget)
+		if err != nil {
+			return daemon.Chat{}, err
+		}
+		parsed.Hard = *hardBudget
+		sessionBudget = &parsed
+	}
+
+	// Create the agent; every tab gets one set up the same way
+	newAgent := func(workspace *tools.Workspace, activeProfile agent.ActiveProfile) *agent.Agent {
+		agentInstance := agent.NewAgent(activeProfile.Provider, availableTools, workspace)
+		agentInstance.UseProfile(activeProfile)
+		agentInstance.SetProfileSwitcher(loadProfile)
+		agentInstance.SetResponseLanguage(settings.ResponseLanguage)
+		agentInstance.SetDryRun(*dryRun || settings.DryRun)
+		agentInstance.SetModelRouter(settings.ModelRouter)
+		if settings.Approvals != nil {
+			agentInstance.SetApprovalTools(settings.Approvals.Tools)
+		}
+		if recorder != nil {
+			agentInstance.SetToolInterceptor(recorder.InterceptTool)
+		}
+		if sessionBudget != nil {
+			agentInstance.SetBudget(*sessionBudget)
+		}
+		return agentInstance
+	}
+	agentInstance := newAgent(workspace, activeProfile)
+
+	// Tabs opened later start in their own directory with the startup profile
+	openTab := func(dir string) (*agent.Agent, error) {
+		// A recording holds one conversation, so it can't take in another tab's
+		if recorder != nil {
+			return nil, fmt.Errorf("tabs are unavailable while recording a session with --record")
+		}
+		workspace, err := newWorkspace(dir)
+		if err != nil {
+			return nil, err
+		}
+		activeProfile, err := loadProfile(*profile)
+		if err != nil {
+			return nil, err
+		}
+		return newAgent(workspace, activeProfile), nil
+	}
+
+	// Plain mode stays in the normal screen so the conversation remains in the
+	// terminal's scrollback, where screen readers can review it
+	programOptions := []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()}
+	if tui.PlainMode() {
+		programOptions = nil
+	}
+
+	chatModel := tui.InitialChatModel(agentInstance, renderer)
+	if resumed != nil {
+		chatModel = chatModel.WithResumed(resumed)
 	}
+
+	return daemon.Chat{
+		Model:     chatModel.WithNotice(strings.Join(notices, "\n")).WithTabs(openTab),
+		Options:   programOptions,
+		AltScreen: !tui.PlainMode(),
+		Close: func() {
+			for _, closer := range closers {
+				closer()
+			}
+		},
+	}, nil
 }
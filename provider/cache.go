@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"agent/log"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
+)
+
+// Cache wraps a provider and answers requests it has answered before from
+// responses stored on disk, so replaying the same prompts during development
+// doesn't call the API again. Responses are stored one per file in the
+// format the recorder writes and the mock provider replays.
+type Cache struct {
+	inner Provider
+	dir   string
+}
+
+// NewCache creates a caching provider storing its responses in dir, which is
+// created when the first response is stored
+func NewCache(inner Provider, dir string) *Cache {
+	return &Cache{inner: inner, dir: dir}
+}
+
+// CacheKey identifies a request to endpoint by its model, system prompt,
+// messages and tools, and everything else that changes the response: the
+// token limit, sampling, thinking, tool choice and stop sequences
+func CacheKey(endpoint string, params anthropic.MessageNewParams) (string, error) {
+	data, err := json.Marshal(struct {
+		Endpoint      string                             `json:"endpoint"`
+		Model         anthropic.Model                    `json:"model"`
+		System        []anthropic.TextBlockParam         `json:"system"`
+		Messages      []anthropic.MessageParam           `json:"messages"`
+		Tools         []anthropic.ToolUnionParam         `json:"tools"`
+		MaxTokens     int64                              `json:"max_tokens"`
+		Temperature   param.Opt[float64]                 `json:"temperature"`
+		TopP          param.Opt[float64]                 `json:"top_p"`
+		TopK          param.Opt[int64]                   `json:"top_k"`
+		Thinking      anthropic.ThinkingConfigParamUnion `json:"thinking"`
+		ToolChoice    anthropic.ToolChoiceUnionParam     `json:"tool_choice"`
+		StopSequences []string                           `json:"stop_sequences"`
+	}{endpoint, params.Model, params.System, params.Messages, params.Tools, params.MaxTokens, params.Temperature, params.TopP, params.TopK,
+		params.Thinking, params.ToolChoice, params.StopSequences})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// NewStreaming replays a cached response for the request, or starts it on the
+// wrapped provider and caches the response once it completes
+func (c *Cache) NewStreaming(ctx context.Context, params anthropic.MessageNewParams) Stream {
+	endpoint := ""
+	if e, ok := c.inner.(Endpointer); ok {
+		endpoint = e.Endpoint()
+	}
+
+	key, err := CacheKey(endpoint, params)
+	if err != nil {
+		log.Warn("response cache key failed", "error", err)
+		return c.inner.NewStreaming(ctx, params)
+	}
+
+	path := filepath.Join(c.dir, key+".json")
+	if response, ok := readCached(path); ok {
+		log.Debug("response cache hit", "key", key)
+		return Replay(ctx, response)
+	}
+
+	log.Debug("response cache miss", "key", key)
+	return &cachingStream{Stream: c.inner.NewStreaming(ctx, params), path: path}
+}
+
+// CountTokens forwards to the wrapped provider when it can count tokens
+func (c *Cache) CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int64, error) {
+	counter, ok := c.inner.(TokenCounter)
+	if !ok {
+		return 0, ErrCountUnsupported
+	}
+
+	return counter.CountTokens(ctx, params)
+}
+
+// readCached loads a stored response; unreadable entries count as misses
+func readCached(path string) (RecordedResponse, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return RecordedResponse{}, false
+	}
+
+	var response RecordedResponse
+	if err := json.Unmarshal(data, &response); err != nil || len(response.Events) == 0 {
+		return RecordedResponse{}, false
+	}
+
+	return response, true
+}
+
+// cachingStream collects events as they are consumed and stores the response
+// once the stream ends without an error. Failed, cancelled or abandoned
+// responses aren't cached.
+type cachingStream struct {
+	Stream
+	path     string
+	response RecordedResponse
+	done     bool
+}
+
+func (s *cachingStream) Next() bool {
+	if s.Stream.Next() {
+		raw := s.Stream.Current().RawJSON()
+		if raw != "" {
+			s.response.Events = append(s.response.Events, json.RawMessage(raw))
+		}
+		return true
+	}
+
+	if !s.done && s.Stream.Err() == nil && len(s.response.Events) > 0 {
+		if err := writeCached(s.path, s.response); err != nil {
+			log.Warn("response cache write failed", "error", err)
+		}
+	}
+	s.done = true
+	return false
+}
+
+// writeCached stores a response through a temporary file, so a concurrent
+// reader never sees half of it
+func writeCached(path string, response RecordedResponse) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".response-*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
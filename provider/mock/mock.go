@@ -0,0 +1,88 @@
+// Package mock provides a deterministic provider that replays recorded or
+// scripted responses, so the agent loop can run without the API
+package mock
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"agent/provider"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Provider replays responses in order, one per request
+type Provider struct {
+	mu        sync.Mutex
+	responses []provider.RecordedResponse
+	next      int
+
+	// Requests holds the parameters of every request made, for assertions
+	Requests []anthropic.MessageNewParams
+}
+
+// New creates a mock provider that replays the given responses
+func New(responses ...provider.RecordedResponse) *Provider {
+	return &Provider{responses: responses}
+}
+
+// Load creates a mock provider from a JSON Lines file written by provider.Recorder
+func Load(path string) (*Provider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	responses := []provider.RecordedResponse{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var response provider.RecordedResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			return nil, fmt.Errorf("failed to parse recording: %w", err)
+		}
+		responses = append(responses, response)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return New(responses...), nil
+}
+
+// Remaining returns the number of responses not yet replayed
+func (p *Provider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.responses) - p.next
+}
+
+// NewStreaming replays the next response. Once all responses are used up the
+// returned stream fails with an error.
+func (p *Provider) NewStreaming(ctx context.Context, params anthropic.MessageNewParams) provider.Stream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Requests = append(p.Requests, params)
+
+	if p.next >= len(p.responses) {
+		return provider.Failed(fmt.Errorf("mock provider: no response recorded for request %d", p.next+1))
+	}
+
+	response := p.responses[p.next]
+	p.next++
+
+	return provider.Replay(ctx, response)
+}
@@ -0,0 +1,95 @@
+package mock
+
+import (
+	"encoding/json"
+
+	"agent/provider"
+)
+
+// Text builds a response in which the assistant replies with text and ends its turn
+func Text(text string) provider.RecordedResponse {
+	return build("end_turn",
+		event(map[string]any{
+			"type":          "content_block_start",
+			"index":         0,
+			"content_block": map[string]any{"type": "text", "text": ""},
+		}),
+		event(map[string]any{
+			"type":  "content_block_delta",
+			"index": 0,
+			"delta": map[string]any{"type": "text_delta", "text": text},
+		}),
+		event(map[string]any{"type": "content_block_stop", "index": 0}),
+	)
+}
+
+// Truncated builds a response whose text was cut off at the output token limit
+func Truncated(text string) provider.RecordedResponse {
+	response := Text(text)
+	response.Events[len(response.Events)-2] = event(map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": "max_tokens", "stop_sequence": nil},
+		"usage": map[string]any{"output_tokens": 0},
+	})
+	return response
+}
+
+// ToolUse builds a response in which the assistant calls a tool with the given input
+func ToolUse(id, name string, input any) provider.RecordedResponse {
+	inputJSON, err := json.Marshal(input)
+	if err != nil {
+		panic(err)
+	}
+
+	return build("tool_use",
+		event(map[string]any{
+			"type":          "content_block_start",
+			"index":         0,
+			"content_block": map[string]any{"type": "tool_use", "id": id, "name": name, "input": map[string]any{}},
+		}),
+		event(map[string]any{
+			"type":  "content_block_delta",
+			"index": 0,
+			"delta": map[string]any{"type": "input_json_delta", "partial_json": string(inputJSON)},
+		}),
+		event(map[string]any{"type": "content_block_stop", "index": 0}),
+	)
+}
+
+// build wraps content block events in the message start/delta/stop envelope
+func build(stopReason string, content ...json.RawMessage) provider.RecordedResponse {
+	events := []json.RawMessage{
+		event(map[string]any{
+			"type": "message_start",
+			"message": map[string]any{
+				"id":            "msg_mock",
+				"type":          "message",
+				"role":          "assistant",
+				"model":         "mock",
+				"content":       []any{},
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+				"usage":         map[string]any{"input_tokens": 0, "output_tokens": 0},
+			},
+		}),
+	}
+	events = append(events, content...)
+	events = append(events,
+		event(map[string]any{
+			"type":  "message_delta",
+			"delta": map[string]any{"stop_reason": stopReason, "stop_sequence": nil},
+			"usage": map[string]any{"output_tokens": 0},
+		}),
+		event(map[string]any{"type": "message_stop"}),
+	)
+
+	return provider.RecordedResponse{Events: events}
+}
+
+func event(v map[string]any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,105 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Stream is a stream of message events, matching the Anthropic SDK's streaming API
+type Stream interface {
+	Next() bool
+	Current() anthropic.MessageStreamEventUnion
+	Err() error
+	Close() error
+}
+
+// Provider produces streaming model responses
+type Provider interface {
+	NewStreaming(ctx context.Context, params anthropic.MessageNewParams) Stream
+}
+
+// Endpointer is implemented by providers calling an API, naming the provider
+// and base URL their requests go to
+type Endpointer interface {
+	Endpoint() string
+}
+
+// TokenCounter is implemented by providers that can count the input tokens of a request
+type TokenCounter interface {
+	CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int64, error)
+}
+
+// ErrCountUnsupported is returned by wrappers whose inner provider can't count tokens
+var ErrCountUnsupported = errors.New("provider cannot count tokens")
+
+// Complete runs a request to completion and returns the accumulated message,
+// for callers that don't need to stream
+func Complete(ctx context.Context, p Provider, params anthropic.MessageNewParams) (*anthropic.Message, error) {
+	stream := p.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	message := anthropic.Message{}
+	for stream.Next() {
+		if err := message.Accumulate(stream.Current()); err != nil {
+			return nil, err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
+
+// RecordedResponse is one model response captured as its raw stream events, in order
+type RecordedResponse struct {
+	Events []json.RawMessage `json:"events"`
+}
+
+// Anthropic streams responses from the Anthropic API
+type Anthropic struct {
+	Client *anthropic.Client
+
+	// endpoint names the provider and base URL the client calls
+	endpoint string
+}
+
+// NewAnthropic creates a provider backed by the given client, which calls endpoint
+func NewAnthropic(client *anthropic.Client, endpoint string) *Anthropic {
+	return &Anthropic{Client: client, endpoint: endpoint}
+}
+
+// Endpoint names the provider and base URL the client calls
+func (p *Anthropic) Endpoint() string {
+	return p.endpoint
+}
+
+// NewStreaming starts a streaming Messages API request
+func (p *Anthropic) NewStreaming(ctx context.Context, params anthropic.MessageNewParams) Stream {
+	return p.Client.Messages.NewStreaming(ctx, params)
+}
+
+// CountTokens counts the input tokens of a request with the token counting endpoint
+func (p *Anthropic) CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int64, error) {
+	tools := make([]anthropic.MessageCountTokensToolUnionParam, 0, len(params.Tools))
+	for _, tool := range params.Tools {
+		if tool.OfTool != nil {
+			tools = append(tools, anthropic.MessageCountTokensToolUnionParam{OfTool: tool.OfTool})
+		}
+	}
+
+	count, err := p.Client.Messages.CountTokens(ctx, anthropic.MessageCountTokensParams{
+		Model:    params.Model,
+		Messages: params.Messages,
+		System:   anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: params.System},
+		Tools:    tools,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count.InputTokens, nil
+}
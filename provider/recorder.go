@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// ResponseHandler receives each completed response together with the request that produced it
+type ResponseHandler func(params anthropic.MessageNewParams, response RecordedResponse)
+
+// Recorder wraps a provider and hands every streamed response to a handler
+type Recorder struct {
+	inner    Provider
+	onRecord ResponseHandler
+}
+
+// NewRecorder creates a recording provider
+func NewRecorder(inner Provider, onRecord ResponseHandler) *Recorder {
+	return &Recorder{inner: inner, onRecord: onRecord}
+}
+
+// JSONLinesWriter returns a handler writing each response to w as one JSON line,
+// in the format the mock provider replays
+func JSONLinesWriter(w io.Writer) ResponseHandler {
+	var mu sync.Mutex
+
+	return func(params anthropic.MessageNewParams, response RecordedResponse) {
+		data, err := json.Marshal(response)
+		if err != nil {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		w.Write(append(data, '\n'))
+	}
+}
+
+// NewStreaming starts a request on the wrapped provider and records its events
+func (r *Recorder) NewStreaming(ctx context.Context, params anthropic.MessageNewParams) Stream {
+	return &recordingStream{
+		Stream:   r.inner.NewStreaming(ctx, params),
+		recorder: r,
+		params:   params,
+	}
+}
+
+// CountTokens forwards to the wrapped provider when it can count tokens
+func (r *Recorder) CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int64, error) {
+	counter, ok := r.inner.(TokenCounter)
+	if !ok {
+		return 0, ErrCountUnsupported
+	}
+
+	return counter.CountTokens(ctx, params)
+}
+
+// recordingStream collects events as they are consumed and records them once the stream ends
+type recordingStream struct {
+	Stream
+	recorder *Recorder
+	params   anthropic.MessageNewParams
+	response RecordedResponse
+	written  bool
+}
+
+func (s *recordingStream) Next() bool {
+	if s.Stream.Next() {
+		raw := s.Stream.Current().RawJSON()
+		if raw != "" {
+			s.response.Events = append(s.response.Events, json.RawMessage(raw))
+		}
+		return true
+	}
+
+	s.flush()
+	return false
+}
+
+func (s *recordingStream) Close() error {
+	s.flush()
+	return s.Stream.Close()
+}
+
+func (s *recordingStream) flush() {
+	if s.written || len(s.response.Events) == 0 {
+		return
+	}
+
+	s.written = true
+	s.recorder.onRecord(s.params, s.response)
+}
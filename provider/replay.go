@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Replay returns a stream that plays back a recorded response's events
+func Replay(ctx context.Context, response RecordedResponse) Stream {
+	return &replayStream{ctx: ctx, events: response.Events, index: -1}
+}
+
+// Failed returns a stream that fails with err without producing any events
+func Failed(err error) Stream {
+	return &replayStream{err: err}
+}
+
+// replayStream replays recorded raw events
+type replayStream struct {
+	ctx     context.Context
+	events  []json.RawMessage
+	index   int
+	current anthropic.MessageStreamEventUnion
+	err     error
+}
+
+func (s *replayStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.ctx != nil && s.ctx.Err() != nil {
+		s.err = s.ctx.Err()
+		return false
+	}
+
+	s.index++
+	if s.index >= len(s.events) {
+		return false
+	}
+
+	s.current = anthropic.MessageStreamEventUnion{}
+	if err := s.current.UnmarshalJSON(s.events[s.index]); err != nil {
+		s.err = fmt.Errorf("invalid recorded event: %w", err)
+		return false
+	}
+
+	return true
+}
+
+func (s *replayStream) Current() anthropic.MessageStreamEventUnion {
+	return s.current
+}
+
+func (s *replayStream) Err() error {
+	return s.err
+}
+
+func (s *replayStream) Close() error {
+	return nil
+}
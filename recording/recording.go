@@ -0,0 +1,175 @@
+// Package recording captures whole sessions — user inputs, provider responses
+// and tool results — so they can be replayed deterministically later
+package recording
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"agent/agent"
+	"agent/provider"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// formatVersion is bumped whenever the recording format changes incompatibly
+const formatVersion = 1
+
+// File is the on-disk session recording
+type File struct {
+	Version     int          `json:"version"`
+	Exchanges   []Exchange   `json:"exchanges"`
+	ToolResults []ToolResult `json:"tool_results"`
+}
+
+// Exchange is one model response. Input holds the user text that started the
+// turn when the response is the first of a turn.
+type Exchange struct {
+	Input    string                    `json:"input,omitempty"`
+	Response provider.RecordedResponse `json:"response"`
+}
+
+// ToolResult is the recorded outcome of one tool call
+type ToolResult struct {
+	ID      string          `json:"id"`
+	Name    string          `json:"name"`
+	Input   json.RawMessage `json:"input"`
+	Content string          `json:"content"`
+	IsError bool            `json:"is_error"`
+}
+
+// Load reads a session recording
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse recording: %w", err)
+	}
+
+	if file.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported recording version %d (expected %d)", file.Version, formatVersion)
+	}
+
+	return file, nil
+}
+
+// Responses returns the recorded provider responses in order
+func (f *File) Responses() []provider.RecordedResponse {
+	responses := make([]provider.RecordedResponse, 0, len(f.Exchanges))
+	for _, exchange := range f.Exchanges {
+		responses = append(responses, exchange.Response)
+	}
+
+	return responses
+}
+
+// Inputs returns the user inputs that started each turn, in order
+func (f *File) Inputs() []string {
+	inputs := []string{}
+	for _, exchange := range f.Exchanges {
+		if exchange.Input != "" {
+			inputs = append(inputs, exchange.Input)
+		}
+	}
+
+	return inputs
+}
+
+// Recorder captures a session and rewrites the recording file after every
+// response and tool result, so a crash loses at most the step in flight
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	file File
+}
+
+// NewRecorder creates a recorder writing to path
+func NewRecorder(path string) *Recorder {
+	return &Recorder{
+		path: path,
+		file: File{Version: formatVersion, Exchanges: []Exchange{}, ToolResults: []ToolResult{}},
+	}
+}
+
+// Wrap returns a provider that records every response
+func (r *Recorder) Wrap(inner provider.Provider) provider.Provider {
+	return provider.NewRecorder(inner, r.recordResponse)
+}
+
+// InterceptTool is an agent.ToolInterceptor recording every tool result
+func (r *Recorder) InterceptTool(call agent.ToolCall, next func() (string, error)) (string, error) {
+	content, err := next()
+
+	result := ToolResult{ID: call.ID, Name: call.Name, Input: call.Input, Content: content}
+	if err != nil {
+		result.Content = err.Error()
+		result.IsError = true
+	}
+
+	r.mu.Lock()
+	r.file.ToolResults = append(r.file.ToolResults, result)
+	r.mu.Unlock()
+
+	r.save()
+	return content, err
+}
+
+func (r *Recorder) recordResponse(params anthropic.MessageNewParams, response provider.RecordedResponse) {
+	input := ""
+	if !agent.IsUtilityRequest(params) {
+		input = turnInput(params)
+	}
+
+	r.mu.Lock()
+	r.file.Exchanges = append(r.file.Exchanges, Exchange{
+		Input:    input,
+		Response: response,
+	})
+	r.mu.Unlock()
+
+	r.save()
+}
+
+// turnInput returns the user's text when the request's last message is a new
+// user prompt rather than a batch of tool results
+func turnInput(params anthropic.MessageNewParams) string {
+	if len(params.Messages) == 0 {
+		return ""
+	}
+
+	last := params.Messages[len(params.Messages)-1]
+	if last.Role != anthropic.MessageParamRoleUser {
+		return ""
+	}
+
+	text := ""
+	for _, block := range last.Content {
+		if block.OfToolResult != nil {
+			return ""
+		}
+		if block.OfText != nil {
+			text += block.OfText.Text
+		}
+	}
+
+	return text
+}
+
+// save writes the recording, ignoring errors so recording never breaks a session
+func (r *Recorder) save() {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.file, "", "  ")
+	r.mu.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	os.WriteFile(r.path, data, 0644)
+}
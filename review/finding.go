@@ -0,0 +1,48 @@
+// Package review checks code changes against rules, both built-in ones and
+// natural-language rules judged by the model
+package review
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Severity ranks a finding
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNotice  Severity = "notice"
+)
+
+// Finding is one problem found in a change
+type Finding struct {
+	Path     string   `json:"path"`
+	Line     int      `json:"line,omitempty"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity,omitempty"`
+}
+
+// String formats the finding as "path:line: message [rule]", omitting an unknown location
+func (f Finding) String() string {
+	switch {
+	case f.Path == "":
+		return fmt.Sprintf("%s [%s]", f.Message, f.Rule)
+	case f.Line > 0:
+		return fmt.Sprintf("%s:%d: %s [%s]", f.Path, f.Line, f.Message, f.Rule)
+	default:
+		return fmt.Sprintf("%s: %s [%s]", f.Path, f.Message, f.Rule)
+	}
+}
+
+// Sort orders findings by path and line
+func Sort(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+}
@@ -0,0 +1,50 @@
+package review
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteGitHub writes findings as GitHub Actions workflow commands, which the
+// runner turns into annotations on the changed files
+func WriteGitHub(w io.Writer, findings []Finding) error {
+	for _, finding := range findings {
+		properties := []string{}
+		if finding.Path != "" {
+			properties = append(properties, "file="+escapeProperty(finding.Path))
+		}
+		if finding.Line > 0 {
+			properties = append(properties, fmt.Sprintf("line=%d", finding.Line))
+		}
+		if finding.Rule != "" {
+			properties = append(properties, "title="+escapeProperty(finding.Rule))
+		}
+
+		command := string(finding.Severity)
+		if command == "" {
+			command = string(SeverityError)
+		}
+
+		line := "::" + command
+		if len(properties) > 0 {
+			line += " " + strings.Join(properties, ",")
+		}
+
+		if _, err := fmt.Fprintf(w, "%s::%s\n", line, escapeData(finding.Message)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// escapeData escapes a workflow command's message
+func escapeData(text string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(text)
+}
+
+// escapeProperty escapes a workflow command property value
+func escapeProperty(text string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(text)
+}
@@ -0,0 +1,91 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"agent/agent"
+	"agent/diff"
+	"agent/provider"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// maxReviewDiff caps the diff sent for review
+const maxReviewDiff = 300 * 1024
+
+const reviewSystemPrompt = `You review code changes against a list of rules.
+Report only clear violations of the listed rules in lines the change adds or modifies; do not report style preferences or anything the rules don't cover.
+Reply with only a JSON array, [] when there are no violations. Each element is an object:
+{"path": "<file path as in the diff>", "line": <line number in the new file>, "rule": "<the rule, briefly>", "message": "<what is wrong and how to fix it>", "severity": "error" or "warning"}`
+
+// Review asks the model to check a diff against natural-language rules
+func Review(ctx context.Context, p provider.Provider, files []diff.FileDiff, rules []string) ([]Finding, agent.Usage, error) {
+	var changes strings.Builder
+	for _, file := range files {
+		changes.WriteString(file.Text)
+	}
+
+	text := changes.String()
+	if len(text) > maxReviewDiff {
+		text = text[:maxReviewDiff] + "\n[diff truncated]\n"
+	}
+
+	var prompt strings.Builder
+	prompt.WriteString("Rules:\n")
+	for _, rule := range rules {
+		prompt.WriteString("- " + rule + "\n")
+	}
+	prompt.WriteString("\nChange:\n```diff\n" + text + "```\n")
+
+	message, err := provider.Complete(ctx, p, anthropic.MessageNewParams{
+		Model:     agent.DefaultModel,
+		MaxTokens: 4096,
+		System:    []anthropic.TextBlockParam{{Text: reviewSystemPrompt}},
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock(prompt.String()))},
+	})
+	if err != nil {
+		return nil, agent.Usage{}, fmt.Errorf("review request failed: %w", err)
+	}
+
+	usage := agent.Usage{InputTokens: message.Usage.InputTokens, OutputTokens: message.Usage.OutputTokens}
+
+	reply := ""
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			reply += block.Text
+		}
+	}
+
+	findings, err := parseFindings(reply)
+	if err != nil {
+		return nil, usage, err
+	}
+
+	return findings, usage, nil
+}
+
+// parseFindings extracts the JSON array of findings from the model's reply,
+// tolerating surrounding prose or a code fence
+func parseFindings(reply string) ([]Finding, error) {
+	start := strings.Index(reply, "[")
+	end := strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("the review reply contained no findings list: %q", reply)
+	}
+
+	var findings []Finding
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &findings); err != nil {
+		return nil, fmt.Errorf("failed to parse review findings: %w", err)
+	}
+
+	for i := range findings {
+		if findings[i].Severity != SeverityWarning && findings[i].Severity != SeverityNotice {
+			findings[i].Severity = SeverityError
+		}
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,68 @@
+package review
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// rdjsonResult is reviewdog's diagnostic format, read with `reviewdog -f=rdjson`
+type rdjsonResult struct {
+	Source      rdjsonSource       `json:"source"`
+	Diagnostics []rdjsonDiagnostic `json:"diagnostics"`
+}
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type rdjsonDiagnostic struct {
+	Message  string         `json:"message"`
+	Location rdjsonLocation `json:"location"`
+	Severity string         `json:"severity"`
+	Code     rdjsonCode     `json:"code"`
+}
+
+type rdjsonLocation struct {
+	Path  string       `json:"path"`
+	Range *rdjsonRange `json:"range,omitempty"`
+}
+
+type rdjsonRange struct {
+	Start rdjsonPosition `json:"start"`
+}
+
+type rdjsonPosition struct {
+	Line int `json:"line"`
+}
+
+type rdjsonCode struct {
+	Value string `json:"value"`
+}
+
+// WriteRDJSON writes findings in reviewdog's rdjson format
+func WriteRDJSON(w io.Writer, findings []Finding) error {
+	result := rdjsonResult{
+		Source:      rdjsonSource{Name: "cli-agent", URL: "https://github.com/shtayeb/cli-agent"},
+		Diagnostics: []rdjsonDiagnostic{},
+	}
+
+	for _, finding := range findings {
+		diagnostic := rdjsonDiagnostic{
+			Message:  finding.Message,
+			Location: rdjsonLocation{Path: finding.Path},
+			Severity: strings.ToUpper(string(finding.Severity)),
+			Code:     rdjsonCode{Value: finding.Rule},
+		}
+		if finding.Line > 0 {
+			diagnostic.Location.Range = &rdjsonRange{Start: rdjsonPosition{Line: finding.Line}}
+		}
+
+		result.Diagnostics = append(result.Diagnostics, diagnostic)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -0,0 +1,91 @@
+package review
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"agent/diff"
+)
+
+// todoPattern matches markers of unfinished work
+var todoPattern = regexp.MustCompile(`\b(TODO|FIXME|XXX)\b`)
+
+// sourceExtensions are the file types the tests rule applies to
+var sourceExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true,
+	".rb": true, ".rs": true, ".java": true, ".kt": true, ".swift": true, ".c": true,
+	".cc": true, ".cpp": true, ".h": true, ".cs": true, ".php": true, ".scala": true,
+}
+
+// NoTodos reports TODO, FIXME and XXX markers on added lines
+func NoTodos(files []diff.FileDiff) []Finding {
+	var findings []Finding
+
+	for _, file := range files {
+		for _, line := range file.Added {
+			if marker := todoPattern.FindString(line.Text); marker != "" {
+				findings = append(findings, Finding{
+					Path:     file.Path(),
+					Line:     line.Number,
+					Rule:     "no-todos",
+					Message:  marker + " added; finish the work or track it in an issue",
+					Severity: SeverityError,
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+// TestsUpdated reports source changes that come without any test changes
+func TestsUpdated(files []diff.FileDiff) []Finding {
+	var sources []string
+
+	for _, file := range files {
+		if file.NewPath == "" {
+			continue
+		}
+		if IsTestFile(file.NewPath) {
+			return nil
+		}
+		if sourceExtensions[path.Ext(file.NewPath)] {
+			sources = append(sources, file.NewPath)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil
+	}
+
+	return []Finding{{
+		Path:     sources[0],
+		Rule:     "tests-updated",
+		Message:  "source files changed (" + strings.Join(sources, ", ") + ") but no tests were added or updated",
+		Severity: SeverityError,
+	}}
+}
+
+// IsTestFile recognizes test files by the common naming conventions
+func IsTestFile(file string) bool {
+	base := path.Base(file)
+	name := strings.TrimSuffix(base, path.Ext(base))
+
+	switch {
+	case strings.HasSuffix(name, "_test"), strings.HasPrefix(name, "test_"):
+		return true
+	case strings.HasSuffix(name, ".test"), strings.HasSuffix(name, ".spec"):
+		return true
+	case strings.HasSuffix(name, "Test"), strings.HasSuffix(name, "Tests"):
+		return true
+	}
+
+	for _, dir := range strings.Split(path.Dir(file), "/") {
+		if dir == "test" || dir == "tests" || dir == "__tests__" || dir == "spec" {
+			return true
+		}
+	}
+
+	return false
+}
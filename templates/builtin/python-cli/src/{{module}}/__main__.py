@@ -0,0 +1,11 @@
+import argparse
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser(prog="{{name}}", description="{{description}}")
+    parser.parse_args()
+    print("Hello from {{name}}")
+
+
+if __name__ == "__main__":
+    main()
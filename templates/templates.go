@@ -0,0 +1,248 @@
+// Package templates scaffolds new projects from built-in or user-defined
+// templates for `cli-agent new`
+package templates
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"agent/config"
+)
+
+//go:embed all:builtin
+var builtin embed.FS
+
+// manifestFile describes a template; it is not copied into the project
+const manifestFile = "template.json"
+
+// templateSuffix is dropped from file names, so templates can hold files such
+// as go.mod or main.go that would otherwise be picked up by the Go tooling
+const templateSuffix = ".tmpl"
+
+// variablePattern matches a {{name}} placeholder in file contents and paths
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Template is a project skeleton
+type Template struct {
+	Name        string     `json:"-"`
+	Description string     `json:"description"`
+	Variables   []Variable `json:"variables,omitempty"`
+
+	// Prompt is suggested to the user when the chat opens in the new project
+	Prompt string `json:"prompt,omitempty"`
+
+	// User is set for templates from the user config directory
+	User bool `json:"-"`
+
+	files fs.FS
+}
+
+// Variable is a value substituted for {{name}} in a template. The variable
+// "name" is always available and defaults to the project directory's name.
+type Variable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+}
+
+// UserDir returns where user-defined templates live, one directory each
+func UserDir() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "templates"), nil
+}
+
+// List returns the available templates sorted by name. User templates
+// replace built-in templates of the same name.
+func List() ([]Template, error) {
+	found := map[string]Template{}
+
+	entries, err := fs.ReadDir(builtin, "builtin")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		sub, err := fs.Sub(builtin, path.Join("builtin", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := load(entry.Name(), sub)
+		if err != nil {
+			return nil, err
+		}
+		found[tmpl.Name] = tmpl
+	}
+
+	if dir, err := UserDir(); err == nil {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			tmpl, err := load(entry.Name(), os.DirFS(filepath.Join(dir, entry.Name())))
+			if err != nil {
+				return nil, err
+			}
+			tmpl.User = true
+			found[tmpl.Name] = tmpl
+		}
+	}
+
+	templates := make([]Template, 0, len(found))
+	for _, tmpl := range found {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
+// Find returns the template with the given name
+func Find(name string) (Template, error) {
+	templates, err := List()
+	if err != nil {
+		return Template{}, err
+	}
+
+	names := make([]string, len(templates))
+	for i, tmpl := range templates {
+		if tmpl.Name == name {
+			return tmpl, nil
+		}
+		names[i] = tmpl.Name
+	}
+
+	return Template{}, fmt.Errorf("no template named %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// load reads a template's manifest. A template without one just has no
+// description or variables besides "name".
+func load(name string, files fs.FS) (Template, error) {
+	tmpl := Template{}
+
+	data, err := fs.ReadFile(files, manifestFile)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &tmpl); err != nil {
+			return tmpl, fmt.Errorf("template %s: invalid %s: %w", name, manifestFile, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return tmpl, fmt.Errorf("template %s: %w", name, err)
+	}
+
+	tmpl.Name = name
+	tmpl.files = files
+	return tmpl, nil
+}
+
+// Values fills in defaults for the variables that weren't given, and reports
+// the variables that still have no value
+func (t Template) Values(given map[string]string) (map[string]string, []Variable) {
+	values := map[string]string{}
+	for key, value := range given {
+		values[key] = value
+	}
+
+	var missing []Variable
+	for _, variable := range t.Variables {
+		if _, ok := values[variable.Name]; ok {
+			continue
+		}
+		if variable.Default == "" {
+			missing = append(missing, variable)
+			continue
+		}
+		values[variable.Name] = Substitute(variable.Default, values)
+	}
+
+	return values, missing
+}
+
+// Create writes the template's files into dir, which must not exist or be
+// empty, and returns the paths it wrote relative to dir. Every path is
+// checked before anything is written, so variables such as a name of
+// "../x" can't place files outside dir.
+func (t Template) Create(dir string, values map[string]string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return nil, fmt.Errorf("%s already exists and is not empty", dir)
+	}
+
+	type file struct {
+		target  string
+		content string
+	}
+	var files []file
+	err = fs.WalkDir(t.files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || name == manifestFile {
+			return nil
+		}
+
+		content, err := fs.ReadFile(t.files, name)
+		if err != nil {
+			return err
+		}
+
+		target := Substitute(strings.TrimSuffix(name, templateSuffix), values)
+		if !filepath.IsLocal(filepath.FromSlash(target)) {
+			return fmt.Errorf("template file %s would be written to %s, outside the project; check the variables in its path", name, target)
+		}
+
+		files = append(files, file{target: target, content: Substitute(string(content), values)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, file := range files {
+		absTarget := filepath.Join(dir, filepath.FromSlash(file.target))
+		if err := os.MkdirAll(filepath.Dir(absTarget), 0755); err != nil {
+			return written, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.WriteFile(absTarget, []byte(file.content), 0644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", file.target, err)
+		}
+
+		written = append(written, file.target)
+	}
+
+	return written, nil
+}
+
+// Substitute replaces {{name}} placeholders with their values. Placeholders
+// for unknown variables are left alone, so templates can contain other
+// {{...}} syntax such as Handlebars.
+func Substitute(text string, values map[string]string) string {
+	return variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
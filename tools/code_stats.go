@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxStatsFiles stops code_stats in huge trees
+	maxStatsFiles = 50000
+	// maxStatsFileBytes skips files too large to be hand-written source
+	maxStatsFileBytes = 1 << 20
+)
+
+// CodeStats tool definition and implementation
+var CodeStatsDefinition = ToolDefinition{
+	Name:        "code_stats",
+	Description: "Count files and lines of code, comments and blank lines per language, and lines of code per directory, like tokei or cloc. Use it to get a sense of a codebase's size and shape before diving in. Ignored directories such as node_modules and vendor are skipped, as are files whose language isn't recognized.",
+	InputSchema: CodeStatsInputSchema,
+	Function:    CodeStats,
+	ReadOnly:    true,
+}
+
+type CodeStatsInput struct {
+	Path  string `json:"path,omitempty" jsonschema_description:"The directory to count. Defaults to the working directory."`
+	Depth *int   `json:"depth,omitempty" jsonschema_description:"How many directory levels the per-directory breakdown shows. Defaults to 1; 0 leaves it out."`
+}
+
+var CodeStatsInputSchema = GenerateSchema[CodeStatsInput]()
+
+// lineCounts are the line counts of one file or a group of files
+type lineCounts struct {
+	files    int
+	code     int
+	comments int
+	blank    int
+}
+
+func (c *lineCounts) add(other lineCounts) {
+	c.files += other.files
+	c.code += other.code
+	c.comments += other.comments
+	c.blank += other.blank
+}
+
+func CodeStats(ws *Workspace, input json.RawMessage) (string, error) {
+	codeStatsInput := CodeStatsInput{}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &codeStatsInput); err != nil {
+			return "", fmt.Errorf("failed to parse input: %w", err)
+		}
+	}
+
+	dir := codeStatsInput.Path
+	if dir == "" {
+		dir = "."
+	}
+	depth := 1
+	if codeStatsInput.Depth != nil {
+		depth = *codeStatsInput.Depth
+	}
+
+	root, err := ws.Resolve(dir)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", dir)
+	}
+
+	languages := map[string]*lineCounts{}
+	dirs := map[string]*lineCounts{}
+	seen := 0
+	truncated := false
+
+	err = ws.walkParallel(root, func(relPath string, entry fs.DirEntry) error {
+		relPath = filepath.ToSlash(relPath)
+		if ws.Ignored(relPath, entry.IsDir()) {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		language := detectLanguage(relPath)
+		if language == "" {
+			return nil
+		}
+
+		seen++
+		if seen > maxStatsFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		counts, ok := countLines(filepath.Join(root, relPath), commentsByLanguage[language])
+		if !ok {
+			return nil
+		}
+
+		if languages[language] == nil {
+			languages[language] = &lineCounts{}
+		}
+		languages[language].add(counts)
+
+		// Files directly in the directory being counted are grouped as "."
+		parts := strings.Split(relPath, "/")
+		group := "."
+		if len(parts) > 1 && depth > 0 {
+			group = strings.Join(parts[:min(depth, len(parts)-1)], "/")
+		}
+		if depth > 0 {
+			if dirs[group] == nil {
+				dirs[group] = &lineCounts{}
+			}
+			dirs[group].add(counts)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(languages) == 0 {
+		return fmt.Sprintf("No source files found in %s", dir), nil
+	}
+
+	var b strings.Builder
+	total := lineCounts{}
+	fmt.Fprintf(&b, "language | files | code | comments | blank\n")
+	for _, language := range sortedByCode(languages) {
+		counts := languages[language]
+		total.add(*counts)
+		fmt.Fprintf(&b, "%s | %d | %d | %d | %d\n", language, counts.files, counts.code, counts.comments, counts.blank)
+	}
+	fmt.Fprintf(&b, "total | %d | %d | %d | %d\n", total.files, total.code, total.comments, total.blank)
+
+	if len(dirs) > 0 {
+		fmt.Fprintf(&b, "\ndirectory | files | code\n")
+		for _, group := range sortedByCode(dirs) {
+			fmt.Fprintf(&b, "%s | %d | %d\n", group, dirs[group].files, dirs[group].code)
+		}
+	}
+
+	if truncated {
+		fmt.Fprintf(&b, "\n(stopped counting after %d files)\n", maxStatsFiles)
+	}
+
+	return b.String(), nil
+}
+
+// countLines classifies each line of a file as code, comment or blank. A
+// line with both code and a comment counts as code. Binary and very large
+// files are skipped.
+func countLines(path string, syntax commentSyntax) (lineCounts, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxStatsFileBytes {
+		return lineCounts{}, false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(content, 0) >= 0 {
+		return lineCounts{}, false
+	}
+
+	counts := lineCounts{files: 1}
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStatsFileBytes)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case inBlock:
+			counts.comments++
+			if end := strings.Index(line, syntax.blockEnd); end >= 0 {
+				inBlock = false
+				if strings.TrimSpace(line[end+len(syntax.blockEnd):]) != "" {
+					counts.comments--
+					counts.code++
+				}
+			}
+		case line == "":
+			counts.blank++
+		case syntax.blockStart != "" && strings.HasPrefix(line, syntax.blockStart):
+			counts.comments++
+			inBlock = !strings.Contains(line[len(syntax.blockStart):], syntax.blockEnd)
+		case hasAnyPrefix(line, syntax.line):
+			counts.comments++
+		default:
+			counts.code++
+		}
+	}
+
+	return counts, true
+}
+
+func hasAnyPrefix(line string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedByCode returns the keys of counts with the most code first
+func sortedByCode(counts map[string]*lineCounts) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]].code != counts[keys[j]].code {
+			return counts[keys[i]].code > counts[keys[j]].code
+		}
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
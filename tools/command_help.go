@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CommandHelp tool definition and implementation
+var CommandHelpDefinition = ToolDefinition{
+	Name:         "command_help",
+	Description:  "Look up the documentation of a command-line program installed on this machine: its man page, or the output of `<program> --help` when there is none. Use it to check flags and syntax before writing shell commands, scripts or Makefiles instead of relying on memory, since options differ between versions and platforms. Subcommands are looked up in the man pages, e.g. \"git rebase\" as git-rebase; without one, the program's own --help is shown. Use search to show only the lines mentioning a flag or word.",
+	InputSchema:  CommandHelpInputSchema,
+	Function:     CommandHelp,
+	ReadOnly:     true,
+	RunsPrograms: true,
+}
+
+type CommandHelpInput struct {
+	Command string `json:"command" jsonschema_description:"The program, optionally followed by subcommands, e.g. \"tar\" or \"docker compose up\"."`
+	Search  string `json:"search,omitempty" jsonschema_description:"Optional case-insensitive text, such as a flag like --exclude, to show only the matching lines with a little context."`
+}
+
+var CommandHelpInputSchema = GenerateSchema[CommandHelpInput]()
+
+const (
+	// maxHelpChars caps the help text returned, which for some man pages runs to megabytes
+	maxHelpChars = 16000
+	// helpTimeout bounds each lookup, in case a program ignores --help and waits for input
+	helpTimeout = 10 * time.Second
+	// helpSearchContext is the number of lines shown around each search match
+	helpSearchContext = 2
+)
+
+// commandWordPattern matches the words a command may be made of, which keeps
+// the lookup from running anything but the program's own help
+var commandWordPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+
+// overstrikePattern matches the backspace sequences man uses for bold and underline
+var overstrikePattern = regexp.MustCompile(".\b")
+
+func CommandHelp(ws *Workspace, input json.RawMessage) (string, error) {
+	commandHelpInput := CommandHelpInput{}
+	if err := json.Unmarshal(input, &commandHelpInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	words := strings.Fields(commandHelpInput.Command)
+	if len(words) == 0 {
+		return "", fmt.Errorf("command is required")
+	}
+	for _, word := range words {
+		if !commandWordPattern.MatchString(word) {
+			return "", fmt.Errorf("invalid command word %q: give a program name and subcommands only, without paths or flags", word)
+		}
+	}
+
+	binary, err := exec.LookPath(words[0])
+	if err != nil {
+		return "", fmt.Errorf("%s is not installed or not on the PATH", words[0])
+	}
+	// Help is only looked up for installed programs, not ones the workspace provides
+	if ws.providesProgram(binary) {
+		return "", fmt.Errorf("%s is inside the workspace; read its source or docs instead", words[0])
+	}
+
+	source, text := manPage(words)
+	if text == "" {
+		source, text = helpOutput(binary, words[0])
+	}
+	if text == "" {
+		return "", fmt.Errorf("no man page or --help output found for %s", strings.Join(words, " "))
+	}
+
+	if search := strings.TrimSpace(commandHelpInput.Search); search != "" {
+		matches := searchHelp(text, search)
+		if matches == "" {
+			return fmt.Sprintf("%s: nothing mentions %q", source, search), nil
+		}
+		text = matches
+	}
+
+	if len(text) > maxHelpChars {
+		text = strings.ToValidUTF8(text[:maxHelpChars], "") + "\n[truncated, use search to find a specific flag]"
+	}
+
+	return fmt.Sprintf("%s:\n\n%s", source, text), nil
+}
+
+// providesProgram reports whether a program, or the file its links lead to,
+// lies in any workspace root
+func (ws *Workspace) providesProgram(binary string) bool {
+	resolved, err := filepath.EvalSymlinks(binary)
+	if err != nil {
+		return true
+	}
+
+	roots := []string{ws.Root()}
+	for _, root := range ws.Roots() {
+		roots = append(roots, root.Path)
+	}
+	for _, root := range roots {
+		if isWithin(root, binary) || isWithin(root, resolved) {
+			return true
+		}
+		if realRoot, err := filepath.EvalSymlinks(root); err == nil && isWithin(realRoot, resolved) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// manPage renders the man page for a command, trying "git-rebase" for "git rebase"
+func manPage(words []string) (string, string) {
+	man, err := exec.LookPath("man")
+	if err != nil {
+		return "", ""
+	}
+
+	names := []string{words[0]}
+	if len(words) > 1 {
+		names = []string{strings.Join(words, "-"), words[0]}
+	}
+
+	for _, name := range names {
+		cmd := exec.Command(man, name)
+		cmd.Env = append(os.Environ(), "MANPAGER=cat", "PAGER=cat", "MANWIDTH=100", "MAN_KEEP_FORMATTING=0")
+		output, err := runHelp(cmd)
+		if err != nil || output == "" {
+			continue
+		}
+		return "man " + name, output
+	}
+
+	return "", ""
+}
+
+// helpOutput runs the bare "<command> --help", without any other arguments.
+// Subcommands and fallbacks such as -h aren't tried: programs that don't know
+// the flag may act on the rest, e.g. "shutdown now -h" halts the machine.
+func helpOutput(binary, name string) (string, string) {
+	// Programs print help to either stream and often exit non-zero, so any output counts
+	output, _ := runHelp(exec.Command(binary, "--help"))
+	return name + " --help", output
+}
+
+// runHelp runs a help command with no input and a timeout, returning its
+// cleaned-up combined output
+func runHelp(cmd *exec.Cmd) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), helpTimeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	command := exec.CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
+	command.Env = cmd.Env
+	command.Stdout = &output
+	command.Stderr = &output
+	command.Dir = os.TempDir()
+	// Don't wait past the timeout for children that keep the output open
+	command.WaitDelay = time.Second
+
+	err := command.Run()
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	text := overstrikePattern.ReplaceAllString(output.String(), "")
+	return strings.TrimSpace(strings.ToValidUTF8(text, "")), err
+}
+
+// searchHelp returns the lines containing search with some context, with
+// gaps between groups marked
+func searchHelp(text, search string) string {
+	lines := strings.Split(text, "\n")
+	needle := strings.ToLower(search)
+
+	keep := make([]bool, len(lines))
+	found := false
+	for i, line := range lines {
+		if !strings.Contains(strings.ToLower(line), needle) {
+			continue
+		}
+		found = true
+		for j := max(i-helpSearchContext, 0); j <= min(i+helpSearchContext, len(lines)-1); j++ {
+			keep[j] = true
+		}
+	}
+	if !found {
+		return ""
+	}
+
+	var b strings.Builder
+	for i, line := range lines {
+		if !keep[i] {
+			continue
+		}
+		if i > 0 && !keep[i-1] && b.Len() > 0 {
+			b.WriteString("...\n")
+		}
+		b.WriteString(line + "\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
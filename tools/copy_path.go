@@ -0,0 +1,268 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxCopyFiles caps how many files one copy_path call may copy
+const maxCopyFiles = 1000
+
+// CopyPath tool definition and implementation
+var CopyPathDefinition = ToolDefinition{
+	Name:        "copy_path",
+	Description: "Copy a file, or a directory with everything in it, e.g. to duplicate a module as a starting point. destination is the path of the copy itself, not a directory to copy into. Missing parent directories are created. Existing files are never replaced unless overwrite=true; with overwrite a directory is merged into an existing one.",
+	InputSchema: CopyPathInputSchema,
+	Function:    CopyPath,
+	Paths:       copyPathPaths,
+}
+
+type CopyPathInput struct {
+	Source      string `json:"source" jsonschema_description:"The file or directory to copy."`
+	Destination string `json:"destination" jsonschema_description:"The path of the copy."`
+	Overwrite   bool   `json:"overwrite,omitempty" jsonschema_description:"Whether to replace files that already exist at the destination. Defaults to false."`
+}
+
+var CopyPathInputSchema = GenerateSchema[CopyPathInput]()
+
+// copyPathPaths lists the path a copy_path call writes
+func copyPathPaths(input json.RawMessage) []string {
+	copyInput := CopyPathInput{}
+	if err := json.Unmarshal(input, &copyInput); err != nil {
+		return nil
+	}
+
+	return []string{copyInput.Destination}
+}
+
+// copyEntry is one file, directory or symlink to copy
+type copyEntry struct {
+	source string
+	target string
+	name   string
+	mode   fs.FileMode
+	// link is what a copied symlink points to
+	link string
+}
+
+func CopyPath(ws *Workspace, input json.RawMessage) (string, error) {
+	copyInput := CopyPathInput{}
+	err := json.Unmarshal(input, &copyInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if copyInput.Source == "" || copyInput.Destination == "" {
+		return "", fmt.Errorf("source and destination are required")
+	}
+
+	// Copies keep permissions and links, which a staged change can't carry
+	if ws.Staging() {
+		return "", ErrDryRun
+	}
+
+	source, err := ws.Resolve(copyInput.Source)
+	if err != nil {
+		return "", err
+	}
+	destination, err := ws.Resolve(copyInput.Destination)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", fmt.Errorf("failed to access %s: %w", copyInput.Source, err)
+	}
+	if isWithin(source, destination) {
+		return "", fmt.Errorf("can't copy %s into itself", copyInput.Source)
+	}
+
+	entries, err := copyEntries(source, destination, copyInput.Destination, info)
+	if err != nil {
+		return "", err
+	}
+
+	// Check everything before copying anything, so a refused copy leaves no partial result
+	conflicts := []string{}
+	for i, entry := range entries {
+		if err := ws.checkWriteTarget(entry.target); err != nil {
+			return "", err
+		}
+		if entry.mode&fs.ModeSymlink != 0 {
+			if entries[i].link, err = ws.copyLink(entry); err != nil {
+				return "", err
+			}
+		}
+
+		existing, err := os.Lstat(entry.target)
+		if err != nil {
+			continue
+		}
+		if entry.mode.IsDir() && existing.IsDir() {
+			continue
+		}
+		if entry.mode.IsDir() != existing.IsDir() {
+			return "", fmt.Errorf("%s exists and is a %s", entry.name, describeMode(existing.Mode()))
+		}
+		conflicts = append(conflicts, entry.name)
+	}
+	if len(conflicts) > 0 && !copyInput.Overwrite {
+		if len(conflicts) > 10 {
+			conflicts = append(conflicts[:10], fmt.Sprintf("and %d more", len(conflicts)-10))
+		}
+		return "", fmt.Errorf("destination already exists (use overwrite=true to replace): %s", strings.Join(conflicts, ", "))
+	}
+
+	files := 0
+	for _, entry := range entries {
+		if err := copyOne(ws, entry); err != nil {
+			return "", fmt.Errorf("copied %d files before %s failed: %w", files, entry.name, err)
+		}
+		if !entry.mode.IsDir() {
+			files++
+		}
+	}
+
+	if info.IsDir() {
+		return fmt.Sprintf("Successfully copied directory %s to %s (%d files)", copyInput.Source, copyInput.Destination, files), nil
+	}
+	return fmt.Sprintf("Successfully copied %s to %s", copyInput.Source, copyInput.Destination), nil
+}
+
+// copyEntries lists what copying source to destination creates, parents
+// first, named for messages by their path below name
+func copyEntries(source, destination, name string, info fs.FileInfo) ([]copyEntry, error) {
+	if !info.IsDir() {
+		return []copyEntry{{source: source, target: destination, name: name, mode: info.Mode()}}, nil
+	}
+
+	entries := []copyEntry{}
+	files := 0
+	err := filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() && info.Mode()&fs.ModeSymlink == 0 {
+			// Sockets, devices and the like aren't project files
+			return nil
+		}
+
+		files++
+		if files > maxCopyFiles {
+			return fmt.Errorf("more than %d files to copy; copy smaller directories", maxCopyFiles)
+		}
+
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, copyEntry{
+			source: path,
+			target: filepath.Join(destination, rel),
+			name:   filepath.ToSlash(filepath.Join(name, rel)),
+			mode:   info.Mode(),
+		})
+		return nil
+	})
+
+	return entries, err
+}
+
+// copyLink returns what the copy of a symlink should point to. Links out of
+// the workspace aren't copied. A relative link is kept as it is when it still
+// leads into the workspace from the copy's location, e.g. to the copy of a
+// file next to it, and otherwise rewritten to point where the original does.
+func (ws *Workspace) copyLink(entry copyEntry) (string, error) {
+	link, err := os.Readlink(entry.source)
+	if err != nil {
+		return "", err
+	}
+
+	original := link
+	if !filepath.IsAbs(link) {
+		original = filepath.Join(filepath.Dir(entry.source), link)
+	}
+	roots := ws.realRoots()
+	if real, err := realPath(original); err != nil || !withinAny(roots, real) {
+		return "", fmt.Errorf("%s is a symbolic link to %s, outside the workspace; not copying it", entry.name, link)
+	}
+	if filepath.IsAbs(link) {
+		return link, nil
+	}
+
+	if real, err := realPath(filepath.Join(filepath.Dir(entry.target), link)); err == nil && withinAny(roots, real) {
+		return link, nil
+	}
+	return filepath.Rel(filepath.Dir(entry.target), original)
+}
+
+// copyOne copies a single entry, keeping its permissions. Symlinks are
+// copied as links, as copyLink decided. Files they replace go to the trash.
+func copyOne(ws *Workspace, entry copyEntry) error {
+	switch {
+	case entry.mode.IsDir():
+		if err := os.MkdirAll(entry.target, entry.mode.Perm()|0700); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		return nil
+
+	case entry.mode&fs.ModeSymlink != 0:
+		if existing, err := os.Readlink(entry.target); err == nil && existing == entry.link {
+			return nil
+		}
+		if _, err := os.Lstat(entry.target); err == nil {
+			if err := ws.Trash(entry.target); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.Symlink(entry.link, entry.target)
+	}
+
+	unlock, err := LockFile(entry.target)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := os.ReadFile(entry.source)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(entry.target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := ws.keepReplaced(entry.target, content); err != nil {
+		return err
+	}
+	if err := ws.WriteFile(entry.target, content); err != nil {
+		return err
+	}
+
+	return os.Chmod(entry.target, entry.mode.Perm())
+}
+
+// describeMode names the kind of file for error messages
+func describeMode(mode fs.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return "file"
+	}
+}
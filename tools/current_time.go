@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	// Embedded so time zones resolve on systems without a zoneinfo database, such as Windows
+	_ "time/tzdata"
+)
+
+// CurrentTime tool definition and implementation
+var CurrentTimeDefinition = ToolDefinition{
+	Name:        "current_time",
+	Description: "Get the current date and time instead of guessing it, e.g. for changelog entries, copyright headers or reading log timestamps. Shows the time in the local or a given time zone, in UTC and as a Unix timestamp, with the weekday and ISO week. Use format for a specific layout.",
+	InputSchema: CurrentTimeInputSchema,
+	Function:    CurrentTime,
+	ReadOnly:    true,
+	Volatile:    true,
+}
+
+type CurrentTimeInput struct {
+	Timezone string `json:"timezone,omitempty" jsonschema_description:"IANA time zone such as Europe/Berlin, America/New_York or UTC. Defaults to the system's local time zone."`
+	Format   string `json:"format,omitempty" jsonschema_description:"Optional layout: one of rfc3339, date, datetime, time, unix, rfc1123, year or iso_week, or a Go time layout such as \"Jan 2, 2006 15:04\"."`
+}
+
+var CurrentTimeInputSchema = GenerateSchema[CurrentTimeInput]()
+
+// namedTimeFormats are the layouts format can name
+var namedTimeFormats = map[string]string{
+	"rfc3339":  time.RFC3339,
+	"date":     time.DateOnly,
+	"datetime": time.DateTime,
+	"time":     time.TimeOnly,
+	"rfc1123":  time.RFC1123,
+	"year":     "2006",
+}
+
+func CurrentTime(ws *Workspace, input json.RawMessage) (string, error) {
+	currentTimeInput := CurrentTimeInput{}
+	if err := json.Unmarshal(input, &currentTimeInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	now := time.Now()
+	zone := strings.TrimSpace(currentTimeInput.Timezone)
+	if zone != "" {
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone %q, use an IANA name such as Europe/Berlin", zone)
+		}
+		now = now.In(location)
+	}
+
+	if format := strings.TrimSpace(currentTimeInput.Format); format != "" {
+		return formatTime(now, format), nil
+	}
+
+	name, offset := now.Zone()
+	year, week := now.ISOWeek()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s, %s, UTC%s)\n", now.Format(time.RFC3339), now.Location(), name, formatUTCOffset(offset))
+	fmt.Fprintf(&b, "%s, ISO week %d-W%02d, day %d of the year\n", now.Weekday(), year, week, now.YearDay())
+	fmt.Fprintf(&b, "UTC: %s\n", now.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Unix: %d", now.Unix())
+
+	return b.String(), nil
+}
+
+// formatTime formats t with a named layout or a Go time layout
+func formatTime(t time.Time, format string) string {
+	switch strings.ToLower(format) {
+	case "unix":
+		return fmt.Sprint(t.Unix())
+	case "iso_week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+
+	if layout, ok := namedTimeFormats[strings.ToLower(format)]; ok {
+		return t.Format(layout)
+	}
+	return t.Format(format)
+}
+
+// formatUTCOffset formats a zone offset in seconds as +hh:mm
+func formatUTCOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%02d:%02d", sign, offset/3600, offset%3600/60)
+}
@@ -0,0 +1,528 @@
+package tools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DependencyGraph tool definition and implementation
+var DependencyGraphDefinition = ToolDefinition{
+	Name:        "dependency_graph",
+	Description: "Find what depends on a package, to judge the blast radius of a change. Reads Go imports, package.json dependencies and pyproject.toml dependencies across the workspace, and lists the workspace packages that depend on the target directly and transitively. The target is a directory of the workspace (e.g. \"internal/auth\"), a Go import path, or a dependency name such as \"github.com/pkg/errors\", \"react\" or \"requests\". Without a target, lists the workspace packages with the most dependents.",
+	InputSchema: DependencyGraphInputSchema,
+	Function:    DependencyGraph,
+	ReadOnly:    true,
+}
+
+type DependencyGraphInput struct {
+	Target     string `json:"target,omitempty" jsonschema_description:"The directory, import path or dependency name to find the dependents of."`
+	Transitive *bool  `json:"transitive,omitempty" jsonschema_description:"Whether to include packages that depend on the target through others. Defaults to true."`
+}
+
+var DependencyGraphInputSchema = GenerateSchema[DependencyGraphInput]()
+
+// maxGraphFiles stops dependency_graph in huge trees
+const maxGraphFiles = 100000
+
+// dependencyKind tells regular dependencies apart from ones only used in
+// tests or development
+type dependencyKind int
+
+const (
+	dependencyRegular dependencyKind = iota
+	dependencyDev
+)
+
+// depPackage is a package of the workspace: a Go package, an npm package or a Python project
+type depPackage struct {
+	id        string
+	dir       string
+	ecosystem string
+	deps      map[string]dependencyKind
+}
+
+// depGraph holds the workspace packages by id
+type depGraph struct {
+	packages map[string]*depPackage
+}
+
+func (g *depGraph) pkg(id, dir, ecosystem string) *depPackage {
+	p, ok := g.packages[id]
+	if !ok {
+		p = &depPackage{id: id, dir: dir, ecosystem: ecosystem, deps: map[string]dependencyKind{}}
+		g.packages[id] = p
+	}
+	return p
+}
+
+// addDep records that p depends on dep. A regular use wins over a dev-only one.
+func (p *depPackage) addDep(dep string, kind dependencyKind) {
+	if existing, ok := p.deps[dep]; !ok || kind < existing {
+		p.deps[dep] = kind
+	}
+}
+
+func DependencyGraph(ws *Workspace, input json.RawMessage) (string, error) {
+	graphInput := DependencyGraphInput{}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &graphInput); err != nil {
+			return "", fmt.Errorf("failed to parse input: %w", err)
+		}
+	}
+
+	graph, truncated, err := buildDepGraph(ws, ws.Root())
+	if err != nil {
+		return "", err
+	}
+	if len(graph.packages) == 0 {
+		return "No Go packages, package.json or pyproject.toml files found in the workspace", nil
+	}
+
+	var result string
+	if graphInput.Target == "" {
+		result = mostDepended(graph)
+	} else {
+		transitive := graphInput.Transitive == nil || *graphInput.Transitive
+		result = dependentsReport(graph, resolveDepTarget(ws, graph, graphInput.Target), graphInput.Target, transitive)
+	}
+
+	if truncated {
+		result += fmt.Sprintf("\n(stopped reading after %d files; the graph is incomplete)\n", maxGraphFiles)
+	}
+	return result, nil
+}
+
+// buildDepGraph reads the dependencies of every package below root
+func buildDepGraph(ws *Workspace, root string) (*depGraph, bool, error) {
+	goFiles := map[string][]string{}
+	goModules := map[string]string{}
+	manifests := []string{}
+	seen := 0
+	truncated := false
+
+	err := ws.walkParallel(root, func(relPath string, entry fs.DirEntry) error {
+		relPath = filepath.ToSlash(relPath)
+		if ws.Ignored(relPath, entry.IsDir()) {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		seen++
+		if seen > maxGraphFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		dir := path.Dir(relPath)
+		switch name := entry.Name(); {
+		case strings.HasSuffix(name, ".go"):
+			goFiles[dir] = append(goFiles[dir], relPath)
+		case name == "go.mod":
+			if module := readModulePath(filepath.Join(root, relPath)); module != "" {
+				goModules[dir] = module
+			}
+		case name == "package.json", name == "pyproject.toml":
+			manifests = append(manifests, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	graph := &depGraph{packages: map[string]*depPackage{}}
+
+	for dir, files := range goFiles {
+		id := goImportPath(goModules, dir)
+		if id == "" {
+			continue
+		}
+		p := graph.pkg(id, dir, "Go")
+		for _, file := range files {
+			kind := dependencyRegular
+			if strings.HasSuffix(file, "_test.go") {
+				kind = dependencyDev
+			}
+			for _, imported := range readGoImports(filepath.Join(root, file)) {
+				if imported != id {
+					p.addDep(imported, kind)
+				}
+			}
+		}
+	}
+
+	for _, manifest := range manifests {
+		dir := path.Dir(manifest)
+		if path.Base(manifest) == "package.json" {
+			readPackageJSON(graph, filepath.Join(root, manifest), dir)
+		} else {
+			readPyproject(graph, filepath.Join(root, manifest), dir)
+		}
+	}
+
+	return graph, truncated, nil
+}
+
+// goImportPath is the import path of the Go package in dir, from the nearest
+// go.mod above it, or "" outside any module
+func goImportPath(modules map[string]string, dir string) string {
+	for moduleDir := dir; ; moduleDir = path.Dir(moduleDir) {
+		if module, ok := modules[moduleDir]; ok {
+			if moduleDir == dir {
+				return module
+			}
+			rel := strings.TrimPrefix(dir, moduleDir+"/")
+			if moduleDir == "." {
+				rel = dir
+			}
+			return module + "/" + rel
+		}
+		if moduleDir == "." || moduleDir == "/" {
+			return ""
+		}
+	}
+}
+
+var modulePattern = regexp.MustCompile(`(?m)^module\s+("?)([^\s"]+)`)
+
+// readModulePath returns the module path declared in a go.mod file
+func readModulePath(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	match := modulePattern.FindSubmatch(content)
+	if match == nil {
+		return ""
+	}
+	return string(match[2])
+}
+
+// readGoImports returns the import paths of a Go file
+func readGoImports(path string) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+
+	imports := make([]string, 0, len(file.Imports))
+	for _, spec := range file.Imports {
+		if imported, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, imported)
+		}
+	}
+	return imports
+}
+
+// readPackageJSON adds an npm package and its dependencies to the graph
+func readPackageJSON(graph *depGraph, path, dir string) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	manifest := struct {
+		Name                 string            `json:"name"`
+		Dependencies         map[string]string `json:"dependencies"`
+		PeerDependencies     map[string]string `json:"peerDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+	}{}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return
+	}
+
+	name := manifest.Name
+	if name == "" {
+		name = dir
+	}
+	p := graph.pkg(name, dir, "npm")
+	for _, deps := range []map[string]string{manifest.Dependencies, manifest.PeerDependencies, manifest.OptionalDependencies} {
+		for dep := range deps {
+			p.addDep(dep, dependencyRegular)
+		}
+	}
+	for dep := range manifest.DevDependencies {
+		p.addDep(dep, dependencyDev)
+	}
+}
+
+// requirementName matches the distribution name at the start of a Python requirement
+var requirementName = regexp.MustCompile(`^\s*([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// readPyproject adds a Python project and its dependencies to the graph. It
+// understands PEP 621 [project] tables and Poetry's dependency tables, which
+// is enough of TOML for dependency lists.
+func readPyproject(graph *depGraph, path, dir string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	name := ""
+	deps := map[string]dependencyKind{}
+	section := ""
+	inArray := false
+	arrayKind := dependencyRegular
+
+	addRequirement := func(requirement string, kind dependencyKind) {
+		if match := requirementName.FindStringSubmatch(requirement); match != nil {
+			dep := normalizePythonName(match[1])
+			if existing, ok := deps[dep]; !ok || kind < existing {
+				deps[dep] = kind
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if inArray {
+			for _, requirement := range quotedStrings(line) {
+				addRequirement(requirement, arrayKind)
+			}
+			if strings.Contains(line, "]") {
+				inArray = false
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case (section == "project" || section == "tool.poetry") && key == "name":
+			if names := quotedStrings(value); len(names) > 0 {
+				name = names[0]
+			}
+
+		case section == "project" && key == "dependencies",
+			section == "project.optional-dependencies",
+			section == "dependency-groups":
+			arrayKind = dependencyRegular
+			if section != "project" {
+				arrayKind = dependencyDev
+			}
+			for _, requirement := range quotedStrings(value) {
+				addRequirement(requirement, arrayKind)
+			}
+			inArray = strings.HasPrefix(value, "[") && !strings.Contains(value, "]")
+
+		case section == "tool.poetry.dependencies" && key != "python":
+			addRequirement(key, dependencyRegular)
+
+		case section == "tool.poetry.dev-dependencies",
+			strings.HasPrefix(section, "tool.poetry.group.") && strings.HasSuffix(section, ".dependencies"):
+			addRequirement(key, dependencyDev)
+		}
+	}
+
+	if name == "" {
+		name = dir
+	}
+	p := graph.pkg(normalizePythonName(name), dir, "Python")
+	for dep, kind := range deps {
+		p.addDep(dep, kind)
+	}
+}
+
+var quotedPattern = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+
+// quotedStrings returns the quoted strings in a line of TOML
+func quotedStrings(line string) []string {
+	values := []string{}
+	for _, match := range quotedPattern.FindAllStringSubmatch(line, -1) {
+		values = append(values, match[1]+match[2])
+	}
+	return values
+}
+
+// normalizePythonName normalizes a distribution name as PEP 503 does, so
+// "Foo_Bar" and "foo-bar" are the same dependency
+func normalizePythonName(name string) string {
+	name = strings.ToLower(name)
+	return strings.NewReplacer("_", "-", ".", "-").Replace(name)
+}
+
+// resolveDepTarget turns a target into the ids it matches: the package in a
+// workspace directory, or the dependency named by it
+func resolveDepTarget(ws *Workspace, graph *depGraph, target string) []string {
+	if absPath, err := ws.Resolve(target); err == nil {
+		if rel, err := filepath.Rel(ws.Root(), absPath); err == nil {
+			dir := filepath.ToSlash(rel)
+			ids := []string{}
+			for id, p := range graph.packages {
+				if p.dir == dir {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) > 0 {
+				return ids
+			}
+		}
+	}
+
+	return []string{target}
+}
+
+// matchesTarget reports whether a dependency is one of the targets, or for Go
+// a package inside a target module. Python names were normalized when read.
+func matchesTarget(dep string, targets []string) bool {
+	for _, target := range targets {
+		if dep == target || strings.HasPrefix(dep, target+"/") || dep == normalizePythonName(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependentsReport lists the packages that depend on the targets
+func dependentsReport(graph *depGraph, targets []string, asked string, transitive bool) string {
+	// Direct dependents, with whether any of them uses the target outside tests
+	direct := map[string]dependencyKind{}
+	for id, p := range graph.packages {
+		if matchesTarget(id, targets) {
+			continue
+		}
+		for dep, kind := range p.deps {
+			if matchesTarget(dep, targets) {
+				if existing, ok := direct[id]; !ok || kind < existing {
+					direct[id] = kind
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Dependents of %s", asked)
+	if len(targets) == 1 && targets[0] != asked {
+		fmt.Fprintf(&b, " (%s)", targets[0])
+	}
+	b.WriteString("\n")
+
+	if len(direct) == 0 {
+		b.WriteString("No workspace package depends on it.\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\nDirect (%d):\n", len(direct))
+	for _, id := range sortedIDs(direct) {
+		b.WriteString("- " + describePackage(graph.packages[id], direct[id]) + "\n")
+	}
+
+	if !transitive {
+		return b.String()
+	}
+
+	// Walk up the regular dependencies, remembering through which package each was reached
+	via := map[string]string{}
+	queue := sortedIDs(direct)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for id, p := range graph.packages {
+			if _, ok := direct[id]; ok || matchesTarget(id, targets) {
+				continue
+			}
+			if _, ok := via[id]; ok {
+				continue
+			}
+			if kind, ok := p.deps[current]; ok && kind == dependencyRegular {
+				via[id] = current
+				queue = append(queue, id)
+			}
+		}
+	}
+
+	if len(via) > 0 {
+		fmt.Fprintf(&b, "\nTransitive (%d):\n", len(via))
+		ids := make([]string, 0, len(via))
+		for id := range via {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintf(&b, "- %s via %s\n", describePackage(graph.packages[id], dependencyRegular), via[id])
+		}
+	}
+
+	return b.String()
+}
+
+// mostDepended lists the workspace packages with the most direct dependents
+func mostDepended(graph *depGraph) string {
+	counts := map[string]int{}
+	for _, p := range graph.packages {
+		for dep := range p.deps {
+			if _, ok := graph.packages[dep]; ok {
+				counts[dep]++
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(graph.packages))
+	for id := range graph.packages {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if counts[ids[i]] != counts[ids[j]] {
+			return counts[ids[i]] > counts[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d workspace packages. Most depended on:\n", len(ids))
+	for _, id := range ids[:min(20, len(ids))] {
+		fmt.Fprintf(&b, "- %s, dependents: %d\n", describePackage(graph.packages[id], dependencyRegular), counts[id])
+	}
+	b.WriteString("\nPass a target to see what depends on a package.\n")
+
+	return b.String()
+}
+
+// describePackage names a package with its directory and ecosystem
+func describePackage(p *depPackage, kind dependencyKind) string {
+	text := fmt.Sprintf("%s (%s, %s)", p.id, p.dir, p.ecosystem)
+	if kind == dependencyDev {
+		text += " [tests or dev only]"
+	}
+	return text
+}
+
+func sortedIDs(ids map[string]dependencyKind) []string {
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,637 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Evaluate tool definition and implementation
+var EvaluateDefinition = ToolDefinition{
+	Name:        "evaluate",
+	Description: "Evaluate an arithmetic expression exactly instead of computing it in your head, optionally with units and a conversion. Supports + - * / % ^ (power), parentheses, the bitwise & | << >>, hex (0x), octal (0o) and binary (0b) literals, the functions sqrt, abs, floor, ceil, round, min, max, pow, log (natural), log2, log10 and exp, and the constants pi and e. Numbers can carry units of data size (B, KB, MB, GB, TB, KiB, MiB, GiB, TiB, bit, Mbit, ...), time (ns, us, ms, s, min, h, d, wk) or length (mm, cm, m, km, inch, ft, mi), and a result converts with \"in\" or \"to\", e.g. \"1.5 GiB in MB\", \"3 * 512 KiB + 2 MB\", \"2h 30min to s\" or \"0xff & 0b1010\".",
+	InputSchema: EvaluateInputSchema,
+	Function:    Evaluate,
+	ReadOnly:    true,
+}
+
+type EvaluateInput struct {
+	Expression string `json:"expression" jsonschema_description:"The expression to evaluate, optionally ending in \"in <unit>\" or \"to <unit>\"."`
+}
+
+var EvaluateInputSchema = GenerateSchema[EvaluateInput]()
+
+// unit is a unit of measure as a multiple of its dimension's base unit
+type unit struct {
+	dimension string
+	factor    float64
+}
+
+// Dimensions and their base units
+const (
+	dimensionData   = "data"
+	dimensionTime   = "time"
+	dimensionLength = "length"
+)
+
+var baseUnits = map[string]string{
+	dimensionData:   "B",
+	dimensionTime:   "s",
+	dimensionLength: "m",
+}
+
+var units = map[string]unit{
+	"B": {dimensionData, 1}, "byte": {dimensionData, 1}, "bytes": {dimensionData, 1},
+	"KB": {dimensionData, 1e3}, "MB": {dimensionData, 1e6}, "GB": {dimensionData, 1e9}, "TB": {dimensionData, 1e12}, "PB": {dimensionData, 1e15},
+	"KiB": {dimensionData, 1 << 10}, "MiB": {dimensionData, 1 << 20}, "GiB": {dimensionData, 1 << 30}, "TiB": {dimensionData, 1 << 40}, "PiB": {dimensionData, 1 << 50},
+	"bit": {dimensionData, 1.0 / 8}, "bits": {dimensionData, 1.0 / 8},
+	"Kbit": {dimensionData, 1e3 / 8}, "Mbit": {dimensionData, 1e6 / 8}, "Gbit": {dimensionData, 1e9 / 8}, "Tbit": {dimensionData, 1e12 / 8},
+
+	"ns": {dimensionTime, 1e-9}, "us": {dimensionTime, 1e-6}, "µs": {dimensionTime, 1e-6}, "ms": {dimensionTime, 1e-3},
+	"s": {dimensionTime, 1}, "sec": {dimensionTime, 1}, "secs": {dimensionTime, 1}, "second": {dimensionTime, 1}, "seconds": {dimensionTime, 1},
+	"min": {dimensionTime, 60}, "mins": {dimensionTime, 60}, "minute": {dimensionTime, 60}, "minutes": {dimensionTime, 60},
+	"h": {dimensionTime, 3600}, "hr": {dimensionTime, 3600}, "hrs": {dimensionTime, 3600}, "hour": {dimensionTime, 3600}, "hours": {dimensionTime, 3600},
+	"d": {dimensionTime, 86400}, "day": {dimensionTime, 86400}, "days": {dimensionTime, 86400},
+	"wk": {dimensionTime, 604800}, "week": {dimensionTime, 604800}, "weeks": {dimensionTime, 604800},
+	"yr": {dimensionTime, 365 * 86400}, "year": {dimensionTime, 365 * 86400}, "years": {dimensionTime, 365 * 86400},
+
+	"mm": {dimensionLength, 1e-3}, "cm": {dimensionLength, 1e-2}, "m": {dimensionLength, 1}, "km": {dimensionLength, 1e3},
+	"inch": {dimensionLength, 0.0254}, "inches": {dimensionLength, 0.0254}, "ft": {dimensionLength, 0.3048}, "feet": {dimensionLength, 0.3048},
+	"yd": {dimensionLength, 0.9144}, "mi": {dimensionLength, 1609.344}, "mile": {dimensionLength, 1609.344}, "miles": {dimensionLength, 1609.344},
+}
+
+// quantity is a number in the base unit of its dimension, or a plain number
+type quantity struct {
+	value     float64
+	dimension string
+}
+
+func Evaluate(ws *Workspace, input json.RawMessage) (string, error) {
+	evaluateInput := EvaluateInput{}
+	if err := json.Unmarshal(input, &evaluateInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if strings.TrimSpace(evaluateInput.Expression) == "" {
+		return "", fmt.Errorf("expression is required")
+	}
+
+	tokens, err := tokenizeExpression(evaluateInput.Expression)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression: %w", err)
+	}
+
+	// A trailing "in <unit>" or "to <unit>" converts the result
+	target := ""
+	if n := len(tokens); n >= 2 && (tokens[n-2].text == "in" || tokens[n-2].text == "to") && tokens[n-1].kind == tokenIdent {
+		target = tokens[n-1].text
+		if _, ok := units[target]; !ok {
+			return "", fmt.Errorf("unknown unit %q", target)
+		}
+		tokens = tokens[:n-2]
+	}
+
+	p := &exprParser{tokens: tokens}
+	result, err := p.parseExpression()
+	if err != nil {
+		return "", fmt.Errorf("invalid expression: %w", err)
+	}
+	if p.pos < len(p.tokens) {
+		return "", fmt.Errorf("invalid expression: unexpected %q", p.tokens[p.pos].text)
+	}
+	if math.IsNaN(result.value) || math.IsInf(result.value, 0) {
+		return "", fmt.Errorf("result is not a finite number")
+	}
+
+	if target != "" {
+		to := units[target]
+		if result.dimension != to.dimension {
+			return "", fmt.Errorf("can't convert %s to %s", describeDimension(result.dimension), target)
+		}
+		return fmt.Sprintf("%s %s", formatNumber(result.value/to.factor), target), nil
+	}
+
+	if result.dimension == "" {
+		text := formatNumber(result.value)
+		if p.integerHint && result.value == math.Trunc(result.value) && math.Abs(result.value) < 1<<53 {
+			text += " (" + formatHex(int64(result.value)) + ")"
+		}
+		return text, nil
+	}
+
+	text := fmt.Sprintf("%s %s", formatNumber(result.value), baseUnits[result.dimension])
+	if readable := readableQuantity(result); readable != "" {
+		text += " (" + readable + ")"
+	}
+	return text, nil
+}
+
+// formatHex prints an integer in hex, with the sign before the prefix
+func formatHex(value int64) string {
+	if value < 0 {
+		return fmt.Sprintf("-0x%x", -value)
+	}
+	return fmt.Sprintf("0x%x", value)
+}
+
+func describeDimension(dimension string) string {
+	if dimension == "" {
+		return "a plain number"
+	}
+	return "a " + dimension + " quantity"
+}
+
+// formatNumber prints a number without trailing zeros or float noise
+func formatNumber(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'g', 12, 64)
+}
+
+// readableQuantity shows a quantity in the largest unit it has at least one of
+func readableQuantity(q quantity) string {
+	switch q.dimension {
+	case dimensionData:
+		for _, name := range []string{"PiB", "TiB", "GiB", "MiB", "KiB"} {
+			if math.Abs(q.value) >= units[name].factor {
+				return formatNumber(q.value/units[name].factor) + " " + name
+			}
+		}
+	case dimensionTime:
+		if math.Abs(q.value) < math.MaxInt64/1e9 {
+			return time.Duration(q.value * 1e9).String()
+		}
+	case dimensionLength:
+		if math.Abs(q.value) >= 1000 {
+			return formatNumber(q.value/1000) + " km"
+		}
+	}
+	return ""
+}
+
+type tokenKind int
+
+const (
+	tokenNumber tokenKind = iota
+	tokenIdent
+	tokenOperator
+)
+
+type exprToken struct {
+	kind  tokenKind
+	text  string
+	value float64
+}
+
+// tokenizeExpression splits an expression into numbers, names and operators
+func tokenizeExpression(expression string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E') && !strings.HasPrefix(strings.ToLower(string(runes[start:i])), "0x"))) {
+				// Stop before a unit written without a space, such as "10ms", unless it's part of a literal
+				if unicode.IsLetter(runes[i]) && !isNumberLetter(runes[start:i+1]) {
+					break
+				}
+				i++
+			}
+			text := strings.ReplaceAll(string(runes[start:i]), "_", "")
+			value, err := parseNumber(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", string(runes[start:i]))
+			}
+			tokens = append(tokens, exprToken{kind: tokenNumber, text: text, value: value})
+
+		case unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokenIdent, text: string(runes[start:i])})
+
+		default:
+			text := string(r)
+			if i+1 < len(runes) {
+				if two := string(runes[i : i+2]); two == "**" || two == "<<" || two == ">>" {
+					text = two
+				}
+			}
+			if !strings.Contains("+-*/%^&|(),", text) && text != "**" && text != "<<" && text != ">>" {
+				return nil, fmt.Errorf("unexpected character %q", text)
+			}
+			i += len([]rune(text))
+			tokens = append(tokens, exprToken{kind: tokenOperator, text: text})
+		}
+	}
+
+	return tokens, nil
+}
+
+// isNumberLetter reports whether a number literal so far, ending in a
+// letter, is still a literal: a base prefix, hex digits or an exponent
+func isNumberLetter(literal []rune) bool {
+	text := strings.ToLower(string(literal))
+	last := text[len(text)-1]
+	switch {
+	case len(text) == 2 && text[0] == '0' && (last == 'x' || last == 'o' || last == 'b'):
+		return true
+	case strings.HasPrefix(text, "0x"):
+		return strings.ContainsRune("abcdef", rune(last))
+	case last == 'e':
+		return !strings.HasPrefix(text, "0b") && !strings.HasPrefix(text, "0o")
+	}
+	return false
+}
+
+func parseNumber(text string) (float64, error) {
+	lower := strings.ToLower(text)
+	if len(lower) > 2 && lower[0] == '0' && strings.ContainsRune("xob", rune(lower[1])) {
+		n, err := strconv.ParseInt(lower, 0, 64)
+		return float64(n), err
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// exprParser is a recursive descent parser that evaluates as it parses
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	// integerHint is set when the expression looks like bit twiddling, so
+	// the result is shown in hex too
+	integerHint bool
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *exprParser) accept(operators ...string) string {
+	if t := p.peek(); t != nil && t.kind == tokenOperator {
+		for _, operator := range operators {
+			if t.text == operator {
+				p.pos++
+				return operator
+			}
+		}
+	}
+	return ""
+}
+
+// parseExpression handles the lowest precedence: + - |
+func (p *exprParser) parseExpression() (quantity, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return left, err
+	}
+
+	for {
+		operator := p.accept("+", "-", "|")
+		if operator == "" {
+			return left, nil
+		}
+		right, err := p.parseTerm()
+		if err != nil {
+			return left, err
+		}
+
+		if operator == "|" {
+			left, err = integerOp(left, right, func(a, b int64) int64 { return a | b })
+			p.integerHint = true
+		} else {
+			if left.dimension != right.dimension {
+				return left, fmt.Errorf("can't add %s and %s", describeDimension(left.dimension), describeDimension(right.dimension))
+			}
+			if operator == "+" {
+				left.value += right.value
+			} else {
+				left.value -= right.value
+			}
+		}
+		if err != nil {
+			return left, err
+		}
+	}
+}
+
+// parseTerm handles * / % & << >>
+func (p *exprParser) parseTerm() (quantity, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return left, err
+	}
+
+	for {
+		operator := p.accept("*", "/", "%", "&", "<<", ">>")
+		if operator == "" {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return left, err
+		}
+
+		switch operator {
+		case "*":
+			if left.dimension != "" && right.dimension != "" {
+				return left, fmt.Errorf("can't multiply two %s quantities", left.dimension)
+			}
+			left = quantity{value: left.value * right.value, dimension: left.dimension + right.dimension}
+		case "/":
+			if right.value == 0 {
+				return left, fmt.Errorf("division by zero")
+			}
+			switch {
+			case left.dimension == right.dimension:
+				left = quantity{value: left.value / right.value}
+			case right.dimension == "":
+				left.value /= right.value
+			default:
+				return left, fmt.Errorf("can't divide %s by %s", describeDimension(left.dimension), describeDimension(right.dimension))
+			}
+		case "%":
+			if right.value == 0 {
+				return left, fmt.Errorf("division by zero")
+			}
+			if right.dimension != "" && right.dimension != left.dimension {
+				return left, fmt.Errorf("can't take %s modulo %s", describeDimension(left.dimension), describeDimension(right.dimension))
+			}
+			left.value = math.Mod(left.value, right.value)
+		case "&":
+			left, err = integerOp(left, right, func(a, b int64) int64 { return a & b })
+		case "<<":
+			left, err = shiftLeft(left, right)
+		case ">>":
+			left, err = shiftOp(left, right, func(a, b int64) int64 { return a >> b })
+		}
+		if operator == "&" || operator == "<<" || operator == ">>" {
+			p.integerHint = true
+		}
+		if err != nil {
+			return left, err
+		}
+	}
+}
+
+// integerOp applies a bitwise operator, which only makes sense for plain integers
+func integerOp(left, right quantity, op func(a, b int64) int64) (quantity, error) {
+	if left.dimension != "" || right.dimension != "" {
+		return left, fmt.Errorf("bitwise operators need plain numbers")
+	}
+	if left.value != math.Trunc(left.value) || right.value != math.Trunc(right.value) {
+		return left, fmt.Errorf("bitwise operators need integers")
+	}
+	if right.value < 0 {
+		return left, fmt.Errorf("bitwise operators need non-negative right operands")
+	}
+	// Converting a float outside the int64 range gives an arbitrary value
+	if !fitsInt64(left.value) || !fitsInt64(right.value) {
+		return left, fmt.Errorf("bitwise operators need integers between -2^63 and 2^63")
+	}
+	return quantity{value: float64(op(int64(left.value), int64(right.value)))}, nil
+}
+
+// shiftOp applies a shift operator, whose count must be below the 64 bits of an int64
+func shiftOp(left, right quantity, op func(a, b int64) int64) (quantity, error) {
+	if right.value >= 64 {
+		return left, fmt.Errorf("shift counts must be less than 64")
+	}
+	return integerOp(left, right, op)
+}
+
+// shiftLeft applies <<, refusing shifts whose result doesn't fit in an int64
+func shiftLeft(left, right quantity) (quantity, error) {
+	shifted, err := shiftOp(left, right, func(a, b int64) int64 { return a << b })
+	if err != nil {
+		return left, err
+	}
+	// Shifting by a power of two is exact in a float, so the check is too
+	if !fitsInt64(left.value * math.Exp2(right.value)) {
+		return left, fmt.Errorf("%s << %s overflows a 64-bit integer", formatNumber(left.value), formatNumber(right.value))
+	}
+	return shifted, nil
+}
+
+// fitsInt64 reports whether a whole number converts to an int64 exactly
+func fitsInt64(value float64) bool {
+	return value >= math.MinInt64 && value < math.MaxInt64
+}
+
+func (p *exprParser) parseUnary() (quantity, error) {
+	if operator := p.accept("-", "+"); operator != "" {
+		value, err := p.parseUnary()
+		if operator == "-" {
+			value.value = -value.value
+		}
+		return value, err
+	}
+	return p.parsePower()
+}
+
+// parsePower handles ^ and **, which bind right to left
+func (p *exprParser) parsePower() (quantity, error) {
+	base, err := p.parseQuantity()
+	if err != nil {
+		return base, err
+	}
+
+	if p.accept("^", "**") == "" {
+		return base, nil
+	}
+	exponent, err := p.parseUnary()
+	if err != nil {
+		return base, err
+	}
+	if base.dimension != "" || exponent.dimension != "" {
+		return base, fmt.Errorf("powers need plain numbers")
+	}
+	return quantity{value: math.Pow(base.value, exponent.value)}, nil
+}
+
+// parseQuantity is a primary with an optional unit. Adjacent quantities of the
+// same dimension add up, so "2h 30min" is two and a half hours.
+func (p *exprParser) parseQuantity() (quantity, error) {
+	q, err := p.parsePrimary()
+	if err != nil {
+		return q, err
+	}
+
+	if !p.applyUnit(&q) {
+		return q, nil
+	}
+
+	for {
+		next := p.peek()
+		if next == nil || next.kind != tokenNumber || p.pos+1 >= len(p.tokens) || p.tokens[p.pos+1].kind != tokenIdent {
+			return q, nil
+		}
+		u, ok := units[p.tokens[p.pos+1].text]
+		if !ok || u.dimension != q.dimension {
+			return q, nil
+		}
+		q.value += next.value * u.factor
+		p.pos += 2
+	}
+}
+
+// applyUnit converts q by a following unit name, if there is one
+func (p *exprParser) applyUnit(q *quantity) bool {
+	t := p.peek()
+	if t == nil || t.kind != tokenIdent {
+		return false
+	}
+	u, ok := units[t.text]
+	if !ok || q.dimension != "" {
+		return false
+	}
+
+	p.pos++
+	q.value *= u.factor
+	q.dimension = u.dimension
+	return true
+}
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+var functions = map[string]func(args []float64) (float64, error){
+	"sqrt":  oneArg(math.Sqrt),
+	"abs":   oneArg(math.Abs),
+	"floor": oneArg(math.Floor),
+	"ceil":  oneArg(math.Ceil),
+	"round": oneArg(math.Round),
+	"log":   oneArg(math.Log),
+	"ln":    oneArg(math.Log),
+	"log2":  oneArg(math.Log2),
+	"log10": oneArg(math.Log10),
+	"exp":   oneArg(math.Exp),
+	"pow": func(args []float64) (float64, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("takes 2 arguments")
+		}
+		return math.Pow(args[0], args[1]), nil
+	},
+	"min": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("takes at least 1 argument")
+		}
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result, nil
+	},
+	"max": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("takes at least 1 argument")
+		}
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result, nil
+	},
+}
+
+func oneArg(f func(float64) float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("takes 1 argument")
+		}
+		return f(args[0]), nil
+	}
+}
+
+func (p *exprParser) parsePrimary() (quantity, error) {
+	t := p.peek()
+	if t == nil {
+		return quantity{}, fmt.Errorf("unexpected end of expression")
+	}
+	p.pos++
+
+	switch t.kind {
+	case tokenNumber:
+		lower := strings.ToLower(t.text)
+		if strings.HasPrefix(lower, "0x") || strings.HasPrefix(lower, "0b") || strings.HasPrefix(lower, "0o") {
+			p.integerHint = true
+		}
+		return quantity{value: t.value}, nil
+
+	case tokenIdent:
+		if value, ok := constants[t.text]; ok {
+			return quantity{value: value}, nil
+		}
+
+		function, ok := functions[t.text]
+		if !ok {
+			if _, isUnit := units[t.text]; isUnit {
+				// A bare unit means one of it, as in "GiB in MB"
+				p.pos--
+				q := quantity{value: 1}
+				p.applyUnit(&q)
+				return q, nil
+			}
+			return quantity{}, fmt.Errorf("unknown name %q", t.text)
+		}
+		if p.accept("(") == "" {
+			return quantity{}, fmt.Errorf("%s() needs arguments in parentheses", t.text)
+		}
+
+		args := []float64{}
+		dimension := ""
+		for p.accept(")") == "" {
+			if len(args) > 0 && p.accept(",") == "" {
+				return quantity{}, fmt.Errorf("expected , or ) in %s()", t.text)
+			}
+			arg, err := p.parseExpression()
+			if err != nil {
+				return arg, err
+			}
+			// min, max, abs and the rounding functions keep a unit; the rest need plain numbers
+			if arg.dimension != "" {
+				if dimension != "" && dimension != arg.dimension || !strings.Contains(" min max abs floor ceil round ", " "+t.text+" ") {
+					return quantity{}, fmt.Errorf("%s() can't take %s", t.text, describeDimension(arg.dimension))
+				}
+				dimension = arg.dimension
+			}
+			args = append(args, arg.value)
+		}
+
+		value, err := function(args)
+		if err != nil {
+			return quantity{}, fmt.Errorf("%s() %w", t.text, err)
+		}
+		return quantity{value: value, dimension: dimension}, nil
+
+	default:
+		if t.text == "(" {
+			value, err := p.parseExpression()
+			if err != nil {
+				return value, err
+			}
+			if p.accept(")") == "" {
+				return value, fmt.Errorf("missing )")
+			}
+			return value, nil
+		}
+		return quantity{}, fmt.Errorf("unexpected %q", t.text)
+	}
+}
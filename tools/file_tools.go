@@ -6,27 +6,41 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ReadFile tool definition and implementation
 var ReadFileDefinition = ToolDefinition{
 	Name:        "read_file",
-	Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names.",
+	Description: "Read the contents of a given relative file path. Use this when you want to see what's inside a file. Do not use this with directory names. Set line_numbers before edits that refer to line numbers, and around_line to read just the lines surrounding a location.",
 	InputSchema: ReadFileInputSchema,
 	Function:    ReadFile,
+	ReadOnly:    true,
 }
 
 type ReadFileInput struct {
-	Path      string `json:"path" jsonschema_description:"The relative path of a file in the working directory."`
-	StartLine *int   `json:"start_line,omitempty" jsonschema_description:"Optional starting line number (1-based). If provided, only reads from this line onwards."`
-	EndLine   *int   `json:"end_line,omitempty" jsonschema_description:"Optional ending line number (1-based). If provided with start_line, reads only the specified range."`
+	Path        string `json:"path" jsonschema_description:"The relative path of a file in the working directory."`
+	StartLine   *int   `json:"start_line,omitempty" jsonschema_description:"Optional starting line number (1-based). If provided, only reads from this line onwards."`
+	EndLine     *int   `json:"end_line,omitempty" jsonschema_description:"Optional ending line number (1-based). If provided with start_line, reads only the specified range."`
+	AroundLine  *int   `json:"around_line,omitempty" jsonschema_description:"Optional line number (1-based) to center a window on. Reads 'context' lines either side of it; cannot be combined with start_line or end_line."`
+	Context     *int   `json:"context,omitempty" jsonschema_description:"Number of lines to show either side of around_line. Defaults to 10."`
+	LineNumbers bool   `json:"line_numbers,omitempty" jsonschema_description:"Prefix each line with its line number and a tab. Use this before edits that reference line numbers."`
 }
 
 var ReadFileInputSchema = GenerateSchema[ReadFileInput]()
 
-func ReadFile(input json.RawMessage) (string, error) {
+// defaultReadContext is the number of lines shown either side of around_line
+const defaultReadContext = 10
+
+func ReadFile(ws *Workspace, input json.RawMessage) (string, error) {
 	readFileInput := ReadFileInput{}
 
 	err := json.Unmarshal(input, &readFileInput)
@@ -38,23 +52,55 @@ func ReadFile(input json.RawMessage) (string, error) {
 		return "", fmt.Errorf("path is required")
 	}
 
-	content, err := os.ReadFile(readFileInput.Path)
+	path, err := ws.Resolve(readFileInput.Path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ws.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// If no line range specified, return full content
-	if readFileInput.StartLine == nil && readFileInput.EndLine == nil {
-		return string(content), nil
+	// The model sees "\n" line endings whatever the file uses; edits convert back
+	content := toLF(string(data))
+
+	// If no line range or numbering is requested, return full content
+	if readFileInput.StartLine == nil && readFileInput.EndLine == nil && readFileInput.AroundLine == nil && !readFileInput.LineNumbers {
+		return content, nil
 	}
 
-	// Split content into lines for range reading
-	lines := strings.Split(string(content), "\n")
+	// Split content into lines for range reading. A trailing newline ends the
+	// last line rather than starting an empty one.
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
 	totalLines := len(lines)
 
 	startLine := 1
 	endLine := totalLines
 
+	if readFileInput.AroundLine != nil {
+		if readFileInput.StartLine != nil || readFileInput.EndLine != nil {
+			return "", fmt.Errorf("around_line cannot be combined with start_line or end_line")
+		}
+		if *readFileInput.AroundLine < 1 {
+			return "", fmt.Errorf("around_line must be >= 1")
+		}
+		if *readFileInput.AroundLine > totalLines {
+			return "", fmt.Errorf("around_line (%d) exceeds total lines (%d)", *readFileInput.AroundLine, totalLines)
+		}
+
+		context := defaultReadContext
+		if readFileInput.Context != nil {
+			if *readFileInput.Context < 0 {
+				return "", fmt.Errorf("context must be >= 0")
+			}
+			context = *readFileInput.Context
+		}
+
+		startLine = max(*readFileInput.AroundLine-context, 1)
+		endLine = *readFileInput.AroundLine + context
+	}
+
 	if readFileInput.StartLine != nil {
 		if *readFileInput.StartLine < 1 {
 			return "", fmt.Errorf("start_line must be >= 1")
@@ -85,35 +131,106 @@ func ReadFile(input json.RawMessage) (string, error) {
 	}
 
 	selectedLines := lines[startIdx:endIdx]
-	return strings.Join(selectedLines, "\n"), nil
+	if !readFileInput.LineNumbers {
+		return strings.Join(selectedLines, "\n"), nil
+	}
+
+	// Right-align the numbers so the tab-separated content lines up
+	width := len(fmt.Sprint(endIdx))
+	numbered := make([]string, len(selectedLines))
+	for i, line := range selectedLines {
+		numbered[i] = fmt.Sprintf("%*d\t%s", width, startLine+i, line)
+	}
+
+	return strings.Join(numbered, "\n"), nil
 }
 
 // ListFiles tool definition and implementation
 var ListFilesDefinition = ToolDefinition{
 	Name:        "list_files",
-	Description: "List files and directories at a given path. If no path is provided, lists files in the current directory.",
+	Description: "List files and directories at a given path. If no path is provided, lists files in the current directory. Results are paged: the response has the total count and, when more entries remain, the next_offset to pass back. Prefix the path with a root name (e.g. 'backend:') to list an additional workspace root. Symbolic links are listed in symlinks with their targets; recursive listings follow links to directories outside the listed tree.",
 	InputSchema: ListFilesInputSchema,
 	Function:    ListFiles,
+	ReadOnly:    true,
 }
 
 type ListFilesInput struct {
-	Path      string `json:"path,omitempty" jsonschema_description:"Optional relative path to list files from. Defaults to current directory if not provided."`
-	Recursive bool   `json:"recursive,omitempty" jsonschema_description:"Whether to list files recursively. Defaults to true."`
-	MaxDepth  *int   `json:"max_depth,omitempty" jsonschema_description:"Maximum depth to recurse. Only applies if recursive is true."`
+	Path      string   `json:"path,omitempty" jsonschema_description:"Optional relative path to list files from. Defaults to current directory if not provided."`
+	Recursive bool     `json:"recursive,omitempty" jsonschema_description:"Whether to list files recursively. Defaults to true."`
+	MaxDepth  *int     `json:"max_depth,omitempty" jsonschema_description:"Maximum depth to recurse. Only applies if recursive is true."`
+	Sort      string   `json:"sort,omitempty" jsonschema_description:"Sort order: 'name' (default), 'mtime' (most recently modified first) or 'size' (largest first)."`
+	Limit     *int     `json:"limit,omitempty" jsonschema_description:"Maximum number of entries to return. Defaults to 500."`
+	Offset    int      `json:"offset,omitempty" jsonschema_description:"Number of entries to skip, for paging through large listings."`
+	Include   []string `json:"include,omitempty" jsonschema_description:"Only list files matching one of these globs, e.g. ['*.go']. Patterns without a '/' match the file name; others match the relative path and may use '**'. Directories are omitted from the results when set."`
+	Exclude   []string `json:"exclude,omitempty" jsonschema_description:"Leave out files and directories matching any of these globs, e.g. ['*_test.go']. Excluded directories are not descended into."`
+
+	IncludeIgnored bool `json:"include_ignored,omitempty" jsonschema_description:"Descend into directories recursive listings skip by default, such as .git, node_modules, vendor and build output."`
 }
 
 var ListFilesInputSchema = GenerateSchema[ListFilesInput]()
 
-func ListFiles(input json.RawMessage) (string, error) {
+// defaultListLimit caps list_files results when the model doesn't ask for a limit
+const defaultListLimit = 500
+
+// maxListEntries stops recursive walks early in huge trees. Entries are
+// sorted after the walk, so they all have to be held in memory.
+const maxListEntries = 100000
+
+// listEntry is a file or directory found by list_files
+type listEntry struct {
+	path    string
+	isDir   bool
+	link    string
+	size    int64
+	modTime time.Time
+}
+
+// ListFilesResult is the JSON returned by list_files. Truncated is set when
+// the walk stopped at maxListEntries, making Total a lower bound.
+type ListFilesResult struct {
+	Total      int               `json:"total"`
+	Truncated  bool              `json:"truncated,omitempty"`
+	Offset     int               `json:"offset"`
+	Files      []string          `json:"files"`
+	Symlinks   map[string]string `json:"symlinks,omitempty"`
+	NextOffset *int              `json:"next_offset,omitempty"`
+}
+
+func ListFiles(ws *Workspace, input json.RawMessage) (string, error) {
 	listFilesInput := ListFilesInput{}
 	err := json.Unmarshal(input, &listFilesInput)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse input: %w", err)
 	}
 
-	dir := "."
-	if listFilesInput.Path != "" {
-		dir = listFilesInput.Path
+	switch listFilesInput.Sort {
+	case "", "name", "mtime", "size":
+	default:
+		return "", fmt.Errorf("invalid sort %q: use name, mtime or size", listFilesInput.Sort)
+	}
+
+	limit := defaultListLimit
+	if listFilesInput.Limit != nil {
+		if *listFilesInput.Limit < 1 {
+			return "", fmt.Errorf("limit must be >= 1")
+		}
+		limit = *listFilesInput.Limit
+	}
+
+	if listFilesInput.Offset < 0 {
+		return "", fmt.Errorf("offset must be >= 0")
+	}
+
+	for _, pattern := range append(listFilesInput.Include, listFilesInput.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return "", fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	filter := listFilter{include: listFilesInput.Include, exclude: listFilesInput.Exclude}
+
+	dir, err := ws.Resolve(listFilesInput.Path)
+	if err != nil {
+		return "", err
 	}
 
 	// Default to recursive if not specified
@@ -123,7 +240,11 @@ func ListFiles(input json.RawMessage) (string, error) {
 		recursive = false
 	}
 
-	var files []string
+	// Sizes and times are only looked up when sorting needs them
+	needInfo := listFilesInput.Sort == "mtime" || listFilesInput.Sort == "size"
+
+	var files []listEntry
+	truncated := false
 
 	if !recursive {
 		// Non-recursive listing
@@ -133,10 +254,13 @@ func ListFiles(input json.RawMessage) (string, error) {
 		}
 
 		for _, entry := range entries {
-			if entry.IsDir() {
-				files = append(files, entry.Name()+"/")
-			} else {
-				files = append(files, entry.Name())
+			if !filter.allows(entry.Name(), entry.IsDir()) {
+				continue
+			}
+
+			file, ok := newListEntry(dir, entry.Name(), entry, needInfo)
+			if ok {
+				files = append(files, file)
 			}
 		}
 	} else {
@@ -146,36 +270,57 @@ func ListFiles(input json.RawMessage) (string, error) {
 			maxDepth = *listFilesInput.MaxDepth
 		}
 
-		err = filepath.Walk(dir, func(filePath string, info fs.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+		// Ignore patterns are relative to the root the listing is in
+		root, _ := ws.containingRoot(dir)
 
-			relPath, err := filepath.Rel(dir, filePath)
-			if err != nil {
-				return err
-			}
-
-			// Skip the root directory itself
-			if relPath == "." {
-				return nil
+		err = ws.walkParallel(dir, func(relPath string, entry fs.DirEntry) error {
+			// Stop once the cap is reached; the total becomes a lower bound
+			if len(files) >= maxListEntries {
+				truncated = true
+				return filepath.SkipAll
 			}
 
 			// Check depth limit
 			if maxDepth >= 0 {
 				depth := strings.Count(relPath, string(filepath.Separator))
 				if depth > maxDepth {
-					if info.IsDir() {
+					if entry.IsDir() {
 						return filepath.SkipDir
 					}
 					return nil
 				}
 			}
 
-			if info.IsDir() {
-				files = append(files, relPath+"/")
-			} else {
-				files = append(files, relPath)
+			if filter.excludes(filepath.ToSlash(relPath)) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Ignored directories are listed so the model knows they exist, but not descended into
+			skip := false
+			if !listFilesInput.IncludeIgnored {
+				rootRel, err := filepath.Rel(root, filepath.Join(dir, relPath))
+				if err != nil {
+					rootRel = relPath
+				}
+				if ws.Ignored(filepath.ToSlash(rootRel), entry.IsDir()) {
+					if !entry.IsDir() {
+						return nil
+					}
+					skip = true
+				}
+			}
+
+			if filter.allows(filepath.ToSlash(relPath), entry.IsDir()) {
+				if file, ok := newListEntry(dir, relPath, entry, needInfo); ok {
+					files = append(files, file)
+				}
+			}
+
+			if skip {
+				return filepath.SkipDir
 			}
 			return nil
 		})
@@ -185,12 +330,165 @@ func ListFiles(input json.RawMessage) (string, error) {
 		}
 	}
 
-	result, err := json.Marshal(files)
+	sortListEntries(files, listFilesInput.Sort)
+
+	result := ListFilesResult{
+		Total:     len(files),
+		Truncated: truncated,
+		Offset:    listFilesInput.Offset,
+		Files:     []string{},
+	}
+
+	start := min(listFilesInput.Offset, len(files))
+	end := min(start+limit, len(files))
+	for _, file := range files[start:end] {
+		name := file.path
+		if file.isDir {
+			name += "/"
+		}
+		result.Files = append(result.Files, name)
+
+		if file.link != "" {
+			if result.Symlinks == nil {
+				result.Symlinks = map[string]string{}
+			}
+			result.Symlinks[name] = file.link
+		}
+	}
+	if end < len(files) {
+		result.NextOffset = &end
+	}
+
+	output, err := json.Marshal(result)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal result: %w", err)
 	}
 
-	return string(result), nil
+	return string(output), nil
+}
+
+// newListEntry builds a listing entry for relPath below dir, reading the
+// file's metadata when withInfo is set. It reports false when the entry
+// vanished in the meantime.
+func newListEntry(dir, relPath string, entry fs.DirEntry, withInfo bool) (listEntry, bool) {
+	// Paths are reported with forward slashes on every platform, as the model writes them
+	file := listEntry{path: filepath.ToSlash(relPath), isDir: entry.IsDir()}
+
+	// Symlinks show where they point; a link to a directory is listed as one
+	if entry.Type()&fs.ModeSymlink != 0 {
+		path := filepath.Join(dir, relPath)
+		target, err := os.Readlink(path)
+		if err != nil {
+			return file, false
+		}
+		file.link = filepath.ToSlash(target)
+		if info, err := os.Stat(path); err == nil {
+			file.isDir = info.IsDir()
+		}
+	}
+
+	if !withInfo {
+		return file, true
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return file, false
+	}
+	file.size = info.Size()
+	file.modTime = info.ModTime()
+
+	return file, true
+}
+
+// listFilter applies list_files' include and exclude globs
+type listFilter struct {
+	include []string
+	exclude []string
+}
+
+// excludes reports whether a slash-separated relative path matches an exclude glob
+func (f listFilter) excludes(relPath string) bool {
+	for _, pattern := range f.exclude {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether an entry belongs in the results. With include globs
+// only matching files are listed.
+func (f listFilter) allows(relPath string, isDir bool) bool {
+	if f.excludes(relPath) {
+		return false
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	if isDir {
+		return false
+	}
+
+	for _, pattern := range f.include {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash-separated relative path against a glob. Patterns
+// without a '/' match the last path element; others match the whole path,
+// where a "**" element matches any number of directories.
+func MatchGlob(pattern, relPath string) bool {
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(relPath))
+		return matched
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	matched, _ := path.Match(pattern[0], parts[0])
+	return matched && matchSegments(pattern[1:], parts[1:])
+}
+
+// sortListEntries orders entries by name, by modification time (newest
+// first) or by size (largest first), breaking ties by name
+func sortListEntries(entries []listEntry, order string) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch order {
+		case "mtime":
+			if !a.modTime.Equal(b.modTime) {
+				return a.modTime.After(b.modTime)
+			}
+		case "size":
+			if a.size != b.size {
+				return a.size > b.size
+			}
+		}
+		return a.path < b.path
+	})
 }
 
 // CreateFile tool definition and implementation
@@ -209,7 +507,7 @@ type CreateFileInput struct {
 
 var CreateFileInputSchema = GenerateSchema[CreateFileInput]()
 
-func CreateFile(input json.RawMessage) (string, error) {
+func CreateFile(ws *Workspace, input json.RawMessage) (string, error) {
 	createFileInput := CreateFileInput{}
 	err := json.Unmarshal(input, &createFileInput)
 	if err != nil {
@@ -220,25 +518,44 @@ func CreateFile(input json.RawMessage) (string, error) {
 		return "", fmt.Errorf("path is required")
 	}
 
+	path, err := ws.Resolve(createFileInput.Path)
+	if err != nil {
+		return "", err
+	}
+
+	// Hold the file for the whole read-modify-write, in case another agent writes it too
+	unlock, err := LockFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
 	// Check if file exists
-	if _, err := os.Stat(createFileInput.Path); err == nil {
+	if ws.fileExists(path) {
 		if !createFileInput.Overwrite {
 			return "", fmt.Errorf("file already exists: %s (use overwrite=true to replace)", createFileInput.Path)
 		}
 	}
 
+	// Check before creating directories, which could otherwise land outside through a link
+	if err := ws.checkWriteTarget(path); err != nil {
+		return "", err
+	}
+
 	// Create directory if it doesn't exist
-	dir := filepath.Dir(createFileInput.Path)
-	if dir != "." && dir != "" {
-		err := os.MkdirAll(dir, 0755)
-		if err != nil {
-			return "", fmt.Errorf("failed to create directory: %w", err)
-		}
+	err = ws.mkdirParents(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// The original goes to the trash, where the user can get it back
+	if err := ws.keepReplaced(path, []byte(createFileInput.Content)); err != nil {
+		return "", err
 	}
 
-	err = os.WriteFile(createFileInput.Path, []byte(createFileInput.Content), 0644)
+	err = ws.WriteFile(path, []byte(createFileInput.Content))
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return "", err
 	}
 
 	return fmt.Sprintf("Successfully created file: %s", createFileInput.Path), nil
@@ -254,22 +571,34 @@ var EditFileDefinition = ToolDefinition{
 	- 'append': Append new_str to the end of the file
 	- 'prepend': Prepend new_str to the beginning of the file
 	- 'delete_line': Delete the line containing old_str
+	- 'insert_at_line': Insert new_str so it starts at line_number (one past the last line appends)
+	- 'delete_range': Delete lines start_line to end_line
+	- 'replace_range': Replace lines start_line to end_line with new_str
+	- 'regex_replace': Replace every match of the regular expression old_str (Go RE2 syntax) with new_str, where $1 or ${name} insert capture groups
 	`,
-	InputSchema: EditFileInputSchema,
-	Function:    EditFile,
+	InputSchema:  EditFileInputSchema,
+	Function:     EditFile,
+	EditsInPlace: true,
 }
 
 type EditFileInput struct {
 	Path       string `json:"path" jsonschema_description:"The path to the file to edit."`
-	Mode       string `json:"mode" jsonschema_description:"Edit mode: 'replace', 'insert_after', 'insert_before', 'append', 'prepend', or 'delete_line'."`
+	Mode       string `json:"mode" jsonschema_description:"Edit mode: 'replace', 'insert_after', 'insert_before', 'append', 'prepend', 'delete_line', 'insert_at_line', 'delete_range', 'replace_range' or 'regex_replace'."`
 	OldStr     string `json:"old_str,omitempty" jsonschema_description:"Text to search for (required for replace, insert_after, insert_before, delete_line modes)."`
 	NewStr     string `json:"new_str,omitempty" jsonschema_description:"Text to insert/replace with (required for replace, insert_after, insert_before, append, prepend modes)."`
-	LineNumber *int   `json:"line_number,omitempty" jsonschema_description:"Specific line number for insert operations (1-based, optional alternative to old_str)."`
+	LineNumber *int   `json:"line_number,omitempty" jsonschema_description:"Specific line number for insert operations (1-based, optional alternative to old_str). Required for insert_at_line."`
+	StartLine  *int   `json:"start_line,omitempty" jsonschema_description:"First line of the range for delete_range and replace_range (1-based, inclusive)."`
+	EndLine    *int   `json:"end_line,omitempty" jsonschema_description:"Last line of the range for delete_range and replace_range (1-based, inclusive)."`
+
+	MaxReplacements *int `json:"max_replacements,omitempty" jsonschema_description:"For regex_replace: fail without changing the file if the pattern matches more often than this. Defaults to 100."`
 }
 
+// defaultMaxReplacements guards regex_replace against patterns that match far more than intended
+const defaultMaxReplacements = 100
+
 var EditFileInputSchema = GenerateSchema[EditFileInput]()
 
-func EditFile(input json.RawMessage) (string, error) {
+func EditFile(ws *Workspace, input json.RawMessage) (string, error) {
 	editFileInput := EditFileInput{}
 	err := json.Unmarshal(input, &editFileInput)
 	if err != nil {
@@ -280,12 +609,24 @@ func EditFile(input json.RawMessage) (string, error) {
 		return "", fmt.Errorf("path is required")
 	}
 
+	path, err := ws.Resolve(editFileInput.Path)
+	if err != nil {
+		return "", err
+	}
+
+	// Hold the file for the whole read-modify-write, in case another agent writes it too
+	unlock, err := LockFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
 	if editFileInput.Mode == "" {
 		return "", fmt.Errorf("mode is required")
 	}
 
 	// Validate mode
-	validModes := []string{"replace", "insert_after", "insert_before", "append", "prepend", "delete_line"}
+	validModes := []string{"replace", "insert_after", "insert_before", "append", "prepend", "delete_line", "insert_at_line", "delete_range", "replace_range", "regex_replace"}
 	isValidMode := false
 	for _, mode := range validModes {
 		if editFileInput.Mode == mode {
@@ -298,12 +639,25 @@ func EditFile(input json.RawMessage) (string, error) {
 	}
 
 	// Read existing file
-	content, err := os.ReadFile(editFileInput.Path)
+	data, err := ws.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	lines := strings.Split(string(content), "\n")
+	// Edit with "\n" line endings, as the model writes them, and restore the
+	// file's own line endings when saving
+	ending := lineEnding(string(data))
+	content := toLF(string(data))
+	editFileInput.OldStr = toLF(editFileInput.OldStr)
+	editFileInput.NewStr = toLF(editFileInput.NewStr)
+
+	lines := strings.Split(content, "\n")
+
+	// A trailing newline ends the last line rather than starting another
+	lineCount := len(lines)
+	if strings.HasSuffix(content, "\n") {
+		lineCount--
+	}
 
 	switch editFileInput.Mode {
 	case "append":
@@ -326,7 +680,7 @@ func EditFile(input json.RawMessage) (string, error) {
 			return "", fmt.Errorf("old_str and new_str must be different")
 		}
 
-		originalContent := string(content)
+		originalContent := content
 		newContent := strings.Replace(originalContent, editFileInput.OldStr, editFileInput.NewStr, -1)
 
 		// Count occurrences to ensure exactly one match
@@ -338,12 +692,45 @@ func EditFile(input json.RawMessage) (string, error) {
 			return "", fmt.Errorf("old_str found %d times, expected exactly 1 occurrence for safety", occurrences)
 		}
 
-		err = os.WriteFile(editFileInput.Path, []byte(newContent), 0644)
+		err = ws.WriteFile(path, []byte(withLineEnding(newContent, ending)))
 		if err != nil {
-			return "", fmt.Errorf("failed to write file: %w", err)
+			return "", err
 		}
 		return "Successfully replaced text in file", nil
 
+	case "regex_replace":
+		if editFileInput.OldStr == "" {
+			return "", fmt.Errorf("old_str (the pattern) is required for regex_replace mode")
+		}
+		pattern, err := regexp.Compile(editFileInput.OldStr)
+		if err != nil {
+			return "", fmt.Errorf("invalid pattern: %w", err)
+		}
+
+		maxReplacements := defaultMaxReplacements
+		if editFileInput.MaxReplacements != nil {
+			maxReplacements = *editFileInput.MaxReplacements
+		}
+
+		matches := len(pattern.FindAllStringIndex(content, -1))
+		if matches == 0 {
+			return "", fmt.Errorf("pattern matched nothing in the file")
+		}
+		if matches > maxReplacements {
+			return "", fmt.Errorf("pattern matched %d times, more than max_replacements (%d); nothing was changed", matches, maxReplacements)
+		}
+
+		newContent := pattern.ReplaceAllString(content, editFileInput.NewStr)
+		if newContent == content {
+			return "", fmt.Errorf("pattern matched %d times, but the replacement leaves the file unchanged", matches)
+		}
+
+		err = ws.WriteFile(path, []byte(withLineEnding(newContent, ending)))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Successfully replaced %d matches in file", matches), nil
+
 	case "insert_after", "insert_before", "delete_line":
 		if editFileInput.NewStr == "" && editFileInput.Mode != "delete_line" {
 			return "", fmt.Errorf("new_str is required for %s mode", editFileInput.Mode)
@@ -402,15 +789,48 @@ func EditFile(input json.RawMessage) (string, error) {
 			lines = newLines
 		}
 
+	case "insert_at_line":
+		if editFileInput.LineNumber == nil {
+			return "", fmt.Errorf("line_number is required for insert_at_line mode")
+		}
+		if editFileInput.NewStr == "" {
+			return "", fmt.Errorf("new_str is required for insert_at_line mode")
+		}
+		lineNumber := *editFileInput.LineNumber
+		if lineNumber < 1 || lineNumber > lineCount+1 {
+			return "", fmt.Errorf("line_number %d is out of range (1-%d)", lineNumber, lineCount+1)
+		}
+
+		inserted := strings.Split(strings.TrimSuffix(editFileInput.NewStr, "\n"), "\n")
+		lines = slices.Insert(lines, lineNumber-1, inserted...)
+
+	case "delete_range", "replace_range":
+		if editFileInput.StartLine == nil || editFileInput.EndLine == nil {
+			return "", fmt.Errorf("start_line and end_line are required for %s mode", editFileInput.Mode)
+		}
+		if editFileInput.Mode == "replace_range" && editFileInput.NewStr == "" {
+			return "", fmt.Errorf("new_str is required for replace_range mode (use delete_range to remove lines)")
+		}
+		start, end := *editFileInput.StartLine, *editFileInput.EndLine
+		if start < 1 || end > lineCount || start > end {
+			return "", fmt.Errorf("line range %d-%d is out of range (the file has %d lines)", start, end, lineCount)
+		}
+
+		var replacement []string
+		if editFileInput.Mode == "replace_range" {
+			replacement = strings.Split(strings.TrimSuffix(editFileInput.NewStr, "\n"), "\n")
+		}
+		lines = slices.Replace(lines, start-1, end, replacement...)
+
 	default:
 		return "", fmt.Errorf("unsupported mode: %s", editFileInput.Mode)
 	}
 
 	// Write the modified content back to file
 	newContent := strings.Join(lines, "\n")
-	err = os.WriteFile(editFileInput.Path, []byte(newContent), 0644)
+	err = ws.WriteFile(path, []byte(withLineEnding(newContent, ending)))
 	if err != nil {
-		return "", fmt.Errorf("failed to write file: %w", err)
+		return "", err
 	}
 
 	return fmt.Sprintf("Successfully edited file using %s mode", editFileInput.Mode), nil
@@ -432,7 +852,7 @@ type AppendToFileInput struct {
 
 var AppendToFileInputSchema = GenerateSchema[AppendToFileInput]()
 
-func AppendToFile(input json.RawMessage) (string, error) {
+func AppendToFile(ws *Workspace, input json.RawMessage) (string, error) {
 	appendInput := AppendToFileInput{}
 	err := json.Unmarshal(input, &appendInput)
 	if err != nil {
@@ -443,21 +863,28 @@ func AppendToFile(input json.RawMessage) (string, error) {
 		return "", fmt.Errorf("path is required")
 	}
 
-	// Create directory if it doesn't exist
-	dir := filepath.Dir(appendInput.Path)
-	if dir != "." && dir != "" {
-		err := os.MkdirAll(dir, 0755)
-		if err != nil {
-			return "", fmt.Errorf("failed to create directory: %w", err)
-		}
+	path, err := ws.Resolve(appendInput.Path)
+	if err != nil {
+		return "", err
 	}
 
-	// Open file for appending, create if it doesn't exist
-	file, err := os.OpenFile(appendInput.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	// Hold the file for the whole read-modify-write, in case another agent writes it too
+	unlock, err := LockFile(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to open file: %w", err)
+		return "", err
+	}
+	defer unlock()
+
+	// Check before creating directories, which could otherwise land outside through a link
+	if err := ws.checkWriteTarget(path); err != nil {
+		return "", err
+	}
+
+	// Create directory if it doesn't exist
+	err = ws.mkdirParents(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
 	}
-	defer file.Close()
 
 	// Check if we need to add a newline (default to true)
 	addNewline := true
@@ -465,48 +892,179 @@ func AppendToFile(input json.RawMessage) (string, error) {
 		addNewline = appendInput.NewLine
 	}
 
-	// Check if file has content and doesn't end with newline
-	if addNewline {
+	data := appendInput.Content
+	ending := "\n"
+
+	// Check if file has content and doesn't end with newline, and match its line endings
+	if staged, ok := ws.stagedContent(path); ok {
+		ending = lineEnding(string(staged))
+		if addNewline && len(staged) > 0 && staged[len(staged)-1] != '\n' {
+			data = "\n" + data
+		}
+	} else if file, err := os.Open(path); err == nil {
+		ending = readLineEnding(file)
 		stat, err := file.Stat()
-		if err == nil && stat.Size() > 0 {
+		if addNewline && err == nil && stat.Size() > 0 {
 			// Read the last byte to check if it's a newline
-			_, err = file.Seek(-1, 2) // Seek to last byte
-			if err == nil {
-				lastByte := make([]byte, 1)
-				_, err = file.Read(lastByte)
-				if err == nil && lastByte[0] != '\n' {
-					_, err = file.WriteString("\n")
-					if err != nil {
-						return "", fmt.Errorf("failed to write newline: %w", err)
-					}
-				}
-			}
-			// Seek back to end for appending
-			_, err = file.Seek(0, 2)
-			if err != nil {
-				return "", fmt.Errorf("failed to seek to end: %w", err)
+			lastByte := make([]byte, 1)
+			_, err = file.ReadAt(lastByte, stat.Size()-1)
+			if err == nil && lastByte[0] != '\n' {
+				data = "\n" + data
 			}
 		}
+		file.Close()
 	}
+	data = withLineEnding(data, ending)
 
-	_, err = file.WriteString(appendInput.Content)
+	// Append to the file, creating it if it doesn't exist
+	err = ws.AppendFile(path, []byte(data))
 	if err != nil {
-		return "", fmt.Errorf("failed to append content: %w", err)
+		return "", err
 	}
 
 	return fmt.Sprintf("Successfully appended content to: %s", appendInput.Path), nil
 }
 
+// CreateDirectory tool definition and implementation
+var CreateDirectoryDefinition = ToolDefinition{
+	Name:        "create_directory",
+	Description: "Create a directory, including any missing parent directories. Succeeds if the directory already exists.",
+	InputSchema: CreateDirectoryInputSchema,
+	Function:    CreateDirectory,
+}
+
+type CreateDirectoryInput struct {
+	Path string `json:"path" jsonschema_description:"The path of the directory to create."`
+}
+
+var CreateDirectoryInputSchema = GenerateSchema[CreateDirectoryInput]()
+
+func CreateDirectory(ws *Workspace, input json.RawMessage) (string, error) {
+	createDirInput := CreateDirectoryInput{}
+	err := json.Unmarshal(input, &createDirInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if createDirInput.Path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	path, err := ws.Resolve(createDirInput.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if !info.IsDir() {
+			return "", fmt.Errorf("%s exists and is not a directory", createDirInput.Path)
+		}
+		return fmt.Sprintf("Directory already exists: %s", createDirInput.Path), nil
+	}
+
+	if ws.Staging() {
+		return "", ErrDryRun
+	}
+
+	// Check before creating directories, which could otherwise land outside through a link
+	if err := ws.checkWriteTarget(path); err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	return fmt.Sprintf("Successfully created directory: %s", createDirInput.Path), nil
+}
+
+// TouchFile tool definition and implementation
+var TouchFileDefinition = ToolDefinition{
+	Name:        "touch_file",
+	Description: "Create an empty file, including any missing parent directories, e.g. as a placeholder such as .gitkeep. If the file exists, its content is left alone and only its modification time is updated.",
+	InputSchema: TouchFileInputSchema,
+	Function:    TouchFile,
+}
+
+type TouchFileInput struct {
+	Path string `json:"path" jsonschema_description:"The path of the file to create or touch."`
+}
+
+var TouchFileInputSchema = GenerateSchema[TouchFileInput]()
+
+func TouchFile(ws *Workspace, input json.RawMessage) (string, error) {
+	touchInput := TouchFileInput{}
+	err := json.Unmarshal(input, &touchInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if touchInput.Path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	path, err := ws.Resolve(touchInput.Path)
+	if err != nil {
+		return "", err
+	}
+
+	unlock, err := LockFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if err := ws.checkWriteTarget(path); err != nil {
+		return "", err
+	}
+
+	if ws.IsStaged(path) {
+		return fmt.Sprintf("File already exists as a staged change: %s", touchInput.Path), nil
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return "", fmt.Errorf("%s is a directory", touchInput.Path)
+		}
+		if ws.Staging() {
+			return "", ErrDryRun
+		}
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return "", fmt.Errorf("failed to update modification time: %w", err)
+		}
+		return fmt.Sprintf("Updated modification time of existing file: %s", touchInput.Path), nil
+	}
+
+	err = ws.mkdirParents(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	err = ws.WriteFile(path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Successfully created empty file: %s", touchInput.Path), nil
+}
+
 // GetFileInfo tool definition and implementation
 var GetFileInfoDefinition = ToolDefinition{
 	Name:        "get_file_info",
-	Description: "Get information about a file or directory (size, permissions, modification time, etc.).",
+	Description: "Get information about files or directories: size, line count, permissions, owner, modification time, detected language, whether git tracks it and the last commit that touched it. Pass paths to describe several at once as a compact table.",
 	InputSchema: GetFileInfoInputSchema,
 	Function:    GetFileInfo,
+	ReadOnly:    true,
 }
 
+// maxFileInfoPaths caps how many paths one get_file_info call describes
+const maxFileInfoPaths = 50
+
 type GetFileInfoInput struct {
-	Path string `json:"path" jsonschema_description:"The path to get information about."`
+	Path  string   `json:"path,omitempty" jsonschema_description:"The path to get information about."`
+	Paths []string `json:"paths,omitempty" jsonschema_description:"Several paths to get information about at once, returned as a table. Up to 50."`
 }
 
 var GetFileInfoInputSchema = GenerateSchema[GetFileInfoInput]()
@@ -519,44 +1077,93 @@ type FileInfo struct {
 	ModTime     string `json:"mod_time"`
 	LineCount   *int   `json:"line_count,omitempty"`
 	Exists      bool   `json:"exists"`
+	Owner       string `json:"owner,omitempty"`
+	Language    string `json:"language,omitempty"`
+	GitTracked  *bool  `json:"git_tracked,omitempty"`
+	LastCommit  string `json:"last_commit,omitempty"`
 }
 
-func GetFileInfo(input json.RawMessage) (string, error) {
+func GetFileInfo(ws *Workspace, input json.RawMessage) (string, error) {
 	getFileInfoInput := GetFileInfoInput{}
 	err := json.Unmarshal(input, &getFileInfoInput)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse input: %w", err)
 	}
 
-	if getFileInfoInput.Path == "" {
-		return "", fmt.Errorf("path is required")
+	if len(getFileInfoInput.Paths) == 0 {
+		if getFileInfoInput.Path == "" {
+			return "", fmt.Errorf("path or paths is required")
+		}
+
+		fileInfo, err := statFileInfo(ws, getFileInfoInput.Path)
+		if err != nil {
+			return "", err
+		}
+
+		result, err := json.Marshal(fileInfo)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal result: %w", err)
+		}
+
+		return string(result), nil
+	}
+
+	paths := getFileInfoInput.Paths
+	if getFileInfoInput.Path != "" {
+		paths = append([]string{getFileInfoInput.Path}, paths...)
+	}
+	if len(paths) > maxFileInfoPaths {
+		return "", fmt.Errorf("%d paths given; ask for at most %d per call", len(paths), maxFileInfoPaths)
 	}
 
-	info, err := os.Stat(getFileInfoInput.Path)
-	fileInfo := FileInfo{
-		Path:   getFileInfoInput.Path,
-		Exists: err == nil,
+	var b strings.Builder
+	b.WriteString("path | type | size | lines | modified | mode | owner | language | git | last commit\n")
+	for _, path := range paths {
+		fileInfo, err := statFileInfo(ws, path)
+		switch {
+		case err != nil:
+			fmt.Fprintf(&b, "%s | error: %s\n", path, err)
+		case !fileInfo.Exists:
+			fmt.Fprintf(&b, "%s | missing\n", path)
+		default:
+			b.WriteString(fileInfoRow(fileInfo) + "\n")
+		}
+	}
+
+	return b.String(), nil
+}
+
+// statFileInfo describes one path
+func statFileInfo(ws *Workspace, relPath string) (FileInfo, error) {
+	fileInfo := FileInfo{Path: relPath}
+
+	path, err := ws.Resolve(relPath)
+	if err != nil {
+		return fileInfo, err
 	}
 
+	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			result, marshalErr := json.Marshal(fileInfo)
-			if marshalErr != nil {
-				return "", fmt.Errorf("failed to marshal result: %w", marshalErr)
-			}
-			return string(result), nil
+			return fileInfo, nil
 		}
-		return "", fmt.Errorf("failed to stat file: %w", err)
+		return fileInfo, fmt.Errorf("failed to stat file: %w", err)
 	}
 
+	fileInfo.Exists = true
 	fileInfo.IsDirectory = info.IsDir()
 	fileInfo.Size = info.Size()
 	fileInfo.Mode = info.Mode().String()
 	fileInfo.ModTime = info.ModTime().Format("2006-01-02 15:04:05")
+	fileInfo.Owner = fileOwner(info)
+	fileInfo.GitTracked, fileInfo.LastCommit = gitFileInfo(path, info.IsDir())
 
-	// Get line count for text files
+	// Get line count and language for text files
+	if !info.IsDir() {
+		fileInfo.Language = detectLanguage(path)
+	}
 	if !info.IsDir() && info.Size() > 0 {
-		file, err := os.Open(getFileInfoInput.Path)
+		file, err := os.Open(path)
 		if err == nil {
 			defer file.Close()
 			scanner := bufio.NewScanner(file)
@@ -570,10 +1177,66 @@ func GetFileInfo(input json.RawMessage) (string, error) {
 		}
 	}
 
-	result, err := json.Marshal(fileInfo)
+	return fileInfo, nil
+}
+
+// fileInfoRow formats an existing path's info as a row of the get_file_info table
+func fileInfoRow(fileInfo FileInfo) string {
+	kind := "file"
+	if fileInfo.IsDirectory {
+		kind = "dir"
+	}
+
+	lines := "-"
+	if fileInfo.LineCount != nil {
+		lines = strconv.Itoa(*fileInfo.LineCount)
+	}
+
+	git := "-"
+	if fileInfo.GitTracked != nil {
+		git = "untracked"
+		if *fileInfo.GitTracked {
+			git = "tracked"
+		}
+	}
+
+	columns := []string{
+		fileInfo.Path, kind, strconv.FormatInt(fileInfo.Size, 10), lines, fileInfo.ModTime,
+		fileInfo.Mode, fileInfo.Owner, fileInfo.Language, git, fileInfo.LastCommit,
+	}
+	for i, column := range columns {
+		if column == "" {
+			columns[i] = "-"
+		}
+	}
+
+	return strings.Join(columns, " | ")
+}
+
+// gitFileInfo reports whether git tracks a path, or for a directory any file
+// below it, and the last commit touching it. Both are empty outside a repository.
+func gitFileInfo(path string, isDir bool) (*bool, string) {
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	if isDir {
+		dir, name = path, "."
+	}
+
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		return strings.TrimSpace(string(output)), err
+	}
+
+	files, err := git("ls-files", "--", name)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal result: %w", err)
+		return nil, ""
+	}
+	tracked := files != ""
+	if !tracked {
+		return &tracked, ""
 	}
 
-	return string(result), nil
+	commit, _ := git("log", "-1", "--date=short", "--format=%h %ad %an: %s", "--", name)
+	return &tracked, commit
 }
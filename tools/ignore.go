@@ -0,0 +1,60 @@
+package tools
+
+import "path"
+
+// DefaultIgnoredDirs are not descended into by recursive listings or watched
+// for changes: version control metadata, dependencies and build output
+var DefaultIgnoredDirs = []string{
+	".git",
+	".hg",
+	".svn",
+	"node_modules",
+	"vendor",
+	"dist",
+	"build",
+	"target",
+	".cache",
+	".next",
+	"__pycache__",
+	".venv",
+}
+
+// DefaultIgnoredDir reports whether a directory name is in DefaultIgnoredDirs
+func DefaultIgnoredDir(name string) bool {
+	for _, dir := range DefaultIgnoredDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIgnore replaces the project's extra ignore globs, which are skipped by
+// recursive listings in addition to DefaultIgnoredDirs
+func (ws *Workspace) SetIgnore(patterns []string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.ignore = append([]string(nil), patterns...)
+}
+
+// Ignored reports whether a slash-separated path, relative to the directory
+// being walked, should be skipped by recursive listings
+func (ws *Workspace) Ignored(relPath string, isDir bool) bool {
+	if isDir {
+		if DefaultIgnoredDir(path.Base(relPath)) || ignoredTrash(relPath) {
+			return true
+		}
+	}
+
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	for _, pattern := range ws.ignore {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languagesByExt maps file extensions to the language they usually hold
+var languagesByExt = map[string]string{
+	".go":     "Go",
+	".py":     "Python",
+	".js":     "JavaScript",
+	".mjs":    "JavaScript",
+	".cjs":    "JavaScript",
+	".jsx":    "JavaScript",
+	".ts":     "TypeScript",
+	".tsx":    "TypeScript",
+	".rs":     "Rust",
+	".java":   "Java",
+	".kt":     "Kotlin",
+	".swift":  "Swift",
+	".c":      "C",
+	".h":      "C",
+	".cc":     "C++",
+	".cpp":    "C++",
+	".hpp":    "C++",
+	".cs":     "C#",
+	".rb":     "Ruby",
+	".php":    "PHP",
+	".scala":  "Scala",
+	".lua":    "Lua",
+	".sh":     "Shell",
+	".bash":   "Shell",
+	".zsh":    "Shell",
+	".ps1":    "PowerShell",
+	".sql":    "SQL",
+	".html":   "HTML",
+	".htm":    "HTML",
+	".css":    "CSS",
+	".scss":   "SCSS",
+	".vue":    "Vue",
+	".svelte": "Svelte",
+	".md":     "Markdown",
+	".json":   "JSON",
+	".yaml":   "YAML",
+	".yml":    "YAML",
+	".toml":   "TOML",
+	".xml":    "XML",
+	".proto":  "Protocol Buffers",
+}
+
+// languagesByName covers files recognized by name rather than extension
+var languagesByName = map[string]string{
+	"Makefile":   "Makefile",
+	"Dockerfile": "Dockerfile",
+	"go.mod":     "Go module",
+	"go.sum":     "Go checksums",
+}
+
+// detectLanguage guesses a file's language from its name, or returns ""
+func detectLanguage(path string) string {
+	name := filepath.Base(path)
+	if language, ok := languagesByName[name]; ok {
+		return language
+	}
+
+	return languagesByExt[strings.ToLower(filepath.Ext(name))]
+}
+
+// commentSyntax is how a language writes comments, for counting comment lines
+type commentSyntax struct {
+	line       []string
+	blockStart string
+	blockEnd   string
+}
+
+var (
+	cComments    = commentSyntax{line: []string{"//"}, blockStart: "/*", blockEnd: "*/"}
+	hashComments = commentSyntax{line: []string{"#"}}
+	xmlComments  = commentSyntax{blockStart: "<!--", blockEnd: "-->"}
+)
+
+// commentsByLanguage covers the languages detectLanguage knows; languages
+// without comments, such as JSON, are missing
+var commentsByLanguage = map[string]commentSyntax{
+	"Go":               cComments,
+	"JavaScript":       cComments,
+	"TypeScript":       cComments,
+	"Rust":             cComments,
+	"Java":             cComments,
+	"Kotlin":           cComments,
+	"Swift":            cComments,
+	"C":                cComments,
+	"C++":              cComments,
+	"C#":               cComments,
+	"PHP":              {line: []string{"//", "#"}, blockStart: "/*", blockEnd: "*/"},
+	"Scala":            cComments,
+	"CSS":              {blockStart: "/*", blockEnd: "*/"},
+	"SCSS":             cComments,
+	"Protocol Buffers": cComments,
+	"Python":           hashComments,
+	"Ruby":             hashComments,
+	"Shell":            hashComments,
+	"PowerShell":       {line: []string{"#"}, blockStart: "<#", blockEnd: "#>"},
+	"YAML":             hashComments,
+	"TOML":             hashComments,
+	"Makefile":         hashComments,
+	"Dockerfile":       hashComments,
+	"Lua":              {line: []string{"--"}, blockStart: "--[[", blockEnd: "]]"},
+	"SQL":              {line: []string{"--"}, blockStart: "/*", blockEnd: "*/"},
+	"HTML":             xmlComments,
+	"XML":              xmlComments,
+	"Vue":              xmlComments,
+	"Svelte":           xmlComments,
+	"Markdown":         xmlComments,
+	"Go module":        {line: []string{"//"}},
+}
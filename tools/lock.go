@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"agent/log"
+)
+
+// lockTimeout is how long a write waits for another writer to finish with a file
+const lockTimeout = 10 * time.Second
+
+// lockRetryInterval is how often a held lock is tried again
+const lockRetryInterval = 50 * time.Millisecond
+
+// LockFile takes an exclusive advisory lock on a file for the whole of a
+// read-modify-write, so two agents in the same workspace, or two tool calls
+// running at once, can't interleave their writes. The lock is held on a lock
+// file in the user cache directory named after the file's real path, which
+// keeps the workspace clean and is released by the OS if the process dies.
+// It returns a function releasing the lock. Writes go ahead unlocked if the
+// lock file can't be created at all.
+func LockFile(path string) (func(), error) {
+	file, err := openLockFile(path)
+	if err != nil {
+		log.Warn("writing without a file lock", "path", path, "error", err)
+		return func() {}, nil
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for waited := false; ; waited = true {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			return func() {
+				unlock(file)
+				file.Close()
+			}, nil
+		}
+
+		if !waited {
+			log.Debug("waiting for another writer", "path", path)
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, fmt.Errorf("%s is being written by another agent; try again once it is done", path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// openLockFile opens the lock file for path, creating it if needed
+func openLockFile(path string) (*os.File, error) {
+	real, err := realPath(path)
+	if err != nil {
+		real = path
+	}
+
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "cli-agent", "locks")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(real))
+	return os.OpenFile(filepath.Join(dir, hex.EncodeToString(sum[:16])+".lock"), os.O_CREATE|os.O_RDWR, 0600)
+}
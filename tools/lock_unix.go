@@ -0,0 +1,24 @@
+//go:build !windows
+
+package tools
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// tryLock takes an exclusive flock on file without waiting. flock locks
+// belong to the open file, so they also exclude other opens in this process.
+func tryLock(file *os.File) (bool, error) {
+	err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+	if errors.Is(err, unix.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlock(file *os.File) {
+	unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
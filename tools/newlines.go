@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"io"
+	"strings"
+)
+
+// lineEnding returns the line ending a file uses: "\r\n" when its first line
+// ends that way, as files written on Windows usually do, otherwise "\n"
+func lineEnding(content string) string {
+	line, _, found := strings.Cut(content, "\n")
+	if found && strings.HasSuffix(line, "\r") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// toLF converts Windows line endings to "\n", so text from the model, which
+// always uses "\n", can be matched against files written on Windows
+func toLF(text string) string {
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
+
+// withLineEnding converts "\n"-separated text to the given line ending
+func withLineEnding(text, ending string) string {
+	if ending == "\n" {
+		return text
+	}
+	return strings.ReplaceAll(toLF(text), "\n", ending)
+}
+
+// readLineEnding detects the line ending of an open file from its first
+// line, without reading all of a large file
+func readLineEnding(file io.ReaderAt) string {
+	head := make([]byte, 4096)
+	n, _ := file.ReadAt(head, 0)
+	return lineEnding(string(head[:n]))
+}
@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"agent/config"
+)
+
+// WorkspaceOverview tool definition and implementation
+var WorkspaceOverviewDefinition = ToolDefinition{
+	Name:        "workspace_overview",
+	Description: "Get an overview of the working directory: a map of its top-level directories with file counts, the main languages, the detected build and test commands and the contents of key manifests such as go.mod or package.json. Call this first when starting work on an unfamiliar project instead of exploring with list_files.",
+	InputSchema: WorkspaceOverviewInputSchema,
+	Function:    WorkspaceOverview,
+	ReadOnly:    true,
+}
+
+type WorkspaceOverviewInput struct {
+	Refresh bool `json:"refresh,omitempty" jsonschema_description:"Rebuild the overview instead of using the one computed when the session started."`
+}
+
+var WorkspaceOverviewInputSchema = GenerateSchema[WorkspaceOverviewInput]()
+
+// manifestFiles are included in the overview when present in the root
+var manifestFiles = []string{
+	"go.mod",
+	"package.json",
+	"Cargo.toml",
+	"pyproject.toml",
+	"requirements.txt",
+	"Gemfile",
+	"composer.json",
+	"pom.xml",
+	"build.gradle",
+	"build.gradle.kts",
+	"Makefile",
+}
+
+const (
+	// maxManifestBytes caps how much of each manifest is included
+	maxManifestBytes = 4096
+	// maxOverviewFiles stops the repo map walk in huge trees
+	maxOverviewFiles = 200000
+	// mapDepth is how many directory levels the repo map shows
+	mapDepth = 2
+)
+
+// overviewCache holds the overview for one workspace root, computed once
+type overviewCache struct {
+	root string
+	once sync.Once
+	text string
+}
+
+// Prewarm starts building the workspace overview in the background, so the
+// first workspace_overview call doesn't have to wait for it
+func (ws *Workspace) Prewarm() {
+	cache := ws.overviewCache(false)
+	go cache.once.Do(func() {
+		cache.text = buildOverview(ws, cache.root)
+	})
+}
+
+// Overview returns the workspace overview, building it if needed
+func (ws *Workspace) Overview(refresh bool) string {
+	cache := ws.overviewCache(refresh)
+	cache.once.Do(func() {
+		cache.text = buildOverview(ws, cache.root)
+	})
+
+	return cache.text
+}
+
+// overviewCache returns the cache for the current root, replacing it when the
+// root changed or a refresh is requested
+func (ws *Workspace) overviewCache(refresh bool) *overviewCache {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if refresh || ws.overview == nil || ws.overview.root != ws.root {
+		ws.overview = &overviewCache{root: ws.root}
+	}
+
+	return ws.overview
+}
+
+func WorkspaceOverview(ws *Workspace, input json.RawMessage) (string, error) {
+	workspaceOverviewInput := WorkspaceOverviewInput{}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &workspaceOverviewInput); err != nil {
+			return "", fmt.Errorf("failed to parse input: %w", err)
+		}
+	}
+
+	return ws.Overview(workspaceOverviewInput.Refresh), nil
+}
+
+// buildOverview renders the overview of root as Markdown
+func buildOverview(ws *Workspace, root string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Workspace overview: %s\n", root)
+
+	dirCounts, extCounts, truncated := countFiles(ws, root)
+
+	b.WriteString("\n## Directories\n")
+	dirs := make([]string, 0, len(dirCounts))
+	for dir := range dirCounts {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		depth := strings.Count(dir, "/")
+		fmt.Fprintf(&b, "%s- %s/ (%d files)\n", strings.Repeat("  ", depth), dir, dirCounts[dir])
+	}
+	if len(dirs) == 0 {
+		b.WriteString("(no subdirectories)\n")
+	}
+	if truncated {
+		fmt.Fprintf(&b, "(stopped counting after %d files)\n", maxOverviewFiles)
+	}
+
+	if languages := topExtensions(extCounts, 8); len(languages) > 0 {
+		b.WriteString("\n## File types\n")
+		b.WriteString(strings.Join(languages, ", ") + "\n")
+	}
+
+	if commands := detectCommands(root); len(commands) > 0 {
+		b.WriteString("\n## Build and test commands\n")
+		for _, command := range commands {
+			b.WriteString("- " + command + "\n")
+		}
+	}
+
+	for _, name := range manifestFiles {
+		content, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			continue
+		}
+
+		text := string(content)
+		if len(text) > maxManifestBytes {
+			text = strings.ToValidUTF8(text[:maxManifestBytes], "") + "\n[truncated]"
+		}
+		fmt.Fprintf(&b, "\n## %s\n```\n%s\n```\n", name, strings.TrimRight(text, "\n"))
+	}
+
+	return b.String()
+}
+
+// countFiles counts the files below each directory up to mapDepth levels
+// deep, and the files of each extension, skipping ignored directories
+func countFiles(ws *Workspace, root string) (map[string]int, map[string]int, bool) {
+	dirCounts := map[string]int{}
+	extCounts := map[string]int{}
+	total := 0
+	truncated := false
+
+	ws.walkParallel(root, func(relPath string, entry fs.DirEntry) error {
+		relPath = filepath.ToSlash(relPath)
+		if ws.Ignored(relPath, entry.IsDir()) {
+			return filepath.SkipDir
+		}
+
+		if entry.IsDir() {
+			if strings.Count(relPath, "/") < mapDepth {
+				dirCounts[relPath] += 0
+			}
+			return nil
+		}
+
+		total++
+		if total > maxOverviewFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		// Count the file towards each of its ancestors shown in the map
+		parts := strings.Split(relPath, "/")
+		for i := 1; i < len(parts) && i <= mapDepth; i++ {
+			dirCounts[strings.Join(parts[:i], "/")]++
+		}
+
+		if ext := filepath.Ext(entry.Name()); ext != "" && ext != entry.Name() {
+			extCounts[ext]++
+		}
+		return nil
+	})
+
+	return dirCounts, extCounts, truncated
+}
+
+// topExtensions returns the most common extensions with their counts
+func topExtensions(counts map[string]int, limit int) []string {
+	exts := make([]string, 0, len(counts))
+	for ext := range counts {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if counts[exts[i]] != counts[exts[j]] {
+			return counts[exts[i]] > counts[exts[j]]
+		}
+		return exts[i] < exts[j]
+	})
+
+	top := []string{}
+	for _, ext := range exts[:min(limit, len(exts))] {
+		top = append(top, fmt.Sprintf("%s (%d)", ext, counts[ext]))
+	}
+	return top
+}
+
+// detectCommands guesses how the project is built and tested, preferring the
+// checks configured in the project config
+func detectCommands(root string) []string {
+	commands := []string{}
+
+	if projectConfig, err := config.LoadProjectConfig(root); err == nil {
+		for _, check := range projectConfig.Checks {
+			name := check.Name
+			if name == "" {
+				name = "check"
+			}
+			commands = append(commands, fmt.Sprintf("%s: %s (project config)", name, check.Command))
+		}
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+
+	if exists("go.mod") {
+		commands = append(commands, "build: go build ./...", "test: go test ./...")
+	}
+
+	if exists("package.json") {
+		runner := nodeRunner(exists)
+
+		var pkg struct {
+			Scripts map[string]string `json:"scripts"`
+		}
+		if data, err := os.ReadFile(filepath.Join(root, "package.json")); err == nil && json.Unmarshal(data, &pkg) == nil {
+			for _, script := range []string{"build", "test", "lint"} {
+				if _, ok := pkg.Scripts[script]; ok {
+					commands = append(commands, fmt.Sprintf("%s: %s run %s", script, runner, script))
+				}
+			}
+		}
+	}
+
+	if exists("Cargo.toml") {
+		commands = append(commands, "build: cargo build", "test: cargo test")
+	}
+
+	if exists("pyproject.toml") || exists("requirements.txt") {
+		commands = append(commands, "test: pytest")
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, "Makefile")); err == nil {
+		for _, target := range []string{"build", "test", "lint"} {
+			if strings.Contains(string(data), "\n"+target+":") || strings.HasPrefix(string(data), target+":") {
+				commands = append(commands, fmt.Sprintf("%s: make %s", target, target))
+			}
+		}
+	}
+
+	return commands
+}
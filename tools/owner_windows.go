@@ -0,0 +1,8 @@
+package tools
+
+import "io/fs"
+
+// fileOwner is unknown on Windows, where files are owned through ACLs
+func fileOwner(info fs.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// maxQueryResults caps how many results query_json returns
+	maxQueryResults = 1000
+	// queryTimeout stops expressions that loop forever, such as `repeat(.)`
+	queryTimeout = 5 * time.Second
+)
+
+// QueryJSON tool definition and implementation
+var QueryJSONDefinition = ToolDefinition{
+	Name:        "query_json",
+	Description: "Evaluate a jq expression against a JSON, JSON Lines or YAML file and return the results, e.g. `.dependencies | keys` or `.packages[] | select(.name == \"x\") | .version`. Use it to inspect large config, lock or data files without reading them whole. YAML files are queried like JSON, as yq does; a file with several YAML documents or JSON values is queried once per document.",
+	InputSchema: QueryJSONInputSchema,
+	Function:    QueryJSON,
+	ReadOnly:    true,
+}
+
+type QueryJSONInput struct {
+	Path       string `json:"path" jsonschema_description:"The JSON or YAML file to query."`
+	Expression string `json:"expression" jsonschema_description:"The jq expression to evaluate, e.g. .scripts or .items[] | .name."`
+	Format     string `json:"format,omitempty" jsonschema_description:"The file's format, json or yaml. Defaults to the one its extension suggests, and json for other extensions."`
+	Raw        bool   `json:"raw,omitempty" jsonschema_description:"Print string results without quotes, like jq -r. Defaults to false."`
+}
+
+var QueryJSONInputSchema = GenerateSchema[QueryJSONInput]()
+
+func QueryJSON(ws *Workspace, input json.RawMessage) (string, error) {
+	queryInput := QueryJSONInput{}
+	if err := json.Unmarshal(input, &queryInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if queryInput.Path == "" || queryInput.Expression == "" {
+		return "", fmt.Errorf("path and expression are required")
+	}
+
+	query, err := gojq.Parse(queryInput.Expression)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression: %w", err)
+	}
+	code, err := gojq.Compile(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression: %w", err)
+	}
+
+	path, err := ws.Resolve(queryInput.Path)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	format := strings.ToLower(queryInput.Format)
+	if format == "" {
+		format = "json"
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+			format = "yaml"
+		}
+	}
+
+	var documents []any
+	switch format {
+	case "json":
+		documents, err = decodeJSONDocuments(content)
+	case "yaml":
+		documents, err = decodeYAMLDocuments(content)
+	default:
+		return "", fmt.Errorf("unknown format %q; use json or yaml", queryInput.Format)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s as %s: %w", queryInput.Path, format, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	results := []string{}
+	for _, document := range documents {
+		iter := code.RunWithContext(ctx, document)
+		for {
+			value, ok := iter.Next()
+			if !ok {
+				break
+			}
+			if err, ok := value.(error); ok {
+				if errors.Is(err, context.DeadlineExceeded) {
+					return "", fmt.Errorf("the expression didn't finish within %s", queryTimeout)
+				}
+				return "", fmt.Errorf("query failed: %w", err)
+			}
+
+			if len(results) == maxQueryResults {
+				results = append(results, fmt.Sprintf("(stopped after %d results)", maxQueryResults))
+				return strings.Join(results, "\n"), nil
+			}
+			results = append(results, formatQueryResult(value, queryInput.Raw))
+		}
+	}
+
+	if len(results) == 0 {
+		return "(no results)", nil
+	}
+	return strings.Join(results, "\n"), nil
+}
+
+// formatQueryResult prints a result as indented JSON, or a string as is in raw mode
+func formatQueryResult(value any, raw bool) string {
+	if text, ok := value.(string); ok && raw {
+		return text
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// decodeJSONDocuments decodes every JSON value in content, so JSON Lines
+// files work as well as single documents
+func decodeJSONDocuments(content []byte) ([]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	documents := []any{}
+	for {
+		var document any
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// decodeYAMLDocuments decodes every document of a YAML stream into the
+// JSON-like values jq works on
+func decodeYAMLDocuments(content []byte) ([]any, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	documents := []any{}
+	for {
+		var document any
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, normalizeYAML(document))
+	}
+}
+
+// normalizeYAML converts the values yaml.v3 decodes that jq doesn't know,
+// such as maps with non-string keys and timestamps
+func normalizeYAML(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, item := range value {
+			value[key] = normalizeYAML(item)
+		}
+		return value
+	case map[any]any:
+		converted := make(map[string]any, len(value))
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return converted
+	case []any:
+		for i, item := range value {
+			value[i] = normalizeYAML(item)
+		}
+		return value
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case uint64:
+		return float64(value)
+	default:
+		return value
+	}
+}
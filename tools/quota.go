@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+)
+
+// WriteLimits caps how much data the agent may write. Zero disables a limit.
+type WriteLimits struct {
+	MaxFileBytes    int64
+	MaxSessionBytes int64
+}
+
+// DefaultWriteLimits protects against runaway output while leaving room for normal work
+var DefaultWriteLimits = WriteLimits{
+	MaxFileBytes:    10 << 20,
+	MaxSessionBytes: 100 << 20,
+}
+
+// QuotaError is returned when a write would exceed the workspace write limits
+type QuotaError struct {
+	Path   string
+	Reason string
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("write to %s refused: %s", e.Path, e.Reason)
+}
+
+// SetLimits replaces the write limits
+func (ws *Workspace) SetLimits(limits WriteLimits) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.limits = limits
+}
+
+// Limits returns the current write limits
+func (ws *Workspace) Limits() WriteLimits {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.limits
+}
+
+// BytesWritten returns the total bytes written by tools this session
+func (ws *Workspace) BytesWritten() int64 {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.written
+}
+
+// AllowNextWrite lets the next write bypass the limits once, after the user approved an override
+func (ws *Workspace) AllowNextWrite() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.override = true
+}
+
+// reserveWrite checks a write of n bytes resulting in a file of fileSize bytes
+// against the limits and records it on success
+func (ws *Workspace) reserveWrite(path string, fileSize, n int64) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.override {
+		ws.override = false
+		ws.written += n
+		return nil
+	}
+
+	if ws.limits.MaxFileBytes > 0 && fileSize > ws.limits.MaxFileBytes {
+		return &QuotaError{
+			Path:   path,
+			Reason: fmt.Sprintf("file would be %d bytes, exceeding the %d byte per-file limit", fileSize, ws.limits.MaxFileBytes),
+		}
+	}
+
+	if ws.limits.MaxSessionBytes > 0 && ws.written+n > ws.limits.MaxSessionBytes {
+		return &QuotaError{
+			Path:   path,
+			Reason: fmt.Sprintf("session has written %d bytes and this write of %d bytes would exceed the %d byte session limit", ws.written, n, ws.limits.MaxSessionBytes),
+		}
+	}
+
+	ws.written += n
+	return nil
+}
+
+// WriteFile writes data to an already resolved path, enforcing the write
+// limits and refusing to follow symlinks out of the workspace
+func (ws *Workspace) WriteFile(path string, data []byte) error {
+	if err := ws.checkWriteTarget(path); err != nil {
+		return err
+	}
+	if err := ws.reserveWrite(path, int64(len(data)), int64(len(data))); err != nil {
+		return err
+	}
+
+	if ws.Staging() {
+		ws.stage(path, data)
+		return nil
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// AppendFile appends data to an already resolved path, creating it if needed,
+// with the same checks as WriteFile
+func (ws *Workspace) AppendFile(path string, data []byte) error {
+	if err := ws.checkWriteTarget(path); err != nil {
+		return err
+	}
+
+	var existing int64
+	if content, ok := ws.stagedContent(path); ok {
+		existing = int64(len(content))
+	} else if info, err := os.Stat(path); err == nil {
+		existing = info.Size()
+	}
+
+	if err := ws.reserveWrite(path, existing+int64(len(data)), int64(len(data))); err != nil {
+		return err
+	}
+
+	if ws.Staging() {
+		current, err := ws.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
+		ws.stage(path, append(current, data...))
+		return nil
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("failed to append content: %w", err)
+	}
+
+	return nil
+}
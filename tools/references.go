@@ -0,0 +1,382 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxReferences caps how many references find_references reports
+	maxReferences = 200
+	// maxReferenceFiles stops find_references in huge trees
+	maxReferenceFiles = 50000
+	// maxReferenceFileBytes skips files too large to be hand-written source
+	maxReferenceFileBytes = 1 << 20
+)
+
+// FindReferences tool definition and implementation
+var FindReferencesDefinition = ToolDefinition{
+	Name:        "find_references",
+	Description: "Find where an identifier is defined and used across the workspace, as a lighter alternative to a language server. Matches whole identifiers only and ignores occurrences in comments and strings, and marks the lines that look like its definition. It is a heuristic: it can't tell apart different symbols with the same name, so check the results when a name is common.",
+	InputSchema: FindReferencesInputSchema,
+	Function:    FindReferences,
+	ReadOnly:    true,
+}
+
+type FindReferencesInput struct {
+	Symbol          string `json:"symbol" jsonschema_description:"The identifier to find, e.g. a function, type or variable name. A qualified name such as pkg.Name finds Name."`
+	Path            string `json:"path,omitempty" jsonschema_description:"The directory to search. Defaults to the working directory."`
+	Include         string `json:"include,omitempty" jsonschema_description:"Only search files matching this glob, e.g. *.go or src/**/*.ts."`
+	IncludeComments bool   `json:"include_comments,omitempty" jsonschema_description:"Also report occurrences in comments and strings, e.g. to find mentions in docs. Defaults to false."`
+}
+
+var FindReferencesInputSchema = GenerateSchema[FindReferencesInput]()
+
+// nonCodeLanguages are skipped unless an include glob asks for them
+var nonCodeLanguages = map[string]bool{
+	"Markdown": true, "JSON": true, "YAML": true, "TOML": true, "XML": true,
+	"Go module": true, "Go checksums": true,
+}
+
+// identifierPattern is what find_references can search for
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][\w$]*$`)
+
+// reference is one occurrence of the symbol
+type reference struct {
+	line       int
+	text       string
+	definition bool
+}
+
+func FindReferences(ws *Workspace, input json.RawMessage) (string, error) {
+	refsInput := FindReferencesInput{}
+	if err := json.Unmarshal(input, &refsInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	// Look for the last part of a qualified name, which is what the code spells out
+	name := refsInput.Symbol
+	if i := strings.LastIndexAny(name, ".:"); i >= 0 {
+		name = name[i+1:]
+	}
+	if !identifierPattern.MatchString(name) {
+		return "", fmt.Errorf("symbol must be an identifier, got %q", refsInput.Symbol)
+	}
+
+	dir := refsInput.Path
+	if dir == "" {
+		dir = "."
+	}
+	root, err := ws.Resolve(dir)
+	if err != nil {
+		return "", err
+	}
+
+	pattern := regexp.MustCompile(`(^|[^\w$])` + regexp.QuoteMeta(name) + `($|[^\w$])`)
+
+	results := map[string][]reference{}
+	total := 0
+	definitions := 0
+	seen := 0
+	truncated := false
+
+	err = ws.walkParallel(root, func(relPath string, entry fs.DirEntry) error {
+		relPath = filepath.ToSlash(relPath)
+		if ws.Ignored(relPath, entry.IsDir()) {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if refsInput.Include != "" && !MatchGlob(refsInput.Include, relPath) {
+			return nil
+		}
+
+		// Without a glob only source files are searched, not data or docs
+		language := detectLanguage(relPath)
+		if refsInput.Include == "" && (language == "" || nonCodeLanguages[language]) {
+			return nil
+		}
+
+		seen++
+		if seen > maxReferenceFiles {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		refs := fileReferences(filepath.Join(root, relPath), name, pattern, language, refsInput.IncludeComments)
+		if len(refs) == 0 {
+			return nil
+		}
+
+		for _, ref := range refs {
+			if ref.definition {
+				definitions++
+			}
+		}
+		total += len(refs)
+		results[relPath] = refs
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if total == 0 {
+		return fmt.Sprintf("No references to %s found in %s", name, dir), nil
+	}
+
+	files := make([]string, 0, len(results))
+	for file := range results {
+		files = append(files, file)
+	}
+	// Files defining the symbol come first, then the rest by path
+	sort.Slice(files, func(i, j int) bool {
+		di, dj := hasDefinition(results[files[i]]), hasDefinition(results[files[j]])
+		if di != dj {
+			return di
+		}
+		return files[i] < files[j]
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d references to %s in %d files (%d look like definitions)\n", total, name, len(files), definitions)
+
+	shown := 0
+	for _, file := range files {
+		if shown >= maxReferences {
+			break
+		}
+		prefix := file
+		if dir != "." {
+			prefix = filepath.ToSlash(filepath.Join(dir, file))
+		}
+		b.WriteString("\n" + prefix + "\n")
+		for _, ref := range results[file] {
+			if shown >= maxReferences {
+				break
+			}
+			marker := ""
+			if ref.definition {
+				marker = " [definition]"
+			}
+			fmt.Fprintf(&b, "  L%d%s: %s\n", ref.line, marker, ref.text)
+			shown++
+		}
+	}
+
+	if shown < total {
+		fmt.Fprintf(&b, "\n(showing %d of %d; narrow the search with path or include)\n", shown, total)
+	}
+	if truncated {
+		fmt.Fprintf(&b, "\n(stopped searching after %d files)\n", maxReferenceFiles)
+	}
+
+	return b.String(), nil
+}
+
+func hasDefinition(refs []reference) bool {
+	for _, ref := range refs {
+		if ref.definition {
+			return true
+		}
+	}
+	return false
+}
+
+// fileReferences finds the symbol in one file, skipping comments and strings
+// unless asked to keep them
+func fileReferences(path, name string, pattern *regexp.Regexp, language string, includeComments bool) []reference {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxReferenceFileBytes {
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(content, 0) >= 0 || !bytes.Contains(content, []byte(name)) {
+		return nil
+	}
+
+	definitions := definitionPatterns(language, name)
+	masker := codeMasker{syntax: commentsByLanguage[language], charLiterals: language == "Rust"}
+
+	refs := []reference{}
+	for i, line := range strings.Split(toLF(string(content)), "\n") {
+		code := line
+		if !includeComments {
+			code = masker.mask(line)
+		}
+		if !pattern.MatchString(code) {
+			continue
+		}
+
+		ref := reference{line: i + 1, text: strings.TrimSpace(line)}
+		if len(ref.text) > 200 {
+			ref.text = ref.text[:200] + "…"
+		}
+		for _, definition := range definitions {
+			if definition.MatchString(code) {
+				ref.definition = true
+				break
+			}
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+// definitionTemplates are patterns for lines that define %s, by language
+var definitionTemplates = map[string][]string{
+	"Go": {
+		`\bfunc\s+(\([^)]*\)\s*)?%s\b`,
+		`\btype\s+%s\b`,
+		`\b(var|const)\s+%s\b`,
+		`^\s*%s(\s*,\s*\w+)*\s*(:=|\s+\S+\s*=|\s+=)`,
+		`^\s*%s\s+(\*?[\w.\[\]]+|struct|interface|func)\b[^=(]*$`,
+	},
+	"Python": {
+		`\b(def|class)\s+%s\b`,
+		`^\s*%s\s*(:[^=]+)?=[^=]`,
+	},
+	"JavaScript": {
+		`\bfunction\s*\*?\s*%s\b`,
+		`\bclass\s+%s\b`,
+		`\b(const|let|var)\s+%s\b`,
+		`^\s*(static\s+|async\s+|get\s+|set\s+)*%s\s*\([^)]*\)\s*\{`,
+	},
+	"TypeScript": {
+		`\bfunction\s*\*?\s*%s\b`,
+		`\b(class|interface|type|enum|namespace)\s+%s\b`,
+		`\b(const|let|var)\s+%s\b`,
+		`^\s*(public\s+|private\s+|protected\s+|static\s+|async\s+|readonly\s+|get\s+|set\s+)*%s\s*(<[^>]*>)?\([^)]*\)\s*(:[^{]+)?\{`,
+	},
+	"Rust": {
+		`\bfn\s+%s\b`,
+		`\b(struct|enum|trait|type|mod|const|static|union)\s+%s\b`,
+		`\blet\s+(mut\s+)?%s\b`,
+	},
+	"Java": {
+		`\b(class|interface|enum|record)\s+%s\b`,
+		`[\w<>\[\],?]+\s+%s\s*\([^;]*\)\s*(\{|throws\b|$)`,
+	},
+	"C#": {
+		`\b(class|interface|enum|record|struct)\s+%s\b`,
+		`[\w<>\[\],?]+\s+%s\s*\([^;]*\)\s*(\{|=>|$)`,
+	},
+	"Kotlin": {
+		`\b(class|interface|object|fun|val|var|typealias)\s+%s\b`,
+	},
+	"Swift": {
+		`\b(class|struct|enum|protocol|func|let|var|typealias)\s+%s\b`,
+	},
+	"Scala": {
+		`\b(class|object|trait|def|val|var|type)\s+%s\b`,
+	},
+	"C": {
+		`^[\w\s\*]*\b%s\s*\([^;]*$`,
+		`\b(struct|union|enum)\s+%s\b`,
+		`#define\s+%s\b`,
+	},
+	"C++": {
+		`^[\w\s\*&:<>,~]*\b%s\s*\([^;]*$`,
+		`\b(struct|class|union|enum|namespace)\s+%s\b`,
+		`#define\s+%s\b`,
+	},
+	"PHP": {
+		`\bfunction\s+&?%s\b`,
+		`\b(class|interface|trait|enum)\s+%s\b`,
+	},
+	"Ruby": {
+		`\bdef\s+(self\.)?%s\b`,
+		`\b(class|module)\s+%s\b`,
+		`^\s*%s\s*=[^=]`,
+	},
+	"Shell": {
+		`^\s*(function\s+)?%s\s*\(\)`,
+		`^\s*(export\s+)?%s=`,
+	},
+}
+
+// definitionPatterns compiles the definition patterns of a language for name
+func definitionPatterns(language, name string) []*regexp.Regexp {
+	patterns := []*regexp.Regexp{}
+	for _, template := range definitionTemplates[language] {
+		patterns = append(patterns, regexp.MustCompile(fmt.Sprintf(template, regexp.QuoteMeta(name))))
+	}
+	return patterns
+}
+
+// codeMasker blanks out the comments and string literals of source lines,
+// remembering block comments and raw strings across lines, so only code is searched
+type codeMasker struct {
+	syntax       commentSyntax
+	charLiterals bool
+	inComment    bool
+	quote        byte
+}
+
+func (m *codeMasker) mask(line string) string {
+	out := []byte(line)
+
+	blank := func(from, to int) {
+		for i := from; i < to && i < len(out); i++ {
+			out[i] = ' '
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case m.inComment:
+			if strings.HasPrefix(line[i:], m.syntax.blockEnd) {
+				blank(i, i+len(m.syntax.blockEnd))
+				i += len(m.syntax.blockEnd) - 1
+				m.inComment = false
+			} else {
+				out[i] = ' '
+			}
+
+		case m.quote != 0:
+			if line[i] == '\\' && m.quote != '`' && i+1 < len(line) {
+				blank(i, i+2)
+				i++
+			} else if line[i] == m.quote {
+				m.quote = 0
+			} else {
+				out[i] = ' '
+			}
+
+		case m.syntax.blockStart != "" && strings.HasPrefix(line[i:], m.syntax.blockStart):
+			blank(i, i+len(m.syntax.blockStart))
+			i += len(m.syntax.blockStart) - 1
+			m.inComment = true
+
+		case hasAnyPrefix(line[i:], m.syntax.line):
+			blank(i, len(line))
+			return string(out)
+
+		case line[i] == '"' || line[i] == '`':
+			m.quote = line[i]
+
+		case line[i] == '\'':
+			// Rust lifetimes also use ', so there only short char literals are strings
+			if !m.charLiterals || (i+2 < len(line) && line[i+2] == '\'') || (i+1 < len(line) && line[i+1] == '\\') {
+				m.quote = line[i]
+			}
+		}
+	}
+
+	// Only backtick strings, such as Go raw strings and JavaScript templates, span lines
+	if m.quote != '`' {
+		m.quote = 0
+	}
+
+	return string(out)
+}
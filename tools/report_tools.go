@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ReportProblem tool definition and implementation. It isn't part of
+// GetAllTools: headless modes that collect problems add it explicitly and
+// read the reports from the tool calls.
+var ReportProblemDefinition = ToolDefinition{
+	Name:        "report_problem",
+	Description: "Report a problem you found in the code, such as a bug, a failing check or a rule violation. Call it once per problem, with the most precise location you can.",
+	InputSchema: ReportProblemInputSchema,
+	Function:    ReportProblem,
+	ReadOnly:    true,
+}
+
+type ReportProblemInput struct {
+	Path     string `json:"path" jsonschema_description:"The file the problem is in, relative to the working directory."`
+	Line     int    `json:"line,omitempty" jsonschema_description:"The 1-based line number of the problem, if known."`
+	Severity string `json:"severity,omitempty" jsonschema_description:"One of 'error', 'warning' or 'notice'. Defaults to 'error'."`
+	Title    string `json:"title,omitempty" jsonschema_description:"A short title for the problem."`
+	Message  string `json:"message" jsonschema_description:"What is wrong and how to fix it."`
+}
+
+var ReportProblemInputSchema = GenerateSchema[ReportProblemInput]()
+
+func ReportProblem(ws *Workspace, input json.RawMessage) (string, error) {
+	reportProblemInput := ReportProblemInput{}
+	err := json.Unmarshal(input, &reportProblemInput)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if reportProblemInput.Message == "" {
+		return "", fmt.Errorf("message is required")
+	}
+
+	switch reportProblemInput.Severity {
+	case "", "error", "warning", "notice":
+	default:
+		return "", fmt.Errorf("invalid severity %q: use error, warning or notice", reportProblemInput.Severity)
+	}
+
+	if reportProblemInput.Path != "" {
+		if _, err := ws.Resolve(reportProblemInput.Path); err != nil {
+			return "", err
+		}
+	}
+
+	return "Problem reported.", nil
+}
@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrDryRun is returned by tools whose effects can't be held back as a
+// pending change while dry-run mode is on
+var ErrDryRun = errors.New("not available in dry-run mode, where changes are staged for the user to review; write file contents instead, directories are created along with the files in them")
+
+// StagedFile is a write held back by dry-run mode, to be applied or discarded
+// as part of the pending changeset
+type StagedFile struct {
+	AbsPath string
+	Content []byte
+}
+
+// SetStaging turns dry-run mode on or off. While it is on, writes through the
+// workspace are staged in memory instead of reaching the disk. Turning it off
+// leaves any staged changes pending.
+func (ws *Workspace) SetStaging(on bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.staging = on
+}
+
+// Staging reports whether dry-run mode is on
+func (ws *Workspace) Staging() bool {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.staging
+}
+
+// Staged returns the pending changeset, ordered by path
+func (ws *Workspace) Staged() []StagedFile {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	staged := make([]StagedFile, 0, len(ws.staged))
+	for path, content := range ws.staged {
+		staged = append(staged, StagedFile{AbsPath: path, Content: append([]byte(nil), content...)})
+	}
+	sort.Slice(staged, func(i, j int) bool { return staged[i].AbsPath < staged[j].AbsPath })
+
+	return staged
+}
+
+// ReplaceStaged swaps the pending changeset for an earlier copy, e.g. when a checkpoint is restored
+func (ws *Workspace) ReplaceStaged(files []StagedFile) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.staged = nil
+	for _, file := range files {
+		if ws.staged == nil {
+			ws.staged = map[string][]byte{}
+		}
+		ws.staged[file.AbsPath] = append([]byte(nil), file.Content...)
+	}
+}
+
+// ClearStaged drops the pending changeset, after it was applied or discarded
+func (ws *Workspace) ClearStaged() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.staged = nil
+}
+
+// StagedCount returns the number of files with a pending change
+func (ws *Workspace) StagedCount() int {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return len(ws.staged)
+}
+
+// IsStaged reports whether path has a pending change
+func (ws *Workspace) IsStaged(path string) bool {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	_, ok := ws.staged[path]
+	return ok
+}
+
+// stage holds back a write; callers have already checked the target and quota
+func (ws *Workspace) stage(path string, data []byte) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.staged == nil {
+		ws.staged = map[string][]byte{}
+	}
+	ws.staged[path] = append([]byte(nil), data...)
+}
+
+// unstage restores a path's pending change to an earlier state, as returned
+// by stagedContent, e.g. to roll back a failed batch
+func (ws *Workspace) unstage(path string, content []byte, wasStaged bool) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if wasStaged {
+		ws.staged[path] = content
+	} else {
+		delete(ws.staged, path)
+	}
+}
+
+// stagedContent returns the pending content of path, if it has any
+func (ws *Workspace) stagedContent(path string) ([]byte, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	content, ok := ws.staged[path]
+	return content, ok
+}
+
+// ReadFile reads an already resolved path, seeing pending dry-run changes
+// the way the disk will look once they are applied
+func (ws *Workspace) ReadFile(path string) ([]byte, error) {
+	if content, ok := ws.stagedContent(path); ok {
+		return append([]byte(nil), content...), nil
+	}
+
+	return os.ReadFile(path)
+}
+
+// WriteStaged writes a staged file to disk as its change is applied. The
+// target is checked like any other write and the content it replaces goes to
+// the trash; the write quota was already charged when the file was staged.
+func (ws *Workspace) WriteStaged(file StagedFile) error {
+	if err := ws.checkWriteTarget(file.AbsPath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file.AbsPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := ws.keepOriginal(file.AbsPath, file.Content); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file.AbsPath, file.Content, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// fileExists reports whether path exists on disk or as a staged new file
+func (ws *Workspace) fileExists(path string) bool {
+	if _, ok := ws.stagedContent(path); ok {
+		return true
+	}
+
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// mkdirParents creates the directories a file will be written into. In
+// dry-run mode they are created when the change is applied instead.
+func (ws *Workspace) mkdirParents(path string) error {
+	if ws.Staging() {
+		return nil
+	}
+
+	return os.MkdirAll(filepath.Dir(path), 0755)
+}
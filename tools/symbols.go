@@ -0,0 +1,518 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSymbolFileBytes skips files too large to outline
+const maxSymbolFileBytes = 2 << 20
+
+// GetSymbols tool definition and implementation
+var GetSymbolsDefinition = ToolDefinition{
+	Name:        "get_symbols",
+	Description: "List the functions, methods, types and other top-level declarations of a source file with their line ranges, nested by containment. Use it to find what to read or edit in a large file, then read_file with start_line/end_line or edit_file by line range, instead of reading the whole file. Go files are parsed exactly; Python, JavaScript, TypeScript, Rust, Java, Kotlin, C#, Swift, Scala, C, C++, PHP and Ruby are outlined by pattern, so unusual formatting can be missed.",
+	InputSchema: GetSymbolsInputSchema,
+	Function:    GetSymbols,
+	ReadOnly:    true,
+}
+
+type GetSymbolsInput struct {
+	Path string `json:"path" jsonschema_description:"The source file to outline."`
+}
+
+var GetSymbolsInputSchema = GenerateSchema[GetSymbolsInput]()
+
+// symbol is a declaration in a source file
+type symbol struct {
+	kind      string
+	name      string
+	detail    string
+	startLine int
+	endLine   int
+}
+
+func GetSymbols(ws *Workspace, input json.RawMessage) (string, error) {
+	symbolsInput := GetSymbolsInput{}
+	if err := json.Unmarshal(input, &symbolsInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if symbolsInput.Path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	path, err := ws.Resolve(symbolsInput.Path)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to access file: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", symbolsInput.Path)
+	}
+	if info.Size() > maxSymbolFileBytes {
+		return "", fmt.Errorf("%s is too large to outline (%d bytes)", symbolsInput.Path, info.Size())
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	content = []byte(toLF(string(content)))
+
+	language := detectLanguage(path)
+	var symbols []symbol
+	switch {
+	case language == "Go":
+		symbols, err = goSymbols(content)
+		if err != nil {
+			return "", err
+		}
+	case symbolRules[language] != nil:
+		symbols = patternSymbols(string(content), symbolRules[language])
+	default:
+		return "", fmt.Errorf("can't outline %s files; use search or read_file instead", orUnknown(language))
+	}
+
+	lines := bytes.Count(content, []byte("\n"))
+	if len(symbols) == 0 {
+		return fmt.Sprintf("%s (%s, %d lines): no symbols found", symbolsInput.Path, language, lines), nil
+	}
+
+	return formatSymbols(fmt.Sprintf("%s (%s, %d lines)", symbolsInput.Path, language, lines), symbols), nil
+}
+
+func orUnknown(language string) string {
+	if language == "" {
+		return "these"
+	}
+	return language
+}
+
+// formatSymbols lists symbols in file order, indented under the symbols containing them
+func formatSymbols(header string, symbols []symbol) string {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].startLine != symbols[j].startLine {
+			return symbols[i].startLine < symbols[j].startLine
+		}
+		return symbols[i].endLine > symbols[j].endLine
+	})
+
+	var b strings.Builder
+	b.WriteString(header + "\n")
+
+	open := []symbol{}
+	for _, s := range symbols {
+		for len(open) > 0 && open[len(open)-1].endLine < s.startLine {
+			open = open[:len(open)-1]
+		}
+
+		name := s.name
+		if s.detail != "" {
+			name = s.detail
+		}
+		fmt.Fprintf(&b, "%s%s %s  %s\n", strings.Repeat("  ", len(open)), s.kind, name, lineRange(s.startLine, s.endLine))
+
+		if s.endLine > s.startLine {
+			open = append(open, s)
+		}
+	}
+
+	return b.String()
+}
+
+func lineRange(start, end int) string {
+	if start == end {
+		return fmt.Sprintf("L%d", start)
+	}
+	return fmt.Sprintf("L%d-%d", start, end)
+}
+
+// goSymbols outlines a Go file with go/ast, including function signatures
+func goSymbols(content []byte) ([]symbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if file == nil {
+		return nil, fmt.Errorf("failed to parse Go file: %w", err)
+	}
+
+	lineOf := func(pos token.Pos) int {
+		return fset.Position(pos).Line
+	}
+
+	// Doc comments belong to their declaration, so edits by range keep them together
+	start := func(doc *ast.CommentGroup, pos token.Pos) int {
+		if doc != nil {
+			return lineOf(doc.Pos())
+		}
+		return lineOf(pos)
+	}
+
+	symbols := []symbol{}
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "func"
+			if decl.Recv != nil {
+				kind = "method"
+			}
+			signature := *decl
+			signature.Body = nil
+			signature.Doc = nil
+			var buf bytes.Buffer
+			printer.Fprint(&buf, token.NewFileSet(), &signature)
+			detail := strings.TrimPrefix(buf.String(), "func ")
+
+			symbols = append(symbols, symbol{
+				kind:      kind,
+				name:      decl.Name.Name,
+				detail:    detail,
+				startLine: start(decl.Doc, decl.Pos()),
+				endLine:   lineOf(decl.End()),
+			})
+
+		case *ast.GenDecl:
+			if decl.Tok == token.IMPORT {
+				continue
+			}
+			grouped := decl.Lparen.IsValid()
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					kind := "type"
+					switch spec.Type.(type) {
+					case *ast.StructType:
+						kind = "struct"
+					case *ast.InterfaceType:
+						kind = "interface"
+					}
+					doc := spec.Doc
+					if !grouped {
+						doc = decl.Doc
+					}
+					specStart := start(doc, spec.Pos())
+					if !grouped {
+						specStart = start(doc, decl.Pos())
+					}
+					symbols = append(symbols, symbol{kind: kind, name: spec.Name.Name, startLine: specStart, endLine: lineOf(spec.End())})
+					symbols = append(symbols, goFieldSymbols(spec, lineOf)...)
+
+				case *ast.ValueSpec:
+					names := make([]string, len(spec.Names))
+					for i, name := range spec.Names {
+						names[i] = name.Name
+					}
+					doc := spec.Doc
+					if !grouped {
+						doc = decl.Doc
+					}
+					symbols = append(symbols, symbol{
+						kind:      decl.Tok.String(),
+						name:      strings.Join(names, ", "),
+						startLine: start(doc, spec.Pos()),
+						endLine:   lineOf(spec.End()),
+					})
+				}
+			}
+		}
+	}
+
+	return symbols, nil
+}
+
+// goFieldSymbols lists the methods of an interface, whose bodies live nowhere else
+func goFieldSymbols(spec *ast.TypeSpec, lineOf func(token.Pos) int) []symbol {
+	iface, ok := spec.Type.(*ast.InterfaceType)
+	if !ok {
+		return nil
+	}
+
+	symbols := []symbol{}
+	for _, field := range iface.Methods.List {
+		if _, ok := field.Type.(*ast.FuncType); !ok {
+			continue
+		}
+		for _, name := range field.Names {
+			symbols = append(symbols, symbol{kind: "method", name: name.Name, startLine: lineOf(field.Pos()), endLine: lineOf(field.End())})
+		}
+	}
+	return symbols
+}
+
+// symbolRule recognizes a declaration by the line it starts on. The name is
+// the pattern's "name" group.
+type symbolRule struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+// blockStyle is how a language marks where a declaration ends
+type blockStyle int
+
+const (
+	blockBraces blockStyle = iota
+	blockIndent
+	blockEnd
+)
+
+// languageRules are the patterns for one language
+type languageRules struct {
+	block blockStyle
+	rules []symbolRule
+}
+
+func rule(kind, pattern string) symbolRule {
+	return symbolRule{kind: kind, pattern: regexp.MustCompile(pattern)}
+}
+
+// keywords keeps control flow such as "if (x) {" from being taken for a
+// method named "if"
+var keywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"new": true, "else": true, "function": true, "do": true, "try": true, "with": true,
+	"using": true, "lock": true, "foreach": true, "sizeof": true, "typeof": true, "match": true,
+}
+
+var (
+	jsRules = &languageRules{block: blockBraces, rules: []symbolRule{
+		rule("function", `^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[A-Za-z_$][\w$]*)`),
+		rule("class", `^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(?P<name>[A-Za-z_$][\w$]*)`),
+		rule("interface", `^\s*(?:export\s+)?(?:declare\s+)?interface\s+(?P<name>[A-Za-z_$][\w$]*)`),
+		rule("enum", `^\s*(?:export\s+)?(?:declare\s+)?(?:const\s+)?enum\s+(?P<name>[A-Za-z_$][\w$]*)`),
+		rule("type", `^\s*(?:export\s+)?(?:declare\s+)?type\s+(?P<name>[A-Za-z_$][\w$]*)\s*(?:<[^=]*>)?\s*=`),
+		rule("function", `^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|(?:\([^)]*\)|[A-Za-z_$][\w$]*)\s*(?::[^=]+)?=>)`),
+		rule("method", `^\s+(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*(?:\*\s*)?(?P<name>[A-Za-z_$][\w$]*)\s*(?:<[^>]*>)?\([^;]*\)\s*(?::\s*[^{;=]+)?\{\s*$`),
+	}}
+
+	rustRules = &languageRules{block: blockBraces, rules: []symbolRule{
+		rule("fn", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:default\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(?P<name>\w+)`),
+		rule("struct", `^\s*(?:pub(?:\([^)]*\))?\s+)?struct\s+(?P<name>\w+)`),
+		rule("enum", `^\s*(?:pub(?:\([^)]*\))?\s+)?enum\s+(?P<name>\w+)`),
+		rule("trait", `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(?P<name>\w+)`),
+		rule("mod", `^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(?P<name>\w+)\s*\{`),
+		rule("impl", `^\s*(?:unsafe\s+)?impl(?:<[^>]*>)?\s+(?P<name>[^{]+?)\s*(?:where\b.*)?\{?\s*$`),
+		rule("macro", `^\s*macro_rules!\s*(?P<name>\w+)`),
+	}}
+
+	// JVM and .NET languages share their type declarations
+	typeRule = rule("type", `^\s*(?:@\w+(?:\([^)]*\))?\s+)*(?:(?:public|private|protected|internal|static|final|abstract|sealed|open|data|partial|inner|enum|case|annotation|readonly|ref)\s+)*(?P<kind>class|interface|enum|record|struct|object|trait|protocol|extension)\s+(?P<name>\w+)`)
+
+	javaRules = &languageRules{block: blockBraces, rules: []symbolRule{
+		typeRule,
+		rule("method", `^\s*(?:@\w+(?:\([^)]*\))?\s+)*(?:(?:public|private|protected|internal|static|final|abstract|synchronized|native|override|virtual|async|sealed|extern|unsafe|new|default)\s+)*(?:<[^>]+>\s+)?[\w<>\[\],.?]+(?:\s*<[^>]*>)?\s+(?P<name>\w+)\s*\([^;]*$`),
+	}}
+
+	kotlinRules = &languageRules{block: blockBraces, rules: []symbolRule{
+		typeRule,
+		rule("fun", `^\s*(?:(?:public|private|protected|internal|override|open|abstract|suspend|inline|operator|infix|tailrec|static|final|mutating|@\w+)\s+)*(?:fun|func|def)\s+(?:<[^>]*>\s*)?(?:[\w.]+\.)?(?P<name>[\w$]+|`+"`[^`]+`"+`)`),
+	}}
+
+	cRules = &languageRules{block: blockBraces, rules: []symbolRule{
+		rule("type", `^\s*(?:typedef\s+)?(?:template\s*<[^>]*>\s*)?(?P<kind>struct|class|union|enum|namespace)\s+(?:class\s+)?(?P<name>\w+)[^;]*$`),
+		rule("function", `^(?:[\w:*&<>,~]+\s+)+\**&?(?P<name>[\w:~]+)\s*\([^;]*$`),
+	}}
+
+	phpRules = &languageRules{block: blockBraces, rules: []symbolRule{
+		rule("type", `^\s*(?:(?:abstract|final|readonly)\s+)*(?P<kind>class|interface|trait|enum)\s+(?P<name>\w+)`),
+		rule("function", `^\s*(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+&?(?P<name>\w+)`),
+	}}
+
+	pythonRules = &languageRules{block: blockIndent, rules: []symbolRule{
+		rule("class", `^\s*class\s+(?P<name>\w+)`),
+		rule("def", `^\s*(?:async\s+)?def\s+(?P<name>\w+)`),
+	}}
+
+	rubyRules = &languageRules{block: blockEnd, rules: []symbolRule{
+		rule("type", `^\s*(?P<kind>class|module)\s+(?P<name>[\w:]+)`),
+		rule("def", `^\s*def\s+(?P<name>(?:self\.)?[\w?!=\[\]]+)`),
+	}}
+)
+
+// symbolRules are the pattern outlines by language, as named by detectLanguage
+var symbolRules = map[string]*languageRules{
+	"JavaScript": jsRules,
+	"TypeScript": jsRules,
+	"Rust":       rustRules,
+	"Java":       javaRules,
+	"C#":         javaRules,
+	"Kotlin":     kotlinRules,
+	"Swift":      kotlinRules,
+	"Scala":      kotlinRules,
+	"C":          cRules,
+	"C++":        cRules,
+	"PHP":        phpRules,
+	"Python":     pythonRules,
+	"Ruby":       rubyRules,
+}
+
+// patternSymbols outlines a file line by line with a language's rules
+func patternSymbols(content string, rules *languageRules) []symbol {
+	lines := strings.Split(content, "\n")
+	symbols := []symbol{}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "*") {
+			continue
+		}
+
+		for _, r := range rules.rules {
+			match := r.pattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+
+			s := symbol{kind: r.kind, startLine: i + 1}
+			for j, group := range r.pattern.SubexpNames() {
+				switch group {
+				case "name":
+					s.name = strings.TrimSpace(match[j])
+				case "kind":
+					s.kind = match[j]
+				}
+			}
+
+			if (r.kind == "method" || r.kind == "function") && keywords[s.name] {
+				continue
+			}
+
+			var ok bool
+			s.endLine, ok = blockEndLine(lines, i, rules.block)
+			if !ok {
+				// A declaration without a body, such as a call that looks like one
+				break
+			}
+			symbols = append(symbols, s)
+			break
+		}
+	}
+
+	return symbols
+}
+
+// blockEndLine finds the last line of the declaration starting at lines[start].
+// It reports false for a brace language line that ends in ";" before any
+// block opens, which is a prototype or a statement rather than a definition.
+func blockEndLine(lines []string, start int, style blockStyle) (int, bool) {
+	switch style {
+	case blockIndent:
+		indent := indentOf(lines[start])
+		end := start
+		for i := start + 1; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "" {
+				continue
+			}
+			if indentOf(lines[i]) <= indent {
+				break
+			}
+			end = i
+		}
+		return end + 1, true
+
+	case blockEnd:
+		indent := indentOf(lines[start])
+		for i := start + 1; i < len(lines); i++ {
+			trimmed := strings.TrimSpace(lines[i])
+			if indentOf(lines[i]) == indent && (trimmed == "end" || strings.HasPrefix(trimmed, "end ") || strings.HasPrefix(trimmed, "end.")) {
+				return i + 1, true
+			}
+			if trimmed != "" && indentOf(lines[i]) < indent {
+				break
+			}
+		}
+		// One-line definitions such as "def x = 1" end where they start
+		return start + 1, true
+	}
+
+	depth := 0
+	opened := false
+	scanner := braceScanner{}
+	for i := start; i < len(lines); i++ {
+		for _, delta := range scanner.scan(lines[i]) {
+			switch delta {
+			case '{':
+				depth++
+				opened = true
+			case '}':
+				depth--
+			case ';':
+				if !opened && depth == 0 {
+					// Declarations such as Rust's "struct Unit;" or TypeScript types end here
+					return i + 1, i > start || strings.Contains(lines[i], "=") || strings.Contains(lines[i], "struct")
+				}
+			}
+			if opened && depth == 0 {
+				return i + 1, true
+			}
+		}
+		if !opened && i-start >= 10 {
+			// No block within a few lines, so this wasn't a definition
+			return start + 1, false
+		}
+	}
+
+	return len(lines), opened
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// braceScanner returns the braces and semicolons of code lines, skipping
+// strings and comments, and remembers block comments across lines
+type braceScanner struct {
+	inComment bool
+}
+
+func (s *braceScanner) scan(line string) []byte {
+	found := []byte{}
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case s.inComment:
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				s.inComment = false
+				i++
+			}
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return found
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			s.inComment = true
+			i++
+		case c == '"' || c == '`':
+			quote = c
+		case c == '\'':
+			// Rust lifetimes and generics also use ', so only skip char literals like '{'
+			if i+2 < len(line) && line[i+2] == '\'' {
+				i += 2
+			} else if i+3 < len(line) && line[i+1] == '\\' && line[i+3] == '\'' {
+				i += 3
+			}
+		case c == '{' || c == '}' || c == ';':
+			found = append(found, c)
+		}
+	}
+
+	return found
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"agent/config"
+)
+
+// Symlink semantics: paths are checked against the workspace roots as
+// written, so reading through a link is allowed wherever it points. Writes
+// are checked again after resolving links and refused when the file, or the
+// directory it would be created in, really lives outside every root.
+
+// realPath resolves the symlinks in path. When path doesn't exist yet, its
+// deepest existing ancestor is resolved and the rest appended, which is where
+// creating it would put it.
+func realPath(path string) (string, error) {
+	missing := ""
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(real, missing), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		// A dangling link is resolved to where its target would be created
+		if target, err := os.Readlink(path); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+			path = target
+			continue
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// checkWriteTarget refuses a write to an already resolved path when symlinks
+// lead it out of the workspace roots
+func (ws *Workspace) checkWriteTarget(path string) error {
+	real, err := realPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	// Roots may themselves sit below a link, e.g. /tmp on macOS
+	if !withinAny(ws.realRoots(), real) {
+		return fmt.Errorf("refusing to write %s: a symbolic link leads it to %s, outside the workspace", path, real)
+	}
+
+	if name, ok := ws.untrustedRoot(real); ok {
+		return fmt.Errorf("refusing to write %s: the root %s: isn't trusted; open it with cli-agent and trust it first", path, name)
+	}
+	return nil
+}
+
+// untrustedRoot reports the named root a resolved path lies in when it isn't
+// trusted. The primary root's trust is the session's; a path that is also in
+// the primary root or a trusted root counts as trusted.
+func (ws *Workspace) untrustedRoot(real string) (string, bool) {
+	if realRoot, err := filepath.EvalSymlinks(ws.Root()); err == nil && isWithin(realRoot, real) {
+		return "", false
+	}
+
+	untrusted := ""
+	for _, root := range ws.Roots() {
+		realRoot, err := filepath.EvalSymlinks(root.Path)
+		if err != nil || !isWithin(realRoot, real) {
+			continue
+		}
+		if config.LoadTrust(root.Path) == config.TrustGranted {
+			return "", false
+		}
+		untrusted = root.Name
+	}
+
+	return untrusted, untrusted != ""
+}
+
+// RootTrusted reports whether a named root has been trusted, which writes to it need
+func (ws *Workspace) RootTrusted(name string) bool {
+	for _, root := range ws.Roots() {
+		if root.Name == name {
+			return config.LoadTrust(root.Path) == config.TrustGranted
+		}
+	}
+	return false
+}
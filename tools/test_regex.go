@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultRegexMatches is how many matches test_regex lists unless asked for more
+	defaultRegexMatches = 50
+	// maxRegexResult caps how much of a replaced text test_regex shows
+	maxRegexResult = 4000
+)
+
+// TestRegex tool definition and implementation
+var TestRegexDefinition = ToolDefinition{
+	Name:        "test_regex",
+	Description: "Run a regular expression (Go RE2 syntax, the same engine as edit_file's regex_replace mode) against sample text or a file and report every match with its line, column and capture groups. Pass replacement to preview what regex_replace would produce. Use it to check a pattern before putting it in code or an edit. Flags go inline, e.g. (?i) for case-insensitive or (?m) for ^ and $ at line boundaries.",
+	InputSchema: TestRegexInputSchema,
+	Function:    TestRegex,
+	ReadOnly:    true,
+}
+
+type TestRegexInput struct {
+	Pattern     string  `json:"pattern" jsonschema_description:"The regular expression."`
+	Text        string  `json:"text,omitempty" jsonschema_description:"Sample text to match against. Give either text or path."`
+	Path        string  `json:"path,omitempty" jsonschema_description:"A file to match against instead of text."`
+	Replacement *string `json:"replacement,omitempty" jsonschema_description:"Optional replacement, with $1 or ${name} for groups, to preview the result of each match."`
+	MaxMatches  *int    `json:"max_matches,omitempty" jsonschema_description:"How many matches to list. Defaults to 50; all matches are counted."`
+}
+
+var TestRegexInputSchema = GenerateSchema[TestRegexInput]()
+
+func TestRegex(ws *Workspace, input json.RawMessage) (string, error) {
+	regexInput := TestRegexInput{}
+	if err := json.Unmarshal(input, &regexInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if regexInput.Pattern == "" {
+		return "", fmt.Errorf("pattern is required")
+	}
+	if (regexInput.Text == "") == (regexInput.Path == "") {
+		return "", fmt.Errorf("give either text or path")
+	}
+
+	pattern, err := regexp.Compile(regexInput.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	text := regexInput.Text
+	if regexInput.Path != "" {
+		path, err := ws.Resolve(regexInput.Path)
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file: %w", err)
+		}
+		// edit_file matches against LF line endings too
+		text = toLF(string(content))
+	}
+
+	maxMatches := defaultRegexMatches
+	if regexInput.MaxMatches != nil {
+		maxMatches = *regexInput.MaxMatches
+	}
+
+	matches := pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return "No matches", nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d matches", len(matches))
+	if groups := pattern.NumSubexp(); groups > 0 {
+		fmt.Fprintf(&b, ", %d groups", groups)
+	}
+	b.WriteString("\n")
+
+	names := pattern.SubexpNames()
+	for i, match := range matches {
+		if i == maxMatches {
+			fmt.Fprintf(&b, "(%d more matches not shown)\n", len(matches)-maxMatches)
+			break
+		}
+
+		line, column := lineAndColumn(text, match[0])
+		fmt.Fprintf(&b, "%d. L%d:%d %s\n", i+1, line, column, strconv.Quote(text[match[0]:match[1]]))
+
+		for group := 1; group < len(match)/2; group++ {
+			label := strconv.Itoa(group)
+			if names[group] != "" {
+				label += " (" + names[group] + ")"
+			}
+			value := "(no match)"
+			if match[2*group] >= 0 {
+				value = strconv.Quote(text[match[2*group]:match[2*group+1]])
+			}
+			fmt.Fprintf(&b, "   group %s: %s\n", label, value)
+		}
+
+		if regexInput.Replacement != nil {
+			replaced := pattern.ExpandString(nil, *regexInput.Replacement, text, match)
+			fmt.Fprintf(&b, "   replaced with: %s\n", strconv.Quote(string(replaced)))
+		}
+	}
+
+	// For sample text, the whole result is short enough to show
+	if regexInput.Replacement != nil && regexInput.Text != "" {
+		result := pattern.ReplaceAllString(text, *regexInput.Replacement)
+		if len(result) > maxRegexResult {
+			result = result[:maxRegexResult] + "\n[truncated]"
+		}
+		fmt.Fprintf(&b, "\nResult:\n%s\n", result)
+	}
+
+	return b.String(), nil
+}
+
+// lineAndColumn returns the 1-based line and column of a byte offset
+func lineAndColumn(text string, offset int) (int, int) {
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+	return line, column
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ManageTodos tool definition and implementation
+var ManageTodosDefinition = ToolDefinition{
+	Name:        "manage_todos",
+	Description: "Keep a task list for the current job, shown to the user as a live checklist. Use it for work with three or more steps: write the plan as todos before starting, mark one todo in_progress while working on it and completed as soon as it is done, and add todos you discover along the way. Each call replaces the whole list, so always send every todo. Call it without todos to see the current list.",
+	InputSchema: ManageTodosInputSchema,
+	Function:    ManageTodos,
+	ReadOnly:    true,
+	Volatile:    true,
+}
+
+type ManageTodosInput struct {
+	Todos []TodoItem `json:"todos,omitempty" jsonschema_description:"The complete, updated task list in order. Omit it to get the current list."`
+}
+
+// TodoItem is one entry of the agent's task list
+type TodoItem struct {
+	Content string `json:"content" jsonschema_description:"What needs doing, as a short imperative sentence."`
+	Status  string `json:"status" jsonschema:"enum=pending,enum=in_progress,enum=completed" jsonschema_description:"pending, in_progress or completed. Only one todo should be in_progress at a time."`
+}
+
+var ManageTodosInputSchema = GenerateSchema[ManageTodosInput]()
+
+// Todo statuses
+const (
+	TodoPending    = "pending"
+	TodoInProgress = "in_progress"
+	TodoCompleted  = "completed"
+)
+
+// maxTodos keeps the list a plan rather than a log
+const maxTodos = 50
+
+func ManageTodos(ws *Workspace, input json.RawMessage) (string, error) {
+	manageTodosInput := ManageTodosInput{}
+	if err := json.Unmarshal(input, &manageTodosInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if manageTodosInput.Todos == nil {
+		todos := ws.Todos()
+		if len(todos) == 0 {
+			return "The task list is empty.", nil
+		}
+		return FormatTodos(todos), nil
+	}
+
+	if len(manageTodosInput.Todos) > maxTodos {
+		return "", fmt.Errorf("too many todos (%d), keep the list to %d by merging small steps", len(manageTodosInput.Todos), maxTodos)
+	}
+
+	inProgress := 0
+	for i, todo := range manageTodosInput.Todos {
+		manageTodosInput.Todos[i].Content = strings.TrimSpace(todo.Content)
+		if manageTodosInput.Todos[i].Content == "" {
+			return "", fmt.Errorf("todo %d has no content", i+1)
+		}
+		switch todo.Status {
+		case TodoPending, TodoCompleted:
+		case TodoInProgress:
+			inProgress++
+		default:
+			return "", fmt.Errorf("todo %d has invalid status %q, use pending, in_progress or completed", i+1, todo.Status)
+		}
+	}
+	if inProgress > 1 {
+		return "", fmt.Errorf("%d todos are in_progress, finish one before starting the next", inProgress)
+	}
+
+	ws.SetTodos(manageTodosInput.Todos)
+
+	if len(manageTodosInput.Todos) == 0 {
+		return "Cleared the task list.", nil
+	}
+	return "Updated the task list:\n" + FormatTodos(manageTodosInput.Todos), nil
+}
+
+// FormatTodos renders a task list as a plain-text checklist
+func FormatTodos(todos []TodoItem) string {
+	done := 0
+	lines := make([]string, 0, len(todos)+1)
+	for _, todo := range todos {
+		mark := "[ ]"
+		switch todo.Status {
+		case TodoInProgress:
+			mark = "[>]"
+		case TodoCompleted:
+			mark = "[x]"
+			done++
+		}
+		lines = append(lines, mark+" "+todo.Content)
+	}
+
+	return fmt.Sprintf("%d of %d done\n%s", done, len(todos), strings.Join(lines, "\n"))
+}
+
+// Todos returns a copy of the agent's current task list
+func (ws *Workspace) Todos() []TodoItem {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return append([]TodoItem(nil), ws.todos...)
+}
+
+// SetTodos replaces the task list; an empty list clears it
+func (ws *Workspace) SetTodos(todos []TodoItem) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.todos = append([]TodoItem(nil), todos...)
+}
@@ -12,7 +12,21 @@ type ToolDefinition struct {
 	Name        string                         `json:"name"`
 	Description string                         `json:"description"`
 	InputSchema anthropic.ToolInputSchemaParam `json:"input_schema"`
-	Function    func(input json.RawMessage) (string, error)
+	Function    func(ws *Workspace, input json.RawMessage) (string, error)
+	// ReadOnly marks tools that never modify the workspace
+	ReadOnly bool `json:"-"`
+	// EditsInPlace marks tools that change part of an existing file based on
+	// what the model last read, so they must not run against a stale copy
+	EditsInPlace bool `json:"-"`
+	// RunsPrograms marks read-only tools that still execute programs, such
+	// as man, which are only allowed in trusted workspaces
+	RunsPrograms bool `json:"-"`
+	// Volatile marks tools whose result can differ between identical calls
+	// even when no file changed, e.g. the time, so repeated calls always run
+	Volatile bool `json:"-"`
+	// Paths lists the files a call operates on, for tools that don't take a
+	// single "path" argument, so their changes are tracked like any other
+	Paths func(input json.RawMessage) []string `json:"-"`
 }
 
 // GenerateSchema creates a JSON schema for the given type T
@@ -36,8 +50,23 @@ func GetAllTools() []ToolDefinition {
 		ReadFileDefinition,
 		ListFilesDefinition,
 		CreateFileDefinition,
+		WriteFilesDefinition,
+		CreateDirectoryDefinition,
+		TouchFileDefinition,
+		CopyPathDefinition,
 		EditFileDefinition,
 		AppendToFileDefinition,
 		GetFileInfoDefinition,
+		WorkspaceOverviewDefinition,
+		CodeStatsDefinition,
+		DependencyGraphDefinition,
+		GetSymbolsDefinition,
+		FindReferencesDefinition,
+		QueryJSONDefinition,
+		TestRegexDefinition,
+		EvaluateDefinition,
+		CurrentTimeDefinition,
+		CommandHelpDefinition,
+		ManageTodosDefinition,
 	}
 }
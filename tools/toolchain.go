@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Toolchain is a language of the project and the tool that builds it, e.g.
+// Go and go, or TypeScript and pnpm
+type Toolchain struct {
+	Language string
+	Tool     string
+}
+
+// toolchainMarker is a manifest whose presence in the root reveals a toolchain
+type toolchainMarker struct {
+	files     []string
+	toolchain func(exists func(string) bool) Toolchain
+}
+
+// toolchainMarkers are checked in order, so the first found is the main toolchain
+var toolchainMarkers = []toolchainMarker{
+	{[]string{"go.mod"}, func(func(string) bool) Toolchain { return Toolchain{"Go", "go"} }},
+	{[]string{"Cargo.toml"}, func(func(string) bool) Toolchain { return Toolchain{"Rust", "cargo"} }},
+	{[]string{"package.json"}, func(exists func(string) bool) Toolchain {
+		language := "JavaScript"
+		if exists("tsconfig.json") {
+			language = "TypeScript"
+		}
+		return Toolchain{language, nodeRunner(exists)}
+	}},
+	{[]string{"pyproject.toml", "requirements.txt", "setup.py"}, func(exists func(string) bool) Toolchain {
+		switch {
+		case exists("uv.lock"):
+			return Toolchain{"Python", "uv"}
+		case exists("poetry.lock"):
+			return Toolchain{"Python", "poetry"}
+		}
+		return Toolchain{"Python", "pip"}
+	}},
+	{[]string{"Gemfile"}, func(func(string) bool) Toolchain { return Toolchain{"Ruby", "bundler"} }},
+	{[]string{"composer.json"}, func(func(string) bool) Toolchain { return Toolchain{"PHP", "composer"} }},
+	{[]string{"pom.xml"}, func(func(string) bool) Toolchain { return Toolchain{"Java", "maven"} }},
+	{[]string{"build.gradle.kts"}, func(func(string) bool) Toolchain { return Toolchain{"Kotlin", "gradle"} }},
+	{[]string{"build.gradle"}, func(func(string) bool) Toolchain { return Toolchain{"Java", "gradle"} }},
+	{[]string{"CMakeLists.txt"}, func(func(string) bool) Toolchain { return Toolchain{"C++", "cmake"} }},
+}
+
+// DetectToolchains returns the toolchains whose manifests are in root, the
+// main one first
+func DetectToolchains(root string) []Toolchain {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+
+	toolchains := []Toolchain{}
+	for _, marker := range toolchainMarkers {
+		for _, file := range marker.files {
+			if exists(file) {
+				toolchains = append(toolchains, marker.toolchain(exists))
+				break
+			}
+		}
+	}
+	return toolchains
+}
+
+// nodeRunner picks the package manager of a Node.js project from its lock file
+func nodeRunner(exists func(string) bool) string {
+	switch {
+	case exists("pnpm-lock.yaml"):
+		return "pnpm"
+	case exists("yarn.lock"):
+		return "yarn"
+	case exists("bun.lockb"):
+		return "bun"
+	}
+	return "npm"
+}
@@ -0,0 +1,329 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashDir holds files the agent overwrote or deleted, relative to the root
+// they were in. Each batch goes in its own timestamped directory below it,
+// mirroring the files' paths in the root.
+var TrashDir = filepath.Join(".cli-agent", "trash")
+
+// trashTimeLayout names a batch directory; it sorts by time and is valid on every OS
+const trashTimeLayout = "2006-01-02T15-04-05.000"
+
+// TrashItem is a file kept in the trash
+type TrashItem struct {
+	// Path is the original path as tools address it
+	Path string
+	// AbsPath is where the file is restored to
+	AbsPath string
+	// Trashed is where the file is kept in the trash
+	Trashed string
+	Time    time.Time
+	Size    int64
+
+	// batch tells apart batches trashed within the same millisecond
+	batch int
+}
+
+// keepInTrash saves the content a file had before it was overwritten. Files
+// that already are in the trash are skipped.
+func (ws *Workspace) keepInTrash(path string, content []byte) error {
+	root, ok := ws.containingRoot(path)
+	if !ok {
+		return nil
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || inTrash(rel) {
+		return nil
+	}
+
+	target, err := ws.trashTarget(root, rel)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(target, content, 0644); err != nil {
+		return fmt.Errorf("failed to keep %s in the trash: %w", rel, err)
+	}
+
+	return nil
+}
+
+// keepReplaced saves a file's current content in the trash before data
+// replaces it. Missing files and writes that change nothing are skipped, as
+// is everything while staging, which doesn't touch the disk.
+func (ws *Workspace) keepReplaced(path string, data []byte) error {
+	if ws.Staging() {
+		return nil
+	}
+
+	return ws.keepOriginal(path, data)
+}
+
+// keepOriginal saves a file's current content in the trash unless it is missing or equals data
+func (ws *Workspace) keepOriginal(path string, data []byte) error {
+	old, err := os.ReadFile(path)
+	if err != nil || bytes.Equal(old, data) {
+		return nil
+	}
+
+	return ws.keepInTrash(path, old)
+}
+
+// Trash moves a file into the trash instead of deleting it
+func (ws *Workspace) Trash(path string) error {
+	root, ok := ws.containingRoot(path)
+	if !ok {
+		return fmt.Errorf("path %s is outside the workspace roots", path)
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	if inTrash(rel) {
+		return fmt.Errorf("%s is already in the trash", rel)
+	}
+
+	target, err := ws.trashTarget(root, rel)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(path, target); err != nil {
+		return fmt.Errorf("failed to move %s to the trash: %w", rel, err)
+	}
+
+	return nil
+}
+
+// trashDir returns the trash directory of root, refusing it when symbolic
+// links lead it out of the workspace roots
+func (ws *Workspace) trashDir(root string) (string, error) {
+	trash := filepath.Join(root, TrashDir)
+	if err := ws.checkWriteTarget(trash); err != nil {
+		return "", fmt.Errorf("not using the trash: %w", err)
+	}
+	return trash, nil
+}
+
+// trashTarget creates a new batch directory for rel and returns where to keep it
+func (ws *Workspace) trashTarget(root, rel string) (string, error) {
+	trash, err := ws.trashDir(root)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(trash, 0755); err != nil {
+		return "", fmt.Errorf("failed to create the trash: %w", err)
+	}
+
+	// Keep the trash out of version control
+	ignore := filepath.Join(trash, ".gitignore")
+	if _, err := os.Stat(ignore); errors.Is(err, fs.ErrNotExist) {
+		os.WriteFile(ignore, []byte("*\n"), 0644)
+	}
+
+	batch := time.Now().Format(trashTimeLayout)
+	target := filepath.Join(trash, batch, rel)
+	for n := 2; ; n++ {
+		if _, err := os.Lstat(target); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		target = filepath.Join(trash, fmt.Sprintf("%s-%d", batch, n), rel)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create the trash: %w", err)
+	}
+	if err := ws.checkWriteTarget(target); err != nil {
+		return "", fmt.Errorf("not using the trash: %w", err)
+	}
+
+	return target, nil
+}
+
+// inTrash reports whether a root-relative path lies inside the trash
+func inTrash(rel string) bool {
+	return rel == TrashDir || strings.HasPrefix(rel, TrashDir+string(filepath.Separator))
+}
+
+// TrashItems lists the files in the trash of every root, newest first
+func (ws *Workspace) TrashItems() ([]TrashItem, error) {
+	items := []TrashItem{}
+
+	roots := append([]Root{{Path: ws.Root()}}, ws.Roots()...)
+	for _, root := range roots {
+		trash, err := ws.trashDir(root.Path)
+		if err != nil {
+			continue
+		}
+		batches, err := os.ReadDir(trash)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, batch := range batches {
+			if !batch.IsDir() {
+				continue
+			}
+			stamp, suffix := batch.Name(), ""
+			if len(stamp) > len(trashTimeLayout) {
+				stamp, suffix = stamp[:len(trashTimeLayout)], strings.TrimPrefix(stamp[len(trashTimeLayout):], "-")
+			}
+			trashed, err := time.ParseInLocation(trashTimeLayout, stamp, time.Local)
+			if err != nil {
+				continue
+			}
+			seq, _ := strconv.Atoi(suffix)
+
+			batchDir := filepath.Join(trash, batch.Name())
+			err = filepath.WalkDir(batchDir, func(walked string, entry fs.DirEntry, err error) error {
+				if err != nil || entry.IsDir() {
+					return err
+				}
+				info, err := entry.Info()
+				if err != nil {
+					return err
+				}
+
+				rel, _ := filepath.Rel(batchDir, walked)
+				display := filepath.ToSlash(rel)
+				if root.Name != "" {
+					display = root.Name + ":" + display
+				}
+
+				items = append(items, TrashItem{
+					Path:    display,
+					AbsPath: filepath.Join(root.Path, rel),
+					Trashed: walked,
+					Time:    trashed,
+					Size:    info.Size(),
+					batch:   seq,
+				})
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].Time.Equal(items[j].Time) {
+			return items[i].Time.After(items[j].Time)
+		}
+		if items[i].batch != items[j].batch {
+			return items[i].batch > items[j].batch
+		}
+		return items[i].Path < items[j].Path
+	})
+
+	return items, nil
+}
+
+// RestoreTrash moves an item back to where it came from. A file that has
+// since taken its place goes into the trash first, so nothing is lost.
+func (ws *Workspace) RestoreTrash(item TrashItem) error {
+	if err := ws.checkWriteTarget(item.AbsPath); err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(item.AbsPath)
+	switch {
+	case err == nil && info.IsDir():
+		return fmt.Errorf("%s is now a directory", item.Path)
+	case err == nil:
+		if err := ws.Trash(item.AbsPath); err != nil {
+			return err
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(item.AbsPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(item.Trashed, item.AbsPath); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", item.Path, err)
+	}
+
+	if root, ok := ws.containingRoot(item.AbsPath); ok {
+		removeEmptyDirs(filepath.Dir(item.Trashed), filepath.Join(root, TrashDir))
+	}
+	return nil
+}
+
+// removeEmptyDirs removes dir and its parents while they are empty, stopping at stop
+func removeEmptyDirs(dir, stop string) {
+	for dir != stop && isWithin(stop, dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// EmptyTrash permanently deletes everything in the trash of every root,
+// returning how many files were deleted. Links in the trash are removed,
+// never followed, and a trash that links lead out of the workspace is left
+// alone.
+func (ws *Workspace) EmptyTrash() (int, error) {
+	items, err := ws.TrashItems()
+	if err != nil {
+		return 0, err
+	}
+
+	roots := append([]Root{{Path: ws.Root()}}, ws.Roots()...)
+	for _, root := range roots {
+		trash, err := ws.trashDir(root.Path)
+		if err != nil {
+			return 0, err
+		}
+
+		info, err := os.Lstat(trash)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !info.IsDir() {
+			return 0, fmt.Errorf("not emptying %s: it isn't a directory", trash)
+		}
+
+		entries, err := os.ReadDir(trash)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			// RemoveAll deletes links themselves and doesn't descend through them
+			if err := os.RemoveAll(filepath.Join(trash, entry.Name())); err != nil {
+				return 0, err
+			}
+		}
+		os.Remove(trash)
+	}
+
+	return len(items), nil
+}
+
+// ignoredTrash reports whether a slash-separated directory path is a trash directory
+func ignoredTrash(relPath string) bool {
+	trash := filepath.ToSlash(TrashDir)
+	return relPath == trash || strings.HasSuffix(relPath, "/"+trash)
+}
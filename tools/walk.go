@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// walkWorkers bounds how many directories are read concurrently
+const walkWorkers = 8
+
+// walkParallel walks the tree below root, reading directories with a bounded
+// pool of goroutines. visit is called for every entry with its path relative
+// to root; calls are serialized, but their order is not deterministic.
+// Returning filepath.SkipDir from visit skips a directory's contents and
+// filepath.SkipAll stops the walk. Directories that can't be read are
+// skipped; only a failure to read root itself is returned.
+//
+// Symlinks to directories outside the tree but inside another workspace root
+// are followed and reported as directories whose Type still has
+// fs.ModeSymlink set. Links out of the workspace, into the tree, to one of
+// their own ancestors or to a directory already followed are reported as they
+// are and not descended into, so links can neither leave the workspace nor
+// make the walk loop.
+func (ws *Workspace) walkParallel(root string, visit func(relPath string, entry fs.DirEntry) error) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	rootReal, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+	workspaceRoots := ws.realRoots()
+
+	var (
+		mu       sync.Mutex
+		cond     = sync.NewCond(&mu)
+		queue    []string
+		pending  int // directories queued or being read
+		stopped  bool
+		followed = map[string]bool{}
+	)
+
+	// linkTarget returns the directory a symlink should be followed to, if any.
+	// Callers must hold mu.
+	linkTarget := func(relPath string) (string, bool) {
+		target, err := filepath.EvalSymlinks(filepath.Join(root, relPath))
+		if err != nil || followed[target] || isWithin(rootReal, target) || !withinAny(workspaceRoots, target) {
+			return "", false
+		}
+		if info, err := os.Stat(target); err != nil || !info.IsDir() {
+			return "", false
+		}
+
+		parent, err := filepath.EvalSymlinks(filepath.Join(root, filepath.Dir(relPath)))
+		if err != nil || isWithin(target, parent) {
+			return "", false
+		}
+
+		return target, true
+	}
+
+	// visitEntries reports the entries of one directory and queues its
+	// subdirectories. Callers must hold mu.
+	visitEntries := func(dir string, entries []fs.DirEntry) {
+		for _, entry := range entries {
+			if stopped {
+				return
+			}
+
+			relPath := filepath.Join(dir, entry.Name())
+
+			target := ""
+			if entry.Type()&fs.ModeSymlink != 0 {
+				if linked, ok := linkTarget(relPath); ok {
+					target = linked
+					entry = linkedDir{entry}
+				}
+			}
+
+			switch err := visit(relPath, entry); err {
+			case filepath.SkipAll:
+				stopped = true
+				return
+			case nil:
+				if entry.IsDir() {
+					if target != "" {
+						followed[target] = true
+					}
+					queue = append(queue, relPath)
+					pending++
+				}
+			}
+		}
+	}
+
+	mu.Lock()
+	visitEntries("", entries)
+	mu.Unlock()
+
+	var wg sync.WaitGroup
+	for range walkWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for {
+				for len(queue) == 0 && pending > 0 && !stopped {
+					cond.Wait()
+				}
+				if pending == 0 || stopped {
+					cond.Broadcast()
+					return
+				}
+
+				dir := queue[len(queue)-1]
+				queue = queue[:len(queue)-1]
+
+				mu.Unlock()
+				entries, err := os.ReadDir(filepath.Join(root, dir))
+				mu.Lock()
+
+				if err == nil {
+					visitEntries(dir, entries)
+				}
+				pending--
+				cond.Broadcast()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// linkedDir is a symlink to a directory that the walk follows
+type linkedDir struct {
+	fs.DirEntry
+}
+
+func (linkedDir) IsDir() bool {
+	return true
+}
+
+// realRoots returns the workspace roots with their symlinks resolved
+func (ws *Workspace) realRoots() []string {
+	roots := []string{ws.Root()}
+	for _, root := range ws.Roots() {
+		roots = append(roots, root.Path)
+	}
+
+	real := []string{}
+	for _, root := range roots {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			real = append(real, resolved)
+		}
+	}
+	return real
+}
+
+// withinAny reports whether path lies in one of roots
+func withinAny(roots []string, path string) bool {
+	for _, root := range roots {
+		if isWithin(root, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// rootNamePattern restricts extra root names so they can't be confused with Windows drive letters
+var rootNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]+$`)
+
+// Workspace is the sandbox root that all tool paths are resolved against.
+// Additional named roots can be registered and addressed with a "name:" path prefix.
+type Workspace struct {
+	mu    sync.RWMutex
+	root  string
+	roots map[string]string
+
+	limits   WriteLimits
+	written  int64
+	override bool
+
+	ignore   []string
+	overview *overviewCache
+
+	todos []TodoItem
+
+	// staged holds dry-run writes by path while staging is on, or until
+	// they are applied or discarded
+	staging bool
+	staged  map[string][]byte
+}
+
+// Root is a named additional workspace root
+type Root struct {
+	Name string
+	Path string
+}
+
+// NewWorkspace creates a workspace rooted at dir, or the current directory if dir is empty
+func NewWorkspace(dir string) (*Workspace, error) {
+	ws := &Workspace{limits: DefaultWriteLimits}
+	if err := ws.SetRoot(dir); err != nil {
+		return nil, err
+	}
+
+	return ws, nil
+}
+
+// Root returns the absolute workspace root
+func (ws *Workspace) Root() string {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.root
+}
+
+// SetRoot changes the workspace root. Relative paths are resolved against the current root.
+func (ws *Workspace) SetRoot(dir string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	abs, err := ws.resolveDir(dir)
+	if err != nil {
+		return err
+	}
+
+	ws.root = abs
+	return nil
+}
+
+// resolveDir turns a user-provided directory into an absolute path to an existing directory.
+// Callers must hold the lock.
+func (ws *Workspace) resolveDir(dir string) (string, error) {
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to get current directory: %w", err)
+		}
+		dir = cwd
+	}
+
+	// Only the user's own home: "~user" and names like "~backup" stay as they are
+	if dir == "~" || strings.HasPrefix(dir, "~/") || strings.HasPrefix(dir, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+		dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+	}
+
+	if !filepath.IsAbs(dir) && ws.root != "" {
+		dir = filepath.Join(ws.root, dir)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to open directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", abs)
+	}
+
+	return abs, nil
+}
+
+// AddRoot registers an additional named root, addressable by tools as "name:path"
+func (ws *Workspace) AddRoot(name, dir string) error {
+	if !rootNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid root name %q: use at least two letters, digits, '-' or '_', starting with a letter", name)
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	abs, err := ws.resolveDir(dir)
+	if err != nil {
+		return err
+	}
+
+	if ws.roots == nil {
+		ws.roots = map[string]string{}
+	}
+	ws.roots[name] = abs
+
+	return nil
+}
+
+// RemoveRoot unregisters a named root
+func (ws *Workspace) RemoveRoot(name string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if _, ok := ws.roots[name]; !ok {
+		return fmt.Errorf("unknown root: %s", name)
+	}
+
+	delete(ws.roots, name)
+	return nil
+}
+
+// Roots returns the additional named roots sorted by name
+func (ws *Workspace) Roots() []Root {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	roots := make([]Root, 0, len(ws.roots))
+	for name, path := range ws.roots {
+		roots = append(roots, Root{Name: name, Path: path})
+	}
+
+	sort.Slice(roots, func(i, j int) bool {
+		return roots[i].Name < roots[j].Name
+	})
+
+	return roots
+}
+
+// splitRoot separates a "name:path" prefix for a registered root. It returns
+// the primary root and the unchanged path when there is no such prefix.
+func (ws *Workspace) splitRoot(path string) (string, string) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	if name, rest, ok := strings.Cut(path, ":"); ok {
+		if root, ok := ws.roots[name]; ok {
+			return root, rest
+		}
+	}
+
+	return ws.root, path
+}
+
+// containingRoot reports whether an absolute path lies inside the primary or any named root
+func (ws *Workspace) containingRoot(path string) (string, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	candidates := []string{ws.root}
+	for _, root := range ws.roots {
+		candidates = append(candidates, root)
+	}
+
+	for _, root := range candidates {
+		if isWithin(root, path) {
+			return root, true
+		}
+	}
+
+	return "", false
+}
+
+// isWithin reports whether path is root itself or nested below it
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Resolve turns a tool-provided path into an absolute path inside the workspace.
+// Relative paths are joined to the primary root, or to a named root when prefixed
+// with "name:"; paths escaping their root are rejected.
+func (ws *Workspace) Resolve(path string) (string, error) {
+	root, rest := ws.splitRoot(path)
+
+	if rest == "" {
+		rest = "."
+	}
+
+	if filepath.IsAbs(rest) {
+		resolved := filepath.Clean(rest)
+		if _, ok := ws.containingRoot(resolved); !ok {
+			return "", fmt.Errorf("path %s is outside the workspace roots", path)
+		}
+		return resolved, nil
+	}
+
+	resolved := filepath.Clean(filepath.Join(root, rest))
+	if !isWithin(root, resolved) {
+		return "", fmt.Errorf("path %s is outside the workspace root %s", path, root)
+	}
+
+	return resolved, nil
+}
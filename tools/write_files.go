@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxWriteFiles caps how many files one write_files call may write
+const maxWriteFiles = 200
+
+// WriteFiles tool definition and implementation
+var WriteFilesDefinition = ToolDefinition{
+	Name:        "write_files",
+	Description: "Create several files in one call, e.g. to scaffold a project or module. Each entry has a path and the file's full content; missing directories are created. Existing files are only replaced with overwrite=true. With all_or_nothing=true every file is checked before any is written and written files are rolled back if a later one fails, so either all files are written or none.",
+	InputSchema: WriteFilesInputSchema,
+	Function:    WriteFiles,
+	Paths:       writeFilesPaths,
+}
+
+type WriteFilesInput struct {
+	Files        []WriteFilesEntry `json:"files" jsonschema_description:"The files to write, in order."`
+	Overwrite    bool              `json:"overwrite,omitempty" jsonschema_description:"Whether to replace files that already exist. Defaults to false."`
+	AllOrNothing bool              `json:"all_or_nothing,omitempty" jsonschema_description:"Write all files or none: check every file first and roll back if a write fails. Defaults to false, which writes what it can and reports failures per file."`
+}
+
+// WriteFilesEntry is one file for write_files
+type WriteFilesEntry struct {
+	Path    string `json:"path" jsonschema_description:"The path of the file to write."`
+	Content string `json:"content" jsonschema_description:"The full content of the file."`
+}
+
+var WriteFilesInputSchema = GenerateSchema[WriteFilesInput]()
+
+// writeFilesPaths lists the files a write_files call writes
+func writeFilesPaths(input json.RawMessage) []string {
+	writeInput := WriteFilesInput{}
+	if err := json.Unmarshal(input, &writeInput); err != nil {
+		return nil
+	}
+
+	paths := make([]string, len(writeInput.Files))
+	for i, file := range writeInput.Files {
+		paths[i] = file.Path
+	}
+	return paths
+}
+
+// plannedWrite is a write_files entry resolved and checked
+type plannedWrite struct {
+	entry   WriteFilesEntry
+	path    string
+	existed bool
+	old     []byte
+	newDirs []string
+
+	// In dry-run mode the write is staged, and rollback puts back what was staged before
+	staged    bool
+	oldStaged []byte
+	wasStaged bool
+}
+
+func WriteFiles(ws *Workspace, input json.RawMessage) (string, error) {
+	writeInput := WriteFilesInput{}
+	if err := json.Unmarshal(input, &writeInput); err != nil {
+		return "", fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	if len(writeInput.Files) == 0 {
+		return "", fmt.Errorf("files is required")
+	}
+	if len(writeInput.Files) > maxWriteFiles {
+		return "", fmt.Errorf("%d files given; write at most %d per call", len(writeInput.Files), maxWriteFiles)
+	}
+
+	// Resolve and check every file up front
+	planned := []plannedWrite{}
+	problems := []string{}
+	seen := map[string]bool{}
+	for _, entry := range writeInput.Files {
+		write, err := planWrite(ws, entry, writeInput.Overwrite)
+		if err == nil && seen[write.path] {
+			err = fmt.Errorf("listed more than once")
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", entry.Path, err))
+			continue
+		}
+		seen[write.path] = true
+		planned = append(planned, write)
+	}
+
+	if writeInput.AllOrNothing {
+		if len(problems) > 0 {
+			return "", fmt.Errorf("no files were written:\n%s", strings.Join(problems, "\n"))
+		}
+		return writeAll(ws, planned)
+	}
+
+	written := []string{}
+	for _, write := range planned {
+		unlock, err := LockFile(write.path)
+		if err == nil {
+			err = write.apply(ws)
+			unlock()
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", write.entry.Path, err))
+			continue
+		}
+		written = append(written, write.entry.Path)
+	}
+
+	if len(written) == 0 {
+		return "", fmt.Errorf("no files were written:\n%s", strings.Join(problems, "\n"))
+	}
+
+	result := fmt.Sprintf("Wrote %d files: %s", len(written), strings.Join(written, ", "))
+	if len(problems) > 0 {
+		result += fmt.Sprintf("\nFailed to write %d files:\n%s", len(problems), strings.Join(problems, "\n"))
+	}
+	return result, nil
+}
+
+// writeAll writes every planned file while holding all their locks, and
+// restores the previous state if any write fails
+func writeAll(ws *Workspace, planned []plannedWrite) (string, error) {
+	// Lock in a fixed order so two calls can't deadlock on each other's files
+	order := make([]string, len(planned))
+	for i, write := range planned {
+		order[i] = write.path
+	}
+	sort.Strings(order)
+	for _, path := range order {
+		unlock, err := LockFile(path)
+		if err != nil {
+			return "", fmt.Errorf("no files were written: %w", err)
+		}
+		defer unlock()
+	}
+
+	for i, write := range planned {
+		if err := write.apply(ws); err != nil {
+			for j := i; j >= 0; j-- {
+				planned[j].rollback(ws)
+			}
+			return "", fmt.Errorf("no files were written, %s failed: %w", write.entry.Path, err)
+		}
+	}
+
+	paths := make([]string, len(planned))
+	for i, write := range planned {
+		paths[i] = write.entry.Path
+	}
+	return fmt.Sprintf("Wrote %d files: %s", len(planned), strings.Join(paths, ", ")), nil
+}
+
+// planWrite resolves a write_files entry and checks it can be written
+func planWrite(ws *Workspace, entry WriteFilesEntry, overwrite bool) (plannedWrite, error) {
+	if entry.Path == "" {
+		return plannedWrite{}, fmt.Errorf("path is required")
+	}
+
+	path, err := ws.Resolve(entry.Path)
+	if err != nil {
+		return plannedWrite{}, err
+	}
+	if err := ws.checkWriteTarget(path); err != nil {
+		return plannedWrite{}, err
+	}
+
+	write := plannedWrite{entry: entry, path: path}
+
+	// A file staged in dry-run mode counts as existing, with its pending content
+	if content, ok := ws.stagedContent(path); ok {
+		if !overwrite {
+			return write, fmt.Errorf("file already exists (use overwrite=true to replace)")
+		}
+		write.existed = true
+		write.old = content
+		return write, nil
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return write, fmt.Errorf("is a directory")
+	case err == nil && !overwrite:
+		return write, fmt.Errorf("file already exists (use overwrite=true to replace)")
+	case err == nil:
+		write.existed = true
+		if write.old, err = os.ReadFile(path); err != nil {
+			return write, fmt.Errorf("failed to read file: %w", err)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return write, err
+	}
+
+	return write, nil
+}
+
+// apply writes the file, creating missing directories and remembering them for rollback
+func (w *plannedWrite) apply(ws *Workspace) error {
+	if ws.Staging() {
+		w.staged = true
+		w.oldStaged, w.wasStaged = ws.stagedContent(w.path)
+		return ws.WriteFile(w.path, []byte(w.entry.Content))
+	}
+
+	for dir := filepath.Dir(w.path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		w.newDirs = append(w.newDirs, dir)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := ws.keepReplaced(w.path, []byte(w.entry.Content)); err != nil {
+		return err
+	}
+
+	return ws.WriteFile(w.path, []byte(w.entry.Content))
+}
+
+// rollback restores the file's previous content, or removes it and the
+// directories created for it
+func (w *plannedWrite) rollback(ws *Workspace) {
+	if w.staged {
+		ws.unstage(w.path, w.oldStaged, w.wasStaged)
+		return
+	}
+
+	if w.existed {
+		os.WriteFile(w.path, w.old, 0644)
+		return
+	}
+
+	os.Remove(w.path)
+	for _, dir := range w.newDirs {
+		// Only empty directories go; another file may have been written there since
+		os.Remove(dir)
+	}
+}
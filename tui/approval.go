@@ -0,0 +1,70 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// approvalMsg carries an approval request from the agent goroutine to the UI
+type approvalMsg struct {
+	request agent.ApprovalRequest
+	reply   chan bool
+}
+
+// newApprover returns an agent approver that forwards requests to the UI over ch and waits for the answer
+func newApprover(ch chan approvalMsg) agent.Approver {
+	return func(req agent.ApprovalRequest) bool {
+		reply := make(chan bool, 1)
+		ch <- approvalMsg{request: req, reply: reply}
+		return <-reply
+	}
+}
+
+// updateApprovalPrompt handles key presses while an approval request is shown
+func (m *model) updateApprovalPrompt(msg tea.KeyMsg) tea.Cmd {
+	var approved bool
+
+	switch msg.String() {
+	case "ctrl+c":
+		m.pendingApproval.reply <- false
+		return tea.Quit
+	case "y", "Y":
+		approved = true
+	case "n", "N", "esc":
+		approved = false
+	default:
+		return nil
+	}
+
+	m.pendingApproval.reply <- approved
+	m.pendingApproval = nil
+
+	// Resume listening to the turn that was waiting on the decision
+	return m.waitForTurnEvent()
+}
+
+func (m *model) renderApprovalPrompt(width int) string {
+	return renderPromptBox(
+		m.renderer,
+		width,
+		m.pendingApproval.request.Title,
+		m.pendingApproval.request.Detail,
+		locale.T("approval.hint"),
+	)
+}
+
+// renderPromptBox renders a bordered modal prompt with a title, body and key hints
+func renderPromptBox(renderer *lipgloss.Renderer, width int, title, body, hint string) string {
+	titleStyle := renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF6B35"))
+	hintStyle := renderer.NewStyle().Foreground(lipgloss.Color("#888888"))
+
+	boxStyle := renderer.NewStyle().
+		Width(width-2).
+		BorderForeground(lipgloss.Color("#FF6B35")).
+		Padding(1, 2)
+
+	return withBorder(boxStyle, lipgloss.RoundedBorder()).Render(titleStyle.Render(title) + "\n\n" + body + "\n\n" + hintStyle.Render(hint))
+}
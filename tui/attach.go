@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"agent/locale"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxAttachmentSize keeps /add from pushing huge files into the conversation
+const maxAttachmentSize = 200 * 1024
+
+// attachment is a file staged with /add, sent with the next message
+type attachment struct {
+	Path    string
+	Content string
+}
+
+// attachFile reads a workspace file and stages it for the next message
+func (m *model) attachFile(path string) error {
+	resolved, err := m.agent.Workspace().Resolve(path)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > maxAttachmentSize {
+		return fmt.Errorf("%s is larger than %d KB", path, maxAttachmentSize/1024)
+	}
+
+	content, err := os.ReadFile(resolved)
+	if err != nil {
+		return err
+	}
+
+	for i, existing := range m.attachments {
+		if existing.Path == path {
+			m.attachments[i].Content = string(content)
+			return nil
+		}
+	}
+
+	m.attachments = append(m.attachments, attachment{Path: path, Content: string(content)})
+	return nil
+}
+
+// attachmentNames lists the staged attachment paths
+func (m *model) attachmentNames() string {
+	names := make([]string, 0, len(m.attachments))
+	for _, a := range m.attachments {
+		names = append(names, a.Path)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// withAttachments prepends the attached files to a message as labeled code blocks
+func withAttachments(input string, attachments []attachment) string {
+	var b strings.Builder
+
+	for _, a := range attachments {
+		// Use a longer fence when the file itself contains one
+		fence := "```"
+		for strings.Contains(a.Content, fence) {
+			fence += "`"
+		}
+
+		language := strings.TrimPrefix(filepath.Ext(a.Path), ".")
+		b.WriteString(fmt.Sprintf("File: %s\n%s%s\n%s", a.Path, fence, language, a.Content))
+		if !strings.HasSuffix(a.Content, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(fence + "\n\n")
+	}
+
+	b.WriteString(input)
+	return b.String()
+}
+
+// runAddCommand stages files for the next message, lists them, or clears them
+func runAddCommand(m *model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+
+	switch {
+	case len(fields) == 0:
+		if len(m.attachments) == 0 {
+			m.addSystemMessage(locale.T("attach.none"))
+		} else {
+			m.addSystemMessage(locale.T("attach.list", m.attachmentNames()))
+		}
+
+	case len(fields) == 1 && fields[0] == "--clear":
+		m.attachments = nil
+		m.addSystemMessage(locale.T("attach.cleared"))
+
+	default:
+		for _, path := range fields {
+			if err := m.attachFile(path); err != nil {
+				m.addSystemMessage(locale.T("attach.failed", path, err))
+				return nil
+			}
+		}
+		m.addSystemMessage(locale.T("attach.list", m.attachmentNames()))
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package tui
+
+import (
+	"agent/locale"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// notesFile is where bookmarked responses are appended, relative to the workspace root
+var notesFile = filepath.Join(".cli-agent", "notes.md")
+
+// bookmarkPicker lists the assistant's responses, newest first, for
+// choosing one to save to the notes file
+type bookmarkPicker struct {
+	// indexes are positions in the chat's messages
+	indexes  []int
+	selected int
+}
+
+// openBookmarkPicker shows the responses that can be bookmarked
+func (m *model) openBookmarkPicker() {
+	indexes := []int{}
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if isAssistantMessage(m.messages[i]) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	if len(indexes) == 0 {
+		m.addSystemMessage(locale.T("bookmark.none"))
+		m.scrollToLatest()
+		return
+	}
+
+	m.bookmarks = &bookmarkPicker{indexes: indexes}
+}
+
+// isAssistantMessage reports whether msg is a response from the model
+func isAssistantMessage(msg ChatMessage) bool {
+	return !msg.IsUser && !msg.IsSystem && !msg.IsError && !msg.IsTool && !msg.IsMeta && strings.TrimSpace(msg.Content) != ""
+}
+
+// updateBookmarkPicker handles key presses while the picker is open
+func (m *model) updateBookmarkPicker(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return tea.Quit
+	case tea.KeyEsc:
+		m.bookmarks = nil
+	case tea.KeyUp:
+		m.bookmarks.selected = max(m.bookmarks.selected-1, 0)
+	case tea.KeyDown:
+		m.bookmarks.selected = min(m.bookmarks.selected+1, len(m.bookmarks.indexes)-1)
+	case tea.KeyEnter:
+		index := m.bookmarks.indexes[m.bookmarks.selected]
+		m.bookmarks = nil
+
+		path, err := m.saveBookmark(index)
+		if err != nil {
+			m.addSystemMessage(locale.T("bookmark.failed", err))
+		} else {
+			m.addSystemMessage(locale.T("bookmark.saved", path))
+		}
+		m.scrollToLatest()
+	}
+
+	return nil
+}
+
+// saveBookmark appends the response at index to the notes file, headed by
+// when it was saved, the model and the prompt that led to it
+func (m *model) saveBookmark(index int) (string, error) {
+	prompt := ""
+	for i := index - 1; i >= 0; i-- {
+		if m.messages[i].IsUser {
+			prompt = firstLine(m.messages[i].Content)
+			break
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", time.Now().Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "- Model: %s\n", m.agent.Model())
+	if profile := m.agent.Profile(); profile != "" {
+		fmt.Fprintf(&b, "- Profile: %s\n", profile)
+	}
+	if prompt != "" {
+		fmt.Fprintf(&b, "- Prompt: %s\n", prompt)
+	}
+	fmt.Fprintf(&b, "\n%s\n\n", strings.TrimSpace(m.messages[index].Content))
+
+	path := filepath.Join(m.agent.WorkingDirectory(), notesFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(b.String()); err != nil {
+		return "", err
+	}
+
+	return notesFile, nil
+}
+
+func (m *model) renderBookmarkPicker(width int) string {
+	previewStyle := m.renderer.NewStyle().MaxWidth(max(width-10, 10))
+	selectedStyle := m.renderer.NewStyle().Foreground(lipgloss.Color("#FF6B35")).Bold(true)
+
+	previews := make([]string, len(m.bookmarks.indexes))
+	for i, index := range m.bookmarks.indexes {
+		previews[i] = previewStyle.Render(firstLine(m.messages[index].Content))
+	}
+
+	return renderPromptBox(
+		m.renderer,
+		width,
+		locale.T("bookmark.title"),
+		renderChoices(previews, m.bookmarks.selected, 8, selectedStyle),
+		locale.T("bookmark.hint", notesFile),
+	)
+}
@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"agent/locale"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// showPendingChanges puts the staged changes up for review at the end of a dry-run turn
+func (m *model) showPendingChanges() {
+	changes := m.agent.PendingChanges()
+	if len(changes) == 0 {
+		return
+	}
+
+	m.addSystemMessage(renderChangeDiff(m.renderer, changes) + "\n\n" + locale.T("changeset.review", len(changes)))
+}
+
+// runApplyCommand writes the pending changeset to disk
+func runApplyCommand(m *model, args string) tea.Cmd {
+	if m.busy() {
+		m.addSystemMessage(locale.T("changeset.busy"))
+		return nil
+	}
+	if m.agent.Workspace().StagedCount() == 0 {
+		m.addSystemMessage(locale.T("changeset.none"))
+		return nil
+	}
+
+	changes, err := m.agent.ApplyChanges()
+	if err != nil {
+		m.addSystemMessage(locale.T("changeset.apply_failed", err))
+		return nil
+	}
+
+	if len(changes) > 0 {
+		m.lastTurnChanges = changes
+		m.addSystemMessage(renderChangeSummary(m.renderer, changes))
+	}
+	m.addSystemMessage(locale.T("changeset.applied", len(changes)))
+
+	if m.showPreview {
+		m.preview.refresh(m.agent)
+	}
+	return nil
+}
+
+// runDiscardCommand drops the pending changeset and tells the model
+func runDiscardCommand(m *model, args string) tea.Cmd {
+	if m.busy() {
+		m.addSystemMessage(locale.T("changeset.busy"))
+		return nil
+	}
+
+	changes := m.agent.PendingChanges()
+	if m.agent.DiscardChanges() == 0 {
+		m.addSystemMessage(locale.T("changeset.none"))
+		return nil
+	}
+
+	paths := make([]string, len(changes))
+	for i, change := range changes {
+		paths[i] = change.Path
+	}
+	if len(paths) > 0 {
+		m.agent.AddNote(fmt.Sprintf("The user discarded your staged changes to %s. They were not applied; those files are as they are on disk.", strings.Join(paths, ", ")))
+	}
+
+	m.addSystemMessage(locale.T("changeset.discarded", len(changes)))
+	return nil
+}
+
+// runDryRunCommand shows or switches dry-run mode
+func runDryRunCommand(m *model, args string) tea.Cmd {
+	switch args {
+	case "":
+	case "on":
+		m.agent.SetDryRun(true)
+	case "off":
+		m.agent.SetDryRun(false)
+	default:
+		m.addSystemMessage(locale.T("dry_run.usage"))
+		return nil
+	}
+
+	status := locale.T("dry_run.off")
+	if m.agent.DryRun() {
+		status = locale.T("dry_run.on")
+	}
+	if pending := m.agent.Workspace().StagedCount(); pending > 0 {
+		status += " " + locale.T("dry_run.pending", pending)
+	}
+	m.addSystemMessage(status)
+	return nil
+}
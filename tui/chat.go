@@ -2,37 +2,64 @@ package tui
 
 import (
 	"agent/agent"
+	"agent/config"
+	"agent/locale"
+	"agent/tools"
+	"agent/update"
+	"agent/watcher"
 	"context"
-	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 )
 
 const gap = "\n\n"
 
 type (
 	errMsg               error
-	streamingTextMsg     string
+	agentEventMsg        struct{ event agent.AgentEvent }
 	streamingCompleteMsg struct{}
 )
 
 type ChatMessage struct {
-	Content string
-	IsUser  bool
+	Content  string
+	IsUser   bool
+	IsSystem bool
+	IsError  bool
+
+	// Tool call entries: Content summarizes the input, Detail holds the error of a failed call
+	IsTool   bool
+	ToolID   string
+	ToolName string
+	Running  bool
+	Failed   bool
+	Duration time.Duration
+	Detail   string
+
+	// Meta is the latency and token line shown under a response. IsMeta marks
+	// a standalone line for responses without text, e.g. only tool calls.
+	Meta   string
+	IsMeta bool
 }
 
 type model struct {
 	viewport                viewport.Model
-	conversation            []anthropic.MessageParam
+	session                 *agent.Session
 	messages                []ChatMessage
 	currentStreamingMessage string
 	isStreaming             bool
-	streamingChan           chan string
+	events                  <-chan agent.AgentEvent
+	currentThinking         string
+	usage                   agent.Usage
 	textarea                textarea.Model
 	userStyle               lipgloss.Style
 	claudeStyle             lipgloss.Style
@@ -42,39 +69,107 @@ type model struct {
 	agent                   *agent.Agent
 	width                   int
 	height                  int
+	palette                 *palette
+	preview                 filePreview
+	showPreview             bool
+	turnMarker              int
+	lastTurnChanges         []agent.FileChange
+	trustPrompt             bool
+	approvalChan            chan approvalMsg
+	pendingApproval         *approvalMsg
+	queuedInputs            []string
+	lastTurnFailed          bool
+	attachments             []attachment
+	watcher                 *watcher.Watcher
+	renderDirty             bool
+	renderScheduled         bool
+	renderCache             []renderedMessage
+	keys                    inputKeys
+	newContentBelow         bool
+	todos                   []tools.TodoItem
+	bookmarks               *bookmarkPicker
+	checkpoints             map[string][]ChatMessage
+	rateLimitUntil          time.Time
+	rateLimitName           string
+	offline                 bool
+	offlineRetry            time.Time
+
+	// cancelTurn stops the running turn, when quitting
+	cancelTurn context.CancelFunc
+
+	// forgetOnCrash stops saving the conversation on a crash once the tab is closed
+	forgetOnCrash func()
+
+	// openTabs are the titles of the open tabs, when the chat runs in tabs
+	openTabs []string
+
+	// renderer draws the chat in the colors of the terminal it runs in,
+	// colorProfile, or without colors in plain mode. Tabs share it.
+	renderer     *lipgloss.Renderer
+	colorProfile termenv.Profile
 }
 
-func InitialChatModel(agentApp *agent.Agent) model {
+// InitialChatModel creates the chat, drawn with renderer in the colors of its terminal
+func InitialChatModel(agentApp *agent.Agent, renderer *lipgloss.Renderer) model {
+	colorProfile := renderer.ColorProfile()
+	if plainMode {
+		renderer.SetColorProfile(termenv.Ascii)
+	}
+	return newChatModel(agentApp, renderer, colorProfile)
+}
+
+// newChatModel creates a chat drawn with renderer, which is set up for plain
+// mode already, for a terminal with colorProfile
+func newChatModel(agentApp *agent.Agent, renderer *lipgloss.Renderer, colorProfile termenv.Profile) model {
 	ta := textarea.New()
-	ta.Placeholder = "Type your message here..."
+	ta.Placeholder = locale.T("chat.placeholder")
 	ta.Prompt = ""
 	ta.SetWidth(80)
 	ta.SetHeight(4)
 
 	// Remove cursor line styling
-	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	ta.FocusedStyle.CursorLine = renderer.NewStyle()
 	ta.ShowLineNumbers = false
-	ta.KeyMap.InsertNewline.SetEnabled(true)
+	ta.FocusedStyle = textareaStyle(ta.FocusedStyle, renderer)
+	ta.BlurredStyle = textareaStyle(ta.BlurredStyle, renderer)
+	ta.Cursor.Style = ta.Cursor.Style.Renderer(renderer)
+	ta.Cursor.TextStyle = ta.Cursor.TextStyle.Renderer(renderer)
+
+	// The textarea inserts newlines at the cursor; sending is handled in Update
+	settings, settingsErr := config.LoadSettings()
+	keys := newInputKeys(settings.Keys)
+	ta.KeyMap.InsertNewline = keys.newline
+
+	// Home and End scroll the chat; Ctrl+A and Ctrl+E still move within the line
+	ta.KeyMap.LineStart = key.NewBinding(key.WithKeys("ctrl+a"))
+	ta.KeyMap.LineEnd = key.NewBinding(key.WithKeys("ctrl+e"))
+
+	// Ctrl+B bookmarks a response; the left arrow still moves back
+	ta.KeyMap.CharacterBackward = key.NewBinding(key.WithKeys("left"))
 
 	ta.Focus()
 
 	vp := viewport.New(100, 20)
+	vp.KeyMap = chatKeyMap()
 
 	// Chat bubble styles - User on right, Claude on left
-	userBubbleStyle := lipgloss.NewStyle()
-	claudeBubbleStyle := lipgloss.NewStyle()
+	userBubbleStyle := renderer.NewStyle()
+	claudeBubbleStyle := renderer.NewStyle()
 
-	userStyle := lipgloss.NewStyle().
+	userStyle := renderer.NewStyle().
 		Foreground(lipgloss.Color("#007AFF")).
 		Bold(true)
 
-	claudeStyle := lipgloss.NewStyle().
+	claudeStyle := renderer.NewStyle().
 		Foreground(lipgloss.Color("#FF6B35")).
 		Bold(true)
 
-	return model{
+	approvalChan := make(chan approvalMsg)
+	agentApp.SetApprover(newApprover(approvalChan))
+
+	m := model{
 		textarea:          ta,
-		conversation:      []anthropic.MessageParam{},
+		session:           agent.NewSession(),
 		messages:          []ChatMessage{},
 		viewport:          vp,
 		userStyle:         userStyle,
@@ -85,114 +180,250 @@ func InitialChatModel(agentApp *agent.Agent) model {
 		agent:             agentApp,
 		width:             100,
 		height:            25,
+		preview:           newFilePreview(renderer),
+		approvalChan:      approvalChan,
+		keys:              keys,
+		renderer:          renderer,
+		colorProfile:      colorProfile,
+	}
+	if settingsErr != nil {
+		m.addSystemMessage(locale.T("chat.settings_ignored", settingsErr))
 	}
+	m.applyWorkspace()
+	m.forgetOnCrash = saveOnCrash(m.session, agentApp)
+
+	return m
+}
+
+// textareaStyle draws a textarea style with renderer instead of the default one
+func textareaStyle(style textarea.Style, renderer *lipgloss.Renderer) textarea.Style {
+	style.Base = style.Base.Renderer(renderer)
+	style.CursorLine = style.CursorLine.Renderer(renderer)
+	style.CursorLineNumber = style.CursorLineNumber.Renderer(renderer)
+	style.EndOfBuffer = style.EndOfBuffer.Renderer(renderer)
+	style.LineNumber = style.LineNumber.Renderer(renderer)
+	style.Placeholder = style.Placeholder.Renderer(renderer)
+	style.Prompt = style.Prompt.Renderer(renderer)
+	style.Text = style.Text.Renderer(renderer)
+	return style
 }
 
 func (m model) Init() tea.Cmd {
-	return textarea.Blink
+	return tea.Batch(textarea.Blink, waitForFileChanges(m.watcher), checkForUpdate())
 }
 
-func (m *model) waitForStreamingText() tea.Cmd {
+// waitForTurnEvent waits for the next event of the running turn or an approval request
+func (m *model) waitForTurnEvent() tea.Cmd {
+	// Capture the channels now: the command runs later, outside Update, and must not touch the model
+	events := m.events
+	approvalChan := m.approvalChan
+
 	return func() tea.Msg {
-		if m.streamingChan == nil {
+		if events == nil {
 			return streamingCompleteMsg{}
 		}
 
-		text, ok := <-m.streamingChan
-		if !ok {
-			return streamingCompleteMsg{}
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return streamingCompleteMsg{}
+			}
+			return agentEventMsg{event: event}
+
+		case approval := <-approvalChan:
+			return approval
 		}
+	}
+}
 
-		return streamingTextMsg(text)
+// busy reports whether an agent turn is in progress
+func (m *model) busy() bool {
+	return m.events != nil
+}
+
+// sendMessage shows the user's message in the chat and starts an agent turn
+// for it, with the options of its modifiers
+func (m *model) sendMessage(input string) tea.Cmd {
+	options, text, err := agent.ParseTurnModifiers(input)
+	if err != nil {
+		m.addSystemMessage(err.Error())
+		m.scrollToLatest()
+		return nil
 	}
+
+	display, prompt := input, text
+	if len(m.attachments) > 0 {
+		display += "\n" + icon("📎", locale.T("chat.attached")) + m.attachmentNames()
+		prompt = withAttachments(text, m.attachments)
+		m.attachments = nil
+	}
+
+	m.messages = append(m.messages, ChatMessage{
+		Content: display,
+		IsUser:  true,
+	})
+
+	m.followOutput()
+
+	return m.Run(context.Background(), prompt, options)
 }
 
-func (m *model) Run(ctx context.Context, userInput string) tea.Cmd {
-	currentInput := userInput
-	m.streamingChan = make(chan string, 100)
+func (m *model) Run(ctx context.Context, userInput string, options agent.TurnOptions) tea.Cmd {
+	m.turnMarker = m.agent.ActivityCount()
+	m.lastTurnFailed = false
+	ctx, m.cancelTurn = context.WithCancel(ctx)
+	m.events = m.agent.RunTurnWith(ctx, m.session, userInput, options)
+
+	return m.waitForTurnEvent()
+}
 
-	if currentInput != "" {
-		userMessage := anthropic.NewUserMessage(anthropic.NewTextBlock(userInput))
-		m.conversation = append(m.conversation, userMessage)
+// retryTurn re-runs a failed turn from the conversation as it stands, without adding new input
+func (m *model) retryTurn() tea.Cmd {
+	if m.busy() {
+		return nil
+	}
+	if !m.lastTurnFailed {
+		m.addSystemMessage(locale.T("chat.nothing_to_retry"))
+		m.updateViewport()
+		return nil
 	}
 
-	// streaming in a go routine
-	go func() {
-		defer close(m.streamingChan)
+	m.addSystemMessage(locale.T("status.retrying"))
+	m.scrollToLatest()
 
-		hasToolCalls := true
+	return m.Run(context.Background(), "", agent.TurnOptions{})
+}
 
-		for hasToolCalls {
-			hasToolCalls = false // Reset flag
+// flushStreamingMessage moves the partially streamed response into the message history
+func (m *model) flushStreamingMessage() {
+	if m.currentStreamingMessage != "" {
+		// Add the completed Claude message
+		m.messages = append(m.messages, ChatMessage{
+			Content: m.currentStreamingMessage,
+			IsUser:  false,
+		})
+	}
 
-			message, err := m.agent.RunInferenceWithStreaming(ctx, m.conversation, func(text string) {
-				m.streamingChan <- text
-			})
+	m.currentStreamingMessage = ""
+	m.currentThinking = ""
+}
 
-			if err != nil {
-				m.streamingChan <- fmt.Sprintf("Error: %s", err.Error())
-				return
-			}
+// addSystemMessage shows a local notice in the chat that is not sent to Claude
+func (m *model) addSystemMessage(content string) {
+	m.messages = append(m.messages, ChatMessage{
+		Content:  content,
+		IsSystem: true,
+	})
+}
 
-			m.conversation = append(m.conversation, message.ToParam())
+// contentWidth is the centered width available to the whole UI (80% of terminal width, max 180 chars)
+func (m *model) contentWidth() int {
+	// Plain mode uses the whole line so nothing is padded out for a screen reader to skip
+	if plainMode {
+		return m.width
+	}
+	return min(int(float64(m.width)*0.8), 180)
+}
 
-			// handle tool call
-			toolResults := []anthropic.ContentBlockParamUnion{}
-			for _, content := range message.Content {
-				switch content.Type {
-				case "tool_use":
-					// Continue the loop: we have tool calls
-					hasToolCalls = true
+// chatWidth is the width of the chat column, which shrinks when the preview pane is shown
+func (m *model) chatWidth() int {
+	if m.showPreview {
+		return m.contentWidth() * 55 / 100
+	}
+	return m.contentWidth()
+}
 
-					// Send tool call notification
-					m.streamingChan <- fmt.Sprintf("\n🔧 Using tool: %s\n", content.Name)
+// resize lays out the viewport, preview pane and textarea for the current terminal size
+func (m *model) resize() {
+	centeredWidth := m.contentWidth()
+	chatWidth := m.chatWidth()
+
+	m.viewport.Width = chatWidth
+	m.textarea.SetWidth(centeredWidth)
+
+	// Calculate heights
+	headerHeight := 3                     // header + blank line
+	footerHeight := 2                     // status bar + footer
+	gapHeight := lipgloss.Height(gap)     // gap between viewport and textarea
+	textareaHeight := m.textarea.Height() // textarea
+	todoHeight := m.todoPanelHeight()     // task list, when there is one
+	queueHeight := m.queuePanelHeight()   // queued messages, when there are any
+
+	// Set viewport height accounting for all other elements
+	m.viewport.Height = m.height - headerHeight - footerHeight - gapHeight - textareaHeight - todoHeight - queueHeight - 2 // extra padding
+
+	// Preview pane takes the remaining width minus its border and padding,
+	// and leaves room for its title and info lines
+	m.preview.setSize(max(centeredWidth-chatWidth-2, 1), max(m.viewport.Height-2, 1))
+
+	// Update bubble styles with new width (100% of chat width)
+	maxBubbleWidth := (chatWidth * 10) / 10
+	m.userBubbleStyle = m.userBubbleStyle.MaxWidth(maxBubbleWidth)
+	m.claudeBubbleStyle = m.claudeBubbleStyle.MaxWidth(maxBubbleWidth)
+}
 
-					result := m.agent.ExecuteTool(content.ID, content.Name, content.Input)
-					toolResults = append(toolResults, result)
-				}
-			}
+// showLastTurnDiff adds the unified diff of the last turn's changes to the chat
+func (m *model) showLastTurnDiff() {
+	if len(m.lastTurnChanges) == 0 {
+		m.addSystemMessage(locale.T("chat.no_changes"))
+	} else {
+		m.addSystemMessage(renderChangeDiff(m.renderer, m.lastTurnChanges))
+	}
 
-			if hasToolCalls {
-				m.conversation = append(m.conversation, anthropic.NewUserMessage(toolResults...))
-			}
-		}
-	}()
+	m.scrollToLatest()
+}
+
+// togglePreview shows or hides the file preview pane
+func (m *model) togglePreview() {
+	// A side-by-side pane can't be read linearly
+	if plainMode {
+		m.addSystemMessage(locale.T("preview.plain_unavailable"))
+		m.scrollToLatest()
+		return
+	}
+
+	m.showPreview = !m.showPreview
+	if m.showPreview {
+		m.preview.refresh(m.agent)
+	}
 
-	return m.waitForStreamingText()
+	m.resize()
+	m.keepPosition()
 }
 
 func (m *model) renderMessages() string {
 	var rendered []string
 
-	// Calculate centered width for message alignment
-	centeredWidth := min(int(float64(m.width)*0.8), 180)
+	// Calculate chat column width for message alignment
+	centeredWidth := m.chatWidth()
 
 	// Set the bubble width to ensure text wrapping
 	m.userBubbleStyle = m.userBubbleStyle.Width(centeredWidth)
 	m.claudeBubbleStyle = m.claudeBubbleStyle.Width(centeredWidth)
 
-	for _, msg := range m.messages {
-		if msg.IsUser {
-			// User message - aligned to the right
-			userLine := lipgloss.NewStyle().
-				Align(lipgloss.Right).
-				Width(centeredWidth).
-				Render(
-					m.userStyle.Render("You") + "\n" +
-						m.userBubbleStyle.Render(msg.Content))
-
-			rendered = append(rendered, userLine)
-		} else {
-			// Claude message - aligned to the left
-			claudeLine := m.claudeStyle.Render("Claude") + "\n" + m.claudeBubbleStyle.Render(msg.Content)
-
-			rendered = append(rendered, claudeLine)
-		}
+	thinkingStyle := m.renderer.NewStyle().
+		Foreground(lipgloss.Color("#666666")).
+		Italic(true).
+		Width(centeredWidth)
+
+	// Completed messages don't change, so only new ones (or all of them after a resize) are styled
+	for i, msg := range m.messages {
+		rendered = append(rendered, m.cachedRender(i, msg, centeredWidth))
+	}
+	m.renderCache = m.renderCache[:min(len(m.renderCache), len(m.messages))]
+
+	// Show extended thinking while the response is streaming
+	if m.isStreaming && m.currentThinking != "" {
+		rendered = append(rendered, thinkingStyle.Render(icon("💭", locale.T("chat.thinking"))+m.currentThinking))
 	}
 
 	// Add current streaming message if any
 	if m.isStreaming && m.currentStreamingMessage != "" {
-		claudeLine := m.claudeStyle.Render("Claude") + "\n" + m.claudeBubbleStyle.Render(m.currentStreamingMessage+"▋")
+		cursor := "▋"
+		if plainMode {
+			cursor = ""
+		}
+		claudeLine := m.claudeStyle.Render("Claude") + "\n" + m.claudeBubbleStyle.Render(m.currentStreamingMessage+cursor)
 
 		rendered = append(rendered, claudeLine)
 	}
@@ -200,16 +431,58 @@ func (m *model) renderMessages() string {
 	return strings.Join(rendered, "\n\n")
 }
 
+// renderMessage styles a single chat message for the given column width
+func (m *model) renderMessage(msg ChatMessage, centeredWidth int) string {
+	if msg.IsTool {
+		return renderToolMessage(m.renderer, msg, centeredWidth)
+	}
+
+	if msg.IsMeta {
+		return renderMetaLine(m.renderer, msg.Content, centeredWidth)
+	}
+
+	if msg.IsError {
+		return m.renderer.NewStyle().
+			Foreground(lipgloss.Color("#F44336")).
+			Width(centeredWidth).
+			Render(icon("✗", "") + plainText(msg.Content))
+	}
+
+	if msg.IsSystem {
+		return m.renderer.NewStyle().
+			Foreground(lipgloss.Color("#888888")).
+			Width(centeredWidth).
+			Render(plainText(msg.Content))
+	}
+
+	if msg.IsUser {
+		// User message - aligned to the right
+		return m.renderer.NewStyle().
+			Align(align(lipgloss.Right)).
+			Width(centeredWidth).
+			Render(
+				m.userStyle.Render("You") + "\n" +
+					m.userBubbleStyle.Render(msg.Content))
+	}
+
+	// Claude message - aligned to the left
+	claudeLine := m.claudeStyle.Render("Claude") + "\n" + m.claudeBubbleStyle.Render(msg.Content)
+	if msg.Meta != "" {
+		claudeLine += "\n" + renderMetaLine(m.renderer, msg.Meta, centeredWidth)
+	}
+	return claudeLine
+}
+
 func (m *model) renderWelcomeMessage() string {
-	centeredWidth := min(int(float64(m.width)*0.8), 180)
+	centeredWidth := m.chatWidth()
 
-	welcomeStyle := lipgloss.NewStyle().
+	welcomeStyle := m.renderer.NewStyle().
 		Foreground(lipgloss.Color("#888888")).
 		Italic(true).
-		Align(lipgloss.Center).
+		Align(align(lipgloss.Center)).
 		Width(centeredWidth)
 
-	return welcomeStyle.Render("Welcome to Coding Agent! 🤖\nType a message and press Enter to start building.")
+	return welcomeStyle.Render(plainText(locale.T("chat.welcome")))
 }
 
 func (m *model) updateViewport() {
@@ -231,99 +504,194 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		vpCmd tea.Cmd
 	)
 
-	m.textarea, tiCmd = m.textarea.Update(msg)
+	// Pending approvals block the running turn, so they take priority
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.pendingApproval != nil {
+		cmd := m.updateApprovalPrompt(keyMsg)
+		m.updateViewport()
+		return m, cmd
+	}
+
+	// The trust prompt must be answered before anything else
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.trustPrompt {
+		return m, m.updateTrustPrompt(keyMsg)
+	}
+
+	// The command palette captures all key presses while it is open
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.palette != nil {
+		chosen, closed, cmd := m.palette.update(keyMsg)
+		if closed {
+			m.palette = nil
+		}
+		if chosen != nil {
+			cmd = chosen.Action(&m)
+			m.scrollToLatest()
+		}
+		return m, cmd
+	}
+
+	// So does the bookmark picker
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.bookmarks != nil {
+		return m, m.updateBookmarkPicker(keyMsg)
+	}
+
+	// Send keys must not reach the textarea, which could otherwise act on them
+	if keyMsg, ok := msg.(tea.KeyMsg); !ok || !key.Matches(keyMsg, m.keys.send) {
+		m.textarea, tiCmd = m.textarea.Update(msg)
+	}
 	m.viewport, vpCmd = m.viewport.Update(msg)
+	if m.viewport.AtBottom() {
+		m.newContentBelow = false
+	}
 
 	switch msg := msg.(type) {
-	case streamingTextMsg:
+	case agentEventMsg:
 		if !m.isStreaming {
 			m.isStreaming = true
 			m.currentStreamingMessage = ""
+			m.currentThinking = ""
+		}
+
+		var countdown tea.Cmd
+		switch event := msg.event.(type) {
+		case agent.TextDelta:
+			// accumulate streaming text
+			m.currentStreamingMessage += event.Text
+		case agent.ThinkingDelta:
+			m.currentThinking += event.Text
+		case agent.ToolCallStarted:
+			m.startToolCall(event)
+		case agent.ToolResult:
+			m.finishToolCall(event)
+			if event.Name == tools.ManageTodosDefinition.Name {
+				m.refreshTodos()
+			}
+		case agent.Usage:
+			m.usage = m.usage.Add(event)
+		case agent.ResponseComplete:
+			m.addResponseMeta(event)
+		case agent.Notice:
+			m.flushStreamingMessage()
+			m.addSystemMessage(event.Text)
+		case agent.BudgetWarning:
+			m.flushStreamingMessage()
+			m.addSystemMessage(locale.T("chat.budget_warning", event.Used.TotalTokens(), event.Budget))
+		case agent.SpendAlert:
+			m.flushStreamingMessage()
+			m.addSystemMessage(locale.T("chat.spend_alert", event.LastHour, event.Threshold))
+		case agent.RateLimitWait:
+			countdown = m.startRateLimitWait(event)
+		case agent.Offline:
+			countdown = m.goOffline(event)
+		case agent.Online:
+			m.goOnline()
+		case agent.Error:
+			m.flushStreamingMessage()
+			m.messages = append(m.messages, ChatMessage{
+				Content: locale.T("chat.error", event.Err),
+				IsError: true,
+			})
+			m.lastTurnFailed = true
 		}
 
-		// accumulate streaming text
-		m.currentStreamingMessage += string(msg)
+		renderCmd := m.scheduleRender()
 
-		m.updateViewport()
-		m.viewport.GotoBottom()
+		if m.showPreview {
+			m.preview.refresh(m.agent)
+		}
 
 		// Continue listening for more streaming updates
-		return m, m.waitForStreamingText()
+		return m, tea.Batch(m.waitForTurnEvent(), renderCmd, countdown)
 
-	case streamingCompleteMsg:
-		if m.currentStreamingMessage != "" {
-			// Add the completed Claude message
-			m.messages = append(m.messages, ChatMessage{
-				Content: m.currentStreamingMessage,
-				IsUser:  false,
-			})
+	case renderTickMsg:
+		m.flushRender()
+		return m, nil
+
+	case rateLimitTickMsg:
+		if m.busy() && time.Now().Before(m.rateLimitUntil) {
+			return m, rateLimitTick()
+		}
+		return m, nil
+
+	case offlineTickMsg:
+		if m.busy() && m.offline {
+			return m, offlineTick()
 		}
+		return m, nil
 
-		m.isStreaming = false
-		m.streamingChan = nil
-		m.currentStreamingMessage = ""
+	case approvalMsg:
+		m.pendingApproval = &msg
+		return m, nil
 
-		m.updateViewport()
-		m.viewport.GotoBottom()
+	case fileChangesMsg:
+		return m, m.handleFileChanges(msg.paths)
 
+	case updateAvailableMsg:
+		availableUpdate = msg.version
 		return m, nil
 
-	case tea.WindowSizeMsg:
-		m.width = msg.Width
-		m.height = msg.Height
+	case streamingCompleteMsg:
+		m.flushStreamingMessage()
 
-		// Calculate centered dimensions
-		centeredWidth := min(int(float64(msg.Width)*0.8), 180)
+		m.isStreaming = false
+		m.events = nil
+		if m.cancelTurn != nil {
+			m.cancelTurn()
+		}
+		m.offline = false
+		m.renderDirty = false
 
-		m.viewport.Width = centeredWidth
-		m.textarea.SetWidth(centeredWidth)
+		// A finished task list is hidden once its turn is over
+		m.refreshTodos()
 
-		// Calculate heights
-		headerHeight := 3                     // header + blank line
-		footerHeight := 1                     // footer
-		gapHeight := lipgloss.Height(gap)     // gap between viewport and textarea
-		textareaHeight := m.textarea.Height() // textarea
+		// Summarize the files this turn touched
+		if changes := m.agent.ChangesSince(m.turnMarker); len(changes) > 0 {
+			m.lastTurnChanges = changes
+			m.addSystemMessage(renderChangeSummary(m.renderer, changes))
+		}
+		m.showPendingChanges()
 
-		// Set viewport height accounting for all other elements
-		m.viewport.Height = msg.Height - headerHeight - footerHeight - gapHeight - textareaHeight - 2 // extra padding
+		if m.showPreview {
+			m.preview.refresh(m.agent)
+		}
 
-		// Update bubble styles with new width (100% of centered width)
-		maxBubbleWidth := (centeredWidth * 10) / 10
-		m.userBubbleStyle = m.userBubbleStyle.MaxWidth(maxBubbleWidth)
-		m.claudeBubbleStyle = m.claudeBubbleStyle.MaxWidth(maxBubbleWidth)
+		m.followOutput()
 
-		m.updateViewport()
-		m.viewport.GotoBottom()
+		// Start the next queued message, if any
+		return m, m.sendQueued()
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+		m.resize()
+		m.keepPosition()
 
 	case tea.KeyMsg:
+		if key.Matches(msg, m.keys.send) {
+			return m, m.submitInput()
+		}
+		if m.scrollKey(msg) {
+			return m, nil
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			return m, tea.Quit
-		case tea.KeyCtrlJ:
-			value := m.textarea.Value()
-			m.textarea.SetValue(value + "\n")
+		case tea.KeyCtrlR:
+			return m, m.retryTurn()
+		case tea.KeyCtrlD:
+			m.showLastTurnDiff()
 			return m, nil
-		case tea.KeyEnter:
-			if msg.Alt {
-				break
-			}
-
-			inputMsg := strings.TrimSpace(m.textarea.Value())
-			if inputMsg == "" {
-				return m, nil
-			}
-
-			// Add user message
-			m.messages = append(m.messages, ChatMessage{
-				Content: inputMsg,
-				IsUser:  true,
-			})
-
-			m.updateViewport()
-			m.textarea.Reset()
-			m.viewport.GotoBottom()
-
-			return m, m.Run(context.TODO(), inputMsg)
+		case tea.KeyCtrlO:
+			m.togglePreview()
+			return m, nil
+		case tea.KeyCtrlB:
+			m.openBookmarkPicker()
+			return m, nil
+		case tea.KeyCtrlK:
+			p := newPalette(m.paletteItems())
+			m.palette = &p
+			return m, textinput.Blink
 		}
 
 	// We handle errors just like any other message
@@ -335,38 +703,159 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(tiCmd, vpCmd)
 }
 
+// submitInput sends the input box's contents as a message or slash command
+func (m *model) submitInput() tea.Cmd {
+	inputMsg := strings.TrimSpace(m.textarea.Value())
+	if inputMsg == "" {
+		return nil
+	}
+
+	if strings.HasPrefix(inputMsg, "/") {
+		m.textarea.Reset()
+		cmd := m.runSlashCommand(inputMsg)
+		m.scrollToLatest()
+		return cmd
+	}
+
+	// A mistyped modifier stays in the input box to be fixed
+	if _, _, err := agent.ParseTurnModifiers(inputMsg); err != nil {
+		m.addSystemMessage(err.Error())
+		m.scrollToLatest()
+		return nil
+	}
+
+	m.textarea.Reset()
+	return m.submitMessage(inputMsg)
+}
+
+// submitMessage sends a message, or holds it until the running turn is over
+func (m *model) submitMessage(inputMsg string) tea.Cmd {
+	// Hold messages sent mid-turn until the current turn completes
+	if m.busy() {
+		m.enqueue(inputMsg)
+		return nil
+	}
+
+	// Sending is a deliberate action, so the view follows the new turn
+	m.viewport.GotoBottom()
+	return m.sendMessage(inputMsg)
+}
+
+// renderStatusBar shows session state such as the working directory
+func (m *model) renderStatusBar(width int) string {
+	cwd := m.agent.WorkingDirectory()
+	if home, err := os.UserHomeDir(); err == nil && (cwd == home || strings.HasPrefix(cwd, home+string(filepath.Separator))) {
+		cwd = "~" + strings.TrimPrefix(cwd, home)
+	}
+
+	// Screen readers read the bullet out, so plain mode separates with commas
+	separator := " • "
+	tokens := "status.tokens"
+	if plainMode {
+		separator = ", "
+		tokens = "status.tokens_plain"
+	}
+
+	status := icon("📁", "") + cwd
+	if !m.agent.Trusted() {
+		status += separator + icon("🔒", "") + locale.T("status.read_only")
+	}
+	if m.agent.DryRun() {
+		status += separator + locale.T("status.dry_run")
+	}
+	if pending := m.agent.Workspace().StagedCount(); pending > 0 {
+		status += separator + locale.T("status.pending", pending)
+	}
+	if profile := m.agent.Profile(); profile != "" {
+		status += separator + icon("👤", locale.T("status.profile")+" ") + profile
+	}
+	if m.usage.InputTokens > 0 || m.usage.OutputTokens > 0 {
+		status += separator + locale.T(tokens, m.usage.InputTokens, m.usage.OutputTokens)
+	}
+	if used, ok := m.agent.BudgetUsed(); ok {
+		status += separator + locale.T("status.budget", int(used*100))
+	}
+	if m.busy() {
+		if countdown := m.rateLimitStatus(); countdown != "" {
+			status += separator + countdown
+		}
+		if offline := m.offlineStatus(); offline != "" {
+			status += separator + offline
+		}
+	}
+	if indicator := m.scrollIndicator(); indicator != "" {
+		status += separator + indicator
+	}
+	if availableUpdate != "" {
+		key := "status.update"
+		if !update.CanInstall() {
+			key = "status.update_manual"
+		}
+		status += separator + icon("⬆", "") + locale.T(key, availableUpdate)
+	}
+
+	return m.renderer.NewStyle().
+		Foreground(lipgloss.Color("#888888")).
+		Width(width).
+		MaxHeight(1).
+		Render(status)
+}
+
 func (m model) View() string {
 	// Calculate centered width (80% of terminal width, max 180 chars)
-	centeredWidth := min(int(float64(m.width)*0.8), 180)
+	centeredWidth := m.contentWidth()
 	leftPadding := (m.width - centeredWidth) / 2
 
-	header := lipgloss.NewStyle().
+	header := m.renderer.NewStyle().
 		Bold(true).
 		Padding(0, 4).
 		Width(centeredWidth).
-		Align(lipgloss.Center).
-		Render("🤖 Coding Agent")
+		Align(align(lipgloss.Center)).
+		Render(plainText(locale.T("chat.title")))
 
-	footer := lipgloss.NewStyle().
+	footer := m.renderer.NewStyle().
 		Foreground(lipgloss.Color("#666666")).
 		Width(centeredWidth).
-		Align(lipgloss.Center).
-		Render("Press Ctrl+C or Esc to quit • Enter to send message • Ctrl+j insert new line")
+		Align(align(lipgloss.Center)).
+		Render(locale.T("chat.footer", m.keys.send.Help().Key, m.keys.newline.Help().Key))
+
+	statusBar := m.renderStatusBar(centeredWidth)
+
+	// Center the viewport content, or show the command palette in its place
+	body := m.viewport.View()
+	if m.palette != nil {
+		body = lipgloss.PlaceVertical(m.viewport.Height, lipgloss.Top, m.palette.view(m.renderer, centeredWidth, m.viewport.Height))
+	}
+	if m.bookmarks != nil {
+		body = lipgloss.PlaceVertical(m.viewport.Height, lipgloss.Top, m.renderBookmarkPicker(centeredWidth))
+	}
+	if m.trustPrompt {
+		body = lipgloss.PlaceVertical(m.viewport.Height, lipgloss.Top, m.renderTrustPrompt(centeredWidth))
+	}
+	if m.pendingApproval != nil {
+		body = lipgloss.PlaceVertical(m.viewport.Height, lipgloss.Top, m.renderApprovalPrompt(centeredWidth))
+	}
+
+	if m.showPreview && m.palette == nil && m.bookmarks == nil && !m.trustPrompt && m.pendingApproval == nil {
+		body = lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			m.renderer.NewStyle().Width(m.chatWidth()).Render(body),
+			m.preview.view(),
+		)
+	}
 
-	// Center the viewport content
-	centeredViewport := lipgloss.NewStyle().
+	centeredViewport := m.renderer.NewStyle().
 		Width(centeredWidth).
-		Render(m.viewport.View())
+		Render(body)
 
 		// Center the textarea with styling
-	centeredTextarea := lipgloss.NewStyle().
+	textareaStyle := m.renderer.NewStyle().
 		Width(centeredWidth).
 		Background(lipgloss.Color("#1e1e1e")).
 		Foreground(lipgloss.Color("#ffffff")).
 		Padding(0, 2).
-		Border(lipgloss.RoundedBorder()).
-		BorderForeground(lipgloss.Color("#404040")).
-		Render(m.textarea.View())
+		BorderForeground(lipgloss.Color("#404040"))
+	centeredTextarea := withBorder(textareaStyle, lipgloss.RoundedBorder()).Render(m.textarea.View())
 
 	// Create the main content
 	content := lipgloss.JoinVertical(
@@ -374,13 +863,16 @@ func (m model) View() string {
 		header,
 		"",
 		centeredViewport,
-		gap,
+		m.renderGap(centeredWidth),
+		m.renderTodoPanel(centeredWidth),
+		m.renderQueuePanel(centeredWidth),
 		centeredTextarea,
+		statusBar,
 		footer,
 	)
 
 	// Center everything horizontally
-	return lipgloss.NewStyle().
+	return m.renderer.NewStyle().
 		PaddingLeft(leftPadding).
 		Render(content)
 }
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"agent/locale"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runCheckpointCommand saves a named restore point, or lists them without a name
+func runCheckpointCommand(m *model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+
+	switch {
+	case len(fields) == 0:
+		checkpoints := m.agent.Checkpoints()
+		if len(checkpoints) == 0 {
+			m.addSystemMessage(locale.T("checkpoint.none"))
+			return nil
+		}
+
+		var b strings.Builder
+		b.WriteString(locale.T("checkpoint.list") + "\n")
+		for _, checkpoint := range checkpoints {
+			b.WriteString(fmt.Sprintf("  %-20s %s\n", checkpoint.Name, checkpoint.Created.Format("15:04:05")))
+		}
+		m.addSystemMessage(strings.TrimRight(b.String(), "\n"))
+
+	case len(fields) > 1:
+		m.addSystemMessage(locale.T("checkpoint.usage"))
+
+	case m.busy():
+		m.addSystemMessage(locale.T("checkpoint.busy"))
+
+	default:
+		name := fields[0]
+		m.agent.CreateCheckpoint(m.session, name)
+
+		if m.checkpoints == nil {
+			m.checkpoints = map[string][]ChatMessage{}
+		}
+		m.checkpoints[name] = append([]ChatMessage(nil), m.messages...)
+
+		m.addSystemMessage(locale.T("checkpoint.saved", name, name))
+	}
+
+	return nil
+}
+
+// runRestoreCommand previews what restoring a checkpoint undoes, and
+// restores it once confirmed
+func runRestoreCommand(m *model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 || len(fields) == 2 && fields[1] != "confirm" {
+		m.addSystemMessage(locale.T("restore.usage"))
+		return nil
+	}
+	if m.busy() {
+		m.addSystemMessage(locale.T("restore.busy"))
+		return nil
+	}
+
+	name := fields[0]
+	changes, err := m.agent.CheckpointChanges(name)
+	if err != nil {
+		m.addSystemMessage(locale.T("restore.unknown", name))
+		return nil
+	}
+
+	if len(fields) == 1 {
+		preview := locale.T("restore.preview_none", name)
+		if len(changes) > 0 {
+			preview = locale.T("restore.preview", name) + "\n" + renderRevertActions(m.renderer, changes)
+		}
+		m.addSystemMessage(preview + locale.T("restore.confirm", name))
+		return nil
+	}
+
+	reverted, err := m.agent.RestoreCheckpoint(m.session, name)
+	if err != nil {
+		m.addSystemMessage(locale.T("revert.failed", len(reverted), err))
+		return nil
+	}
+
+	m.messages = append([]ChatMessage(nil), m.checkpoints[name]...)
+	m.lastTurnChanges = nil
+	m.refreshTodos()
+	m.addSystemMessage(locale.T("restore.done", name, len(reverted)))
+	return nil
+}
@@ -0,0 +1,586 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/config"
+	"agent/locale"
+	"agent/log"
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// slashCommand is a command the user can type into the input box, e.g. "/clear"
+type slashCommand struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(m *model, args string) tea.Cmd
+}
+
+// slashCommands returns all available slash commands sorted by name
+func slashCommands() []slashCommand {
+	commands := []slashCommand{
+		{
+			Name:        "diff",
+			Description: locale.T("command.diff"),
+			Run: func(m *model, args string) tea.Cmd {
+				m.showLastTurnDiff()
+				return nil
+			},
+		},
+		{
+			Name:        "add",
+			Usage:       "[<path>... | --clear]",
+			Description: locale.T("command.add"),
+			Run:         runAddCommand,
+		},
+		{
+			Name:        "apply",
+			Description: locale.T("command.apply"),
+			Run:         runApplyCommand,
+		},
+		{
+			Name:        "bookmark",
+			Description: locale.T("command.bookmark"),
+			Run: func(m *model, args string) tea.Cmd {
+				m.openBookmarkPicker()
+				return nil
+			},
+		},
+		{
+			Name:        "budget",
+			Usage:       "[<tokens>|$<dollars> [hard] | off]",
+			Description: locale.T("command.budget"),
+			Run:         runBudgetCommand,
+		},
+		{
+			Name:        "discard",
+			Description: locale.T("command.discard"),
+			Run:         runDiscardCommand,
+		},
+		{
+			Name:        "dry-run",
+			Usage:       "[on|off]",
+			Description: locale.T("command.dry_run"),
+			Run:         runDryRunCommand,
+		},
+		{
+			Name:        "help",
+			Description: locale.T("command.help"),
+			Run: func(m *model, args string) tea.Cmd {
+				m.addSystemMessage(helpText())
+				return nil
+			},
+		},
+		{
+			Name:        "cd",
+			Usage:       "<path>",
+			Description: locale.T("command.cd"),
+			Run: func(m *model, args string) tea.Cmd {
+				if args == "" {
+					m.addSystemMessage(locale.T("cd.current", m.agent.WorkingDirectory()))
+					return nil
+				}
+				if m.busy() {
+					m.addSystemMessage(locale.T("cd.busy"))
+					return nil
+				}
+				if err := m.agent.SetWorkingDirectory(args); err != nil {
+					m.addSystemMessage(locale.T("cd.failed", err))
+					return nil
+				}
+				m.addSystemMessage(locale.T("cd.changed", m.agent.WorkingDirectory()))
+				m.applyWorkspace()
+				return nil
+			},
+		},
+		{
+			Name:        "checkpoint",
+			Usage:       "[<name>]",
+			Description: locale.T("command.checkpoint"),
+			Run:         runCheckpointCommand,
+		},
+		{
+			Name:        "clear",
+			Description: locale.T("command.clear"),
+			Run: func(m *model, args string) tea.Cmd {
+				if m.busy() {
+					m.addSystemMessage(locale.T("clear.busy"))
+					return nil
+				}
+				m.messages = []ChatMessage{}
+				m.session.Reset()
+				m.agent.Workspace().SetTodos(nil)
+				m.refreshTodos()
+				return nil
+			},
+		},
+		{
+			Name:        "pin",
+			Usage:       "[<path>... | auto on|off]",
+			Description: locale.T("command.pin"),
+			Run:         runPinCommand,
+		},
+		{
+			Name:        "preview",
+			Description: locale.T("command.preview"),
+			Run: func(m *model, args string) tea.Cmd {
+				m.togglePreview()
+				return nil
+			},
+		},
+		{
+			Name:        "profile",
+			Usage:       "[<name>]",
+			Description: locale.T("command.profile"),
+			Run:         runProfileCommand,
+		},
+		{
+			Name:        "restore",
+			Usage:       "<name> [confirm]",
+			Description: locale.T("command.restore"),
+			Run:         runRestoreCommand,
+		},
+		{
+			Name:        "retry",
+			Description: locale.T("command.retry"),
+			Run: func(m *model, args string) tea.Cmd {
+				return m.retryTurn()
+			},
+		},
+		{
+			Name:        "revert-session",
+			Usage:       "[confirm]",
+			Description: locale.T("command.revert_session"),
+			Run:         runRevertSessionCommand,
+		},
+		{
+			Name:        "router",
+			Usage:       "[on|off]",
+			Description: locale.T("command.router"),
+			Run:         runRouterCommand,
+		},
+		{
+			Name:        "roots",
+			Usage:       "[add <name> <path> | remove <name>]",
+			Description: locale.T("command.roots"),
+			Run:         runRootsCommand,
+		},
+		{
+			Name:        "queue",
+			Usage:       "[send | edit <n> | drop <n> | clear]",
+			Description: locale.T("command.queue"),
+			Run:         runQueueCommand,
+		},
+		{
+			Name:        "stats",
+			Usage:       "[tools]",
+			Description: locale.T("command.stats"),
+			Run:         runStatsCommand,
+		},
+		{
+			Name:        "tab",
+			Usage:       "[new [<dir>] | close | <n>]",
+			Description: locale.T("command.tab"),
+			Run:         runTabCommand,
+		},
+		{
+			Name:        "think",
+			Usage:       "[<tokens>] <message>",
+			Description: locale.T("command.think"),
+			Run:         runThinkCommand,
+		},
+		{
+			Name:        "todos",
+			Description: locale.T("command.todos"),
+			Run: func(m *model, args string) tea.Cmd {
+				m.addSystemMessage(m.todoSummary())
+				return nil
+			},
+		},
+		{
+			Name:        "trash",
+			Usage:       "[restore <n> | empty [confirm]]",
+			Description: locale.T("command.trash"),
+			Run:         runTrashCommand,
+		},
+		{
+			Name:        "trust",
+			Description: locale.T("command.trust"),
+			Run: func(m *model, args string) tea.Cmd {
+				m.setTrust(config.TrustGranted)
+				return nil
+			},
+		},
+		{
+			Name:        "unpin",
+			Usage:       "<path>...",
+			Description: locale.T("command.unpin"),
+			Run: func(m *model, args string) tea.Cmd {
+				for _, path := range strings.Fields(args) {
+					if !m.agent.Unpin(path) {
+						m.addSystemMessage(locale.T("pin.not_pinned", path))
+						return nil
+					}
+				}
+				m.addSystemMessage(pinnedSummary(m.agent))
+				return nil
+			},
+		},
+		{
+			Name:        "untrust",
+			Description: locale.T("command.untrust"),
+			Run: func(m *model, args string) tea.Cmd {
+				m.setTrust(config.TrustRestricted)
+				return nil
+			},
+		},
+		{
+			Name:        "quit",
+			Description: locale.T("command.quit"),
+			Run: func(m *model, args string) tea.Cmd {
+				return tea.Quit
+			},
+		},
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+
+	return commands
+}
+
+// findSlashCommand looks up a slash command by name
+func findSlashCommand(name string) (slashCommand, bool) {
+	for _, command := range slashCommands() {
+		if command.Name == name {
+			return command, true
+		}
+	}
+
+	return slashCommand{}, false
+}
+
+// runSlashCommand parses and executes a "/name args" input line
+func (m *model) runSlashCommand(input string) tea.Cmd {
+	name, args, _ := strings.Cut(strings.TrimPrefix(input, "/"), " ")
+
+	command, ok := findSlashCommand(name)
+	if !ok {
+		m.addSystemMessage(locale.T("command.unknown", name))
+		return nil
+	}
+
+	log.Debug("slash command", "command", command.Name, "args", strings.TrimSpace(args))
+	return command.Run(m, strings.TrimSpace(args))
+}
+
+// helpText lists the slash commands and key bindings
+func helpText() string {
+	var b strings.Builder
+
+	b.WriteString(locale.T("help.commands") + "\n")
+	for _, command := range slashCommands() {
+		usage := "/" + command.Name
+		if command.Usage != "" {
+			usage += " " + command.Usage
+		}
+		b.WriteString(fmt.Sprintf("  %-20s %s\n", usage, command.Description))
+	}
+
+	b.WriteString("\n" + locale.T("help.keys") + "\n")
+	for _, binding := range [][2]string{
+		{"Ctrl+K", locale.T("help.key_palette")},
+		{"Ctrl+R", locale.T("help.key_retry")},
+		{"Ctrl+D", locale.T("help.key_diff")},
+		{"Ctrl+O", locale.T("help.key_preview")},
+		{"Ctrl+B", locale.T("help.key_bookmark")},
+		{"Ctrl+J", locale.T("help.key_newline")},
+		{"Alt+1…9", locale.T("help.key_tab")},
+		{"Ctrl+C / Esc", locale.T("help.key_quit")},
+	} {
+		b.WriteString(fmt.Sprintf("  %-20s %s\n", binding[0], binding[1]))
+	}
+
+	return b.String()
+}
+
+// runRootsCommand manages the additional named workspace roots
+func runRootsCommand(m *model, args string) tea.Cmd {
+	workspace := m.agent.Workspace()
+	fields := strings.Fields(args)
+
+	switch {
+	case len(fields) == 0:
+		var b strings.Builder
+		b.WriteString(locale.T("roots.title") + "\n")
+		b.WriteString(fmt.Sprintf("  %-12s %s\n", "("+locale.T("roots.primary")+")", workspace.Root()))
+		for _, root := range workspace.Roots() {
+			line := fmt.Sprintf("  %-12s %s", root.Name+":", root.Path)
+			if !workspace.RootTrusted(root.Name) {
+				line += "  (" + locale.T("roots.untrusted") + ")"
+			}
+			b.WriteString(line + "\n")
+		}
+		m.addSystemMessage(strings.TrimSuffix(b.String(), "\n"))
+
+	case fields[0] == "add" && len(fields) == 3:
+		if err := workspace.AddRoot(fields[1], fields[2]); err != nil {
+			m.addSystemMessage(locale.T("roots.add_failed", err))
+			return nil
+		}
+		if !workspace.RootTrusted(fields[1]) {
+			m.addSystemMessage(locale.T("roots.added_untrusted", fields[1], fields[2]))
+			return nil
+		}
+		m.addSystemMessage(locale.T("roots.added", fields[1], fields[2]))
+
+	case fields[0] == "remove" && len(fields) == 2:
+		if err := workspace.RemoveRoot(fields[1]); err != nil {
+			m.addSystemMessage(locale.T("roots.remove_failed", err))
+			return nil
+		}
+		m.addSystemMessage(locale.T("roots.removed", fields[1]))
+
+	default:
+		m.addSystemMessage(locale.T("usage", "/roots [add <name> <path> | remove <name>]"))
+	}
+
+	return nil
+}
+
+// runRevertSessionCommand previews the session's changes, and reverts them once confirmed
+func runRevertSessionCommand(m *model, args string) tea.Cmd {
+	if m.busy() {
+		m.addSystemMessage(locale.T("revert.busy"))
+		return nil
+	}
+
+	changes := m.agent.SessionChanges()
+	if len(changes) == 0 {
+		m.addSystemMessage(locale.T("revert.none"))
+		return nil
+	}
+
+	if args != "confirm" {
+		m.addSystemMessage(locale.T("revert.preview") + "\n" + renderRevertActions(m.renderer, changes) + locale.T("revert.confirm"))
+		return nil
+	}
+
+	reverted, err := m.agent.RevertSession()
+	if err != nil {
+		m.addSystemMessage(locale.T("revert.failed", len(reverted), err))
+		return nil
+	}
+
+	m.addSystemMessage(locale.T("revert.done", len(reverted)))
+	return nil
+}
+
+// renderRevertActions lists what reverting each change will do to the file
+func renderRevertActions(renderer *lipgloss.Renderer, changes []agent.FileChange) string {
+	styles := newDiffStyles(renderer)
+	var b strings.Builder
+	for _, change := range changes {
+		action := locale.T("revert.restore")
+		switch {
+		case !change.Restorable:
+			action = locale.T("revert.skip")
+		case change.Status == agent.ChangeCreated:
+			action = locale.T("revert.delete")
+		case change.Status == agent.ChangeDeleted:
+			action = locale.T("revert.recreate")
+		}
+		b.WriteString(fmt.Sprintf("  %-9s %s  %s %s\n",
+			action,
+			change.Path,
+			styles.added.Render(fmt.Sprintf("+%d", change.Added)),
+			styles.removed.Render(fmt.Sprintf("-%d", change.Removed)),
+		))
+	}
+
+	return b.String()
+}
+
+// runThinkCommand sends a message with extended thinking for that turn only,
+// like the !think modifier
+func runThinkCommand(m *model, args string) tea.Cmd {
+	if args == "" {
+		m.addSystemMessage(locale.T("think.usage"))
+		return nil
+	}
+
+	input := "!think " + args
+	if _, _, err := agent.ParseTurnModifiers(input); err != nil {
+		m.addSystemMessage(err.Error())
+		return nil
+	}
+	return m.submitMessage(input)
+}
+
+// runRouterCommand shows the model router or turns it on or off
+func runRouterCommand(m *model, args string) tea.Cmd {
+	switch args {
+	case "":
+	case "on":
+		if m.agent.ModelRouter() == nil {
+			router := &config.ModelRouter{}
+			if settings, err := config.LoadSettings(); err == nil && settings.ModelRouter != nil {
+				router = settings.ModelRouter
+			}
+			m.agent.SetModelRouter(router)
+		}
+	case "off":
+		m.agent.SetModelRouter(nil)
+	default:
+		m.addSystemMessage(locale.T("router.usage"))
+		return nil
+	}
+
+	router := m.agent.ModelRouter()
+	if router == nil {
+		m.addSystemMessage(locale.T("router.off", m.agent.Model()))
+		return nil
+	}
+	light, heavy := router.Light, router.Heavy
+	if light == "" {
+		light = string(agent.DefaultLightModel)
+	}
+	if heavy == "" {
+		heavy = string(agent.DefaultHeavyModel)
+	}
+	m.addSystemMessage(locale.T("router.on", light, m.agent.Model(), heavy))
+	return nil
+}
+
+// runBudgetCommand shows or changes the session budget
+func runBudgetCommand(m *model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+
+	switch {
+	case len(fields) == 0:
+		usage := m.agent.SessionUsage()
+		text := locale.T("budget.status", m.agent.Budget(), usage.TotalTokens())
+		if cost, ok := m.agent.SessionCost(); ok {
+			text += fmt.Sprintf(" ($%.4f)", cost)
+		}
+		if used, ok := m.agent.BudgetUsed(); ok {
+			text += locale.T("budget.used", int(used*100))
+		}
+		m.addSystemMessage(text)
+
+	case fields[0] == "off" && len(fields) == 1:
+		m.agent.SetBudget(agent.Budget{})
+		m.addSystemMessage(locale.T("budget.cleared", m.agent.Budget()))
+
+	case len(fields) <= 2:
+		budget, err := agent.ParseBudget(fields[0])
+		if err != nil {
+			m.addSystemMessage(err.Error())
+			return nil
+		}
+		if len(fields) == 2 {
+			if fields[1] != "hard" {
+				m.addSystemMessage(locale.T("usage", "/budget [<tokens>|$<dollars> [hard] | off]"))
+				return nil
+			}
+			budget.Hard = true
+		}
+		m.agent.SetBudget(budget)
+		m.addSystemMessage(locale.T("budget.set", budget))
+
+	default:
+		m.addSystemMessage(locale.T("usage", "/budget [<tokens>|$<dollars> [hard] | off]"))
+	}
+
+	return nil
+}
+
+// runProfileCommand lists the configured profiles or switches the active one
+func runProfileCommand(m *model, args string) tea.Cmd {
+	if args == "" {
+		settings, err := config.LoadSettings()
+		if err != nil {
+			m.addSystemMessage(err.Error())
+			return nil
+		}
+
+		names := settings.ProfileNames()
+		if len(names) == 0 {
+			m.addSystemMessage(locale.T("profile.none"))
+			return nil
+		}
+
+		var text strings.Builder
+		text.WriteString(locale.T("profile.title"))
+		for _, name := range names {
+			marker := "  "
+			if name == m.agent.Profile() {
+				marker = "* "
+			}
+			text.WriteString("\n" + marker + name)
+			if model := settings.Profiles[name].Model; model != "" {
+				text.WriteString(" (" + model + ")")
+			}
+		}
+		m.addSystemMessage(text.String())
+		return nil
+	}
+
+	if m.busy() {
+		m.addSystemMessage(locale.T("profile.busy"))
+		return nil
+	}
+	if err := m.agent.SwitchProfile(args); err != nil {
+		m.addSystemMessage(locale.T("profile.failed", err))
+		return nil
+	}
+
+	m.addSystemMessage(locale.T("profile.switched", m.agent.Profile(), m.agent.Model()))
+	return nil
+}
+
+// runPinCommand lists the pinned files, pins more, or toggles automatic pinning
+func runPinCommand(m *model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+
+	switch {
+	case len(fields) == 0:
+		m.addSystemMessage(pinnedSummary(m.agent))
+
+	case fields[0] == "auto" && len(fields) == 2 && (fields[1] == "on" || fields[1] == "off"):
+		m.agent.SetAutoPin(fields[1] == "on")
+		m.addSystemMessage(pinnedSummary(m.agent))
+
+	default:
+		for _, path := range fields {
+			if err := m.agent.Pin(path); err != nil {
+				m.addSystemMessage(locale.T("pin.failed", path, err))
+				return nil
+			}
+		}
+		m.addSystemMessage(pinnedSummary(m.agent))
+	}
+
+	return nil
+}
+
+// pinnedSummary describes the pinned context
+func pinnedSummary(agentApp *agent.Agent) string {
+	auto := locale.T("pin.off")
+	if agentApp.AutoPin() {
+		auto = locale.T("pin.on")
+	}
+
+	files := agentApp.PinnedFiles()
+	if len(files) == 0 {
+		return locale.T("pin.none", auto)
+	}
+
+	return locale.T("pin.list", auto, strings.Join(files, "\n  "))
+}
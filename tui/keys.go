@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"slices"
+	"strings"
+
+	"agent/config"
+	"agent/locale"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// inputKeys are the bindings that send the message or break the line in the input box
+type inputKeys struct {
+	send    key.Binding
+	newline key.Binding
+}
+
+// newInputKeys builds the input bindings from the user's settings. A key
+// bound to both actions sends.
+func newInputKeys(keys config.KeysConfig) inputKeys {
+	newline := []string{}
+	for _, k := range keys.Newline {
+		if !slices.Contains(keys.Send, k) {
+			newline = append(newline, k)
+		}
+	}
+
+	return inputKeys{
+		send:    key.NewBinding(key.WithKeys(keys.Send...), key.WithHelp(keyNames(keys.Send), locale.T("keys.send"))),
+		newline: key.NewBinding(key.WithKeys(newline...), key.WithHelp(keyNames(newline), locale.T("keys.newline"))),
+	}
+}
+
+// keyNames renders key names for the footer, e.g. "Alt+Enter/Ctrl+J"
+func keyNames(keys []string) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		parts := strings.Split(k, "+")
+		for j, part := range parts {
+			if len(part) > 1 {
+				part = strings.ToUpper(part[:1]) + part[1:]
+			} else {
+				part = strings.ToUpper(part)
+			}
+			parts[j] = part
+		}
+		names[i] = strings.Join(parts, "+")
+	}
+
+	return strings.Join(names, "/")
+}
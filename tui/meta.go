@@ -0,0 +1,59 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// formatResponseMeta summarizes a model response: elapsed time, output
+// tokens, stop reason and, when the router picked it, the model
+func formatResponseMeta(event agent.ResponseComplete) string {
+	parts := []string{
+		formatDuration(event.Elapsed),
+		locale.T("meta.tokens", event.OutputTokens),
+	}
+
+	if event.StopReason == "max_tokens" {
+		parts = append(parts, "⚠ "+locale.T("meta.cut_off"))
+	} else if event.StopReason != "" {
+		parts = append(parts, event.StopReason)
+	}
+
+	if event.RouteReason != "" {
+		parts = append(parts, locale.T("meta.routed", event.Model, event.RouteReason))
+	}
+
+	return strings.Join(parts, " • ")
+}
+
+// addResponseMeta moves the streamed text of a finished response into the
+// history and attaches its metadata line
+func (m *model) addResponseMeta(event agent.ResponseComplete) {
+	hadText := m.currentStreamingMessage != ""
+	m.flushStreamingMessage()
+
+	meta := formatResponseMeta(event)
+	if hadText {
+		m.messages[len(m.messages)-1].Meta = meta
+		return
+	}
+
+	m.messages = append(m.messages, ChatMessage{Content: meta, IsMeta: true})
+}
+
+// renderMetaLine renders a response metadata line, highlighting truncated output
+func renderMetaLine(renderer *lipgloss.Renderer, meta string, width int) string {
+	color := lipgloss.Color("#555555")
+	if strings.Contains(meta, "max_tokens") {
+		color = lipgloss.Color("#FFA726")
+	}
+
+	return renderer.NewStyle().
+		Foreground(color).
+		Italic(true).
+		Width(width).
+		Render(plainText(meta))
+}
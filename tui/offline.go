@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// offlineTickMsg counts down to the next attempt to reach the API in the status bar
+type offlineTickMsg struct{}
+
+func offlineTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return offlineTickMsg{}
+	})
+}
+
+// goOffline pauses the streaming view while the agent waits for the
+// connection to come back. Text streamed before the connection dropped is
+// discarded, since the response is requested again from the start.
+func (m *model) goOffline(event agent.Offline) tea.Cmd {
+	m.currentStreamingMessage = ""
+	m.currentThinking = ""
+	m.offlineRetry = event.Retry
+
+	// A countdown that is still running keeps ticking on its own
+	if m.offline {
+		return nil
+	}
+	m.offline = true
+	m.addSystemMessage(locale.T("chat.offline", event.Err))
+	return offlineTick()
+}
+
+// goOnline clears the offline indicator once the paused turn resumes
+func (m *model) goOnline() {
+	if !m.offline {
+		return
+	}
+	m.offline = false
+	m.addSystemMessage(locale.T("chat.online"))
+}
+
+// offlineStatus is the offline indicator for the status bar, or "" when online
+func (m *model) offlineStatus() string {
+	if !m.offline {
+		return ""
+	}
+
+	remaining := time.Until(m.offlineRetry)
+	if remaining <= 0 {
+		return icon("📡", "") + locale.T("status.offline_retrying")
+	}
+	seconds := int(remaining.Round(time.Second) / time.Second)
+	return icon("📡", "") + locale.T("status.offline", max(seconds, 1))
+}
@@ -0,0 +1,329 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const paletteMaxVisible = 10
+
+// maxPaletteSessions caps the saved conversations offered for resuming
+const maxPaletteSessions = 10
+
+// paletteItem is a single selectable entry in the command palette
+type paletteItem struct {
+	Kind        string
+	Title       string
+	Description string
+	Action      func(m *model) tea.Cmd
+}
+
+// palette is the fuzzy-searchable command palette opened with Ctrl+K
+type palette struct {
+	input    textinput.Model
+	items    []paletteItem
+	filtered []paletteItem
+	selected int
+}
+
+func newPalette(items []paletteItem) palette {
+	ti := textinput.New()
+	ti.Placeholder = locale.T("palette.placeholder")
+	ti.Prompt = "> "
+	ti.Focus()
+
+	p := palette{
+		input: ti,
+		items: items,
+	}
+	p.filter()
+
+	return p
+}
+
+// filter narrows the item list to entries fuzzily matching the query, best matches first
+func (p *palette) filter() {
+	query := p.input.Value()
+
+	type scored struct {
+		item  paletteItem
+		score int
+	}
+
+	matches := []scored{}
+	for _, item := range p.items {
+		score, ok := fuzzyScore(query, item.Title)
+		if !ok {
+			continue
+		}
+		matches = append(matches, scored{item: item, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	p.filtered = make([]paletteItem, 0, len(matches))
+	for _, match := range matches {
+		p.filtered = append(p.filtered, match.item)
+	}
+
+	if p.selected >= len(p.filtered) {
+		p.selected = max(len(p.filtered)-1, 0)
+	}
+}
+
+// fuzzyScore reports whether every rune of pattern appears in order in text,
+// scoring consecutive matches and matches at word starts higher
+func fuzzyScore(pattern, text string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+
+	patternRunes := []rune(strings.ToLower(pattern))
+	textRunes := []rune(strings.ToLower(text))
+
+	score := 0
+	patternIdx := 0
+	lastMatch := -1
+
+	for i, r := range textRunes {
+		if patternIdx >= len(patternRunes) {
+			break
+		}
+		if r != patternRunes[patternIdx] {
+			continue
+		}
+
+		score++
+		if lastMatch == i-1 {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(textRunes[i-1]) {
+			score += 3
+		}
+
+		lastMatch = i
+		patternIdx++
+	}
+
+	if patternIdx < len(patternRunes) {
+		return 0, false
+	}
+
+	// Prefer shorter candidates when scores tie
+	return score*100 - len(textRunes), true
+}
+
+// update handles key presses while the palette is open. It returns the
+// palette item chosen by the user, if any, and whether the palette should close.
+func (p *palette) update(msg tea.KeyMsg) (chosen *paletteItem, closed bool, cmd tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlK:
+		return nil, true, nil
+
+	case tea.KeyUp, tea.KeyCtrlP:
+		if p.selected > 0 {
+			p.selected--
+		}
+		return nil, false, nil
+
+	case tea.KeyDown, tea.KeyCtrlN:
+		if p.selected < len(p.filtered)-1 {
+			p.selected++
+		}
+		return nil, false, nil
+
+	case tea.KeyEnter:
+		if len(p.filtered) == 0 {
+			return nil, false, nil
+		}
+		item := p.filtered[p.selected]
+		return &item, true, nil
+	}
+
+	p.input, cmd = p.input.Update(msg)
+	p.filter()
+
+	return nil, false, cmd
+}
+
+func (p *palette) view(renderer *lipgloss.Renderer, width, height int) string {
+	kindStyle := renderer.NewStyle().Foreground(lipgloss.Color("#888888"))
+	selectedStyle := renderer.NewStyle().Foreground(lipgloss.Color("#FF6B35")).Bold(true)
+	descriptionStyle := renderer.NewStyle().Foreground(lipgloss.Color("#666666"))
+
+	lines := []string{p.input.View(), ""}
+
+	// Keep the selected entry within the visible window
+	visible := min(paletteMaxVisible, max(height-4, 1))
+	start := 0
+	if p.selected >= visible {
+		start = p.selected - visible + 1
+	}
+	end := min(start+visible, len(p.filtered))
+
+	for i := start; i < end; i++ {
+		item := p.filtered[i]
+
+		title := item.Title
+		if i == p.selected {
+			title = selectedStyle.Render(selectionMarker() + title)
+		} else {
+			title = "  " + title
+		}
+
+		line := fmt.Sprintf("%s %s", kindStyle.Render(fmt.Sprintf("%-8s", item.Kind)), title)
+		if item.Description != "" {
+			line += "  " + descriptionStyle.Render(item.Description)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(p.filtered) == 0 {
+		lines = append(lines, descriptionStyle.Render("  "+locale.T("palette.no_matches")))
+	}
+
+	boxStyle := renderer.NewStyle().
+		Width(width-2).
+		BorderForeground(lipgloss.Color("#FF6B35")).
+		Padding(0, 1)
+
+	return withBorder(boxStyle, lipgloss.RoundedBorder()).Render(strings.Join(lines, "\n"))
+}
+
+// paletteItems collects everything the palette can offer: slash commands and recently touched files
+func (m *model) paletteItems() []paletteItem {
+	items := []paletteItem{}
+
+	for _, command := range slashCommands() {
+		command := command
+		items = append(items, paletteItem{
+			Kind:        "command",
+			Title:       "/" + command.Name,
+			Description: command.Description,
+			Action: func(m *model) tea.Cmd {
+				// Commands that take arguments are pre-filled so the user can complete them
+				if command.Usage != "" {
+					m.textarea.SetValue("/" + command.Name + " ")
+					return nil
+				}
+				return command.Run(m, "")
+			},
+		})
+	}
+
+	for i, title := range m.openTabs {
+		n := i + 1
+		items = append(items, paletteItem{
+			Kind:        "tab",
+			Title:       fmt.Sprintf("%d %s", n, title),
+			Description: locale.T("palette.switch_tab"),
+			Action: func(m *model) tea.Cmd {
+				return func() tea.Msg { return switchTabMsg{n: n} }
+			},
+		})
+	}
+
+	items = append(items, m.modeItems()...)
+
+	for _, saved := range agent.RecentSessions(maxPaletteSessions) {
+		saved := saved
+		items = append(items, paletteItem{
+			Kind:        "session",
+			Title:       saved.Dir,
+			Description: locale.T("palette.resume", saved.Saved.Local().Format("2006-01-02 15:04")),
+			Action: func(m *model) tea.Cmd {
+				return m.resumeSaved(saved)
+			},
+		})
+	}
+
+	for _, path := range m.agent.RecentFiles(20) {
+		path := path
+		items = append(items, paletteItem{
+			Kind:        "file",
+			Title:       path,
+			Description: locale.T("palette.insert_path"),
+			Action: func(m *model) tea.Cmd {
+				m.textarea.InsertString(path)
+				return nil
+			},
+		})
+	}
+
+	return items
+}
+
+// modeItems toggles the dry-run, plain output and preview modes
+func (m *model) modeItems() []paletteItem {
+	toggle := func(on bool, enable, disable string) string {
+		if on {
+			return locale.T(disable)
+		}
+		return locale.T(enable)
+	}
+
+	items := []paletteItem{
+		{
+			Kind:  "mode",
+			Title: toggle(m.agent.DryRun(), "palette.dry_run_on", "palette.dry_run_off"),
+			Action: func(m *model) tea.Cmd {
+				if m.agent.DryRun() {
+					return runDryRunCommand(m, "off")
+				}
+				return runDryRunCommand(m, "on")
+			},
+		},
+		{
+			Kind:  "mode",
+			Title: toggle(plainMode, "palette.plain_on", "palette.plain_off"),
+			Action: func(m *model) tea.Cmd {
+				m.togglePlainMode()
+				return nil
+			},
+		},
+	}
+
+	// A side-by-side pane can't be read linearly, so plain mode has none
+	if !plainMode {
+		items = append(items, paletteItem{
+			Kind:  "mode",
+			Title: toggle(m.showPreview, "palette.preview_on", "palette.preview_off"),
+			Action: func(m *model) tea.Cmd {
+				m.togglePreview()
+				return nil
+			},
+		})
+	}
+	return items
+}
+
+// resumeSaved continues a saved conversation: in this chat when it is empty
+// and in the same folder, or else in a new tab
+func (m *model) resumeSaved(saved agent.SavedSession) tea.Cmd {
+	session, dir, err := agent.LoadSession(saved.Path)
+	if err != nil {
+		m.addSystemMessage(err.Error())
+		return nil
+	}
+
+	if !m.busy() && m.session.Len() == 0 && dir == m.agent.Workspace().Root() {
+		*m = m.WithResumed(session)
+		m.scrollToLatest()
+		return nil
+	}
+	if m.openTabs == nil {
+		m.addSystemMessage(locale.T("palette.resume_elsewhere", saved.Path))
+		return nil
+	}
+	return func() tea.Msg { return openTabMsg{dir: dir, resume: session} }
+}
@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// plainMode renders the chat for screen readers and dumb terminals: no
+// colors, borders or emoji, a single left-aligned column, and words wherever
+// an icon or a color would otherwise carry the meaning
+var plainMode bool
+
+// colorProfile is the terminal's color profile, restored when plain mode is
+// turned off again
+var colorProfile termenv.Profile
+
+// SetPlainMode turns the accessible plain-output mode on or off. It is
+// called before the UI starts; the chat switches it with togglePlainMode.
+// The chat draws with its own renderer, which follows plain mode from when
+// the chat is created.
+func SetPlainMode(on bool) {
+	if on && !plainMode {
+		colorProfile = lipgloss.ColorProfile()
+	}
+	if !on && plainMode {
+		lipgloss.SetColorProfile(colorProfile)
+	}
+
+	plainMode = on
+	if on {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// togglePlainMode switches plain output while the chat runs and renders the
+// chat again in the new mode
+func (m *model) togglePlainMode() {
+	SetPlainMode(!plainMode)
+	if plainMode {
+		m.renderer.SetColorProfile(termenv.Ascii)
+		m.showPreview = false
+	} else {
+		m.renderer.SetColorProfile(m.colorProfile)
+	}
+
+	m.resize()
+	m.keepPosition()
+}
+
+// PlainMode reports whether the accessible plain-output mode is on
+func PlainMode() bool {
+	return plainMode
+}
+
+// icon returns symbol followed by a space, or label in plain mode
+func icon(symbol, label string) string {
+	if plainMode {
+		return label
+	}
+	return symbol + " "
+}
+
+// withBorder adds a border to style unless plain mode is on
+func withBorder(style lipgloss.Style, border lipgloss.Border, sides ...bool) lipgloss.Style {
+	if plainMode {
+		return style
+	}
+	return style.Border(border, sides...)
+}
+
+// align returns position, or left alignment in plain mode so everything reads top to bottom
+func align(position lipgloss.Position) lipgloss.Position {
+	if plainMode {
+		return lipgloss.Left
+	}
+	return position
+}
+
+// plainText drops emoji and other pictographs from text in plain mode,
+// e.g. from catalog strings, along with the space that followed them
+func plainText(text string) string {
+	if !plainMode {
+		return text
+	}
+
+	var b strings.Builder
+	dropped := false
+	for _, r := range text {
+		if unicode.Is(unicode.So, r) || r == '\uFE0F' {
+			dropped = true
+			continue
+		}
+		if dropped && r == ' ' {
+			dropped = false
+			continue
+		}
+		dropped = false
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// selectionMarker marks the selected entry of a list with an arrow, or an
+// ASCII ">" in plain mode, so the selection isn't shown by color alone
+func selectionMarker() string {
+	if plainMode {
+		return "> "
+	}
+	return "› "
+}
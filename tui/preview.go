@@ -0,0 +1,123 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// filePreview is the optional right-hand pane showing the most recently read or edited file
+type filePreview struct {
+	viewport viewport.Model
+	activity agent.FileActivity
+	loaded   bool
+	renderer *lipgloss.Renderer
+}
+
+func newFilePreview(renderer *lipgloss.Renderer) filePreview {
+	return filePreview{
+		viewport: viewport.New(40, 20),
+		renderer: renderer,
+	}
+}
+
+func (p *filePreview) setSize(width, height int) {
+	p.viewport.Width = width
+	p.viewport.Height = height
+	p.render()
+}
+
+// refresh reloads the preview when the agent has touched a file since the last render
+func (p *filePreview) refresh(agentApp *agent.Agent) {
+	latest, ok := agentApp.LatestActivity()
+	if !ok {
+		return
+	}
+
+	if p.loaded && latest.Time.Equal(p.activity.Time) && latest.Path == p.activity.Path {
+		return
+	}
+
+	p.activity = latest
+	p.loaded = true
+	p.render()
+}
+
+func (p *filePreview) render() {
+	if !p.loaded {
+		p.viewport.SetContent(p.renderer.NewStyle().
+			Foreground(lipgloss.Color("#888888")).
+			Italic(true).
+			Render(locale.T("preview.empty")))
+		return
+	}
+
+	content := p.activity.After
+	if p.activity.ReadOnly || !p.activity.ExistsAfter {
+		data, err := os.ReadFile(p.activity.AbsPath)
+		if err != nil {
+			p.viewport.SetContent(locale.T("preview.read_failed", p.activity.Path, err))
+			return
+		}
+		content = string(data)
+	}
+
+	// Carriage returns from Windows line endings would garble the terminal
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	numberWidth := len(fmt.Sprint(len(lines)))
+
+	numberStyle := p.renderer.NewStyle().Foreground(lipgloss.Color("#666666"))
+	changedStyle := p.renderer.NewStyle().Background(lipgloss.Color("#2d4a2d"))
+	lineStyle := p.renderer.NewStyle().MaxWidth(max(p.viewport.Width-numberWidth-1, 1))
+
+	rendered := make([]string, 0, len(lines))
+	for i, line := range lines {
+		lineNumber := i + 1
+		text := lineStyle.Render(strings.ReplaceAll(line, "\t", "    "))
+
+		if lineNumber >= p.activity.ChangedFrom && lineNumber <= p.activity.ChangedTo {
+			text = changedStyle.Render(text)
+		}
+
+		rendered = append(rendered, numberStyle.Render(fmt.Sprintf("%*d", numberWidth, lineNumber))+" "+text)
+	}
+
+	p.viewport.SetContent(strings.Join(rendered, "\n"))
+
+	// Scroll the changed region into view with a little leading context
+	p.viewport.GotoTop()
+	if p.activity.ChangedFrom > 0 {
+		p.viewport.SetYOffset(max(p.activity.ChangedFrom-4, 0))
+	}
+}
+
+func (p *filePreview) view() string {
+	titleStyle := p.renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF6B35"))
+	infoStyle := p.renderer.NewStyle().Foreground(lipgloss.Color("#888888"))
+
+	title := titleStyle.Render(locale.T("preview.title"))
+	info := ""
+	if p.loaded {
+		title = titleStyle.Render(p.activity.Path)
+		info = p.activity.Tool
+		if p.activity.ChangedFrom > 0 {
+			info += " • " + locale.T("preview.changed_lines", p.activity.ChangedFrom, p.activity.ChangedTo)
+		}
+	}
+
+	paneStyle := p.renderer.NewStyle().
+		BorderForeground(lipgloss.Color("#404040")).
+		PaddingLeft(1)
+
+	return withBorder(paneStyle, lipgloss.NormalBorder(), false, false, false, true).Render(lipgloss.JoinVertical(
+		lipgloss.Left,
+		p.renderer.NewStyle().MaxWidth(p.viewport.Width).Render(title),
+		infoStyle.MaxWidth(p.viewport.Width).Render(info),
+		p.viewport.View(),
+	))
+}
@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"agent/locale"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxQueueLines caps the messages listed in the queue panel
+const maxQueueLines = 3
+
+// enqueue holds a message sent while a turn is running until the turns
+// before it have finished
+func (m *model) enqueue(input string) {
+	m.queuedInputs = append(m.queuedInputs, input)
+	if m.offline {
+		m.addSystemMessage(locale.T("chat.queued_offline", len(m.queuedInputs)))
+	}
+	m.resize()
+	m.keepPosition()
+}
+
+// sendQueued starts a turn for the oldest queued message. A failed turn
+// holds the queue, since the next message would likely fail the same way.
+func (m *model) sendQueued() tea.Cmd {
+	if len(m.queuedInputs) == 0 {
+		return nil
+	}
+	if m.lastTurnFailed {
+		m.addSystemMessage(locale.T("queue.held", len(m.queuedInputs)))
+		m.scrollToLatest()
+		return nil
+	}
+
+	next := m.queuedInputs[0]
+	m.queuedInputs = m.queuedInputs[1:]
+	m.resize()
+	return m.sendMessage(next)
+}
+
+// queuePanelHeight is the number of lines the queue panel takes up
+func (m *model) queuePanelHeight() int {
+	if len(m.queuedInputs) == 0 {
+		return 0
+	}
+	return lipgloss.Height(m.renderQueuePanel(m.contentWidth()))
+}
+
+// renderQueuePanel lists the queued messages above the input box, one line
+// each, in the order they will be sent
+func (m *model) renderQueuePanel(width int) string {
+	if len(m.queuedInputs) == 0 {
+		return ""
+	}
+
+	titleStyle := m.renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("#007AFF"))
+	moreStyle := m.renderer.NewStyle().Foreground(lipgloss.Color("#777777"))
+
+	lines := []string{titleStyle.Render(icon("⏳", "") + locale.T("queue.title", len(m.queuedInputs)))}
+	for i, input := range m.queuedInputs[:min(len(m.queuedInputs), maxQueueLines)] {
+		// Multi-line messages show their first line, marked as cut off
+		line := fmt.Sprintf("%d. %s", i+1, firstLine(input))
+		if strings.Contains(strings.TrimSpace(input), "\n") {
+			line += " …"
+		}
+		lines = append(lines, m.renderer.NewStyle().MaxWidth(width-1).Render(line))
+	}
+	if len(m.queuedInputs) > maxQueueLines {
+		lines = append(lines, moreStyle.Render(locale.T("queue.more", len(m.queuedInputs)-maxQueueLines)))
+	}
+
+	return m.renderer.NewStyle().Width(width).PaddingLeft(1).Render(strings.Join(lines, "\n"))
+}
+
+// runQueueCommand lists the queued messages, or sends, edits, drops or clears them
+func runQueueCommand(m *model, args string) tea.Cmd {
+	action, rest, _ := strings.Cut(strings.TrimSpace(args), " ")
+
+	switch action {
+	case "":
+		if len(m.queuedInputs) == 0 {
+			m.addSystemMessage(locale.T("queue.empty"))
+			return nil
+		}
+		var b strings.Builder
+		b.WriteString(locale.T("queue.header"))
+		for i, input := range m.queuedInputs {
+			fmt.Fprintf(&b, "\n%d. %s", i+1, input)
+		}
+		m.addSystemMessage(b.String())
+
+	case "clear":
+		m.addSystemMessage(locale.T("queue.cleared", len(m.queuedInputs)))
+		m.queuedInputs = nil
+		m.resize()
+
+	case "send":
+		if m.busy() {
+			m.addSystemMessage(locale.T("queue.busy"))
+			return nil
+		}
+		if len(m.queuedInputs) == 0 {
+			m.addSystemMessage(locale.T("queue.empty"))
+			return nil
+		}
+		m.lastTurnFailed = false
+		return m.sendQueued()
+
+	case "drop", "edit":
+		n, err := strconv.Atoi(strings.TrimSpace(rest))
+		if err != nil || n < 1 || n > len(m.queuedInputs) {
+			m.addSystemMessage(locale.T("queue.unknown", strings.TrimSpace(rest)))
+			return nil
+		}
+		input := m.queuedInputs[n-1]
+		m.queuedInputs = append(m.queuedInputs[:n-1:n-1], m.queuedInputs[n:]...)
+		m.resize()
+
+		// Editing takes the message out of the queue into the input box;
+		// sending it again queues it at the end
+		if action == "edit" {
+			m.textarea.SetValue(input)
+			return nil
+		}
+		m.addSystemMessage(locale.T("queue.dropped", n))
+
+	default:
+		m.addSystemMessage(locale.T("queue.usage"))
+	}
+
+	return nil
+}
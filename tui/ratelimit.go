@@ -0,0 +1,43 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// rateLimitTickMsg counts down a rate limit wait in the status bar
+type rateLimitTickMsg struct{}
+
+func rateLimitTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return rateLimitTickMsg{}
+	})
+}
+
+// startRateLimitWait shows a countdown while the agent holds a request back
+// to stay within the rate limits
+func (m *model) startRateLimitWait(event agent.RateLimitWait) tea.Cmd {
+	counting := time.Now().Before(m.rateLimitUntil)
+	m.rateLimitUntil = event.Until
+	m.rateLimitName = event.Limit
+
+	// A countdown that is still running keeps ticking on its own
+	if counting {
+		return nil
+	}
+	return rateLimitTick()
+}
+
+// rateLimitStatus is the countdown for the status bar, or "" when not waiting
+func (m *model) rateLimitStatus() string {
+	remaining := time.Until(m.rateLimitUntil)
+	if remaining <= 0 {
+		return ""
+	}
+
+	seconds := int(remaining.Round(time.Second) / time.Second)
+	return icon("⏳", "") + locale.T("status.rate_limit", max(seconds, 1), m.rateLimitName)
+}
@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/crash"
+	"agent/locale"
+	"strings"
+)
+
+// saveOnCrash saves the chat's conversation if cli-agent crashes, so it can
+// be resumed with --resume. The returned function stops that, for closed tabs.
+func saveOnCrash(session *agent.Session, agentApp *agent.Agent) func() {
+	return crash.OnCrash(func() string {
+		return saveForResume(session, agentApp)
+	})
+}
+
+// saveForResume saves a chat's conversation and returns how to resume it, or
+// "" when there is nothing to save
+func saveForResume(session *agent.Session, agentApp *agent.Agent) string {
+	root := agentApp.Workspace().Root()
+	path, err := agent.SaveSession(session, root)
+	if err != nil {
+		return locale.T("shutdown.save_failed", root, err)
+	}
+	if path == "" {
+		return ""
+	}
+	return locale.T("shutdown.saved", root, path)
+}
+
+// WithResumed continues a saved conversation, showing its messages
+func (m model) WithResumed(session *agent.Session) model {
+	m.session.Replace(session.Messages())
+
+	for _, message := range session.Messages() {
+		texts := []string{}
+		for _, block := range message.Content {
+			if block.OfText != nil {
+				texts = append(texts, block.OfText.Text)
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		// Notes for the model come before the user's own text
+		if message.Role == "user" {
+			m.messages = append(m.messages, ChatMessage{Content: texts[len(texts)-1], IsUser: true})
+		} else {
+			m.messages = append(m.messages, ChatMessage{Content: strings.Join(texts, "\n\n")})
+		}
+	}
+
+	m.addSystemMessage(locale.T("chat.resumed", session.Len()))
+	return m
+}
@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// renderInterval caps how often the chat is re-rendered while a response
+// streams, so fast models don't trigger a full render on every token
+const renderInterval = time.Second / 30
+
+// renderTickMsg triggers a deferred re-render of the chat
+type renderTickMsg struct{}
+
+// scheduleRender marks the chat as out of date and schedules a re-render
+// unless one is already pending, so a burst of deltas renders once
+func (m *model) scheduleRender() tea.Cmd {
+	m.renderDirty = true
+	if m.renderScheduled {
+		return nil
+	}
+
+	m.renderScheduled = true
+	return tea.Tick(renderInterval, func(time.Time) tea.Msg {
+		return renderTickMsg{}
+	})
+}
+
+// flushRender applies a pending re-render
+func (m *model) flushRender() {
+	m.renderScheduled = false
+	if !m.renderDirty {
+		return
+	}
+
+	m.renderDirty = false
+	m.followOutput()
+}
+
+// renderedMessage is the styled output of a message at a given width, in
+// plain mode or not
+type renderedMessage struct {
+	message ChatMessage
+	width   int
+	plain   bool
+	output  string
+}
+
+// cachedRender returns the styled output of the i-th message, reusing the
+// previous render when neither the message, the width nor the mode changed
+func (m *model) cachedRender(i int, msg ChatMessage, width int) string {
+	if i < len(m.renderCache) && m.renderCache[i].width == width && m.renderCache[i].plain == plainMode && m.renderCache[i].message == msg {
+		return m.renderCache[i].output
+	}
+
+	entry := renderedMessage{message: msg, width: width, plain: plainMode, output: m.renderMessage(msg, width)}
+	if i < len(m.renderCache) {
+		m.renderCache[i] = entry
+	} else {
+		m.renderCache = append(m.renderCache, entry)
+	}
+
+	return entry.output
+}
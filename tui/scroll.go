@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"agent/locale"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// chatKeyMap limits chat scrolling to keys that don't clash with typing.
+// The mouse wheel scrolls too.
+func chatKeyMap() viewport.KeyMap {
+	return viewport.KeyMap{
+		PageDown: key.NewBinding(key.WithKeys("pgdown"), key.WithHelp("pgdn", locale.T("keys.page_down"))),
+		PageUp:   key.NewBinding(key.WithKeys("pgup"), key.WithHelp("pgup", locale.T("keys.page_up"))),
+	}
+}
+
+// scrollKey handles the keys that jump to either end of the chat, reporting
+// whether the key was one of them
+func (m *model) scrollKey(msg tea.KeyMsg) bool {
+	switch msg.Type {
+	case tea.KeyHome, tea.KeyCtrlHome:
+		m.viewport.GotoTop()
+	case tea.KeyEnd, tea.KeyCtrlEnd:
+		m.viewport.GotoBottom()
+		m.newContentBelow = false
+	default:
+		return false
+	}
+
+	return true
+}
+
+// followOutput re-renders the chat after new output arrived. The view stays
+// on the latest output if it was there; if the user has scrolled up to read,
+// it stays put and the new content marker is shown instead.
+func (m *model) followOutput() {
+	atBottom := m.viewport.AtBottom()
+	m.updateViewport()
+
+	if atBottom {
+		m.viewport.GotoBottom()
+	} else {
+		m.newContentBelow = true
+	}
+}
+
+// scrollToLatest re-renders the chat and jumps to the bottom, for output the user asked for
+func (m *model) scrollToLatest() {
+	m.updateViewport()
+	m.viewport.GotoBottom()
+	m.newContentBelow = false
+}
+
+// keepPosition re-renders the chat after a layout change without moving the
+// reader, other than keeping the bottom in view if they were there
+func (m *model) keepPosition() {
+	atBottom := m.viewport.AtBottom()
+	m.updateViewport()
+
+	if atBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+// scrollIndicator shows how far through the chat the view is, when it doesn't fit on screen
+func (m *model) scrollIndicator() string {
+	if m.viewport.TotalLineCount() <= m.viewport.Height {
+		return ""
+	}
+
+	percent := int(m.viewport.ScrollPercent() * 100)
+	if plainMode {
+		return locale.T("scroll.position", percent)
+	}
+	return fmt.Sprintf("↕ %d%%", percent)
+}
+
+// renderGap renders the space between the chat and the input box, which
+// carries the new content marker while the user is scrolled up
+func (m *model) renderGap(width int) string {
+	if !m.newContentBelow {
+		return gap
+	}
+
+	marker := m.renderer.NewStyle().
+		Foreground(lipgloss.Color("#FF6B35")).
+		Width(width).
+		Align(align(lipgloss.Center)).
+		Render(locale.T("scroll.new_content"))
+
+	return "\n" + marker + "\n"
+}
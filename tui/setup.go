@@ -0,0 +1,352 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/config"
+	"agent/locale"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// setupProfile is the profile the setup wizard writes
+const setupProfile = "default"
+
+// setupStep is a page of the first-run setup wizard
+type setupStep int
+
+const (
+	stepProvider setupStep = iota
+	stepBaseURL
+	stepKey
+	stepModel
+)
+
+// setupProviders are the choices on the provider page
+func setupProviders() []string {
+	return []string{
+		locale.T("setup.provider_anthropic"),
+		locale.T("setup.provider_custom"),
+	}
+}
+
+// setupCheckedMsg reports the outcome of testing the entered key
+type setupCheckedMsg struct {
+	models []string
+	err    error
+}
+
+// setupModel is the first-run wizard. It collects a provider, an API key,
+// which it tests against the API, and a default model, then saves them.
+type setupModel struct {
+	step     setupStep
+	selected int
+	custom   bool
+	input    textinput.Model
+	checking bool
+	err      error
+	models   []string
+
+	baseURL string
+	key     string
+
+	width     int
+	completed bool
+	notice    string
+}
+
+func newSetupModel() setupModel {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.Width = 60
+
+	return setupModel{input: ti}
+}
+
+// RunSetup runs the first-run setup wizard. It returns a notice to show once
+// the chat opens, or an error when the wizard was cancelled.
+func RunSetup() (string, error) {
+	var options []tea.ProgramOption
+	if !plainMode {
+		options = append(options, tea.WithAltScreen())
+	}
+
+	result, err := tea.NewProgram(newSetupModel(), options...).Run()
+	if err != nil {
+		return "", err
+	}
+
+	setup := result.(setupModel)
+	if !setup.completed {
+		return "", fmt.Errorf("setup cancelled")
+	}
+
+	return setup.notice, nil
+}
+
+func (m setupModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m setupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+
+	case setupCheckedMsg:
+		m.checking = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.models = append([]string{""}, msg.models...)
+		m.step = stepModel
+		m.selected = 0
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEsc {
+			return m, tea.Quit
+		}
+		if m.checking {
+			return m, nil
+		}
+		return m.updateStep(msg)
+	}
+
+	return m, nil
+}
+
+// updateStep handles key presses on the current page
+func (m setupModel) updateStep(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.step {
+	case stepProvider, stepModel:
+		count := len(setupProviders())
+		if m.step == stepModel {
+			count = len(m.models)
+		}
+
+		switch msg.Type {
+		case tea.KeyUp:
+			m.selected = max(m.selected-1, 0)
+		case tea.KeyDown:
+			m.selected = min(m.selected+1, count-1)
+		case tea.KeyEnter:
+			if m.step == stepModel {
+				return m.finish(m.models[m.selected])
+			}
+
+			m.custom = m.selected == 1
+			m.step = stepKey
+			if m.custom {
+				m.step = stepBaseURL
+			}
+			m.showInput()
+			return m, textinput.Blink
+		}
+		return m, nil
+
+	case stepBaseURL:
+		if msg.Type == tea.KeyEnter {
+			value := strings.TrimSpace(m.input.Value())
+			if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				m.err = errors.New(locale.T("setup.url_invalid"))
+				return m, nil
+			}
+			m.baseURL = value
+			m.err = nil
+			m.step = stepKey
+			m.showInput()
+			return m, textinput.Blink
+		}
+
+	case stepKey:
+		// Some compatible endpoints don't list models; let the user go on anyway
+		if msg.Type == tea.KeyTab && m.err != nil && m.custom {
+			m.key = strings.TrimSpace(m.input.Value())
+			m.err = nil
+			m.models = []string{""}
+			m.step = stepModel
+			m.selected = 0
+			return m, nil
+		}
+		if msg.Type == tea.KeyEnter {
+			m.key = strings.TrimSpace(m.input.Value())
+			// Local servers often don't check keys
+			if m.key == "" && !m.custom {
+				m.err = errors.New(locale.T("setup.key_missing"))
+				return m, nil
+			}
+			m.checking = true
+			m.err = nil
+			return m, checkSetupKey(m.key, m.baseURL)
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// showInput resets the text input for the base URL or key page
+func (m *setupModel) showInput() {
+	m.input.Reset()
+	m.input.Focus()
+	m.err = nil
+
+	if m.step == stepKey {
+		m.input.Placeholder = "sk-ant-..."
+		m.input.EchoMode = textinput.EchoPassword
+	} else {
+		m.input.Placeholder = "http://localhost:8080"
+		m.input.EchoMode = textinput.EchoNormal
+	}
+}
+
+// WithNotice adds a system message to show when the chat opens, e.g. the setup outcome
+func (m model) WithNotice(text string) model {
+	if text != "" {
+		m.addSystemMessage(text)
+	}
+	return m
+}
+
+// checkSetupKey tests the key by listing the models it can use
+func checkSetupKey(key, baseURL string) tea.Cmd {
+	return func() tea.Msg {
+		options := []option.RequestOption{option.WithMaxRetries(0)}
+		if key != "" {
+			options = append(options, option.WithAPIKey(key))
+		}
+		if baseURL != "" {
+			options = append(options, option.WithBaseURL(baseURL))
+		}
+		client := anthropic.NewClient(options...)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		page, err := client.Models.List(ctx, anthropic.ModelListParams{})
+		if err != nil {
+			return setupCheckedMsg{err: err}
+		}
+
+		models := make([]string, 0, len(page.Data))
+		for _, info := range page.Data {
+			models = append(models, info.ID)
+		}
+		return setupCheckedMsg{models: models}
+	}
+}
+
+// finish saves the profile and key and closes the wizard
+func (m setupModel) finish(chosen string) (tea.Model, tea.Cmd) {
+	err := config.UpdateSettings(func(settings *config.Settings) {
+		if settings.Profiles == nil {
+			settings.Profiles = map[string]config.Profile{}
+		}
+		settings.Profiles[setupProfile] = config.Profile{BaseURL: m.baseURL, Model: chosen}
+		settings.DefaultProfile = setupProfile
+	})
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+
+	if m.key != "" {
+		if err := config.StoreAPIKey(config.KeychainAccount(setupProfile), m.key); err != nil {
+			// Keep the session usable; the key just won't be remembered
+			os.Setenv("ANTHROPIC_API_KEY", m.key)
+			m.notice = locale.T("setup.keychain_failed", err)
+		}
+	}
+	if m.notice == "" {
+		m.notice = locale.T("setup.done")
+	}
+
+	m.completed = true
+	return m, tea.Quit
+}
+
+func (m setupModel) View() string {
+	width := max(min(m.width, 80), 40)
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF6B35")).Bold(true)
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555"))
+
+	var title, body, hint string
+
+	switch m.step {
+	case stepProvider:
+		title = locale.T("setup.provider_title")
+		body = renderChoices(setupProviders(), m.selected, len(setupProviders()), selectedStyle)
+		hint = locale.T("setup.choose_hint")
+
+	case stepBaseURL:
+		title = locale.T("setup.url_title")
+		body = m.input.View()
+		hint = locale.T("setup.continue_hint")
+
+	case stepKey:
+		title = locale.T("setup.key_title")
+		body = locale.T("setup.key_body") + "\n\n" + m.input.View()
+		if m.custom {
+			body += "\n\n" + locale.T("setup.key_optional")
+		}
+		if m.checking {
+			body += "\n\n" + locale.T("setup.checking")
+		}
+		hint = locale.T("setup.key_hint")
+		if m.err != nil && m.custom {
+			hint = locale.T("setup.key_retry_hint")
+		}
+
+	case stepModel:
+		labels := make([]string, len(m.models))
+		for i, id := range m.models {
+			labels[i] = id
+			if id == "" {
+				labels[i] = locale.T("setup.default_model", agent.DefaultModel)
+			}
+		}
+		title = locale.T("setup.model_title")
+		body = renderChoices(labels, m.selected, 10, selectedStyle)
+		hint = locale.T("setup.model_hint")
+	}
+
+	if m.err != nil {
+		body += "\n\n" + errorStyle.Render(m.err.Error())
+	}
+
+	return renderPromptBox(lipgloss.DefaultRenderer(), width, title, body, hint)
+}
+
+// renderChoices lists options with the selected one highlighted, scrolled to keep it visible
+func renderChoices(options []string, selected, visible int, selectedStyle lipgloss.Style) string {
+	start := 0
+	if selected >= visible {
+		start = selected - visible + 1
+	}
+	end := min(start+visible, len(options))
+
+	lines := []string{}
+	for i := start; i < end; i++ {
+		if i == selected {
+			lines = append(lines, selectedStyle.Render(selectionMarker()+options[i]))
+		} else {
+			lines = append(lines, "  "+options[i])
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
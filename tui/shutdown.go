@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"agent/locale"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// shutdownTimeout bounds how long quitting waits for running turns to stop
+const shutdownTimeout = 10 * time.Second
+
+// Shutdown stops the turns still running in the chats of a program that has
+// quit, e.g. on Ctrl+C or SIGTERM, and saves their conversations so they can
+// be resumed with --resume. It returns a line for the user per conversation.
+func Shutdown(final tea.Model) []string {
+	chats := []*model{}
+	switch final := final.(type) {
+	case *tabs:
+		for _, tab := range final.tabs {
+			chats = append(chats, &tab.chat)
+		}
+	case model:
+		chats = append(chats, &final)
+	}
+
+	deadline := time.Now().Add(shutdownTimeout)
+	lines := []string{}
+	for _, m := range chats {
+		m.stopTurn(deadline)
+		m.forgetOnCrash()
+		if line := saveForResume(m.session, m.agent); line != "" {
+			lines = append(lines, line)
+		}
+		if n := len(m.queuedInputs); n > 0 {
+			lines = append(lines, locale.T("shutdown.queued", n))
+		}
+	}
+	return lines
+}
+
+// stopTurn cancels the running turn and waits until it has stopped or the
+// deadline passes. The provider stream is cancelled, a running tool is let
+// finish, and the approvals the turn asks for meanwhile are denied.
+func (m *model) stopTurn(deadline time.Time) {
+	if m.events == nil {
+		return
+	}
+	m.cancelTurn()
+
+	if m.pendingApproval != nil {
+		select {
+		case m.pendingApproval.reply <- false:
+		default:
+		}
+	}
+
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+	for {
+		select {
+		case _, ok := <-m.events:
+			if !ok {
+				return
+			}
+		case approval := <-m.approvalChan:
+			approval.reply <- false
+		case <-timeout.C:
+			return
+		}
+	}
+}
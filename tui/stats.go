@@ -0,0 +1,50 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runStatsCommand shows how the session's tools performed: how often the
+// model called each, how often the calls failed or repeated, and how long
+// they took
+func runStatsCommand(m *model, args string) tea.Cmd {
+	if args != "" && args != "tools" {
+		m.addSystemMessage(locale.T("stats.usage"))
+		return nil
+	}
+
+	stats := m.agent.ToolStats()
+	if len(stats) == 0 {
+		m.addSystemMessage(locale.T("stats.tools.empty"))
+		return nil
+	}
+
+	width := len(locale.T("stats.tools.tool"))
+	for _, s := range stats {
+		width = max(width, len(s.Name))
+	}
+
+	var b strings.Builder
+	b.WriteString(locale.T("stats.tools.title") + "\n")
+	fmt.Fprintf(&b, "  %-*s %7s %11s %8s %8s %8s %8s %8s\n", width, locale.T("stats.tools.tool"), locale.T("stats.tools.calls"), locale.T("stats.tools.failed"), locale.T("stats.tools.repeated"), "p50", "p90", "max", locale.T("stats.tools.total"))
+
+	slow := []string{}
+	for _, s := range stats {
+		failed := fmt.Sprintf("%d (%d%%)", s.Failures, int(s.FailureRate()*100+0.5))
+		fmt.Fprintf(&b, "  %-*s %7d %11s %8d %8s %8s %8s %8s\n", width, s.Name, s.Calls, failed, s.Repeats, formatDuration(s.Median), formatDuration(s.P90), formatDuration(s.Max), formatDuration(s.Total))
+		if s.Slow > 0 {
+			slow = append(slow, fmt.Sprintf("%s (%d)", s.Name, s.Slow))
+		}
+	}
+
+	if len(slow) > 0 {
+		b.WriteString(locale.T("stats.tools.slow", formatDuration(agent.SlowToolCall), strings.Join(slow, ", ")))
+	}
+	m.addSystemMessage(strings.TrimRight(b.String(), "\n"))
+	return nil
+}
@@ -0,0 +1,90 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/diff"
+	"agent/locale"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// diffStyles color added and removed lines and hunk headers
+type diffStyles struct {
+	added, removed, hunk lipgloss.Style
+}
+
+func newDiffStyles(renderer *lipgloss.Renderer) diffStyles {
+	return diffStyles{
+		added:   renderer.NewStyle().Foreground(lipgloss.Color("#4CAF50")),
+		removed: renderer.NewStyle().Foreground(lipgloss.Color("#F44336")),
+		hunk:    renderer.NewStyle().Foreground(lipgloss.Color("#00BCD4")),
+	}
+}
+
+// renderChangeSummary renders the compact per-turn list of changed files
+func renderChangeSummary(renderer *lipgloss.Renderer, changes []agent.FileChange) string {
+	styles := newDiffStyles(renderer)
+	var b strings.Builder
+
+	b.WriteString(icon("📝", "") + locale.T("summary.changed", len(changes)) + "\n")
+
+	pathWidth := 0
+	for _, change := range changes {
+		pathWidth = max(pathWidth, len(change.Path))
+	}
+
+	for _, change := range changes {
+		b.WriteString(fmt.Sprintf("  %-9s %-*s  %s %s\n",
+			change.Status,
+			pathWidth, change.Path,
+			styles.added.Render(fmt.Sprintf("+%d", change.Added)),
+			styles.removed.Render(fmt.Sprintf("-%d", change.Removed)),
+		))
+	}
+
+	b.WriteString(locale.T("summary.view_diff"))
+
+	return b.String()
+}
+
+// renderChangeDiff renders a colored unified diff for the given changes
+func renderChangeDiff(renderer *lipgloss.Renderer, changes []agent.FileChange) string {
+	styles := newDiffStyles(renderer)
+	var rendered []string
+
+	for _, change := range changes {
+		fromName, toName := "a/"+change.Path, "b/"+change.Path
+		switch change.Status {
+		case agent.ChangeCreated:
+			fromName = "/dev/null"
+		case agent.ChangeDeleted:
+			toName = "/dev/null"
+		}
+
+		unified := diff.Unified(fromName, toName, change.Before, change.After, 3)
+
+		lines := strings.Split(strings.TrimSuffix(unified, "\n"), "\n")
+		for i, line := range lines {
+			// A carriage return from a Windows line ending would send the cursor back to the line start
+			line = strings.TrimSuffix(line, "\r")
+			lines[i] = line
+
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				lines[i] = renderer.NewStyle().Bold(true).Render(line)
+			case strings.HasPrefix(line, "@@"):
+				lines[i] = styles.hunk.Render(line)
+			case strings.HasPrefix(line, "+"):
+				lines[i] = styles.added.Render(line)
+			case strings.HasPrefix(line, "-"):
+				lines[i] = styles.removed.Render(line)
+			}
+		}
+
+		rendered = append(rendered, strings.Join(lines, "\n"))
+	}
+
+	return strings.Join(rendered, "\n\n")
+}
@@ -0,0 +1,377 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/crash"
+	"agent/locale"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxTabs is how many tabs can be open, one per Alt+digit key
+const maxTabs = 9
+
+// tab is one independent chat: its own agent, conversation, working directory and turn
+type tab struct {
+	id   int
+	chat model
+
+	// unseen marks a background tab whose turn finished since it was last shown
+	unseen bool
+}
+
+// tabs runs several chats side by side in one terminal and shows one at a
+// time. Every chat runs as if it were alone; the messages its commands
+// produce are tagged with its tab, so a background turn keeps streaming into
+// its own chat while another tab is shown.
+type tabs struct {
+	tabs     []*tab
+	active   int
+	nextID   int
+	width    int
+	height   int
+	newAgent func(dir string) (*agent.Agent, error)
+}
+
+// tabMsg is a message produced by a tab's commands, routed back to that tab
+type tabMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// Tab commands run inside a chat but act on the tabs around it
+type (
+	openTabMsg struct {
+		dir    string
+		resume *agent.Session
+	}
+	closeTabMsg  struct{}
+	switchTabMsg struct{ n int }
+	listTabsMsg  struct{}
+)
+
+// WithTabs lets the chat open more tabs with /tab new, each with an agent
+// made by newAgent for the given working directory
+func (m model) WithTabs(newAgent func(dir string) (*agent.Agent, error)) tea.Model {
+	return &tabs{
+		tabs:     []*tab{{id: 0, chat: m}},
+		nextID:   1,
+		newAgent: newAgent,
+	}
+}
+
+func (t *tabs) Init() tea.Cmd {
+	return tagged(t.tabs[0].id, t.tabs[0].chat.Init())
+}
+
+func (t *tabs) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	defer crash.Remember()
+
+	switch msg := msg.(type) {
+	case tabMsg:
+		i := t.index(msg.id)
+		if i < 0 {
+			// The tab was closed while one of its commands was running
+			return t, nil
+		}
+
+		switch request := msg.msg.(type) {
+		case openTabMsg:
+			return t, t.open(i, request)
+		case closeTabMsg:
+			return t, t.close(i)
+		case switchTabMsg:
+			if !t.switchTo(request.n - 1) {
+				t.notify(i, locale.T("tabs.unknown", request.n))
+			}
+			return t, nil
+		case listTabsMsg:
+			t.notify(i, t.list())
+			return t, nil
+		case streamingCompleteMsg:
+			if i != t.active {
+				t.tabs[i].unseen = true
+			}
+		}
+
+		return t, t.update(i, msg.msg)
+
+	case tea.WindowSizeMsg:
+		t.width, t.height = msg.Width, msg.Height
+		return t, t.resize()
+
+	case tea.KeyMsg:
+		if n, ok := tabKey(msg); ok {
+			t.switchTo(n - 1)
+			return t, nil
+		}
+		// The command palette lists the tabs, so the chat on screen gets them current
+		t.tabs[t.active].chat.openTabs = t.titles()
+	}
+
+	// Keys, the mouse and anything else from the terminal go to the tab on screen
+	return t, t.update(t.active, msg)
+}
+
+func (t *tabs) View() string {
+	defer crash.Remember()
+
+	view := t.tabs[t.active].chat.View()
+	if len(t.tabs) == 1 {
+		return view
+	}
+	return t.renderTabBar() + "\n" + view
+}
+
+// update passes a message to a tab's chat and tags the commands it returns
+func (t *tabs) update(i int, msg tea.Msg) tea.Cmd {
+	next, cmd := t.tabs[i].chat.Update(msg)
+	t.tabs[i].chat = next.(model)
+	return tagged(t.tabs[i].id, cmd)
+}
+
+// tagged wraps a tab's command so its message comes back as a tabMsg for that
+// tab. Batches are unpacked so each of their commands is tagged, and quitting
+// is left for the program to act on.
+func tagged(id int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+
+	return func() tea.Msg {
+		switch msg := cmd().(type) {
+		case nil:
+			return nil
+		case tea.QuitMsg:
+			return msg
+		case tea.BatchMsg:
+			cmds := make(tea.BatchMsg, len(msg))
+			for i, inner := range msg {
+				cmds[i] = tagged(id, inner)
+			}
+			return cmds
+		default:
+			return tabMsg{id: id, msg: msg}
+		}
+	}
+}
+
+// index finds the position of the tab with the given id, or -1 once it is closed
+func (t *tabs) index(id int) int {
+	for i, tab := range t.tabs {
+		if tab.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// open starts a new tab in dir and shows it
+func (t *tabs) open(from int, request openTabMsg) tea.Cmd {
+	if len(t.tabs) >= maxTabs {
+		t.notify(from, locale.T("tabs.limit", maxTabs))
+		return nil
+	}
+
+	agentApp, err := t.newAgent(request.dir)
+	if err != nil {
+		t.notify(from, locale.T("tabs.open_failed", err))
+		return nil
+	}
+
+	first := &t.tabs[0].chat
+	opened := &tab{id: t.nextID, chat: newChatModel(agentApp, first.renderer, first.colorProfile)}
+	if request.resume != nil {
+		opened.chat = opened.chat.WithResumed(request.resume)
+	}
+	t.nextID++
+	t.tabs = append(t.tabs, opened)
+	t.active = len(t.tabs) - 1
+
+	return tea.Batch(tagged(opened.id, opened.chat.Init()), t.resize())
+}
+
+// close closes a tab, unless it is the last one or its turn is still running
+func (t *tabs) close(i int) tea.Cmd {
+	if len(t.tabs) == 1 {
+		t.notify(i, locale.T("tabs.last"))
+		return nil
+	}
+	if t.tabs[i].chat.busy() {
+		t.notify(i, locale.T("tabs.busy"))
+		return nil
+	}
+
+	if w := t.tabs[i].chat.watcher; w != nil {
+		w.Close()
+	}
+	t.tabs[i].chat.forgetOnCrash()
+	t.tabs = append(t.tabs[:i], t.tabs[i+1:]...)
+	if t.active >= i && t.active > 0 {
+		t.active--
+	}
+	t.tabs[t.active].unseen = false
+
+	return t.resize()
+}
+
+// switchTo shows the i-th tab, reporting false when there is none
+func (t *tabs) switchTo(i int) bool {
+	if i < 0 || i >= len(t.tabs) {
+		return false
+	}
+
+	t.active = i
+	t.tabs[i].unseen = false
+	return true
+}
+
+// resize lays out every tab below the tab bar, which is hidden while there is only one tab
+func (t *tabs) resize() tea.Cmd {
+	if t.width == 0 {
+		return nil
+	}
+
+	height := t.height
+	if len(t.tabs) > 1 {
+		height--
+	}
+
+	var cmds []tea.Cmd
+	for i := range t.tabs {
+		cmds = append(cmds, t.update(i, tea.WindowSizeMsg{Width: t.width, Height: height}))
+	}
+	return tea.Batch(cmds...)
+}
+
+// notify shows a system message in a tab's chat
+func (t *tabs) notify(i int, text string) {
+	t.tabs[i].chat.addSystemMessage(text)
+	t.tabs[i].chat.scrollToLatest()
+}
+
+// title names a tab after its working directory, with a mark for what it is doing
+func (tab *tab) title() string {
+	chat := &tab.chat
+	title := filepath.Base(chat.agent.WorkingDirectory())
+
+	switch {
+	case chat.pendingApproval != nil || chat.trustPrompt:
+		title += " " + icon("❓", locale.T("tabs.waiting"))
+	case chat.offline:
+		title += " " + icon("📡", locale.T("tabs.offline"))
+	case chat.busy():
+		title += " " + icon("⏳", locale.T("tabs.busy_mark"))
+	case tab.unseen:
+		title += " " + icon("●", locale.T("tabs.unseen"))
+	}
+	return strings.TrimSpace(title)
+}
+
+// list describes the open tabs for /tab
+func (t *tabs) list() string {
+	var b strings.Builder
+	b.WriteString(locale.T("tabs.list") + "\n")
+	for i, tab := range t.tabs {
+		marker := " "
+		if i == t.active {
+			marker = "*"
+		}
+		b.WriteString(fmt.Sprintf(" %s%d. %s  %s\n", marker, i+1, tab.title(), tab.chat.agent.WorkingDirectory()))
+	}
+	b.WriteString(locale.T("tabs.hint"))
+	return b.String()
+}
+
+// titles returns the title of every open tab, in order
+func (t *tabs) titles() []string {
+	titles := make([]string, len(t.tabs))
+	for i, tab := range t.tabs {
+		titles[i] = tab.title()
+	}
+	return titles
+}
+
+// renderTabBar shows the open tabs above the chat, with the shown one highlighted
+func (t *tabs) renderTabBar() string {
+	chat := &t.tabs[t.active].chat
+	width := chat.contentWidth()
+	leftPadding := (t.width - width) / 2
+
+	labels := make([]string, len(t.tabs))
+	for i, tab := range t.tabs {
+		label := fmt.Sprintf("%d %s", i+1, tab.title())
+		switch {
+		case plainMode && i == t.active:
+			labels[i] = "[" + label + "]"
+		case plainMode:
+			labels[i] = label
+		case i == t.active:
+			labels[i] = chat.renderer.NewStyle().Bold(true).Reverse(true).Padding(0, 1).Render(label)
+		default:
+			labels[i] = chat.renderer.NewStyle().Foreground(lipgloss.Color("#888888")).Padding(0, 1).Render(label)
+		}
+	}
+
+	separator := " "
+	if plainMode {
+		separator = ", "
+	}
+
+	return chat.renderer.NewStyle().
+		PaddingLeft(leftPadding).
+		Render(chat.renderer.NewStyle().Width(width).MaxHeight(1).Render(strings.Join(labels, separator)))
+}
+
+// tabKey reports the tab number of an Alt+1 to Alt+9 key press. Terminals
+// send Ctrl+digit combinations as plain digits or not at all, so Alt is used.
+func tabKey(msg tea.KeyMsg) (int, bool) {
+	if !msg.Alt || msg.Type != tea.KeyRunes || len(msg.Runes) != 1 {
+		return 0, false
+	}
+	if msg.Runes[0] < '1' || msg.Runes[0] > '9' {
+		return 0, false
+	}
+	return int(msg.Runes[0] - '0'), true
+}
+
+// runTabCommand lists the open tabs, opens a new one in a directory, closes
+// this one or switches to another by its number
+func runTabCommand(m *model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+
+	var request tea.Msg
+	switch {
+	case len(fields) == 0:
+		request = listTabsMsg{}
+
+	case fields[0] == "new":
+		dir := strings.TrimSpace(strings.TrimPrefix(args, "new"))
+		if dir == "" {
+			dir = m.agent.WorkingDirectory()
+		} else if !filepath.IsAbs(dir) {
+			dir = filepath.Join(m.agent.WorkingDirectory(), dir)
+		}
+		request = openTabMsg{dir: dir}
+
+	case fields[0] == "close" && len(fields) == 1:
+		request = closeTabMsg{}
+
+	default:
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || len(fields) != 1 {
+			m.addSystemMessage(locale.T("tabs.usage"))
+			return nil
+		}
+		request = switchTabMsg{n: n}
+	}
+
+	return func() tea.Msg {
+		return request
+	}
+}
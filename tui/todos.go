@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"agent/locale"
+	"agent/tools"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxTodoLines caps the items shown in the task panel so it never crowds out the chat
+const maxTodoLines = 6
+
+// refreshTodos reloads the agent's task list and makes room for the panel
+func (m *model) refreshTodos() {
+	m.todos = m.agent.Workspace().Todos()
+	m.resize()
+	m.keepPosition()
+}
+
+// todosVisible reports whether the task panel is shown: while anything is
+// left to do, and until the end of the turn that finished the last item
+func (m *model) todosVisible() bool {
+	if len(m.todos) == 0 {
+		return false
+	}
+	if m.busy() {
+		return true
+	}
+
+	for _, todo := range m.todos {
+		if todo.Status != tools.TodoCompleted {
+			return true
+		}
+	}
+	return false
+}
+
+// todoPanelHeight is the number of lines the task panel takes up
+func (m *model) todoPanelHeight() int {
+	if !m.todosVisible() {
+		return 0
+	}
+	return lipgloss.Height(m.renderTodoPanel(m.contentWidth()))
+}
+
+// renderTodoPanel renders the task list as a checklist above the input box,
+// scrolled to keep the current item in view
+func (m *model) renderTodoPanel(width int) string {
+	if !m.todosVisible() {
+		return ""
+	}
+
+	titleStyle := m.renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF6B35"))
+	doneStyle := m.renderer.NewStyle().Foreground(lipgloss.Color("#777777")).Strikethrough(!plainMode)
+	activeStyle := m.renderer.NewStyle().Bold(true)
+	moreStyle := m.renderer.NewStyle().Foreground(lipgloss.Color("#777777"))
+
+	done, current := 0, -1
+	for i, todo := range m.todos {
+		if todo.Status == tools.TodoCompleted {
+			done++
+		} else if current < 0 || todo.Status == tools.TodoInProgress && m.todos[current].Status != tools.TodoInProgress {
+			current = i
+		}
+	}
+
+	// Keep a finished item above the current one for context
+	start := 0
+	if len(m.todos) > maxTodoLines && current > 0 {
+		start = min(current-1, len(m.todos)-maxTodoLines)
+	}
+	end := min(start+maxTodoLines, len(m.todos))
+
+	lines := []string{titleStyle.Render(locale.T("todo.title", done, len(m.todos)))}
+	if start > 0 {
+		lines = append(lines, moreStyle.Render(locale.T("todo.more_above", start)))
+	}
+	for _, todo := range m.todos[start:end] {
+		var line string
+		switch todo.Status {
+		case tools.TodoCompleted:
+			line = doneStyle.Render(icon("✓", locale.T("todo.done")+" ") + todo.Content)
+		case tools.TodoInProgress:
+			line = activeStyle.Render(icon("▶", locale.T("todo.in_progress")+" ") + todo.Content)
+		default:
+			line = icon("○", locale.T("todo.pending")+" ") + todo.Content
+		}
+		lines = append(lines, m.renderer.NewStyle().MaxWidth(width).Render(line))
+	}
+	if end < len(m.todos) {
+		lines = append(lines, moreStyle.Render(locale.T("todo.more_below", len(m.todos)-end)))
+	}
+
+	return m.renderer.NewStyle().Width(width).PaddingLeft(1).Render(strings.Join(lines, "\n"))
+}
+
+// todoSummary describes the task list for the /todos command
+func (m *model) todoSummary() string {
+	if len(m.todos) == 0 {
+		return locale.T("todo.empty")
+	}
+	return fmt.Sprintf("%s\n%s", locale.T("todo.header"), tools.FormatTodos(m.todos))
+}
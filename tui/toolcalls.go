@@ -0,0 +1,104 @@
+package tui
+
+import (
+	"agent/agent"
+	"agent/locale"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxToolSummary caps the length of the input summary shown for a tool call
+const maxToolSummary = 80
+
+// startToolCall adds a running tool call to the chat, after the text streamed so far
+func (m *model) startToolCall(event agent.ToolCallStarted) {
+	m.flushStreamingMessage()
+	m.messages = append(m.messages, ChatMessage{
+		Content:  summarizeToolInput(event.Input),
+		IsTool:   true,
+		ToolID:   event.ID,
+		ToolName: event.Name,
+		Running:  true,
+	})
+}
+
+// finishToolCall records the outcome of a tool call on its chat entry
+func (m *model) finishToolCall(event agent.ToolResult) {
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		msg := &m.messages[i]
+		if !msg.IsTool || msg.ToolID != event.ID {
+			continue
+		}
+
+		msg.Running = false
+		msg.Duration = event.Duration
+		msg.Failed = event.IsError
+		if event.IsError {
+			msg.Detail = firstLine(event.Content)
+		}
+		return
+	}
+}
+
+// summarizeToolInput shows the path a tool works on, or its compacted input
+func summarizeToolInput(input json.RawMessage) string {
+	var fields map[string]any
+	if err := json.Unmarshal(input, &fields); err == nil {
+		if path, ok := fields["path"].(string); ok && path != "" {
+			return path
+		}
+	}
+
+	summary := strings.Join(strings.Fields(string(input)), " ")
+	if summary == "{}" {
+		return ""
+	}
+	if len([]rune(summary)) > maxToolSummary {
+		summary = string([]rune(summary)[:maxToolSummary-1]) + "…"
+	}
+	return summary
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
+
+// renderToolMessage renders a tool call entry: icon, tool name, input summary and outcome
+func renderToolMessage(renderer *lipgloss.Renderer, msg ChatMessage, width int) string {
+	nameStyle := renderer.NewStyle().Foreground(lipgloss.Color("#AAAAAA")).Bold(true)
+	detailStyle := renderer.NewStyle().Foreground(lipgloss.Color("#777777"))
+
+	status := renderer.NewStyle().Foreground(lipgloss.Color("#4CAF50")).Render(icon("✓", locale.T("tool.done")+" ") + formatDuration(msg.Duration))
+	switch {
+	case msg.Running:
+		status = detailStyle.Render(locale.T("tool.running"))
+	case msg.Failed:
+		status = renderer.NewStyle().Foreground(lipgloss.Color("#F44336")).Render(icon("✗", locale.T("tool.failed")+" ") + formatDuration(msg.Duration))
+	}
+
+	line := icon("🔧", locale.T("tool.label")+" ") + nameStyle.Render(msg.ToolName)
+	if msg.Content != "" {
+		line += " " + detailStyle.Render(msg.Content)
+	}
+	line += "  " + status
+
+	if msg.Failed && msg.Detail != "" {
+		line += "\n   " + renderer.NewStyle().Foreground(lipgloss.Color("#F44336")).Render(msg.Detail)
+	}
+
+	return renderer.NewStyle().Width(width).Render(line)
+}
+
+// formatDuration renders a tool duration compactly, e.g. "12ms" or "1.4s"
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
@@ -0,0 +1,81 @@
+package tui
+
+import (
+	"agent/locale"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// runTrashCommand lists the files the agent overwrote, restores one by its
+// number in the list, or empties the trash once confirmed
+func runTrashCommand(m *model, args string) tea.Cmd {
+	fields := strings.Fields(args)
+
+	items, err := m.agent.Workspace().TrashItems()
+	if err != nil {
+		m.addSystemMessage(locale.T("trash.failed", err))
+		return nil
+	}
+
+	switch {
+	case len(fields) == 0:
+		if len(items) == 0 {
+			m.addSystemMessage(locale.T("trash.empty"))
+			return nil
+		}
+
+		var b strings.Builder
+		b.WriteString(locale.T("trash.list") + "\n")
+		for i, item := range items {
+			b.WriteString(fmt.Sprintf("  %2d. %s  %s  (%d B)\n", i+1, item.Time.Format("2006-01-02 15:04:05"), item.Path, item.Size))
+		}
+		b.WriteString(locale.T("trash.hint"))
+		m.addSystemMessage(b.String())
+
+	case fields[0] == "restore" && len(fields) == 2:
+		if m.busy() {
+			m.addSystemMessage(locale.T("trash.busy"))
+			return nil
+		}
+
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 || n > len(items) {
+			m.addSystemMessage(locale.T("trash.unknown", fields[1]))
+			return nil
+		}
+
+		item := items[n-1]
+		if err := m.agent.RestoreTrash(item); err != nil {
+			m.addSystemMessage(locale.T("trash.failed", err))
+			return nil
+		}
+		m.addSystemMessage(locale.T("trash.restored", item.Path))
+
+		if m.showPreview {
+			m.preview.refresh(m.agent)
+		}
+
+	case fields[0] == "empty" && len(fields) == 1:
+		if len(items) == 0 {
+			m.addSystemMessage(locale.T("trash.empty"))
+			return nil
+		}
+		m.addSystemMessage(locale.T("trash.empty_confirm", len(items)))
+
+	case fields[0] == "empty" && len(fields) == 2 && fields[1] == "confirm":
+		deleted, err := m.agent.Workspace().EmptyTrash()
+		if err != nil {
+			m.addSystemMessage(locale.T("trash.failed", err))
+			return nil
+		}
+		m.addSystemMessage(locale.T("trash.emptied", deleted))
+
+	default:
+		m.addSystemMessage(locale.T("trash.usage"))
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"agent/config"
+	"agent/locale"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// applyWorkspace reports project config problems, watches the working directory
+// and applies its persisted trust decision, prompting when the directory is new
+func (m *model) applyWorkspace() {
+	if err := m.agent.ProjectConfigError(); err != nil {
+		m.addSystemMessage(locale.T("trust.project_config_ignored", err))
+	}
+
+	m.syncWatcher()
+	m.agent.Workspace().Prewarm()
+
+	switch config.LoadTrust(m.agent.WorkingDirectory()) {
+	case config.TrustGranted:
+		m.agent.SetTrusted(true)
+		m.trustPrompt = false
+	case config.TrustRestricted:
+		m.agent.SetTrusted(false)
+		m.trustPrompt = false
+	default:
+		m.agent.SetTrusted(false)
+		m.trustPrompt = true
+	}
+}
+
+// setTrust records and persists the user's trust decision for the working directory
+func (m *model) setTrust(decision config.TrustDecision) {
+	m.agent.SetTrusted(decision == config.TrustGranted)
+	m.trustPrompt = false
+
+	if err := config.SaveTrust(m.agent.WorkingDirectory(), decision); err != nil {
+		m.addSystemMessage(locale.T("trust.save_failed", err))
+		return
+	}
+
+	if decision == config.TrustGranted {
+		m.addSystemMessage(locale.T("trust.granted"))
+	} else {
+		m.addSystemMessage(locale.T("trust.restricted"))
+	}
+}
+
+// updateTrustPrompt handles key presses while the trust prompt is shown
+func (m *model) updateTrustPrompt(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c", "esc":
+		return tea.Quit
+	case "y", "Y":
+		m.setTrust(config.TrustGranted)
+	case "n", "N":
+		m.setTrust(config.TrustRestricted)
+	default:
+		return nil
+	}
+
+	m.scrollToLatest()
+	return nil
+}
+
+func (m *model) renderTrustPrompt(width int) string {
+	return renderPromptBox(
+		m.renderer,
+		width,
+		locale.T("trust.title"),
+		m.agent.WorkingDirectory()+"\n\n"+locale.T("trust.body"),
+		locale.T("trust.hint"),
+	)
+}
@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"context"
+	"time"
+
+	"agent/config"
+	"agent/update"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// updateCheckTimeout bounds the background look for a new release
+const updateCheckTimeout = 10 * time.Second
+
+// availableUpdate is the newer release announced in the status bar. It is
+// shared by every tab and only touched in Update and View.
+var availableUpdate string
+
+// updateAvailableMsg reports a release newer than the running binary
+type updateAvailableMsg struct {
+	version string
+}
+
+// checkForUpdate looks for a newer release in the background, unless
+// update_check is off in the settings
+func checkForUpdate() tea.Cmd {
+	return func() tea.Msg {
+		if settings, _ := config.LoadSettings(); !settings.CheckForUpdates() {
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+		defer cancel()
+
+		version, ok := update.Available(ctx)
+		if !ok {
+			return nil
+		}
+		return updateAvailableMsg{version: version}
+	}
+}
@@ -0,0 +1,83 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"agent/locale"
+	"agent/watcher"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxListedChanges caps how many changed files are named in a notice
+const maxListedChanges = 10
+
+// fileChangesMsg carries a batch of paths the watcher saw change
+type fileChangesMsg struct {
+	paths []string
+}
+
+// syncWatcher starts the file watcher, or moves it to the current working directory
+func (m *model) syncWatcher() {
+	root := m.agent.WorkingDirectory()
+
+	if m.watcher == nil {
+		w, err := watcher.New(root)
+		if err != nil {
+			m.addSystemMessage(locale.T("watch.failed", err))
+			return
+		}
+		m.watcher = w
+		return
+	}
+
+	if m.watcher.Root() != root {
+		if err := m.watcher.SetRoot(root); err != nil {
+			m.addSystemMessage(locale.T("watch.failed", err))
+		}
+	}
+}
+
+// waitForFileChanges waits for the next batch of changes from the watcher
+func waitForFileChanges(w *watcher.Watcher) tea.Cmd {
+	if w == nil {
+		return nil
+	}
+
+	changes := w.Changes()
+	return func() tea.Msg {
+		paths, ok := <-changes
+		if !ok {
+			return nil
+		}
+		return fileChangesMsg{paths: paths}
+	}
+}
+
+// handleFileChanges tells the user and the model about files changed outside the agent
+func (m *model) handleFileChanges(paths []string) tea.Cmd {
+	changed := m.agent.ExternalChanges(paths)
+	if len(changed) == 0 {
+		return waitForFileChanges(m.watcher)
+	}
+
+	listed := changed
+	if len(listed) > maxListedChanges {
+		listed = listed[:maxListedChanges]
+	}
+	list := strings.Join(listed, ", ")
+	if extra := len(changed) - len(listed); extra > 0 {
+		list += locale.T("watch.more", extra)
+	}
+
+	m.addSystemMessage(locale.T("watch.changed", list))
+	m.agent.AddNote(fmt.Sprintf("These files were changed outside this session (e.g. by the user in an editor) since you last saw them: %s. Re-read them before relying on earlier contents.", list))
+
+	if m.showPreview {
+		m.preview.refresh(m.agent)
+	}
+	m.followOutput()
+
+	return waitForFileChanges(m.watcher)
+}
@@ -0,0 +1,202 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PublicKey is the base64 Ed25519 key release checksums are signed with, set
+// at build time with -ldflags "-X agent/update.PublicKey=...". Builds without
+// it can check for updates but not install them, since a checksum from the
+// same release proves nothing about who published it.
+var PublicKey = ""
+
+// CanInstall reports whether this build has a release key to verify updates with
+func CanInstall() bool {
+	return PublicKey != ""
+}
+
+const (
+	checksumsAsset = "checksums.txt"
+	signatureAsset = "checksums.txt.sig"
+
+	// maxBinarySize bounds a download, so a bad release can't fill the disk
+	maxBinarySize = 512 << 20
+	// maxChecksumsSize bounds the checksum and signature files
+	maxChecksumsSize = 1 << 20
+)
+
+// Install downloads the release's binary for this platform, verifies it
+// against the release's signed checksums, and replaces the running binary
+// with it. It returns the binary's path.
+func Install(ctx context.Context, release *Release) (string, error) {
+	if !CanInstall() {
+		return "", fmt.Errorf("no release key is built in to verify the update with")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return "", fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+
+	name := AssetName()
+	binary, ok := release.asset(name)
+	if !ok {
+		return "", fmt.Errorf("release %s has no binary for %s/%s (expected %s)", release.Version, runtime.GOOS, runtime.GOARCH, name)
+	}
+
+	expected, err := checksum(ctx, release, name)
+	if err != nil {
+		return "", err
+	}
+
+	// Download next to the binary, so the swap is a rename on the same file system
+	file, err := os.CreateTemp(filepath.Dir(executable), ".cli-agent-update-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write the update: %w", err)
+	}
+	downloaded := file.Name()
+	defer os.Remove(downloaded)
+
+	hash := sha256.New()
+	err = download(ctx, binary.URL, maxBinarySize, io.MultiWriter(file, hash))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", name, err)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+	}
+
+	if err := os.Chmod(downloaded, 0755); err != nil {
+		return "", fmt.Errorf("failed to make the update executable: %w", err)
+	}
+	if err := swap(downloaded, executable); err != nil {
+		return "", fmt.Errorf("failed to replace %s: %w", executable, err)
+	}
+
+	return executable, nil
+}
+
+// checksum returns the SHA-256 the release lists for the named file, after
+// checking the list's signature
+func checksum(ctx context.Context, release *Release, name string) (string, error) {
+	asset, ok := release.asset(checksumsAsset)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s to verify the download with", release.Version, checksumsAsset)
+	}
+
+	var checksums bytes.Buffer
+	if err := download(ctx, asset.URL, maxChecksumsSize, &checksums); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", checksumsAsset, err)
+	}
+
+	if err := verifySignature(ctx, release, checksums.Bytes()); err != nil {
+		return "", err
+	}
+
+	// Lines are in sha256sum's format: "<hex>  <name>", with a '*' before
+	// the name for binary mode
+	scanner := bufio.NewScanner(&checksums)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", fmt.Errorf("%s of release %s doesn't list %s", checksumsAsset, release.Version, name)
+}
+
+// verifySignature checks the release's Ed25519 signature of its checksums
+func verifySignature(ctx context.Context, release *Release, checksums []byte) error {
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("the built-in release key is invalid")
+	}
+
+	asset, ok := release.asset(signatureAsset)
+	if !ok {
+		return fmt.Errorf("release %s isn't signed (no %s)", release.Version, signatureAsset)
+	}
+
+	var encoded bytes.Buffer
+	if err := download(ctx, asset.URL, maxChecksumsSize, &encoded); err != nil {
+		return fmt.Errorf("failed to download %s: %w", signatureAsset, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded.String()))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", signatureAsset, err)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, signature) {
+		return fmt.Errorf("the signature of release %s doesn't match; not installing it", release.Version)
+	}
+	return nil
+}
+
+// download writes the file at url to w, failing if it is larger than limit
+func download(ctx context.Context, url string, limit int64, w io.Writer) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("User-Agent", "cli-agent/"+Version)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("server answered %s", response.Status)
+	}
+
+	n, err := io.Copy(w, io.LimitReader(response.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return fmt.Errorf("file is larger than %d bytes", limit)
+	}
+	return nil
+}
+
+// swap moves the new binary over the old one. Windows can't replace a
+// running executable, but it can rename it, so the old binary is moved
+// aside to <name>.old there; the next update removes it.
+func swap(replacement, executable string) error {
+	if runtime.GOOS != "windows" {
+		return os.Rename(replacement, executable)
+	}
+
+	old := executable + ".old"
+	os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		return err
+	}
+	if err := os.Rename(replacement, executable); err != nil {
+		os.Rename(old, executable)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package update finds newer releases of cli-agent on GitHub and replaces the
+// running binary with them
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"agent/config"
+)
+
+// Version is the release this binary was built from, set at build time with
+// -ldflags "-X agent/update.Version=v1.2.3". Development builds are "dev".
+var Version = "dev"
+
+// releasesURL is the GitHub API endpoint for the latest release
+const releasesURL = "https://api.github.com/repos/shtayeb/cli-agent/releases/latest"
+
+// checkInterval is how often Available asks GitHub for a new release
+const checkInterval = 24 * time.Hour
+
+// Release is a published GitHub release
+type Release struct {
+	Version string  `json:"tag_name"`
+	URL     string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// asset returns the release's file with the given name
+func (r *Release) asset(name string) (Asset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+var client = &http.Client{Timeout: 5 * time.Minute}
+
+// Latest fetches the newest published release
+func Latest(ctx context.Context) (*Release, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, releasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("User-Agent", "cli-agent/"+Version)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for releases: GitHub answered %s", response.Status)
+	}
+
+	release := &Release{}
+	if err := json.NewDecoder(response.Body).Decode(release); err != nil {
+		return nil, fmt.Errorf("failed to read the release: %w", err)
+	}
+
+	return release, nil
+}
+
+// Newer reports whether version is a later release than current. Versions
+// are compared as vMAJOR.MINOR.PATCH; anything else, such as a development
+// build, is never older or newer than a release.
+func Newer(version, current string) bool {
+	a, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+	b, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// parseVersion reads vMAJOR.MINOR.PATCH, ignoring a pre-release or build suffix
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	fields := strings.Split(version, ".")
+	if len(fields) != len(parts) {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+
+	return parts, true
+}
+
+// Released reports whether this binary is a release build, which can be
+// compared with and replaced by newer releases
+func Released() bool {
+	_, ok := parseVersion(Version)
+	return ok
+}
+
+// AssetName is the release file holding the binary for this platform,
+// e.g. cli-agent_linux_amd64 or cli-agent_windows_amd64.exe
+func AssetName() string {
+	name := fmt.Sprintf("cli-agent_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// checkState remembers the last check, so the chat asks GitHub at most once a day
+type checkState struct {
+	Checked time.Time `json:"checked"`
+	Latest  string    `json:"latest,omitempty"`
+}
+
+func statePath() (string, error) {
+	dir, err := config.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "update-check.json"), nil
+}
+
+func readState() checkState {
+	state := checkState{}
+
+	path, err := statePath()
+	if err != nil {
+		return state
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func writeState(state checkState) {
+	path, err := statePath()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	os.WriteFile(path, data, 0600)
+}
+
+// Available returns a newer release's version, if there is one. GitHub is
+// asked at most once a day; in between, the last answer is reused.
+// Development builds never have an update available.
+func Available(ctx context.Context) (string, bool) {
+	if !Released() {
+		return "", false
+	}
+
+	state := readState()
+	if time.Since(state.Checked) >= checkInterval {
+		// A failed check waits for the next interval too, so being offline
+		// doesn't mean asking on every start
+		state.Checked = time.Now()
+		if release, err := Latest(ctx); err == nil {
+			state.Latest = release.Version
+		}
+		writeState(state)
+	}
+
+	if !Newer(state.Latest, Version) {
+		return "", false
+	}
+	return state.Latest, true
+}
@@ -0,0 +1,202 @@
+// Package watcher reports file changes in a workspace while a session runs
+package watcher
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"agent/tools"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// debounce groups bursts of events, such as an editor's save, into one batch
+const debounce = 300 * time.Millisecond
+
+// maxWatchedDirs keeps huge trees from exhausting the OS watch limit
+const maxWatchedDirs = 4096
+
+// Watcher watches a directory tree and delivers batches of changed paths,
+// relative to the root
+type Watcher struct {
+	fs      *fsnotify.Watcher
+	changes chan []string
+	done    chan struct{}
+
+	mu      sync.Mutex
+	root    string
+	watched map[string]bool
+}
+
+// New starts watching the tree under root
+func New(root string) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		fs:      fsWatcher,
+		changes: make(chan []string, 16),
+		done:    make(chan struct{}),
+		watched: map[string]bool{},
+	}
+
+	if err := w.SetRoot(root); err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Changes delivers batches of changed paths. It is closed when the watcher is closed.
+func (w *Watcher) Changes() <-chan []string {
+	return w.changes
+}
+
+// Root returns the watched directory
+func (w *Watcher) Root() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.root
+}
+
+// SetRoot moves the watcher to another directory tree
+func (w *Watcher) SetRoot(root string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for dir := range w.watched {
+		w.fs.Remove(dir)
+	}
+	w.watched = map[string]bool{}
+	w.root = root
+
+	return w.addTree(root)
+}
+
+// Close stops the watcher
+func (w *Watcher) Close() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+
+	close(w.done)
+	return w.fs.Close()
+}
+
+// addTree watches dir and its subdirectories. Callers must hold the lock.
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are skipped rather than failing the whole watch
+			if path == dir {
+				return err
+			}
+			return filepath.SkipDir
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dir && tools.DefaultIgnoredDir(entry.Name()) {
+			return filepath.SkipDir
+		}
+		if len(w.watched) >= maxWatchedDirs {
+			return filepath.SkipAll
+		}
+
+		if err := w.fs.Add(path); err != nil {
+			return filepath.SkipDir
+		}
+		w.watched[path] = true
+
+		return nil
+	})
+}
+
+// run collects events and emits them in debounced batches
+func (w *Watcher) run() {
+	defer close(w.changes)
+
+	pending := map[string]bool{}
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case <-w.done:
+			return
+
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Write) {
+				continue
+			}
+
+			rel, ok := w.relative(event.Name)
+			if !ok {
+				continue
+			}
+
+			// Start watching directories created during the session
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if tools.DefaultIgnoredDir(filepath.Base(event.Name)) {
+						continue
+					}
+					w.mu.Lock()
+					w.addTree(event.Name)
+					w.mu.Unlock()
+				}
+			}
+
+			pending[rel] = true
+			if timer == nil {
+				timer = time.After(debounce)
+			}
+
+		case <-timer:
+			timer = nil
+
+			batch := make([]string, 0, len(pending))
+			for path := range pending {
+				batch = append(batch, path)
+			}
+			sort.Strings(batch)
+			pending = map[string]bool{}
+
+			select {
+			case w.changes <- batch:
+			case <-w.done:
+				return
+			}
+
+		case _, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// relative returns path relative to the root, or false when it lies outside it
+func (w *Watcher) relative(path string) (string, bool) {
+	root := w.Root()
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || filepath.IsAbs(rel) || len(rel) > 2 && rel[:3] == ".."+string(filepath.Separator) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}